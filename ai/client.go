@@ -2,6 +2,7 @@ package ai
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -85,8 +86,23 @@ Respond with a JSON object containing:
 	// Parse the response
 	content := resp.Choices[0].Message.Content
 
-	// For now, we'll do simple parsing. In production, we'd parse the JSON response properly
-	// This is a placeholder implementation for the MVP
+	// The system prompt asks for a JSON envelope; fall back to the raw reply if
+	// the model ignored it
+	var envelope struct {
+		ProcessedContent string `json:"processed_content"`
+		Changelog        string `json:"changelog"`
+	}
+	if err := json.Unmarshal([]byte(content), &envelope); err == nil && envelope.ProcessedContent != "" {
+		if envelope.Changelog == "" {
+			envelope.Changelog = "AI-generated modifications applied"
+		}
+		return &ProcessModResponse{
+			ProcessedContent: envelope.ProcessedContent,
+			Changelog:        envelope.Changelog,
+			TokensUsed:       resp.Usage.TotalTokens,
+		}, nil
+	}
+
 	return &ProcessModResponse{
 		ProcessedContent: content,
 		Changelog:        "AI-generated modifications applied",
@@ -109,38 +125,3 @@ func (c *Client) buildPrompt(template, content string, variables map[string]stri
 
 	return prompt
 }
-
-// ValidateModContent performs basic validation on mod content
-func ValidateModContent(content string, gameType string) error {
-	switch gameType {
-	case "minecraft":
-		return validateMinecraftJSON(content)
-	case "skyrim":
-		return validateSkyrimESP(content)
-	case "lua":
-		return validateLuaScript(content)
-	default:
-		return fmt.Errorf("unsupported game type: %s", gameType)
-	}
-}
-
-// validateMinecraftJSON validates Minecraft JSON mod content
-func validateMinecraftJSON(content string) error {
-	// Basic JSON validation - in production we'd use proper JSON parsing
-	if !strings.Contains(content, "{") || !strings.Contains(content, "}") {
-		return fmt.Errorf("invalid JSON structure")
-	}
-	return nil
-}
-
-// validateSkyrimESP validates Skyrim ESP content (placeholder)
-func validateSkyrimESP(content string) error {
-	// Placeholder validation for Skyrim ESP files
-	return nil
-}
-
-// validateLuaScript validates Lua script content (placeholder)
-func validateLuaScript(content string) error {
-	// Placeholder validation for Lua scripts
-	return nil
-}
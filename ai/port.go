@@ -0,0 +1,10 @@
+package ai
+
+// PortPackPrompt asks the AI to update one data pack file for a newer
+// Minecraft version. Variables: path, target_version, pack_format.
+const PortPackPrompt = `Port this Minecraft data pack file ({path}) to Minecraft {target_version} (data pack format {pack_format}).
+Update renamed registry IDs, component syntax and changed JSON fields so the file loads in {target_version}.
+Keep the file's behaviour identical and return the file unchanged if nothing needs porting.
+Only change the file's contents: renamed folders such as recipes/ to recipe/ are moved for you.
+
+{content}`
@@ -0,0 +1,33 @@
+package ai
+
+// TranslateLangPrompt asks the AI to translate one batch of Minecraft
+// language strings. Variables: locale.
+const TranslateLangPrompt = `Translate the values of this JSON object of Minecraft language strings from English (en_us) into the Minecraft locale {locale}.
+Keep every key exactly as it is. Keep format arguments such as %s, %d and %1$s, and § formatting codes such as §a and §r, in each value.
+Return the translated JSON object as the processed content.
+
+{content}`
+
+// TranslateFactorioLocalePrompt asks the AI to translate one batch of Factorio
+// locale strings. Variables: locale.
+const TranslateFactorioLocalePrompt = `Translate the values of this JSON object of Factorio locale strings from English (en) into the Factorio locale {locale}.
+Keep every key exactly as it is. Keep parameters such as __1__ and __ITEM__iron-plate__, and rich text tags such as [item=iron-plate] and [color=red], in each value. Keep each value on one line.
+Return the translated JSON object as the processed content.
+
+{content}`
+
+// TranslateI18nPrompt asks the AI to translate one batch of SMAPI i18n
+// strings for Stardew Valley. Variables: locale.
+const TranslateI18nPrompt = `Translate the values of this JSON object of Stardew Valley mod strings from English into the SMAPI locale {locale}.
+Keep every key exactly as it is. Keep tokens such as {{name}} and {{i18n:key}}, and dialogue commands such as $h and #$b#, in each value.
+Return the translated JSON object as the processed content.
+
+{content}`
+
+// TranslateBukkitMessagesPrompt asks the AI to translate one batch of
+// player-facing messages from a Bukkit plugin. Variables: locale.
+const TranslateBukkitMessagesPrompt = `Translate the values of this JSON object of Minecraft server plugin messages from English into the Minecraft locale {locale}.
+Keep every key exactly as it is. Keep color codes such as &a, §c and &#ff0000, MiniMessage tags such as <red> and <click:run_command:/spawn>, and placeholders such as %player%, {amount}, {0} and %1$s, in each value.
+Return the translated JSON object as the processed content.
+
+{content}`
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 // Config holds all configuration for the application
@@ -14,7 +15,9 @@ type Config struct {
 	CloudflareR2   CloudflareR2Config
 	VirusTotalKey  string
 	AllowedOrigins string
+	AdminEmails    string
 	RateLimit      RateLimitConfig
+	MockAI         bool // stand in for the AI when there is no OpenAI quota
 }
 
 // CloudflareR2Config holds Cloudflare R2 storage configuration
@@ -46,10 +49,12 @@ func Load() *Config {
 		},
 		VirusTotalKey:  getEnv("VIRUSTOTAL_API_KEY", ""),
 		AllowedOrigins: getEnv("ALLOWED_ORIGINS", "http://localhost:3000,http://localhost:5173"),
+		AdminEmails:    getEnv("ADMIN_EMAILS", ""),
 		RateLimit: RateLimitConfig{
 			RequestsPerMinute: getEnvAsInt("RATE_LIMIT_RPM", 5),
 			FreeMonthlyJobs:   getEnvAsInt("FREE_MONTHLY_JOBS", 3),
 		},
+		MockAI: getEnvAsBool("MOCK_AI", false),
 	}
 }
 
@@ -70,3 +75,23 @@ func getEnvAsInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+// getEnvAsBool gets an environment variable as a boolean with a fallback value
+func getEnvAsBool(key string, fallback bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return fallback
+}
+
+// IsAdmin reports whether an email is in the comma-separated ADMIN_EMAILS list
+func (c *Config) IsAdmin(email string) bool {
+	for _, admin := range strings.Split(c.AdminEmails, ",") {
+		if admin = strings.TrimSpace(admin); admin != "" && strings.EqualFold(admin, email) {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -48,6 +50,35 @@ func Initialize(databaseURL string) (*DB, error) {
 	return &DB{sqlDB}, nil
 }
 
+// postgresSeedMigrations are migration files re-applied to existing PostgreSQL
+// databases on startup, which skip the standard migration process. Each must be
+// safe to run repeatedly.
+var postgresSeedMigrations = []string{
+	"005_bethesda_game_presets.up.sql",
+	"010_lua_ecosystem_presets.up.sql",
+	"011_stardew_presets.up.sql",
+	"012_bedrock_presets.up.sql",
+	"013_structure_presets.up.sql",
+	"014_bukkit_presets.up.sql",
+	"016_rule_presets.up.sql",
+}
+
+// postgresColumnUpdates add columns introduced after the auth migration to
+// existing PostgreSQL databases
+var postgresColumnUpdates = []string{
+	`ALTER TABLE mod_jobs ADD COLUMN IF NOT EXISTS scan_findings TEXT`,
+	`ALTER TABLE mod_jobs ADD COLUMN IF NOT EXISTS sha1 TEXT`,
+	`ALTER TABLE mod_jobs ADD COLUMN IF NOT EXISTS sha512 TEXT`,
+	`ALTER TABLE mod_jobs ADD COLUMN IF NOT EXISTS curseforge_fingerprint BIGINT`,
+	`CREATE INDEX IF NOT EXISTS idx_mod_jobs_sha512 ON mod_jobs(sha512)`,
+	`ALTER TABLE mod_jobs ADD COLUMN IF NOT EXISTS change_selections TEXT`,
+	`ALTER TABLE mod_jobs ADD COLUMN IF NOT EXISTS reviewed_file_url TEXT`,
+	`ALTER TABLE mod_jobs ADD COLUMN IF NOT EXISTS ecosystem TEXT`,
+	`ALTER TABLE mod_jobs ADD COLUMN IF NOT EXISTS ecosystem_metadata TEXT`,
+	`ALTER TABLE mod_presets ADD COLUMN IF NOT EXISTS ecosystem TEXT`,
+	`ALTER TABLE mod_presets ADD COLUMN IF NOT EXISTS rules TEXT`,
+}
+
 // RunMigrations runs database migrations
 func RunMigrations(databaseURL string) error {
 	// For production with existing database, apply manual schema updates
@@ -124,6 +155,24 @@ func RunMigrations(databaseURL string) error {
 				log.Printf("Warning: Failed to create token index: %v", err)
 			}
 
+			for _, statement := range postgresColumnUpdates {
+				if _, err := db.Exec(statement); err != nil {
+					log.Printf("Warning: Failed to apply %q: %v", statement, err)
+				}
+			}
+
+			// Apply seed data added after the auth migration
+			for _, name := range postgresSeedMigrations {
+				script, err := os.ReadFile(filepath.Join("migrations", name))
+				if err != nil {
+					log.Printf("Warning: Failed to read %s: %v", name, err)
+					continue
+				}
+				if _, err := db.Exec(string(script)); err != nil {
+					log.Printf("Warning: Failed to apply %s: %v", name, err)
+				}
+			}
+
 			log.Println("Schema updates completed successfully")
 			return nil
 		}
@@ -329,17 +378,47 @@ func (db *DB) GetUserByEmail(email string) (*models.User, error) {
 	return user, nil
 }
 
+// jobColumns are the mod_jobs columns read into a models.Job, in scanJob's order
+const jobColumns = `id, user_id, status, game_type, original_filename, original_file_size,
+		       original_file_url, processed_file_url, preset_type, ai_prompt,
+		       ai_response, changelog, tokens_used, credits_used, error_message,
+		       scan_findings, sha1, sha512, curseforge_fingerprint,
+		       change_selections, reviewed_file_url, ecosystem, ecosystem_metadata,
+		       created_at, updated_at`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanJob reads a row selected with jobColumns
+func scanJob(row rowScanner) (*models.Job, error) {
+	job := &models.Job{}
+	err := row.Scan(
+		&job.ID, &job.UserID, &job.Status, &job.ModType,
+		&job.OriginalFilename, &job.OriginalFileSize, &job.OriginalURL,
+		&job.ProcessedURL, &job.PresetType, &job.AIPrompt,
+		&job.AIResponse, &job.Changelog, &job.TokensUsed,
+		&job.CreditsUsed, &job.ErrorMessage, &job.ScanFindings,
+		&job.SHA1, &job.SHA512, &job.CurseForgeFingerprint,
+		&job.ChangeSelections, &job.ReviewedURL, &job.Ecosystem, &job.EcosystemMetadata,
+		&job.CreatedAt, &job.UpdatedAt,
+	)
+	return job, err
+}
+
 // CreateJob creates a new job record
 func (db *DB) CreateJob(job *models.Job) error {
 	query := `
-		INSERT INTO mod_jobs (id, user_id, status, game_type, original_filename, original_file_size, original_file_url, preset_type, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO mod_jobs (id, user_id, status, game_type, original_filename, original_file_size, original_file_url, preset_type, scan_findings, sha1, sha512, curseforge_fingerprint, ecosystem, ecosystem_metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 	`
 
 	_, err := db.Exec(query,
 		job.ID, job.UserID, job.Status, job.ModType,
 		job.OriginalFilename, job.OriginalFileSize, job.OriginalURL, job.PresetType,
-		job.CreatedAt, job.UpdatedAt,
+		job.ScanFindings, job.SHA1, job.SHA512, job.CurseForgeFingerprint,
+		job.Ecosystem, job.EcosystemMetadata, job.CreatedAt, job.UpdatedAt,
 	)
 
 	if err != nil {
@@ -352,21 +431,11 @@ func (db *DB) CreateJob(job *models.Job) error {
 // GetJobByID retrieves a job by ID
 func (db *DB) GetJobByID(id string) (*models.Job, error) {
 	query := `
-		SELECT id, user_id, status, game_type, original_filename, original_file_size,
-		       original_file_url, processed_file_url, preset_type, ai_prompt,
-		       ai_response, changelog, tokens_used, credits_used, error_message,
-		       created_at, updated_at
+		SELECT ` + jobColumns + `
 		FROM mod_jobs WHERE id = $1
 	`
 
-	job := &models.Job{}
-	err := db.QueryRow(query, id).Scan(
-		&job.ID, &job.UserID, &job.Status, &job.ModType,
-		&job.OriginalFilename, &job.OriginalFileSize, &job.OriginalURL,
-		&job.ProcessedURL, &job.PresetType, &job.AIPrompt,
-		&job.AIResponse, &job.Changelog, &job.TokensUsed,
-		&job.CreditsUsed, &job.ErrorMessage, &job.CreatedAt, &job.UpdatedAt,
-	)
+	job, err := scanJob(db.QueryRow(query, id))
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -384,8 +453,9 @@ func (db *DB) UpdateJob(job *models.Job) error {
 		UPDATE mod_jobs SET 
 			status = $1, processed_file_url = $2, preset_type = $3, ai_prompt = $4,
 			ai_response = $5, changelog = $6, tokens_used = $7, credits_used = $8,
-			error_message = $9, updated_at = $10
-		WHERE id = $11
+			error_message = $9, scan_findings = $10, change_selections = $11,
+			reviewed_file_url = $12, updated_at = $13
+		WHERE id = $14
 	`
 
 	job.UpdatedAt = time.Now()
@@ -393,7 +463,8 @@ func (db *DB) UpdateJob(job *models.Job) error {
 	_, err := db.Exec(query,
 		job.Status, job.ProcessedURL, job.PresetType, job.AIPrompt,
 		job.AIResponse, job.Changelog, job.TokensUsed, job.CreditsUsed,
-		job.ErrorMessage, job.UpdatedAt, job.ID,
+		job.ErrorMessage, job.ScanFindings, job.ChangeSelections,
+		job.ReviewedURL, job.UpdatedAt, job.ID,
 	)
 
 	if err != nil {
@@ -455,15 +526,34 @@ func (db *DB) DeleteSession(token string) error {
 	return nil
 }
 
+// GetScannedJobBySHA512 retrieves the most recent job for a file with the
+// given SHA-512 whose malware scan passed or quarantined it, or nil when
+// there is none. Failed jobs are skipped, since their scan may not have run.
+func (db *DB) GetScannedJobBySHA512(hash string) (*models.Job, error) {
+	query := `
+		SELECT ` + jobColumns + `
+		FROM mod_jobs WHERE sha512 = $1 AND status IN ($2, $3, $4)
+		ORDER BY created_at DESC LIMIT 1
+	`
+
+	job, err := scanJob(db.QueryRow(query, hash, models.JobStatusPending, models.JobStatusCompleted, models.JobStatusQuarantined))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+
+	return job, nil
+}
+
 // GetUserJobs retrieves jobs for a user with pagination
 func (db *DB) GetUserJobs(userID string, page, limit int, status string) ([]*models.Job, error) {
 	offset := (page - 1) * limit
 
 	query := `
-		SELECT id, user_id, status, game_type, original_filename, original_file_size,
-		       original_file_url, processed_file_url, preset_type, ai_prompt,
-		       ai_response, changelog, tokens_used, credits_used, error_message,
-		       created_at, updated_at
+		SELECT ` + jobColumns + `
 		FROM mod_jobs 
 		WHERE user_id = $1
 	`
@@ -485,14 +575,7 @@ func (db *DB) GetUserJobs(userID string, page, limit int, status string) ([]*mod
 
 	var jobs []*models.Job
 	for rows.Next() {
-		job := &models.Job{}
-		err := rows.Scan(
-			&job.ID, &job.UserID, &job.Status, &job.ModType,
-			&job.OriginalFilename, &job.OriginalFileSize, &job.OriginalURL,
-			&job.ProcessedURL, &job.PresetType, &job.AIPrompt,
-			&job.AIResponse, &job.Changelog, &job.TokensUsed,
-			&job.CreditsUsed, &job.ErrorMessage, &job.CreatedAt, &job.UpdatedAt,
-		)
+		job, err := scanJob(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan job: %w", err)
 		}
@@ -502,45 +585,96 @@ func (db *DB) GetUserJobs(userID string, page, limit int, status string) ([]*mod
 	return jobs, nil
 }
 
-// GetPresets retrieves all active presets
-func (db *DB) GetPresets() ([]*models.ModPreset, error) {
+// GetJobsByStatus retrieves jobs of every user in a status, oldest first
+func (db *DB) GetJobsByStatus(status string, page, limit int) ([]*models.Job, error) {
+	offset := (page - 1) * limit
+
 	query := `
-		SELECT id, name, description, game_type, prompt_template, credit_cost, is_active, created_at
-		FROM mod_presets WHERE is_active = true
-		ORDER BY name
+		SELECT ` + jobColumns + `
+		FROM mod_jobs 
+		WHERE status = $1
+		ORDER BY created_at ASC LIMIT $2 OFFSET $3
 	`
 
-	rows, err := db.Query(query)
+	rows, err := db.Query(query, status, limit, offset)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query presets: %w", err)
+		return nil, fmt.Errorf("failed to query jobs: %w", err)
 	}
 	defer rows.Close()
 
-	var presets []*models.ModPreset
+	var jobs []*models.Job
 	for rows.Next() {
-		preset := &models.ModPreset{}
-		err := rows.Scan(
-			&preset.ID, &preset.Name, &preset.Description, &preset.GameType,
-			&preset.PromptTemplate, &preset.CreditCost, &preset.IsActive, &preset.CreatedAt,
-		)
+		job, err := scanJob(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan preset: %w", err)
+			return nil, fmt.Errorf("failed to scan job: %w", err)
 		}
-		presets = append(presets, preset)
+		jobs = append(jobs, job)
 	}
 
-	return presets, nil
+	return jobs, nil
 }
 
-// GetPresetsByType retrieves presets filtered by game type
-func (db *DB) GetPresetsByType(gameType string) ([]*models.ModPreset, error) {
-	query := `
-		SELECT id, name, description, game_type, prompt_template, credit_cost, is_active, created_at
+// DeleteJob deletes a job
+func (db *DB) DeleteJob(id string) error {
+	query := `DELETE FROM mod_jobs WHERE id = $1`
+
+	_, err := db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete job: %w", err)
+	}
+
+	return nil
+}
+
+// GetPresets retrieves all active presets
+func (db *DB) GetPresets() ([]*models.ModPreset, error) {
+	return db.queryPresets(`
+		SELECT ` + presetColumns + `
+		FROM mod_presets WHERE is_active = true
+		ORDER BY name
+	`)
+}
+
+// GetPresetsByType retrieves presets filtered by game type and, when given, by
+// Lua ecosystem. Presets without an ecosystem match every ecosystem.
+func (db *DB) GetPresetsByType(gameType, ecosystem string) ([]*models.ModPreset, error) {
+	if ecosystem == "" {
+		return db.queryPresets(`
+			SELECT `+presetColumns+`
+			FROM mod_presets WHERE game_type = $1 AND is_active = true
+			ORDER BY name
+		`, gameType)
+	}
+
+	return db.queryPresets(`
+		SELECT `+presetColumns+`
 		FROM mod_presets WHERE game_type = $1 AND is_active = true
+		  AND (ecosystem IS NULL OR ecosystem = $2)
 		ORDER BY name
-	`
+	`, gameType, ecosystem)
+}
+
+// GetPresetByID retrieves an active preset
+func (db *DB) GetPresetByID(id string) (*models.ModPreset, error) {
+	presets, err := db.queryPresets(`
+		SELECT `+presetColumns+`
+		FROM mod_presets WHERE id = $1 AND is_active = true
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(presets) == 0 {
+		return nil, fmt.Errorf("preset not found")
+	}
+	return presets[0], nil
+}
+
+// presetColumns are the mod_presets columns read by queryPresets, in scan order
+const presetColumns = `id, name, description, game_type, prompt_template, credit_cost, is_active, ecosystem, rules, created_at`
 
-	rows, err := db.Query(query, gameType)
+// queryPresets runs a query selecting presetColumns
+func (db *DB) queryPresets(query string, args ...interface{}) ([]*models.ModPreset, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query presets: %w", err)
 	}
@@ -551,7 +685,8 @@ func (db *DB) GetPresetsByType(gameType string) ([]*models.ModPreset, error) {
 		preset := &models.ModPreset{}
 		err := rows.Scan(
 			&preset.ID, &preset.Name, &preset.Description, &preset.GameType,
-			&preset.PromptTemplate, &preset.CreditCost, &preset.IsActive, &preset.CreatedAt,
+			&preset.PromptTemplate, &preset.CreditCost, &preset.IsActive,
+			&preset.Ecosystem, &preset.Rules, &preset.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan preset: %w", err)
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"modforge.ai/api/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireAdmin allows only users listed in ADMIN_EMAILS; it runs after VerifyToken
+func (h *Handlers) RequireAdmin(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Authentication required"})
+	}
+	if !h.cfg.IsAdmin(user.Email) {
+		return c.Status(403).JSON(fiber.Map{"error": "Admin access required"})
+	}
+	return c.Next()
+}
+
+// GetQuarantinedJobs lists uploads held by the malware scan, oldest first
+func (h *Handlers) GetQuarantinedJobs(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	page, limit = max(page, 1), min(max(limit, 1), 100)
+
+	jobs, err := h.db.GetJobsByStatus(models.JobStatusQuarantined, page, limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch quarantined jobs"})
+	}
+
+	return c.JSON(fiber.Map{
+		"jobs":  jobs,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// ReviewQuarantinedJob returns a quarantined job with its scan findings and a
+// short-lived link to the original upload
+func (h *Handlers) ReviewQuarantinedJob(c *fiber.Ctx) error {
+	job, status, msg := h.quarantinedJob(c.Params("id"))
+	if job == nil {
+		return c.Status(status).JSON(fiber.Map{"error": msg})
+	}
+
+	downloadURL, err := h.storage.GetPresignedURL(context.Background(), job.OriginalURL, 15*time.Minute)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate download URL"})
+	}
+
+	return c.JSON(fiber.Map{
+		"job":          job,
+		"download_url": downloadURL,
+	})
+}
+
+// ReleaseQuarantinedJob clears a quarantined upload for processing
+func (h *Handlers) ReleaseQuarantinedJob(c *fiber.Ctx) error {
+	job, status, msg := h.quarantinedJob(c.Params("id"))
+	if job == nil {
+		return c.Status(status).JSON(fiber.Map{"error": msg})
+	}
+
+	job.Status = models.JobStatusPending
+	job.UpdatedAt = time.Now()
+	if err := h.db.UpdateJob(job); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to release job"})
+	}
+	log.Printf("Quarantined job %s released by %s", job.ID, c.Locals("user_id"))
+
+	return c.JSON(fiber.Map{
+		"message": "Upload released for processing",
+		"job_id":  job.ID,
+		"status":  job.Status,
+	})
+}
+
+// DeleteQuarantinedJob removes a quarantined upload and its job
+func (h *Handlers) DeleteQuarantinedJob(c *fiber.Ctx) error {
+	job, status, msg := h.quarantinedJob(c.Params("id"))
+	if job == nil {
+		return c.Status(status).JSON(fiber.Map{"error": msg})
+	}
+
+	if err := h.storage.DeleteFile(context.Background(), job.OriginalURL); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete file"})
+	}
+	if err := h.db.DeleteJob(job.ID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete job"})
+	}
+	log.Printf("Quarantined job %s deleted by %s", job.ID, c.Locals("user_id"))
+
+	return c.JSON(fiber.Map{"message": "Upload deleted", "job_id": job.ID})
+}
+
+// quarantinedJob loads a quarantined job, or returns the status code and
+// message to respond with when it does not exist or is not quarantined
+func (h *Handlers) quarantinedJob(id string) (*models.Job, int, string) {
+	job, err := h.db.GetJobByID(id)
+	if err != nil {
+		return nil, 404, "Job not found"
+	}
+	if job.Status != models.JobStatusQuarantined {
+		return nil, 409, "Job is not quarantined"
+	}
+	return job, 0, ""
+}
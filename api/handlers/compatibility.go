@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"modforge.ai/ai"
+	"modforge.ai/api/models"
+	"modforge.ai/mods"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CheckCompatibility reports whether an uploaded jar or pack declares
+// compatibility with a Minecraft version and loader
+func (h *Handlers) CheckCompatibility(c *fiber.Ctx) error {
+	ctx := context.Background()
+
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Authentication required"})
+	}
+
+	var target mods.CompatTarget
+	if err := c.BodyParser(&target); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if target.MinecraftVersion == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "minecraft_version is required"})
+	}
+
+	job, err := h.db.GetJobByID(c.Params("id"))
+	if err != nil || job.UserID != userID {
+		return c.Status(404).JSON(fiber.Map{"error": "Job not found"})
+	}
+
+	content, err := h.storage.DownloadFile(ctx, job.OriginalURL)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to read file"})
+	}
+
+	result, err := mods.CheckCompatibility(jobFilename(job), content, target)
+	if err != nil {
+		return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"compatible": result.Compatible(),
+		"result":     result,
+	})
+}
+
+// portPackInBackground ports a data pack to a newer Minecraft version and
+// only completes the job if the ported pack is declared compatible
+func (h *Handlers) portPackInBackground(ctx context.Context, job *models.Job, minecraftVersion string, credits int) {
+	content, err := h.storage.DownloadFile(ctx, job.OriginalURL)
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("Failed to download file: %v", err))
+		return
+	}
+
+	format, err := mods.PackFormatFor(minecraftVersion, mods.PackKindData)
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", err.Error())
+		return
+	}
+
+	tokensUsed := 0
+	var ported []string
+	port := func(name string, data []byte) ([]byte, error) {
+		response, err := h.aiClient.ProcessMod(ctx, ai.ProcessModRequest{
+			Content:        string(data),
+			PromptTemplate: ai.PortPackPrompt,
+			GameType:       job.ModType,
+			Variables: map[string]string{
+				"path":           name,
+				"target_version": minecraftVersion,
+				"pack_format":    fmt.Sprintf("%d", format),
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		tokensUsed += response.TokensUsed
+		if response.ProcessedContent != string(data) {
+			ported = append(ported, name)
+		}
+		return []byte(response.ProcessedContent), nil
+	}
+
+	output, result, err := mods.PortDataPack(jobFilename(job), content, minecraftVersion, port)
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("Port failed: %v", err))
+		return
+	}
+	if !result.Compatible() {
+		var problems []string
+		for _, check := range result.Checks {
+			if !check.Compatible {
+				problems = append(problems, check.Message)
+			}
+		}
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("Ported pack is not compatible with Minecraft %s: %s", minecraftVersion, strings.Join(problems, "; ")))
+		return
+	}
+
+	outputFormat, err := mods.FormatFor(jobFilename(job), output)
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("Port produced an unreadable file: %v", err))
+		return
+	}
+
+	changelog := fmt.Sprintf("Ported to Minecraft %s (data pack format %d)", minecraftVersion, format)
+	if len(ported) > 0 {
+		changelog += "\n- Updated " + strings.Join(ported, "\n- Updated ")
+	}
+
+	h.completeJob(ctx, job, output, outputFormat, tokensUsed, credits, changelog)
+}
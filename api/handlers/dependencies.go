@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"modforge.ai/mods"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxDependencyJobs caps how many uploads can be resolved together
+const maxDependencyJobs = 200
+
+// DependencyRequest represents a request to resolve a set of uploaded mods
+type DependencyRequest struct {
+	JobIDs      []string          `json:"job_ids"`
+	Environment map[string]string `json:"environment"` // e.g. {"minecraft": "1.20.1", "fabricloader": "0.15.0"}
+}
+
+// ResolveDependencies reports missing, incompatible and circular dependencies across uploaded mods
+func (h *Handlers) ResolveDependencies(c *fiber.Ctx) error {
+	ctx := context.Background()
+
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Authentication required"})
+	}
+
+	var req DependencyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if len(req.JobIDs) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "At least one job ID is required"})
+	}
+	if len(req.JobIDs) > maxDependencyJobs {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("At most %d jobs can be resolved together", maxDependencyJobs)})
+	}
+
+	var descriptors []mods.ModDescriptor
+	var unreadable []fiber.Map
+	for _, jobID := range req.JobIDs {
+		job, err := h.db.GetJobByID(jobID)
+		if err != nil || job.UserID != userID {
+			return c.Status(404).JSON(fiber.Map{"error": fmt.Sprintf("Job %s not found", jobID)})
+		}
+
+		content, err := h.storage.DownloadFile(ctx, job.OriginalURL)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": fmt.Sprintf("Failed to read file for job %s", jobID)})
+		}
+
+		found, err := mods.ParseDescriptors(jobFilename(job), content)
+		if err != nil {
+			unreadable = append(unreadable, fiber.Map{"job_id": jobID, "error": err.Error()})
+			continue
+		}
+		if len(found) == 0 {
+			unreadable = append(unreadable, fiber.Map{"job_id": jobID, "error": "no mod descriptor found"})
+			continue
+		}
+		descriptors = append(descriptors, found...)
+	}
+
+	report := mods.ResolveDependencies(descriptors, req.Environment)
+
+	return c.JSON(fiber.Map{
+		"loadable":   report.Loadable() && len(unreadable) == 0,
+		"report":     report,
+		"unreadable": unreadable,
+	})
+}
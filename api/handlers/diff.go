@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"modforge.ai/api/models"
+	"modforge.ai/mods"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// jobDiff is a job's diff together with the two files it compares
+type jobDiff struct {
+	job                 *models.Job
+	original, processed []byte
+	diff                *mods.Diff
+}
+
+// loadJobDiff diffs a job owned by the user, or returns the status code and
+// message to respond with when that is not possible
+func (h *Handlers) loadJobDiff(ctx context.Context, userID, jobID string) (*jobDiff, int, string) {
+	job, err := h.db.GetJobByID(jobID)
+	if err != nil || job.UserID != userID {
+		return nil, 404, "Job not found"
+	}
+	if job.ProcessedURL == nil {
+		return nil, 400, "Job has no processed file to compare"
+	}
+
+	original, err := h.storage.DownloadFile(ctx, job.OriginalURL)
+	if err != nil {
+		return nil, 500, "Failed to read original file"
+	}
+	processed, err := h.storage.DownloadFile(ctx, *job.ProcessedURL)
+	if err != nil {
+		return nil, 500, "Failed to read processed file"
+	}
+
+	diff, err := mods.DiffFiles(jobFilename(job), original, processed)
+	if err != nil {
+		return nil, 422, fmt.Sprintf("Failed to compare files: %v", err)
+	}
+	return &jobDiff{job: job, original: original, processed: processed, diff: diff}, 0, ""
+}
+
+// jobSelections decodes a job's review decisions, keyed by change ID
+func jobSelections(job *models.Job) map[string]bool {
+	selections := make(map[string]bool)
+	if len(job.ChangeSelections) > 0 {
+		json.Unmarshal(job.ChangeSelections, &selections)
+	}
+	return selections
+}
+
+// reviewSummary counts the accepted, rejected and unreviewed changes
+func reviewSummary(diff *mods.Diff, selections map[string]bool) fiber.Map {
+	ids := diff.ChangeIDs()
+	accepted, rejected := 0, 0
+	for _, id := range ids {
+		if selected, ok := selections[id]; ok {
+			if selected {
+				accepted++
+			} else {
+				rejected++
+			}
+		}
+	}
+	return fiber.Map{
+		"total":    len(ids),
+		"accepted": accepted,
+		"rejected": rejected,
+		"pending":  len(ids) - accepted - rejected,
+	}
+}
+
+// GetJobDiff compares a job's original upload with its processed file. It
+// returns the structured diff as JSON, or unified diff text with ?format=unified.
+func (h *Handlers) GetJobDiff(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Authentication required"})
+	}
+
+	format := c.Query("format", "json")
+	if format != "json" && format != "unified" {
+		return c.Status(400).JSON(fiber.Map{"error": "format must be json or unified"})
+	}
+
+	jd, status, msg := h.loadJobDiff(context.Background(), userID, c.Params("id"))
+	if jd == nil {
+		return c.Status(status).JSON(fiber.Map{"error": msg})
+	}
+
+	if format == "unified" {
+		c.Set(fiber.HeaderContentType, "text/x-diff; charset=utf-8")
+		return c.SendString(jd.diff.Unified())
+	}
+	selections := jobSelections(jd.job)
+	return c.JSON(fiber.Map{
+		"job_id":     jd.job.ID,
+		"diff":       jd.diff,
+		"selections": selections,
+		"review":     reviewSummary(jd.diff, selections),
+	})
+}
+
+// UpdateChangeSelections records accept (true) or reject (false) decisions
+// for changes of a job's diff. Decisions are merged into earlier ones, so a
+// review can be completed over several requests.
+func (h *Handlers) UpdateChangeSelections(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Authentication required"})
+	}
+
+	var params struct {
+		Selections map[string]bool `json:"selections"`
+	}
+	if err := c.BodyParser(&params); err != nil || len(params.Selections) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "selections must map change IDs to true or false"})
+	}
+
+	jd, status, msg := h.loadJobDiff(context.Background(), userID, c.Params("id"))
+	if jd == nil {
+		return c.Status(status).JSON(fiber.Map{"error": msg})
+	}
+
+	known := make(map[string]bool)
+	for _, id := range jd.diff.ChangeIDs() {
+		known[id] = true
+	}
+	selections := jobSelections(jd.job)
+	for id, accepted := range params.Selections {
+		if !known[id] {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Unknown change ID %q", id)})
+		}
+		selections[id] = accepted
+	}
+
+	encoded, err := models.NewJSONText(selections)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save selections"})
+	}
+	jd.job.ChangeSelections = encoded
+	jd.job.ReviewedURL = nil // a build from older selections is stale
+	jd.job.UpdatedAt = time.Now()
+	if err := h.db.UpdateJob(jd.job); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save selections"})
+	}
+
+	return c.JSON(fiber.Map{
+		"job_id":     jd.job.ID,
+		"selections": selections,
+		"review":     reviewSummary(jd.diff, selections),
+	})
+}
+
+// BuildReviewedMod builds the file a user gets from applying only the changes
+// they accepted. Changes that were rejected or not reviewed are left out.
+func (h *Handlers) BuildReviewedMod(c *fiber.Ctx) error {
+	ctx := context.Background()
+
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "Authentication required"})
+	}
+
+	jd, status, msg := h.loadJobDiff(ctx, userID, c.Params("id"))
+	if jd == nil {
+		return c.Status(status).JSON(fiber.Map{"error": msg})
+	}
+
+	selections := jobSelections(jd.job)
+	output, err := jd.diff.Apply(jd.original, jd.processed, selections)
+	if err != nil {
+		return c.Status(422).JSON(fiber.Map{"error": fmt.Sprintf("Failed to apply selected changes: %v", err)})
+	}
+
+	// Accepted changes still have to produce a valid file
+	format, err := mods.FormatFor(jobFilename(jd.job), jd.original)
+	if err != nil {
+		return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := format.Validate(jobFilename(jd.job), output); err != nil {
+		return c.Status(422).JSON(fiber.Map{"error": fmt.Sprintf("Selected changes produce an invalid file: %v", err)})
+	}
+
+	filename := fmt.Sprintf("reviewed_%s_%s", jd.job.ID, jobFilename(jd.job))
+	reviewedURL, err := h.storage.UploadFile(ctx, output, filename, format.Info().MIMETypes[0])
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": fmt.Sprintf("Failed to upload reviewed file: %v", err)})
+	}
+	jd.job.ReviewedURL = &reviewedURL
+	jd.job.UpdatedAt = time.Now()
+	if err := h.db.UpdateJob(jd.job); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update job"})
+	}
+
+	downloadURL, err := h.storage.GetPresignedURL(ctx, reviewedURL, 1*time.Hour)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate download URL"})
+	}
+
+	return c.JSON(fiber.Map{
+		"job_id":       jd.job.ID,
+		"download_url": downloadURL,
+		"review":       reviewSummary(jd.diff, selections),
+	})
+}
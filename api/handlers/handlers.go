@@ -4,8 +4,10 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -18,6 +20,7 @@ import (
 	"modforge.ai/api/models"
 	"modforge.ai/api/storage"
 	"modforge.ai/mods"
+	"modforge.ai/scanner"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -30,15 +33,17 @@ type Handlers struct {
 	cfg      *config.Config
 	storage  *storage.Client
 	aiClient *ai.Client
+	scanner  *scanner.Service
 }
 
 // New creates a new handlers instance
-func New(db *database.DB, cfg *config.Config, storageClient *storage.Client, aiClient *ai.Client) *Handlers {
+func New(db *database.DB, cfg *config.Config, storageClient *storage.Client, aiClient *ai.Client, scanService *scanner.Service) *Handlers {
 	return &Handlers{
 		db:       db,
 		cfg:      cfg,
 		storage:  storageClient,
 		aiClient: aiClient,
+		scanner:  scanService,
 	}
 }
 
@@ -298,13 +303,9 @@ func (h *Handlers) UploadMod(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "No file uploaded"})
 	}
 
-	// Validate file type and size
-	if !isValidModFile(file.Filename) {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid file type. Only .jar, .zip, .json files are allowed"})
-	}
-
-	if file.Size > 100*1024*1024 { // 100MB limit
-		return c.Status(400).JSON(fiber.Map{"error": "File too large. Maximum size is 100MB"})
+	// Reject oversized files before reading them
+	if file.Size > mods.MaxUploadSize() {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("File too large. Maximum size is %dMB", mods.MaxUploadSize()/(1024*1024))})
 	}
 
 	// Open the file
@@ -320,25 +321,71 @@ func (h *Handlers) UploadMod(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to read file"})
 	}
 
-	// Upload to storage
-	fileURL, err := h.storage.UploadFile(ctx, content, file.Filename, file.Header.Get("Content-Type"))
+	// Validate against the policy of the file's format
+	format, err := mods.ValidateUpload(file.Filename, file.Size, file.Header.Get("Content-Type"), content)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": fmt.Sprintf("Failed to upload file: %v", err)})
+		response := fiber.Map{"error": err.Error(), "allowed_extensions": mods.AllowedExtensions()}
+
+		// Unsafe archives carry a machine-readable reason
+		var archiveErr *mods.ArchiveError
+		if errors.As(err, &archiveErr) {
+			response["rejection"] = archiveErr
+		}
+		return c.Status(400).JSON(response)
 	}
 
-	// Detect mod type
-	modType, err := mods.DetectModType(content, file.Filename)
+	// Detect mod type, asking the user to choose when detection is not confident
+	detection := mods.Detect(file.Filename, content)
+	modType := string(detection.Best().GameType)
+	if chosen := c.FormValue("game_type"); chosen != "" {
+		gameType, err := mods.ParseGameType(chosen)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error(), "game_types": mods.GameTypes})
+		}
+		modType = string(gameType)
+	} else if !detection.IsConfident() {
+		return c.Status(422).JSON(fiber.Map{
+			"error":              "Could not confidently detect the game type. Please choose one and upload again.",
+			"requires_game_type": true,
+			"candidates":         detection.Candidates,
+			"game_types":         mods.GameTypes,
+		})
+	}
+
+	// Lua mods are narrowed to the game they script, which the user may override
+	var ecosystem *mods.EcosystemInfo
+	if modType == string(mods.GameTypeLua) {
+		ecosystem = mods.DetectEcosystem(file.Filename, content)
+		if chosen := c.FormValue("ecosystem"); chosen != "" {
+			parsed, err := mods.ParseEcosystem(chosen)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": err.Error(), "ecosystems": mods.Ecosystems})
+			}
+			if ecosystem == nil || ecosystem.Ecosystem != parsed {
+				ecosystem = &mods.EcosystemInfo{Ecosystem: parsed, Confidence: 1}
+			}
+		}
+	}
+
+	// Upload to storage
+	fileURL, err := h.storage.UploadFile(ctx, content, file.Filename, format.Info().MIMETypes[0])
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Failed to detect mod type: %v", err)})
+		return c.Status(500).JSON(fiber.Map{"error": fmt.Sprintf("Failed to upload file: %v", err)})
 	}
 
+	// Fingerprint the file so identical uploads are recognized
+	fingerprints := mods.Fingerprint(content)
+	curseForge := int64(fingerprints.CurseForge)
+
 	// Create a new job
 	job := &models.Job{
 		ID:          uuid.New().String(),
 		UserID:      userID, // Use authenticated user ID
-		Status:      "pending",
+		Status:      models.JobStatusScanning,
 		ModType:     modType,
 		OriginalURL: fileURL,
+		SHA1:        &fingerprints.SHA1,
+		SHA512:      &fingerprints.SHA512,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
@@ -350,16 +397,104 @@ func (h *Handlers) UploadMod(c *fiber.Ctx) error {
 	job.OriginalFilename = &filename
 	job.OriginalFileSize = &fileSize
 	job.PresetType = &presetType
+	job.CurseForgeFingerprint = &curseForge
+	if ecosystem != nil {
+		ecosystemMetadata, err := models.NewJSONText(ecosystem)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to record ecosystem"})
+		}
+		name := string(ecosystem.Ecosystem)
+		job.Ecosystem = &name
+		job.EcosystemMetadata = ecosystemMetadata
+	}
+
+	// An identical file that was already scanned keeps its verdict, including
+	// an admin's release from quarantine
+	previous, err := h.db.GetScannedJobBySHA512(fingerprints.SHA512)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to look up fingerprint"})
+	}
+	if previous != nil {
+		job.Status = models.JobStatusPending
+		if previous.Status == models.JobStatusQuarantined {
+			job.Status = models.JobStatusQuarantined
+		}
+		job.ScanFindings = previous.ScanFindings
+	}
 
 	if err := h.db.CreateJob(job); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to create job"})
 	}
 
+	// The job cannot be processed until the malware scan passes it
+	message := "File uploaded successfully and is being scanned"
+	switch job.Status {
+	case models.JobStatusScanning:
+		h.scanInBackground(job.ID, file.Filename, content)
+	case models.JobStatusQuarantined:
+		message = "File uploaded but quarantined: an identical upload was flagged by the malware scan"
+	default:
+		message = "File uploaded successfully"
+	}
+
+	// Metadata is informational; a file that validated but cannot be analysed still uploads
+	metadata, err := format.ExtractMetadata(content)
+	if err != nil {
+		metadata = map[string]interface{}{}
+	}
+	if modType == string(mods.GameTypeMinecraft) {
+		if references, err := mods.CheckReferences(file.Filename, content, mods.DeclaredMinecraftVersion(file.Filename, content)); err == nil {
+			metadata["references"] = references
+		}
+	}
+
 	return c.JSON(fiber.Map{
-		"job_id":   job.ID,
-		"status":   job.Status,
-		"mod_type": job.ModType,
-		"message":  "File uploaded successfully",
+		"job_id":       job.ID,
+		"status":       job.Status,
+		"mod_type":     job.ModType,
+		"candidates":   detection.Candidates,
+		"ecosystem":    ecosystem,
+		"metadata":     metadata,
+		"fingerprints": fingerprints,
+		"known":        previous != nil,
+		"message":      message,
+	})
+}
+
+// scanInBackground scans an upload and moves its job to pending, to
+// quarantined when the scan flags it, or to failed when the scan could not
+// run, so an unscanned file is never processed
+func (h *Handlers) scanInBackground(jobID, filename string, content []byte) {
+	h.scanner.ScanAsync(filename, content, func(scan *scanner.Result) {
+		for _, scanErr := range scan.Errors {
+			log.Printf("Scan of job %s: %s", jobID, scanErr)
+		}
+
+		job, err := h.db.GetJobByID(jobID)
+		if err != nil {
+			log.Printf("Scan of job %s finished but the job is gone: %v", jobID, err)
+			return
+		}
+		findings, err := models.NewJSONText(scan.Findings)
+		if err != nil {
+			h.updateJobStatus(jobID, models.JobStatusFailed, fmt.Sprintf("Failed to record scan findings: %v", err))
+			return
+		}
+
+		job.Status = models.JobStatusPending
+		switch {
+		case scan.Quarantined:
+			job.Status = models.JobStatusQuarantined
+		case scan.Incomplete:
+			job.Status = models.JobStatusFailed
+			message := "Malware scan did not complete: " + strings.Join(scan.Errors, "; ")
+			job.ErrorMessage = &message
+		}
+		job.ScanFindings = findings
+		job.UpdatedAt = time.Now()
+		if err := h.db.UpdateJob(job); err != nil {
+			log.Printf("Failed to record scan of job %s: %v", jobID, err)
+		}
 	})
 }
 
@@ -391,16 +526,104 @@ func (h *Handlers) ProcessMod(c *fiber.Ctx) error {
 		PresetID    string `json:"preset_id"`
 		Prompt      string `json:"prompt"`
 		ModelConfig string `json:"model_config"`
+
+		// TargetMinecraftVersion ports a data pack to that version's pack format instead
+		TargetMinecraftVersion string `json:"target_minecraft_version"`
+
+		// TargetLocales translates a jar's language files into these locales instead
+		TargetLocales []string `json:"target_locales"`
+
+		// SandboxCheck loads an edited Lua script in a sandbox and rejects it
+		// if it fails to load or stops defining what the original did
+		SandboxCheck bool `json:"sandbox_check"`
+
+		// Rules applies these declarative edits without AI instead
+		Rules []mods.Rule `json:"rules"`
 	}
 	if err := c.BodyParser(&params); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
+	if params.TargetMinecraftVersion != "" {
+		if _, err := mods.PackFormatFor(params.TargetMinecraftVersion, mods.PackKindData); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	// A mocked AI would save the pack unported under a ported name
+	if h.cfg.MockAI && params.TargetMinecraftVersion != "" {
+		return c.Status(503).JSON(fiber.Map{"error": "Porting is unavailable while the AI is mocked"})
+	}
 
 	// Get the job
 	job, err := h.db.GetJobByID(jobID)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Job not found"})
 	}
+	for _, locale := range params.TargetLocales {
+		if job.ModType == string(mods.GameTypeStardew) {
+			if !mods.ValidSMAPILocale(locale) {
+				return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Invalid target locale %q; use SMAPI locale codes such as de or pt", locale)})
+			}
+			continue
+		}
+		if isFactorioJob(job) {
+			if !mods.ValidFactorioLocale(locale) || locale == mods.FactorioSourceLocale {
+				return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Invalid target locale %q; use Factorio locale codes such as de or pt-BR", locale)})
+			}
+			continue
+		}
+		if !mods.ValidLocale(locale) || locale == mods.SourceLocale {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Invalid target locale %q; use Minecraft locale codes such as de_de", locale)})
+		}
+	}
+	switch job.Status {
+	case models.JobStatusScanning:
+		return c.Status(409).JSON(fiber.Map{"error": "This upload is still being scanned. Try again once the scan finishes.", "status": job.Status})
+	case models.JobStatusQuarantined:
+		return c.Status(403).JSON(fiber.Map{"error": "This upload was quarantined by the malware scan and cannot be processed", "scan_findings": job.ScanFindings})
+	case models.JobStatusPending, models.JobStatusCompleted:
+	default:
+		return c.Status(409).JSON(fiber.Map{"error": fmt.Sprintf("This upload cannot be processed while its job is %s", job.Status), "status": job.Status})
+	}
+
+	// A preset costs its credit_cost, a custom AI job customJobCredits and
+	// rules in the request nothing. Rule presets and rules in the request are
+	// applied without AI.
+	rules := params.Rules
+	credits := customJobCredits
+	if params.PresetID != "" {
+		if preset, err := h.db.GetPresetByID(params.PresetID); err == nil {
+			credits = preset.CreditCost
+			if preset.Rules != nil && len(rules) == 0 {
+				if rules, err = mods.ParseRules([]byte(*preset.Rules)); err != nil {
+					return c.Status(500).JSON(fiber.Map{"error": fmt.Sprintf("Preset %s has invalid rules: %v", preset.ID, err)})
+				}
+			}
+		}
+	}
+	if len(params.Rules) > 0 {
+		credits = 0
+	}
+	if params.Rules != nil {
+		if err := mods.ValidateRules(params.Rules); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	// Translating a jar must only touch its language files
+	isArchive := false
+	if formats := mods.FormatsForExtension(jobFilename(job)); len(formats) > 0 {
+		isArchive = formats[0].Info().Container
+	}
+	if len(params.TargetLocales) > 0 && !isArchive {
+		return c.Status(400).JSON(fiber.Map{"error": "target_locales is only supported for jar and zip uploads"})
+	}
+	// A mocked AI would save the source text as every translation
+	if h.cfg.MockAI && (len(params.TargetLocales) > 0 || translatePresets[params.PresetID]) {
+		return c.Status(503).JSON(fiber.Map{"error": "Translation is unavailable while the AI is mocked"})
+	}
+	if translatePresets[params.PresetID] && isArchive && len(params.TargetLocales) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "target_locales is required to translate a jar or zip"})
+	}
 
 	// Update job status to processing
 	job.Status = "processing"
@@ -411,7 +634,19 @@ func (h *Handlers) ProcessMod(c *fiber.Ctx) error {
 
 	// Process in background (for now, we'll do it synchronously)
 	go func() {
-		h.processModInBackground(ctx, job, params.PresetID, params.Prompt)
+		if params.TargetMinecraftVersion != "" {
+			h.portPackInBackground(ctx, job, params.TargetMinecraftVersion, credits)
+			return
+		}
+		if len(params.TargetLocales) > 0 {
+			h.translateInBackground(ctx, job, params.TargetLocales, credits)
+			return
+		}
+		if len(rules) > 0 {
+			h.applyRulesInBackground(ctx, job, rules, credits)
+			return
+		}
+		h.processModInBackground(ctx, job, params.PresetID, params.Prompt, params.SandboxCheck, credits)
 	}()
 
 	return c.JSON(fiber.Map{
@@ -422,7 +657,7 @@ func (h *Handlers) ProcessMod(c *fiber.Ctx) error {
 }
 
 // processModInBackground handles the actual mod processing
-func (h *Handlers) processModInBackground(ctx context.Context, job *models.Job, presetID, prompt string) {
+func (h *Handlers) processModInBackground(ctx context.Context, job *models.Job, presetID, prompt string, sandboxCheck bool, credits int) {
 	// Download original file
 	content, err := h.storage.DownloadFile(ctx, job.OriginalURL)
 	if err != nil {
@@ -430,36 +665,244 @@ func (h *Handlers) processModInBackground(ctx context.Context, job *models.Job,
 		return
 	}
 
-	// Check if we should use mock AI processing (for testing when OpenAI quota exceeded)
-	useMockAI := true // Set to false when you have OpenAI quota
+	// Only formats that can be rebuilt from AI output are sent to the AI
+	format, err := mods.FormatFor(jobFilename(job), content)
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("Unsupported file: %v", err))
+		return
+	}
+	if format.Info().Container {
+		h.processArchiveInBackground(ctx, job, content, format, presetID, prompt, credits)
+		return
+	}
+	if !format.Info().Editable {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("AI editing is not supported for %s files", format.Info().Name))
+		return
+	}
 
-	var processedResponse *ai.ProcessModResponse
+	editable, err := mods.EditableContent(format, content)
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("Failed to decode file: %v", err))
+		return
+	}
+	processedResponse, err := h.editWithAI(ctx, job, editable, prompt)
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("AI processing failed: %v", err))
+		return
+	}
 
-	if useMockAI {
-		// Mock AI response for testing
-		processedResponse = &ai.ProcessModResponse{
-			ProcessedContent: h.generateMockEnhancedContent(string(content), prompt),
-			Changelog:        "Mock AI Enhancement: " + prompt,
-			TokensUsed:       100, // Mock token usage
+	// Rebuild the file in its original format, rejecting output that breaks it
+	output, err := format.Rewrite(content, []byte(processedResponse.ProcessedContent))
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("AI output rejected: %v", err))
+		return
+	}
+	if err := checkNewReferences(job, content, output); err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("AI output rejected: %v", err))
+		return
+	}
+
+	changelog := processedResponse.Changelog
+	if sandboxCheck && format.Info().Name == "lua" {
+		report := mods.CompareLuaLoad(content, output, mods.DefaultLuaLimits)
+		if problems := report.Problems(); len(problems) > 0 {
+			h.updateJobStatus(job.ID, "failed", fmt.Sprintf("AI output rejected by sandbox check: %s", strings.Join(problems, "; ")))
+			return
 		}
-	} else {
-		// Use real AI to process the mod
-		realResponse, err := h.aiClient.ProcessMod(ctx, ai.ProcessModRequest{
-			Content:        string(content),
-			PromptTemplate: prompt,
-			GameType:       job.ModType,
-			Variables:      map[string]string{},
-		})
+		changelog += fmt.Sprintf("\nSandbox check: edited script loads (%s)", report.Edited)
+	}
+	if notes := reportChanges(format, content, []byte(processedResponse.ProcessedContent)); len(notes) > 0 {
+		changelog += "\nChanged settings:\n" + strings.Join(notes, "\n")
+	}
+
+	h.completeJob(ctx, job, output, format, processedResponse.TokensUsed, credits, changelog)
+}
+
+// reportChanges lists the values an edit changed for formats that can
+// report them, such as config files
+func reportChanges(format mods.Format, original, edited []byte) []string {
+	reporter, ok := format.(mods.ChangeReporter)
+	if !ok {
+		return nil
+	}
+	notes, err := reporter.ReportChanges(original, edited)
+	if err != nil {
+		return nil
+	}
+	return notes
+}
+
+// customJobCredits is what an AI job without a preset costs
+const customJobCredits = 1
+
+// translatePresets are the presets that translate an archive's language files
+// into target_locales
+var translatePresets = map[string]bool{
+	"minecraft_translate": true,
+	"stardew_translate":   true,
+	"bukkit_translate":    true,
+}
+
+// factorioPrototypePresets are the presets that only edit the prototype
+// definitions of a Factorio mod, leaving its runtime scripts alone
+var factorioPrototypePresets = map[string]bool{
+	"factorio_balance":    true,
+	"factorio_stack_size": true,
+}
+
+// processArchiveInBackground edits the editable files inside a jar or zip and
+// repackages them into a valid archive
+func (h *Handlers) processArchiveInBackground(ctx context.Context, job *models.Job, content []byte, format mods.Format, presetID, prompt string, credits int) {
+	var factorio *mods.FactorioMod
+	if format.Info().Name == "factorio" && factorioPrototypePresets[presetID] {
+		mod, err := mods.ParseFactorioMod(content)
 		if err != nil {
-			h.updateJobStatus(job.ID, "failed", fmt.Sprintf("AI processing failed: %v", err))
+			h.updateJobStatus(job.ID, "failed", fmt.Sprintf("Invalid Factorio mod: %v", err))
+			return
+		}
+		if len(mod.Prototypes) == 0 {
+			h.updateJobStatus(job.ID, "failed", "This preset edits prototype definitions, but the mod has no data stage scripts")
 			return
 		}
-		processedResponse = realResponse
+		factorio = mod
 	}
 
+	bukkit := format.Info().Name == "archive" && mods.IsBukkitJar(content)
+
+	tokensUsed := 0
+	var changelog []string
+	edit := func(name string, data []byte) ([]byte, error) {
+		if factorio != nil && !factorio.IsPrototypeScript(name) {
+			return data, nil
+		}
+		if bukkit && !mods.IsBukkitEditable(name) {
+			return data, nil // plugin.yml is the plugin's identity, not a setting
+		}
+		if format.Info().Name == "stardew" && !mods.IsStardewEditable(name) {
+			return data, nil // manifests change through the upload, translations through target_locales
+		}
+		if format.Info().Name == "bedrock" && !mods.IsBedrockEditable(name) {
+			return data, nil // pack identities are regenerated when the add-on is packaged
+		}
+		response, err := h.editWithAI(ctx, job, data, prompt)
+		if err != nil {
+			return nil, err
+		}
+		tokensUsed += response.TokensUsed
+		if response.ProcessedContent != string(data) {
+			changelog = append(changelog, fmt.Sprintf("%s: %s", name, response.Changelog))
+			if entryFormat, err := mods.FormatFor(name, data); err == nil {
+				for _, note := range reportChanges(entryFormat, data, []byte(response.ProcessedContent)) {
+					changelog = append(changelog, fmt.Sprintf("%s: %s", name, note))
+				}
+			}
+		}
+		return []byte(response.ProcessedContent), nil
+	}
+
+	output, changed, err := mods.EditArchive(content, edit)
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("AI output rejected: %v", err))
+		return
+	}
+	if len(changed) == 0 {
+		changelog = append(changelog, "No files inside the archive needed changes")
+	}
+	if err := checkNewReferences(job, content, output); err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("AI output rejected: %v", err))
+		return
+	}
+	output, notes, err := packageArchive(format, output)
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("Failed to repackage archive: %v", err))
+		return
+	}
+	changelog = append(changelog, notes...)
+
+	h.completeJob(ctx, job, output, format, tokensUsed, credits, strings.Join(changelog, "\n"))
+}
+
+// packageArchive lays an edited archive out the way its format requires and
+// returns changelog lines for what that changed
+func packageArchive(format mods.Format, output []byte) ([]byte, []string, error) {
+	packager, ok := format.(mods.Packager)
+	if !ok {
+		return output, nil, nil
+	}
+	return packager.Package(output)
+}
+
+// isFactorioJob reports whether a job holds a Factorio mod
+func isFactorioJob(job *models.Job) bool {
+	return job.Ecosystem != nil && *job.Ecosystem == string(mods.EcosystemFactorio)
+}
+
+// checkNewReferences rejects edited Minecraft content that references items,
+// blocks, tags, models or textures that do not exist, unless the original
+// already referenced them
+func checkNewReferences(job *models.Job, original, output []byte) error {
+	if job.ModType != string(mods.GameTypeMinecraft) {
+		return nil
+	}
+	// Each side is checked against the release it declares, so a port that
+	// renames IDs for its new version is not blamed for the renames
+	filename := jobFilename(job)
+	before, err := mods.CheckReferences(filename, original, mods.DeclaredMinecraftVersion(filename, original))
+	if err != nil {
+		return nil // only files the checker understands are gated
+	}
+	after, err := mods.CheckReferences(filename, output, mods.DeclaredMinecraftVersion(filename, output))
+	if err != nil {
+		return nil
+	}
+
+	added := after.NewUnresolved(before)
+	if len(added) == 0 {
+		return nil
+	}
+	var ids []string
+	for _, ref := range added[:min(len(added), 10)] {
+		ids = append(ids, fmt.Sprintf("%s %s (%s)", ref.Kind, ref.ID, ref.Source))
+	}
+	if len(added) > 10 {
+		ids = append(ids, fmt.Sprintf("and %d more", len(added)-10))
+	}
+	return fmt.Errorf("references unknown IDs: %s", strings.Join(ids, ", "))
+}
+
+// editWithAI sends one file to the AI and returns its edited content
+func (h *Handlers) editWithAI(ctx context.Context, job *models.Job, content []byte, prompt string) (*ai.ProcessModResponse, error) {
+	if h.cfg.MockAI {
+		processed, changes := h.generateMockEnhancedContent(string(content))
+		changelog := fmt.Sprintf("Mock AI edit (no AI was called): %s", prompt)
+		if len(changes) == 0 {
+			changelog += "\n- No changes"
+		}
+		for _, change := range changes {
+			changelog += "\n- " + change
+		}
+		return &ai.ProcessModResponse{
+			ProcessedContent: processed,
+			Changelog:        changelog,
+			TokensUsed:       100, // Mock token usage
+		}, nil
+	}
+
+	// Use real AI to process the mod
+	return h.aiClient.ProcessMod(ctx, ai.ProcessModRequest{
+		Content:        string(content),
+		PromptTemplate: prompt,
+		GameType:       job.ModType,
+		Variables:      map[string]string{},
+	})
+}
+
+// completeJob uploads the processed file and marks the job completed, charging
+// the credits the caller priced the job at
+func (h *Handlers) completeJob(ctx context.Context, job *models.Job, output []byte, format mods.Format, tokensUsed, creditsUsed int, changelog string) {
 	// Upload processed file
 	filename := fmt.Sprintf("processed_%s_%s", job.ID, filepath.Base(job.OriginalURL))
-	processedURL, err := h.storage.UploadFile(ctx, []byte(processedResponse.ProcessedContent), filename, "application/octet-stream")
+	processedURL, err := h.storage.UploadFile(ctx, output, filename, format.Info().MIMETypes[0])
 	if err != nil {
 		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("Failed to upload processed file: %v", err))
 		return
@@ -468,8 +911,10 @@ func (h *Handlers) processModInBackground(ctx context.Context, job *models.Job,
 	// Update job with results
 	job.Status = "completed"
 	job.ProcessedURL = &processedURL
-	job.TokensUsed = &processedResponse.TokensUsed
-	creditsUsed := 2 // Mock credits used
+	job.TokensUsed = &tokensUsed
+	job.Changelog = &changelog
+	job.ChangeSelections = nil // decisions about an earlier result no longer apply
+	job.ReviewedURL = nil
 	job.CreditsUsed = &creditsUsed
 	job.UpdatedAt = time.Now()
 	if err := h.db.UpdateJob(job); err != nil {
@@ -478,20 +923,34 @@ func (h *Handlers) processModInBackground(ctx context.Context, job *models.Job,
 	}
 }
 
+// mockEnhancements are the substitutions the mock AI makes, with the
+// changelog line for each
+var mockEnhancements = []struct{ old, new, note string }{
+	{"minecraft:diamond", "minecraft:netherite_ingot", "Upgraded diamond to netherite"},
+	{"enhanced_diamond_sword", "legendary_netherite_sword", "Renamed enhanced_diamond_sword to legendary_netherite_sword"},
+	{"\"count\": 1", "\"count\": 1,\n      \"components\": {\n        \"minecraft:enchantments\": {\n          \"minecraft:sharpness\": 3,\n          \"minecraft:unbreaking\": 2\n        }\n      }", "Added Sharpness III and Unbreaking II to results"},
+}
+
 // generateMockEnhancedContent creates a mock enhanced version of the content
-func (h *Handlers) generateMockEnhancedContent(originalContent, prompt string) string {
-	// Create a simple mock enhancement based on the prompt
+// and describes only the substitutions that matched it
+func (h *Handlers) generateMockEnhancedContent(originalContent string) (string, []string) {
 	enhancedContent := originalContent
+	var changes []string
+	for _, e := range mockEnhancements {
+		if strings.Contains(enhancedContent, e.old) {
+			enhancedContent = strings.ReplaceAll(enhancedContent, e.old, e.new)
+			changes = append(changes, e.note)
+		}
+	}
+	return enhancedContent, changes
+}
 
-	// Add some mock enhancements
-	enhancedContent = strings.ReplaceAll(enhancedContent, "minecraft:diamond", "minecraft:netherite_ingot")
-	enhancedContent = strings.ReplaceAll(enhancedContent, "enhanced_diamond_sword", "legendary_netherite_sword")
-	enhancedContent = strings.ReplaceAll(enhancedContent, "\"count\": 1", "\"count\": 1,\n      \"components\": {\n        \"minecraft:enchantments\": {\n          \"minecraft:sharpness\": 3,\n          \"minecraft:unbreaking\": 2\n        }\n      }")
-
-	// Add a comment about the enhancement
-	enhancement := fmt.Sprintf("\n// Enhanced by ModForge.ai with prompt: %s\n// - Upgraded materials from diamond to netherite\n// - Added enchantments for better gameplay\n// - Balanced recipe cost\n", prompt)
-
-	return enhancement + enhancedContent
+// jobFilename returns the name the job's file was uploaded with
+func jobFilename(job *models.Job) string {
+	if job.OriginalFilename != nil {
+		return *job.OriginalFilename
+	}
+	return filepath.Base(job.OriginalURL)
 }
 
 // updateJobStatus is a helper to update job status
@@ -525,8 +984,17 @@ func (h *Handlers) DownloadMod(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Job not completed or no processed file available"})
 	}
 
+	// ?reviewed=true downloads the file built from the accepted changes
+	fileURL := *job.ProcessedURL
+	if c.QueryBool("reviewed") {
+		if job.ReviewedURL == nil {
+			return c.Status(400).JSON(fiber.Map{"error": "No reviewed file has been built for this job"})
+		}
+		fileURL = *job.ReviewedURL
+	}
+
 	// Get presigned URL for download
-	downloadURL, err := h.storage.GetPresignedURL(ctx, *job.ProcessedURL, 1*time.Hour)
+	downloadURL, err := h.storage.GetPresignedURL(ctx, fileURL, 1*time.Hour)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate download URL"})
 	}
@@ -575,7 +1043,16 @@ func (h *Handlers) GetPresetsByType(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Mod type is required"})
 	}
 
-	presets, err := h.db.GetPresetsByType(modType)
+	var ecosystem string
+	if value := c.Query("ecosystem"); value != "" {
+		parsed, err := mods.ParseEcosystem(value)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error(), "ecosystems": mods.Ecosystems})
+		}
+		ecosystem = string(parsed)
+	}
+
+	presets, err := h.db.GetPresetsByType(modType, ecosystem)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch presets"})
 	}
@@ -637,16 +1114,3 @@ func (h *Handlers) PurchaseCredits(c *fiber.Ctx) error {
 		"credits": user.Credits,
 	})
 }
-
-// isValidModFile checks if the uploaded file is a valid mod file
-func isValidModFile(filename string) bool {
-	ext := strings.ToLower(filepath.Ext(filename))
-	validExtensions := []string{".jar", ".zip", ".json", ".mcmeta"}
-
-	for _, validExt := range validExtensions {
-		if ext == validExt {
-			return true
-		}
-	}
-	return false
-}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"modforge.ai/api/models"
+	"modforge.ai/mods"
+)
+
+// maxRuleChangelog caps the changed values listed in a rule job's changelog
+const maxRuleChangelog = 100
+
+// applyRulesInBackground applies declarative rules to a job's file without
+// AI, so the result uses no tokens and is the same every time
+func (h *Handlers) applyRulesInBackground(ctx context.Context, job *models.Job, rules []mods.Rule, credits int) {
+	content, err := h.storage.DownloadFile(ctx, job.OriginalURL)
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("Failed to download file: %v", err))
+		return
+	}
+
+	output, changes, err := mods.ApplyRules(jobFilename(job), content, rules)
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("Rules failed: %v", err))
+		return
+	}
+	if len(changes) == 0 {
+		h.updateJobStatus(job.ID, "failed", "The rules matched no values in this file")
+		return
+	}
+
+	format, err := mods.FormatFor(jobFilename(job), output)
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("Rules produced an unreadable file: %v", err))
+		return
+	}
+	output, notes, err := packageArchive(format, output)
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("Failed to repackage archive: %v", err))
+		return
+	}
+
+	changelog := []string{fmt.Sprintf("Applied %d rules without AI, changing %d values", len(rules), len(changes))}
+	for i, change := range changes {
+		if i == maxRuleChangelog {
+			changelog = append(changelog, fmt.Sprintf("- and %d more", len(changes)-i))
+			break
+		}
+		changelog = append(changelog, "- "+change.String())
+	}
+	for _, note := range notes {
+		changelog = append(changelog, "- "+note)
+	}
+
+	h.completeJob(ctx, job, output, format, 0, credits, strings.Join(changelog, "\n"))
+}
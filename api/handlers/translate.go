@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"modforge.ai/ai"
+	"modforge.ai/api/models"
+	"modforge.ai/mods"
+)
+
+// translateInBackground creates or completes the given locales of a jar or
+// zip from its en_us language files, of a Factorio mod from its en locale, of
+// SMAPI mods from their i18n/default.json, or of a Bukkit plugin from its
+// messages.yml
+func (h *Handlers) translateInBackground(ctx context.Context, job *models.Job, locales []string, credits int) {
+	content, err := h.storage.DownloadFile(ctx, job.OriginalURL)
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("Failed to download file: %v", err))
+		return
+	}
+
+	bukkit := mods.IsBukkitJar(content)
+
+	tokensUsed := 0
+	translate := func(locale string, batch map[string]string) (map[string]string, error) {
+		data, err := json.MarshalIndent(batch, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		prompt := ai.TranslateLangPrompt
+		switch {
+		case isFactorioJob(job):
+			prompt = ai.TranslateFactorioLocalePrompt
+		case job.ModType == string(mods.GameTypeStardew):
+			prompt = ai.TranslateI18nPrompt
+		case bukkit:
+			prompt = ai.TranslateBukkitMessagesPrompt
+		}
+		response, err := h.aiClient.ProcessMod(ctx, ai.ProcessModRequest{
+			Content:        string(data),
+			PromptTemplate: prompt,
+			GameType:       job.ModType,
+			Variables:      map[string]string{"locale": locale},
+		})
+		if err != nil {
+			return nil, err
+		}
+		tokensUsed += response.TokensUsed
+		return mods.DecodeLangBatch([]byte(response.ProcessedContent))
+	}
+
+	translateArchive := mods.TranslateArchive
+	switch {
+	case isFactorioJob(job):
+		translateArchive = mods.TranslateFactorioMod
+	case job.ModType == string(mods.GameTypeStardew):
+		translateArchive = mods.TranslateStardewMods
+	case bukkit:
+		translateArchive = mods.TranslateBukkitMessages
+	}
+	output, report, err := translateArchive(content, locales, translate)
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("Translation failed: %v", err))
+		return
+	}
+
+	format, err := mods.FormatFor(jobFilename(job), output)
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("Translation produced an unreadable file: %v", err))
+		return
+	}
+	output, notes, err := packageArchive(format, output)
+	if err != nil {
+		h.updateJobStatus(job.ID, "failed", fmt.Sprintf("Failed to repackage archive: %v", err))
+		return
+	}
+
+	changelog := fmt.Sprintf("Translated %d strings into %s", report.Translated, strings.Join(locales, ", "))
+	if len(report.Files) > 0 {
+		changelog += "\n- Wrote " + strings.Join(report.Files, "\n- Wrote ")
+	}
+	for _, issue := range report.Issues {
+		changelog += fmt.Sprintf("\n- Skipped %s %s: %s", issue.Locale, issue.Key, issue.Reason)
+	}
+	for _, note := range notes {
+		changelog += "\n- " + note
+	}
+
+	h.completeJob(ctx, job, output, format, tokensUsed, credits, changelog)
+}
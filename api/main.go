@@ -10,6 +10,7 @@ import (
 	"modforge.ai/api/middleware"
 	"modforge.ai/api/routes"
 	"modforge.ai/api/storage"
+	"modforge.ai/scanner"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -52,6 +53,9 @@ func main() {
 	// Initialize AI client
 	aiClient := ai.NewClient(cfg.OpenAIAPIKey)
 
+	// Initialize upload scanning (VirusTotal is used when configured)
+	scanService := scanner.New(cfg.VirusTotalKey)
+
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
 		ErrorHandler: middleware.ErrorHandler,
@@ -66,7 +70,7 @@ func main() {
 	}))
 
 	// Initialize routes
-	routes.Setup(app, db, cfg, storageClient, aiClient)
+	routes.Setup(app, db, cfg, storageClient, aiClient, scanService)
 
 	// Start server
 	port := os.Getenv("PORT")
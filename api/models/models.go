@@ -1,6 +1,9 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -21,23 +24,81 @@ type User struct {
 
 // Job represents a mod processing job (alias for ModJob for handler compatibility)
 type Job struct {
-	ID               string    `json:"id" db:"id"`
-	UserID           string    `json:"user_id" db:"user_id"`
-	Status           string    `json:"status" db:"status"`
-	ModType          string    `json:"mod_type" db:"game_type"` // Map to game_type in DB
-	OriginalFilename *string   `json:"original_filename,omitempty" db:"original_filename"`
-	OriginalFileSize *int64    `json:"original_file_size,omitempty" db:"original_file_size"`
-	OriginalURL      string    `json:"original_url" db:"original_file_url"`
-	ProcessedURL     *string   `json:"processed_url,omitempty" db:"processed_file_url"`
-	PresetType       *string   `json:"preset_type,omitempty" db:"preset_type"`
-	AIPrompt         *string   `json:"ai_prompt,omitempty" db:"ai_prompt"`
-	AIResponse       *string   `json:"ai_response,omitempty" db:"ai_response"`
-	Changelog        *string   `json:"changelog,omitempty" db:"changelog"`
-	TokensUsed       *int      `json:"tokens_used,omitempty" db:"tokens_used"`
-	CreditsUsed      *int      `json:"credits_used,omitempty" db:"credits_used"`
-	ErrorMessage     *string   `json:"error_message,omitempty" db:"error_message"`
-	CreatedAt        time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+	ID                    string    `json:"id" db:"id"`
+	UserID                string    `json:"user_id" db:"user_id"`
+	Status                string    `json:"status" db:"status"`
+	ModType               string    `json:"mod_type" db:"game_type"` // Map to game_type in DB
+	OriginalFilename      *string   `json:"original_filename,omitempty" db:"original_filename"`
+	OriginalFileSize      *int64    `json:"original_file_size,omitempty" db:"original_file_size"`
+	OriginalURL           string    `json:"original_url" db:"original_file_url"`
+	ProcessedURL          *string   `json:"processed_url,omitempty" db:"processed_file_url"`
+	PresetType            *string   `json:"preset_type,omitempty" db:"preset_type"`
+	AIPrompt              *string   `json:"ai_prompt,omitempty" db:"ai_prompt"`
+	AIResponse            *string   `json:"ai_response,omitempty" db:"ai_response"`
+	Changelog             *string   `json:"changelog,omitempty" db:"changelog"`
+	TokensUsed            *int      `json:"tokens_used,omitempty" db:"tokens_used"`
+	CreditsUsed           *int      `json:"credits_used,omitempty" db:"credits_used"`
+	ErrorMessage          *string   `json:"error_message,omitempty" db:"error_message"`
+	ScanFindings          JSONText  `json:"scan_findings,omitempty" db:"scan_findings"`
+	SHA1                  *string   `json:"sha1,omitempty" db:"sha1"`
+	SHA512                *string   `json:"sha512,omitempty" db:"sha512"`
+	CurseForgeFingerprint *int64    `json:"curseforge_fingerprint,omitempty" db:"curseforge_fingerprint"`
+	ChangeSelections      JSONText  `json:"change_selections,omitempty" db:"change_selections"`   // change ID to accepted
+	ReviewedURL           *string   `json:"reviewed_url,omitempty" db:"reviewed_file_url"`        // built from the accepted changes
+	Ecosystem             *string   `json:"ecosystem,omitempty" db:"ecosystem"`                   // Lua modding ecosystem, e.g. factorio
+	EcosystemMetadata     JSONText  `json:"ecosystem_metadata,omitempty" db:"ecosystem_metadata"` // mods.EcosystemInfo
+	CreatedAt             time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// JSONText is a JSON document stored in a TEXT column. It is NULL when empty
+// and is embedded as JSON, not as a string, in API responses.
+type JSONText json.RawMessage
+
+// NewJSONText encodes v as JSONText
+func NewJSONText(v interface{}) (JSONText, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return JSONText(data), nil
+}
+
+// Value implements driver.Valuer
+func (j JSONText) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+// Scan implements sql.Scanner
+func (j *JSONText) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*j = nil
+	case string:
+		*j = JSONText(v)
+	case []byte:
+		*j = append(JSONText(nil), v...)
+	default:
+		return fmt.Errorf("cannot scan %T into JSONText", src)
+	}
+	return nil
+}
+
+// MarshalJSON embeds the stored document
+func (j JSONText) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+// UnmarshalJSON stores the raw document
+func (j *JSONText) UnmarshalJSON(data []byte) error {
+	*j = append((*j)[:0], data...)
+	return nil
 }
 
 // ModJob represents a mod processing job (legacy name, keeping for compatibility)
@@ -52,6 +113,8 @@ type ModPreset struct {
 	PromptTemplate string    `json:"prompt_template" db:"prompt_template"`
 	CreditCost     int       `json:"credit_cost" db:"credit_cost"`
 	IsActive       bool      `json:"is_active" db:"is_active"`
+	Ecosystem      *string   `json:"ecosystem,omitempty" db:"ecosystem"` // nil applies to every ecosystem
+	Rules          *string   `json:"rules,omitempty" db:"rules"`         // JSON rules applied without AI; nil for AI presets
 	CreatedAt      time.Time `json:"created_at" db:"created_at"`
 }
 
@@ -66,17 +129,25 @@ type UserSession struct {
 
 // Job status constants
 const (
-	JobStatusPending    = "pending"
-	JobStatusProcessing = "processing"
-	JobStatusCompleted  = "completed"
-	JobStatusFailed     = "failed"
+	JobStatusScanning    = "scanning" // waiting for the malware scan to finish
+	JobStatusPending     = "pending"
+	JobStatusProcessing  = "processing"
+	JobStatusCompleted   = "completed"
+	JobStatusFailed      = "failed"
+	JobStatusQuarantined = "quarantined" // held for review after the malware scan flagged it
 )
 
 // Game type constants
 const (
 	GameTypeMinecraft = "minecraft"
-	GameTypeSkyrim    = "skyrim"
+	GameTypeOblivion  = "oblivion"
+	GameTypeSkyrim    = "skyrim" // Skyrim Legendary Edition
+	GameTypeSkyrimSE  = "skyrim_se"
+	GameTypeFallout4  = "fallout4"
+	GameTypeStarfield = "starfield"
 	GameTypeLua       = "lua"
+	GameTypeStardew   = "stardew"
+	GameTypeBedrock   = "minecraft_bedrock"
 )
 
 // Plan constants
@@ -6,14 +6,15 @@ import (
 	"modforge.ai/api/database"
 	"modforge.ai/api/handlers"
 	"modforge.ai/api/storage"
+	"modforge.ai/scanner"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // Setup initializes all routes for the application
-func Setup(app *fiber.App, db *database.DB, cfg *config.Config, storageClient *storage.Client, aiClient *ai.Client) {
+func Setup(app *fiber.App, db *database.DB, cfg *config.Config, storageClient *storage.Client, aiClient *ai.Client, scanService *scanner.Service) {
 	// Initialize handlers
-	h := handlers.New(db, cfg, storageClient, aiClient)
+	h := handlers.New(db, cfg, storageClient, aiClient, scanService)
 
 	// Static file serving for local uploads (for MVP)
 	app.Static("/uploads", "./uploads")
@@ -44,8 +45,20 @@ func Setup(app *fiber.App, db *database.DB, cfg *config.Config, storageClient *s
 	mods.Post("/upload", h.UploadMod)
 	mods.Get("/jobs/:id", h.GetJobStatus)
 	mods.Post("/jobs/:id/process", h.ProcessMod)
+	mods.Post("/jobs/:id/compatibility", h.CheckCompatibility)
+	mods.Get("/jobs/:id/diff", h.GetJobDiff)
+	mods.Put("/jobs/:id/selections", h.UpdateChangeSelections)
+	mods.Post("/jobs/:id/build", h.BuildReviewedMod)
 	mods.Get("/jobs/:id/download", h.DownloadMod)
 	mods.Get("/jobs", h.GetUserJobs)
+	mods.Post("/dependencies", h.ResolveDependencies)
+
+	// Quarantine review (admins only)
+	admin := protected.Group("/admin", h.RequireAdmin)
+	admin.Get("/quarantine", h.GetQuarantinedJobs)
+	admin.Get("/quarantine/:id", h.ReviewQuarantinedJob)
+	admin.Post("/quarantine/:id/release", h.ReleaseQuarantinedJob)
+	admin.Delete("/quarantine/:id", h.DeleteQuarantinedJob)
 
 	// Mod presets
 	presets := v1.Group("/presets") // Public endpoints
@@ -4,13 +4,21 @@ import { Upload as UploadIcon, FileText, Settings, Wand2, Download, CheckCircle,
 import { useAuth } from '../contexts/AuthContext'
 import config from '../config'
 
+interface DetectionCandidate {
+  game_type: string
+  confidence: number
+}
+
 interface UploadedFile {
   file: File
   jobId?: string
-  status?: 'pending' | 'processing' | 'completed' | 'failed'
+  status?: 'pending' | 'scanning' | 'processing' | 'completed' | 'failed' | 'needs_game_type' | 'quarantined'
   modType?: string
   errorMessage?: string
   processedUrl?: string
+  candidates?: DetectionCandidate[]
+  gameTypes?: string[]
+  chosenGameType?: string
 }
 
 interface Preset {
@@ -43,57 +51,94 @@ const Upload: React.FC = () => {
     }
   }
 
-  const onDrop = useCallback(async (acceptedFiles: File[]) => {
-    
-    for (const file of acceptedFiles) {
-      // Add file to state immediately
-      setUploadedFiles(prev => [...prev, { file, status: 'pending' }])
-      
-      try {
-        // Upload file
-        const formData = new FormData()
-        formData.append('mod_file', file)
-        
-        const response = await fetch(`${config.apiUrl}/api/v1/mods/upload`, {
-          method: 'POST',
-          headers: {
-            'Authorization': `Bearer ${token}`,
-          },
-          body: formData,
-        })
-        
-        const result = await response.json()
-        
-        if (response.ok) {
-          // Update file with job info
-          setUploadedFiles(prev => 
-            prev.map(f => 
-              f.file === file 
-                ? { ...f, jobId: result.job_id, status: result.status, modType: result.mod_type }
-                : f
-            )
-          )
-        } else {
-          setUploadedFiles(prev => 
-            prev.map(f => 
-              f.file === file 
-                ? { ...f, status: 'failed', errorMessage: result.error }
-                : f
-            )
+  const uploadFile = async (file: File, gameType?: string) => {
+    try {
+      // Upload file
+      const formData = new FormData()
+      formData.append('mod_file', file)
+      if (gameType) {
+        formData.append('game_type', gameType)
+      }
+
+      const response = await fetch(`${config.apiUrl}/api/v1/mods/upload`, {
+        method: 'POST',
+        headers: {
+          'Authorization': `Bearer ${token}`,
+        },
+        body: formData,
+      })
+
+      const result = await response.json()
+
+      if (response.ok) {
+        // Update file with job info
+        setUploadedFiles(prev =>
+          prev.map(f =>
+            f.file === file
+              ? {
+                  ...f,
+                  jobId: result.job_id,
+                  status: result.status,
+                  modType: result.mod_type,
+                  errorMessage: result.status === 'quarantined' ? result.message : undefined,
+                }
+              : f
           )
+        )
+
+        // Uploads are scanned for malware before they can be processed
+        if (result.status === 'scanning') {
+          pollJobStatus(result.job_id)
         }
-      } catch (error) {
-        console.error('Upload failed:', error)
-        setUploadedFiles(prev => 
-          prev.map(f => 
-            f.file === file 
-              ? { ...f, status: 'failed', errorMessage: 'Upload failed' }
+      } else if (result.requires_game_type) {
+        // Detection was not confident, ask the user to pick the game
+        setUploadedFiles(prev =>
+          prev.map(f =>
+            f.file === file
+              ? {
+                  ...f,
+                  status: 'needs_game_type',
+                  candidates: result.candidates,
+                  gameTypes: result.game_types,
+                  chosenGameType: result.candidates?.[0]?.game_type,
+                }
+              : f
+          )
+        )
+      } else {
+        setUploadedFiles(prev =>
+          prev.map(f =>
+            f.file === file
+              ? { ...f, status: 'failed', errorMessage: result.error }
               : f
           )
         )
       }
+    } catch (error) {
+      console.error('Upload failed:', error)
+      setUploadedFiles(prev =>
+        prev.map(f =>
+          f.file === file
+            ? { ...f, status: 'failed', errorMessage: 'Upload failed' }
+            : f
+        )
+      )
     }
-  }, [])
+  }
+
+  const onDrop = useCallback(async (acceptedFiles: File[]) => {
+    for (const file of acceptedFiles) {
+      // Add file to state immediately
+      setUploadedFiles(prev => [...prev, { file, status: 'pending' }])
+      await uploadFile(file)
+    }
+  }, [token])
+
+  const chooseGameType = (fileIndex: number, gameType: string) => {
+    setUploadedFiles(prev =>
+      prev.map((f, i) => (i === fileIndex ? { ...f, chosenGameType: gameType } : f))
+    )
+  }
 
   const processWithAI = async (fileIndex: number) => {
     const file = uploadedFiles[fileIndex]
@@ -128,7 +173,7 @@ const Upload: React.FC = () => {
         )
         
         // Poll for completion (in a real app, use WebSocket)
-        pollJobStatus(file.jobId!, fileIndex)
+        pollJobStatus(file.jobId!)
       } else {
         setUploadedFiles(prev => 
           prev.map((f, i) => 
@@ -145,7 +190,7 @@ const Upload: React.FC = () => {
     setIsProcessing(false)
   }
 
-  const pollJobStatus = async (jobId: string, fileIndex: number) => {
+  const pollJobStatus = async (jobId: string) => {
     const poll = async () => {
       try {
         const response = await fetch(`${config.apiUrl}/api/v1/mods/jobs/${jobId}`, {
@@ -156,19 +201,21 @@ const Upload: React.FC = () => {
         const job = await response.json()
         
         setUploadedFiles(prev => 
-          prev.map((f, i) => 
-            i === fileIndex 
+          prev.map(f => 
+            f.jobId === jobId 
               ? { 
                   ...f, 
                   status: job.status,
                   processedUrl: job.processed_url,
-                  errorMessage: job.error_message 
+                  errorMessage: job.status === 'quarantined'
+                    ? 'Quarantined: the malware scan flagged this file for review'
+                    : job.error_message 
                 }
               : f
           )
         )
         
-        if (job.status === 'processing') {
+        if (job.status === 'processing' || job.status === 'scanning') {
           setTimeout(poll, 3000) // Poll every 3 seconds
         }
       } catch (error) {
@@ -209,7 +256,8 @@ const Upload: React.FC = () => {
       'application/java-archive': ['.jar'],
       'application/zip': ['.zip'],
       'application/json': ['.json'],
-      'text/plain': ['.mcmeta']
+      'text/plain': ['.mcmeta', '.lua'],
+      'application/octet-stream': ['.esp', '.esm', '.esl']
     },
     maxSize: 100 * 1024 * 1024, // 100MB
   })
@@ -217,9 +265,11 @@ const Upload: React.FC = () => {
   const getStatusIcon = (status?: string) => {
     switch (status) {
       case 'pending': return <Clock className="w-5 h-5 text-yellow-500" />
+      case 'scanning': return <Clock className="w-5 h-5 text-blue-500" />
       case 'processing': return <Settings className="w-5 h-5 text-blue-500 animate-spin" />
       case 'completed': return <CheckCircle className="w-5 h-5 text-green-500" />
       case 'failed': return <AlertCircle className="w-5 h-5 text-red-500" />
+      case 'quarantined': return <AlertCircle className="w-5 h-5 text-orange-500" />
       default: return <FileText className="w-5 h-5 text-gray-500" />
     }
   }
@@ -228,7 +278,7 @@ const Upload: React.FC = () => {
     <div className="max-w-6xl mx-auto space-y-8">
       <div>
         <h1 className="text-3xl font-bold mb-2">Upload & Enhance Your Mods</h1>
-        <p className="text-gray-600">Upload .jar, .zip, .json, or .mcmeta files to enhance them with AI</p>
+        <p className="text-gray-600">Upload .jar, .zip, .json, .mcmeta, .lua, or Bethesda plugin files to enhance them with AI</p>
       </div>
 
       {/* Upload Area */}
@@ -251,7 +301,7 @@ const Upload: React.FC = () => {
                 Drag & drop your mod files here, or click to browse
               </p>
               <p className="text-sm text-gray-500">
-                Supports .jar, .zip, .json, .mcmeta files up to 100MB
+                Supports .jar, .zip, .json, .mcmeta, .lua, .esp, .esm and .esl files up to 100MB
               </p>
             </>
           )}
@@ -341,6 +391,33 @@ const Upload: React.FC = () => {
                   </div>
                 </div>
                 
+                {uploadedFile.status === 'needs_game_type' && (
+                  <div className="mt-2 p-2 bg-yellow-50 border border-yellow-200 rounded text-yellow-800 text-sm flex items-center gap-2">
+                    <span>We couldn't tell which game this mod is for. Please choose one:</span>
+                    <select
+                      value={uploadedFile.chosenGameType || ''}
+                      onChange={(e) => chooseGameType(index, e.target.value)}
+                      className="input w-auto"
+                    >
+                      {(uploadedFile.gameTypes || []).map(gameType => {
+                        const candidate = uploadedFile.candidates?.find(c => c.game_type === gameType)
+                        return (
+                          <option key={gameType} value={gameType}>
+                            {gameType}{candidate ? ` (${Math.round(candidate.confidence * 100)}% match)` : ''}
+                          </option>
+                        )
+                      })}
+                    </select>
+                    <button
+                      onClick={() => uploadFile(uploadedFile.file, uploadedFile.chosenGameType)}
+                      disabled={!uploadedFile.chosenGameType}
+                      className="btn-primary"
+                    >
+                      Upload
+                    </button>
+                  </div>
+                )}
+
                 {uploadedFile.errorMessage && (
                   <div className="mt-2 p-2 bg-red-50 border border-red-200 rounded text-red-700 text-sm">
                     {uploadedFile.errorMessage}
@@ -0,0 +1,2 @@
+-- Remove Bethesda game presets
+DELETE FROM mod_presets WHERE game_type IN ('oblivion', 'skyrim', 'skyrim_se', 'fallout4', 'starfield');
@@ -0,0 +1,13 @@
+-- Presets for each Bethesda game, since plugins are now detected per game
+INSERT INTO mod_presets (id, name, description, game_type, prompt_template, credit_cost) VALUES
+('oblivion_lore_friendly', 'Make Lore-Friendly', 'Rewrite names and descriptions to fit the lore of Cyrodiil', 'oblivion', 'Rewrite the display names and descriptions in the following Oblivion plugin records to fit the lore of Cyrodiil. Keep EditorIDs, FormIDs and record structure intact: {content}', 1),
+('oblivion_balance', 'Balance Items', 'Rebalance weapon, armor and spell values for Oblivion', 'oblivion', 'Rebalance the following Oblivion plugin records. Adjust damage, armor rating, weight, value and spell magnitudes to match vanilla Oblivion progression. Keep EditorIDs and FormIDs intact: {content}', 2),
+('skyrim_lore_friendly', 'Make Lore-Friendly', 'Rewrite names and descriptions to fit the lore of Skyrim', 'skyrim', 'Rewrite the display names and descriptions in the following Skyrim plugin records to fit the lore of Skyrim. Keep EditorIDs, FormIDs and record structure intact: {content}', 1),
+('skyrim_balance', 'Balance Items', 'Rebalance weapon, armor and perk values for Skyrim', 'skyrim', 'Rebalance the following Skyrim plugin records. Adjust damage, armor rating, weight and value to match vanilla Skyrim progression. Keep EditorIDs and FormIDs intact: {content}', 2),
+('skyrim_se_lore_friendly', 'Make Lore-Friendly', 'Rewrite names and descriptions to fit the lore of Skyrim', 'skyrim_se', 'Rewrite the display names and descriptions in the following Skyrim Special Edition plugin records to fit the lore of Skyrim. Keep EditorIDs, FormIDs and record structure intact: {content}', 1),
+('skyrim_se_balance', 'Balance Items', 'Rebalance weapon, armor and perk values for Skyrim Special Edition', 'skyrim_se', 'Rebalance the following Skyrim Special Edition plugin records. Adjust damage, armor rating, weight and value to match vanilla Skyrim progression including Creation Club content. Keep EditorIDs and FormIDs intact: {content}', 2),
+('fallout4_lore_friendly', 'Make Lore-Friendly', 'Rewrite names and descriptions to fit the Commonwealth', 'fallout4', 'Rewrite the display names and descriptions in the following Fallout 4 plugin records to fit the setting of the Commonwealth. Keep EditorIDs, FormIDs and record structure intact: {content}', 1),
+('fallout4_balance', 'Balance Items', 'Rebalance weapon damage, armor and legendary effects for Fallout 4', 'fallout4', 'Rebalance the following Fallout 4 plugin records. Adjust damage, damage resistance, weight, value and object modifications to match vanilla Fallout 4 progression. Keep EditorIDs and FormIDs intact: {content}', 2),
+('starfield_lore_friendly', 'Make Lore-Friendly', 'Rewrite names and descriptions to fit the Settled Systems', 'starfield', 'Rewrite the display names and descriptions in the following Starfield plugin records to fit the setting of the Settled Systems. Keep EditorIDs, FormIDs and record structure intact: {content}', 1),
+('starfield_balance', 'Balance Items', 'Rebalance weapons, spacesuits and ship parts for Starfield', 'starfield', 'Rebalance the following Starfield plugin records. Adjust damage, resistances, mass and value of weapons, spacesuits and ship modules to match vanilla Starfield progression. Keep EditorIDs and FormIDs intact: {content}', 2)
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,2 @@
+-- Remove malware scan findings
+ALTER TABLE mod_jobs DROP COLUMN scan_findings;
@@ -0,0 +1,2 @@
+-- Store malware scan findings on jobs
+ALTER TABLE mod_jobs ADD COLUMN scan_findings TEXT;
@@ -0,0 +1,5 @@
+-- Remove platform fingerprints
+DROP INDEX IF EXISTS idx_mod_jobs_sha512;
+ALTER TABLE mod_jobs DROP COLUMN curseforge_fingerprint;
+ALTER TABLE mod_jobs DROP COLUMN sha512;
+ALTER TABLE mod_jobs DROP COLUMN sha1;
@@ -0,0 +1,5 @@
+-- Store platform fingerprints on jobs so identical uploads can be recognized
+ALTER TABLE mod_jobs ADD COLUMN sha1 TEXT;
+ALTER TABLE mod_jobs ADD COLUMN sha512 TEXT;
+ALTER TABLE mod_jobs ADD COLUMN curseforge_fingerprint BIGINT;
+CREATE INDEX IF NOT EXISTS idx_mod_jobs_sha512 ON mod_jobs(sha512);
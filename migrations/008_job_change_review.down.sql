@@ -0,0 +1,3 @@
+-- Remove per-change review decisions
+ALTER TABLE mod_jobs DROP COLUMN reviewed_file_url;
+ALTER TABLE mod_jobs DROP COLUMN change_selections;
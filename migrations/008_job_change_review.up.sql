@@ -0,0 +1,3 @@
+-- Store per-change review decisions and the artifact built from them
+ALTER TABLE mod_jobs ADD COLUMN change_selections TEXT;
+ALTER TABLE mod_jobs ADD COLUMN reviewed_file_url TEXT;
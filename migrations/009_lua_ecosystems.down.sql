@@ -0,0 +1,4 @@
+-- Remove Lua ecosystems
+ALTER TABLE mod_presets DROP COLUMN ecosystem;
+ALTER TABLE mod_jobs DROP COLUMN ecosystem_metadata;
+ALTER TABLE mod_jobs DROP COLUMN ecosystem;
@@ -0,0 +1,4 @@
+-- Record the Lua modding ecosystem of jobs and scope presets to one
+ALTER TABLE mod_jobs ADD COLUMN ecosystem TEXT;
+ALTER TABLE mod_jobs ADD COLUMN ecosystem_metadata TEXT;
+ALTER TABLE mod_presets ADD COLUMN ecosystem TEXT;
@@ -0,0 +1,2 @@
+-- Remove Lua presets
+DELETE FROM mod_presets WHERE id IN ('lua_comment', 'lua_cleanup', 'factorio_balance', 'factorio_stack_size', 'gmod_balance', 'gmod_localize', 'wow_localize', 'wow_events', 'roblox_modernize', 'roblox_balance');
@@ -0,0 +1,13 @@
+-- Presets for Lua mods, generic ones plus one set per modding ecosystem
+INSERT INTO mod_presets (id, name, description, game_type, prompt_template, credit_cost, ecosystem) VALUES
+('lua_comment', 'Document Script', 'Add comments explaining what each function does', 'lua', 'Add concise comments explaining what each function in the following Lua script does. Do not change any code, function names or globals: {content}', 1, NULL),
+('lua_cleanup', 'Clean Up Script', 'Tidy formatting and replace globals with locals where safe', 'lua', 'Tidy the formatting of the following Lua script and make helper variables local where this does not change behavior. Keep every function and global that other scripts may use: {content}', 1, NULL),
+('factorio_balance', 'Balance Recipes', 'Rebalance recipe costs and crafting times for Factorio', 'lua', 'Rebalance the recipe ingredients, energy_required and result counts in the following Factorio data stage script to match vanilla Factorio progression. Keep prototype names and the data:extend structure intact: {content}', 2, 'factorio'),
+('factorio_stack_size', 'Increase Stack Sizes', 'Raise item stack sizes in Factorio prototypes', 'lua', 'Double the stack_size of every item prototype in the following Factorio data stage script. Keep prototype names and the data:extend structure intact: {content}', 1, 'factorio'),
+('gmod_balance', 'Balance Weapons', 'Rebalance SWEP damage, recoil and fire rate for Garry''s Mod', 'lua', 'Rebalance the SWEP.Primary and SWEP.Secondary values (damage, recoil, delay, clip size) in the following Garry''s Mod weapon script for fair multiplayer play. Keep hooks, networking and function names intact: {content}', 2, 'gmod'),
+('gmod_localize', 'Localize Strings', 'Move hard-coded text into language phrases for Garry''s Mod', 'lua', 'Replace hard-coded user-facing strings in the following Garry''s Mod script with language.Add phrases and language.GetPhrase lookups. Keep hooks, networking and function names intact: {content}', 1, 'gmod'),
+('wow_localize', 'Localize Addon', 'Move user-facing text into a locale table for a WoW addon', 'lua', 'Move the user-facing strings in the following World of Warcraft addon script into a locale table keyed by GetLocale(). Keep frame names, events, slash commands and saved variables intact: {content}', 1, 'wow'),
+('wow_events', 'Tidy Event Handling', 'Dispatch WoW events through a single handler table', 'lua', 'Restructure event handling in the following World of Warcraft addon script so a single OnEvent script dispatches to a table of handlers keyed by event name. Keep frame names, registered events and saved variables intact: {content}', 2, 'wow'),
+('roblox_modernize', 'Modernize APIs', 'Replace deprecated Roblox APIs with their current equivalents', 'lua', 'Replace deprecated Roblox APIs in the following script with their current equivalents, such as wait with task.wait, spawn with task.spawn and Instance.new parent arguments with explicit Parent assignment. Keep module exports and RemoteEvent names intact: {content}', 1, 'roblox'),
+('roblox_balance', 'Balance Gameplay', 'Rebalance damage, health and cooldown values in Roblox scripts', 'lua', 'Rebalance the damage, health, speed and cooldown values in the following Roblox script for fair play. Keep module exports, RemoteEvent names and Instance names intact: {content}', 2, 'roblox')
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,2 @@
+-- Remove Stardew Valley presets
+DELETE FROM mod_presets WHERE game_type = 'stardew';
@@ -0,0 +1,6 @@
+-- Presets for Stardew Valley SMAPI mods and Content Patcher packs
+INSERT INTO mod_presets (id, name, description, game_type, prompt_template, credit_cost) VALUES
+('stardew_balance', 'Balance Content', 'Rebalance prices, crop growth and item stats in Content Patcher edits', 'stardew', 'Rebalance the prices, crop growth times, regrowth days, edibility and item stats set by the following Content Patcher file to match vanilla Stardew Valley progression. Keep the Format, Action, Target, When conditions, entry keys and {{tokens}} intact: {content}', 2),
+('stardew_lore_friendly', 'Make Lore-Friendly', 'Rewrite names, descriptions and dialogue to fit Pelican Town', 'stardew', 'Rewrite the display names, descriptions and dialogue in the following Content Patcher file to fit the tone of Pelican Town. Keep entry keys, field indexes, dialogue commands such as $h and #$b#, and {{tokens}} intact: {content}', 1),
+('stardew_translate', 'Translate Mod', 'Translate i18n/default.json into other languages', 'stardew', 'Translate the following Stardew Valley i18n strings into {target_language}. Keep keys and {{tokens}} intact: {content}', 1)
+ON CONFLICT (id) DO NOTHING;
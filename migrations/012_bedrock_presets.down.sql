@@ -0,0 +1,2 @@
+-- Remove Minecraft Bedrock presets
+DELETE FROM mod_presets WHERE game_type = 'minecraft_bedrock';
@@ -0,0 +1,5 @@
+-- Presets for Minecraft Bedrock add-ons
+INSERT INTO mod_presets (id, name, description, game_type, prompt_template, credit_cost) VALUES
+('bedrock_balance', 'Balance Add-on', 'Rebalance entity health, damage, item durability and block hardness', 'minecraft_bedrock', 'Rebalance the health, attack damage, movement speed, durability, hardness and loot values in the following Minecraft Bedrock add-on file to match vanilla Bedrock progression. Keep format_version, identifiers, component names and event names intact: {content}', 2),
+('bedrock_lore_friendly', 'Make Lore-Friendly', 'Adjust components and behaviors to fit vanilla Minecraft', 'minecraft_bedrock', 'Adjust the components, spawn rules and behaviors in the following Minecraft Bedrock add-on file so they fit naturally alongside vanilla Minecraft. Keep format_version, identifiers and the JSON structure intact: {content}', 1)
+ON CONFLICT (id) DO NOTHING;
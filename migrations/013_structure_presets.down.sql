@@ -0,0 +1,2 @@
+-- Remove Minecraft structure presets
+DELETE FROM mod_presets WHERE id = 'minecraft_palette_swap';
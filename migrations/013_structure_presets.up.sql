@@ -0,0 +1,4 @@
+-- Presets for Minecraft structure files, which the AI edits as SNBT
+INSERT INTO mod_presets (id, name, description, game_type, prompt_template, credit_cost) VALUES
+('minecraft_palette_swap', 'Swap Block Palette', 'Swap the blocks of structure files to a different material theme', 'minecraft', 'The following is a Minecraft structure file written as SNBT. Swap the block palette to a consistent alternative material theme, such as oak to spruce or cobblestone to deepslate, by changing only the Name and Properties of entries in palette or palettes. Keep the number and order of palette entries, blocks, size, entities and DataVersion unchanged, and keep every number suffix such as 1b or 2L: {content}', 2)
+ON CONFLICT (id) DO NOTHING;
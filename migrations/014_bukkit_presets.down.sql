@@ -0,0 +1,2 @@
+-- Remove Bukkit plugin presets
+DELETE FROM mod_presets WHERE id IN ('bukkit_config_tune', 'bukkit_translate');
@@ -0,0 +1,5 @@
+-- Presets for Bukkit, Spigot and Paper plugins, which edit the bundled config.yml and messages.yml
+INSERT INTO mod_presets (id, name, description, game_type, prompt_template, credit_cost) VALUES
+('bukkit_config_tune', 'Tune Plugin Config', 'Adjust the values of a plugin''s config.yml for a typical survival server', 'minecraft', 'The following is a configuration file bundled with a Minecraft server plugin. Adjust its values to suit a typical survival server, such as cooldowns, prices, limits and toggles. Change values only: keep every key, comment, indentation and quote style, and keep color codes such as &a and placeholders such as %player% intact: {content}', 1),
+('bukkit_translate', 'Translate Plugin Messages', 'Translate messages.yml into other languages', 'minecraft', 'Translate the player-facing messages in the following Minecraft server plugin file into {target_language}. Keep keys, color codes such as &a and &#ff0000, MiniMessage tags and placeholders such as %player% and {0} intact: {content}', 1)
+ON CONFLICT (id) DO NOTHING;
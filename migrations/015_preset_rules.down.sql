@@ -0,0 +1,2 @@
+-- Remove preset rules
+ALTER TABLE mod_presets DROP COLUMN rules;
@@ -0,0 +1,2 @@
+-- Let presets carry declarative rules that are applied without AI
+ALTER TABLE mod_presets ADD COLUMN rules TEXT;
@@ -0,0 +1,2 @@
+-- Remove rule presets
+DELETE FROM mod_presets WHERE id IN ('rules_durability_boost', 'rules_double_recipe_output', 'rules_halve_spawn_weights');
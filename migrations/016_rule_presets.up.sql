@@ -0,0 +1,6 @@
+-- Presets applied by the rule engine, which use no AI tokens
+INSERT INTO mod_presets (id, name, description, game_type, prompt_template, credit_cost, rules) VALUES
+('rules_durability_boost', 'Boost Durability x1.5', 'Multiply every durability and max_damage value by 1.5 without AI', 'minecraft', '', 0, '[{"path": "**.durability", "op": "multiply", "value": 1.5}, {"path": "**.max_damage", "op": "multiply", "value": 1.5}]'),
+('rules_double_recipe_output', 'Double Recipe Output', 'Double the result count of every recipe with an item result, counting a missing count as 1, without AI', 'minecraft', '', 0, '[{"files": "data/*/recipe/**/*.json", "path": "result.count", "op": "multiply", "value": 2, "default": 1}, {"files": "data/*/recipes/**/*.json", "path": "result.count", "op": "multiply", "value": 2, "default": 1}]'),
+('rules_halve_spawn_weights', 'Halve Mob Spawn Weights', 'Halve biome spawn weights, keeping every mob able to spawn, without AI', 'minecraft', '', 0, '[{"files": "data/*/worldgen/biome/**/*.json", "path": "spawners.*[*].weight", "op": "multiply", "value": 0.5}, {"files": "data/*/worldgen/biome/**/*.json", "path": "spawners.*[*].weight", "op": "max", "value": 1}, {"files": "data/*/*/biome_modifier/**/*.json", "path": "**.weight", "op": "multiply", "value": 0.5}, {"files": "data/*/*/biome_modifier/**/*.json", "path": "**.weight", "op": "max", "value": 1}]')
+ON CONFLICT (id) DO NOTHING;
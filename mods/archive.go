@@ -0,0 +1,547 @@
+package mods
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ArchiveLimits bound the work done when reading an untrusted archive
+type ArchiveLimits struct {
+	MaxTotalSize  int64   // uncompressed bytes across all entries, including nested archives
+	MaxEntries    int     // entries in a single archive
+	MaxRatio      float64 // uncompressed to compressed size of a single entry
+	MaxDepth      int     // levels of archives nested inside archives
+	MaxPathLength int     // bytes in an entry name
+}
+
+// DefaultArchiveLimits are applied to every uploaded jar and zip
+var DefaultArchiveLimits = ArchiveLimits{
+	MaxTotalSize:  512 * 1024 * 1024,
+	MaxEntries:    20000,
+	MaxRatio:      100,
+	MaxDepth:      3,
+	MaxPathLength: 512,
+}
+
+// minRatioCheckSize exempts small entries, which can legitimately compress
+// far better than the ratio limit
+const minRatioCheckSize = 1024 * 1024
+
+// ArchiveViolation is the reason an archive was rejected
+type ArchiveViolation string
+
+// Archive violations
+const (
+	ViolationCorrupt          ArchiveViolation = "corrupt"
+	ViolationTotalSize        ArchiveViolation = "total_size"
+	ViolationEntryCount       ArchiveViolation = "entry_count"
+	ViolationCompressionRatio ArchiveViolation = "compression_ratio"
+	ViolationNestingDepth     ArchiveViolation = "nesting_depth"
+	ViolationPathLength       ArchiveViolation = "path_length"
+	ViolationPathTraversal    ArchiveViolation = "path_traversal"
+	ViolationAbsolutePath     ArchiveViolation = "absolute_path"
+)
+
+// ArchiveError reports an archive that breaks the extraction limits
+type ArchiveError struct {
+	Reason ArchiveViolation `json:"reason"`
+	Entry  string           `json:"entry,omitempty"`
+	Detail string           `json:"detail"`
+}
+
+func (e *ArchiveError) Error() string {
+	if e.Entry == "" {
+		return fmt.Sprintf("archive rejected (%s): %s", e.Reason, e.Detail)
+	}
+	return fmt.Sprintf("archive rejected (%s): %s: %s", e.Reason, e.Entry, e.Detail)
+}
+
+// Archive is a zip or jar opened through the extraction limits. Nested
+// archives share the uncompressed size budget of the archive they came from.
+type Archive struct {
+	reader *zip.Reader
+	limits ArchiveLimits
+	path   string
+	depth  int
+	budget *int64
+}
+
+// OpenArchive checks an archive's directory against the limits. Entry
+// contents are checked as they are read.
+func OpenArchive(content []byte, limits ArchiveLimits) (*Archive, error) {
+	budget := limits.MaxTotalSize
+	return openArchive(content, limits, "", 0, &budget)
+}
+
+func openArchive(content []byte, limits ArchiveLimits, path string, depth int, budget *int64) (*Archive, error) {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, &ArchiveError{Reason: ViolationCorrupt, Entry: path, Detail: err.Error()}
+	}
+	if len(reader.File) > limits.MaxEntries {
+		return nil, &ArchiveError{Reason: ViolationEntryCount, Entry: path, Detail: fmt.Sprintf("%d entries exceeds the limit of %d", len(reader.File), limits.MaxEntries)}
+	}
+
+	var declared uint64
+	for _, f := range reader.File {
+		name := path + f.Name
+		if err := checkEntryName(f.Name, limits); err != nil {
+			err.Entry = name
+			return nil, err
+		}
+		declared += f.UncompressedSize64
+		if declared > uint64(*budget) {
+			return nil, &ArchiveError{Reason: ViolationTotalSize, Entry: name, Detail: fmt.Sprintf("declared size exceeds the limit of %dMB", limits.MaxTotalSize/(1024*1024))}
+		}
+		if exceedsRatio(f.UncompressedSize64, f.CompressedSize64, limits) {
+			return nil, &ArchiveError{Reason: ViolationCompressionRatio, Entry: name, Detail: fmt.Sprintf("compression ratio exceeds %.0f:1", limits.MaxRatio)}
+		}
+	}
+
+	return &Archive{reader: reader, limits: limits, path: path, depth: depth, budget: budget}, nil
+}
+
+// checkEntryName rejects names that would escape the extraction directory
+func checkEntryName(name string, limits ArchiveLimits) *ArchiveError {
+	if len(name) > limits.MaxPathLength {
+		return &ArchiveError{Reason: ViolationPathLength, Detail: fmt.Sprintf("name is longer than %d bytes", limits.MaxPathLength)}
+	}
+	normalized := strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(normalized, "/") || (len(normalized) >= 2 && normalized[1] == ':') {
+		return &ArchiveError{Reason: ViolationAbsolutePath, Detail: "entry name is an absolute path"}
+	}
+	for _, segment := range strings.Split(normalized, "/") {
+		if segment == ".." {
+			return &ArchiveError{Reason: ViolationPathTraversal, Detail: "entry name escapes the archive root"}
+		}
+	}
+	return nil
+}
+
+func exceedsRatio(uncompressed, compressed uint64, limits ArchiveLimits) bool {
+	if uncompressed < minRatioCheckSize {
+		return false
+	}
+	return compressed == 0 || float64(uncompressed)/float64(compressed) > limits.MaxRatio
+}
+
+// Files returns the archive's entries in directory order
+func (a *Archive) Files() []*zip.File {
+	return a.reader.File
+}
+
+// File returns the named entry, or nil if it is absent
+func (a *Archive) File(name string) *zip.File {
+	for _, f := range a.reader.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// ReadFile decompresses an entry, charging it to the size budget. Declared
+// sizes are not trusted: reading stops as soon as a limit is crossed.
+func (a *Archive) ReadFile(f *zip.File) ([]byte, error) {
+	name := a.path + f.Name
+	rc, err := f.Open()
+	if err != nil {
+		return nil, &ArchiveError{Reason: ViolationCorrupt, Entry: name, Detail: err.Error()}
+	}
+	defer rc.Close()
+
+	limit := min(*a.budget, int64(f.UncompressedSize64))
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, &ArchiveError{Reason: ViolationCorrupt, Entry: name, Detail: err.Error()}
+	}
+	if int64(len(data)) > limit {
+		if limit == *a.budget {
+			return nil, &ArchiveError{Reason: ViolationTotalSize, Entry: name, Detail: fmt.Sprintf("extracted size exceeds the limit of %dMB", a.limits.MaxTotalSize/(1024*1024))}
+		}
+		return nil, &ArchiveError{Reason: ViolationCorrupt, Entry: name, Detail: "entry is larger than its declared size"}
+	}
+	if exceedsRatio(uint64(len(data)), f.CompressedSize64, a.limits) {
+		return nil, &ArchiveError{Reason: ViolationCompressionRatio, Entry: name, Detail: fmt.Sprintf("compression ratio exceeds %.0f:1", a.limits.MaxRatio)}
+	}
+
+	*a.budget -= int64(len(data))
+	return data, nil
+}
+
+// ReadFileNamed reads the named entry, returning nil if it is absent
+func (a *Archive) ReadFileNamed(name string) ([]byte, error) {
+	f := a.File(name)
+	if f == nil {
+		return nil, nil
+	}
+	return a.ReadFile(f)
+}
+
+// OpenNested opens an archive stored as an entry of this one
+func (a *Archive) OpenNested(f *zip.File) (*Archive, error) {
+	name := a.path + f.Name
+	if a.depth+1 > a.limits.MaxDepth {
+		return nil, &ArchiveError{Reason: ViolationNestingDepth, Entry: name, Detail: fmt.Sprintf("archives are nested more than %d deep", a.limits.MaxDepth)}
+	}
+	data, err := a.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+	return openArchive(data, a.limits, name+"!/", a.depth+1, a.budget)
+}
+
+// ScanArchive reads every entry of an archive, and of every archive nested in
+// it, enforcing the limits throughout
+func ScanArchive(content []byte, limits ArchiveLimits) error {
+	archive, err := OpenArchive(content, limits)
+	if err != nil {
+		return err
+	}
+	return archive.scan()
+}
+
+func (a *Archive) scan() error {
+	for _, f := range a.reader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if isNestedArchive(f.Name) {
+			nested, err := a.OpenNested(f)
+			if err != nil {
+				return err
+			}
+			if err := nested.scan(); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := a.ReadFile(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isNestedArchive reports whether an entry name is a jar, zip or Bedrock pack
+func isNestedArchive(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".jar") || strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".mcpack")
+}
+
+// maxEditedEntries caps how many entries of an archive are sent for editing
+const maxEditedEntries = 500
+
+// isSignatureFile reports whether an entry is part of a jar signature, which
+// any edit to the jar invalidates
+func isSignatureFile(name string) bool {
+	upper := strings.ToUpper(name)
+	if !strings.HasPrefix(upper, "META-INF/") || strings.Count(upper, "/") != 1 {
+		return false
+	}
+	base := strings.TrimPrefix(upper, "META-INF/")
+	for _, ext := range []string{".SF", ".RSA", ".DSA", ".EC"} {
+		if strings.HasSuffix(base, ext) {
+			return true
+		}
+	}
+	return strings.HasPrefix(base, "SIG-")
+}
+
+// RewriteArchive builds a new archive from the original and a set of changed
+// entries. Entry order, timestamps, compression methods and the archive
+// comment are kept, and unchanged entries are copied without recompression.
+// Changed entries that do not exist yet are appended, and a nil change
+// removes an entry. When anything changes, signature files are dropped and
+// the manifest loses its per-entry digests.
+func RewriteArchive(content []byte, changes map[string][]byte) ([]byte, error) {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, &ArchiveError{Reason: ViolationCorrupt, Detail: err.Error()}
+	}
+	for name := range changes {
+		if err := checkEntryName(name, DefaultArchiveLimits); err != nil {
+			err.Entry = name
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	if err := writer.SetComment(reader.Comment); err != nil {
+		return nil, err
+	}
+
+	written := make(map[string]bool, len(reader.File))
+	for _, f := range reader.File {
+		written[f.Name] = true
+		data, changed := changes[f.Name]
+		if changed && data == nil {
+			continue
+		}
+
+		if len(changes) > 0 && !changed {
+			if isSignatureFile(f.Name) {
+				continue
+			}
+			if strings.EqualFold(f.Name, "META-INF/MANIFEST.MF") {
+				manifest, err := readEntry(f)
+				if err != nil {
+					return nil, err
+				}
+				data, changed = stripManifestDigests(manifest), true
+			}
+		}
+
+		if !changed {
+			if err := writer.Copy(f); err != nil {
+				return nil, fmt.Errorf("failed to copy %s: %w", f.Name, err)
+			}
+			continue
+		}
+		header := entryHeader(f, f.Name)
+		if err := writeEntry(writer, &header, data); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range sortedKeys(changes) {
+		if written[name] || changes[name] == nil {
+			continue
+		}
+		header := zip.FileHeader{Name: name, Method: zip.Deflate}
+		if err := writeEntry(writer, &header, changes[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenameArchive builds a new archive with every entry renamed by rename,
+// which returns false to leave an entry out. Entry data is copied without
+// recompression.
+func RenameArchive(content []byte, rename func(name string) (string, bool)) ([]byte, error) {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, &ArchiveError{Reason: ViolationCorrupt, Detail: err.Error()}
+	}
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	if err := writer.SetComment(reader.Comment); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(reader.File))
+	for _, f := range reader.File {
+		name, ok := rename(f.Name)
+		if !ok {
+			continue
+		}
+		if err := checkEntryName(name, DefaultArchiveLimits); err != nil {
+			err.Entry = name
+			return nil, err
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("two entries would be named %s", name)
+		}
+		seen[name] = true
+
+		raw, err := f.OpenRaw()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+		}
+		// Raw data keeps its checksum and sizes
+		header := entryHeader(f, name)
+		header.CRC32 = f.CRC32
+		header.CompressedSize64 = f.CompressedSize64
+		header.UncompressedSize64 = f.UncompressedSize64
+		w, err := writer.CreateRaw(&header)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if _, err := io.Copy(w, raw); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// entryHeader starts a fresh header for an entry being written again. Extra
+// fields are left out because the writer adds its own timestamp field.
+func entryHeader(f *zip.File, name string) zip.FileHeader {
+	return zip.FileHeader{
+		Name:           name,
+		Method:         f.Method,
+		Modified:       f.Modified,
+		ModifiedTime:   f.ModifiedTime,
+		ModifiedDate:   f.ModifiedDate,
+		CreatorVersion: f.CreatorVersion, // says how to read ExternalAttrs
+		ExternalAttrs:  f.ExternalAttrs,
+	}
+}
+
+func writeEntry(writer *zip.Writer, header *zip.FileHeader, data []byte) error {
+	w, err := writer.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", header.Name, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", header.Name, err)
+	}
+	return nil
+}
+
+// readEntry reads an entry of an archive the caller has already validated
+func readEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// stripManifestDigests removes the per-entry digest attributes a signed jar's
+// manifest carries, dropping sections left with nothing but a Name. The main
+// section is kept as is.
+func stripManifestDigests(manifest []byte) []byte {
+	text := strings.ReplaceAll(string(manifest), "\r\n", "\n")
+	sections := strings.Split(text, "\n\n")
+
+	var kept []string
+	for i, section := range sections {
+		if i == 0 || strings.TrimSpace(section) == "" {
+			kept = append(kept, section)
+			continue
+		}
+
+		// Attributes may continue onto lines starting with a space
+		var attributes []string
+		for _, line := range strings.Split(section, "\n") {
+			if strings.HasPrefix(line, " ") && len(attributes) > 0 {
+				attributes[len(attributes)-1] += "\n" + line
+				continue
+			}
+			attributes = append(attributes, line)
+		}
+
+		var remaining []string
+		for _, attribute := range attributes {
+			key, _, _ := strings.Cut(attribute, ":")
+			if strings.HasSuffix(strings.ToLower(key), "-digest") {
+				continue
+			}
+			remaining = append(remaining, attribute)
+		}
+		if len(remaining) == 1 && strings.HasPrefix(remaining[0], "Name:") {
+			continue
+		}
+		kept = append(kept, strings.Join(remaining, "\n"))
+	}
+
+	out := strings.Join(kept, "\n\n")
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	if bytes.Contains(manifest, []byte("\r\n")) {
+		out = strings.ReplaceAll(out, "\n", "\r\n")
+	}
+	return []byte(out)
+}
+
+// EditFunc returns the edited content of one archive entry
+type EditFunc func(name string, content []byte) ([]byte, error)
+
+// isLoaderEntry reports whether an archive entry is read by the mod loader
+// rather than the game: descriptors such as fabric.mod.json, mixin configs and
+// their refmaps. Editing one breaks loading, so EditArchive skips them.
+func isLoaderEntry(name string, mixinConfigs map[string]bool) bool {
+	if _, ok := minecraftDescriptors[name]; ok {
+		return true
+	}
+	base := strings.ToLower(path.Base(name))
+	return mixinConfigs[name] ||
+		strings.HasSuffix(base, ".mixins.json") ||
+		(strings.HasPrefix(base, "mixins.") && strings.HasSuffix(base, ".json")) ||
+		strings.HasSuffix(base, "refmap.json")
+}
+
+// EditArchive passes every editable entry of an archive to edit and rebuilds
+// the archive from the results. Entries are editable when a registered format
+// can rewrite them, and binary ones are edited in their decoded text form;
+// each edit must pass that format's Rewrite. Descriptors, mixin configs and
+// refmaps are never edited. It returns the new archive and the names of the
+// entries that changed.
+func EditArchive(content []byte, edit EditFunc) ([]byte, []string, error) {
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mixinConfigs := make(map[string]bool)
+	for _, name := range mixinConfigNames(archive) {
+		mixinConfigs[name] = true
+	}
+	changes := make(map[string][]byte)
+	var changed []string
+	edited := 0
+	for _, f := range archive.Files() {
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "META-INF/") || isLoaderEntry(f.Name, mixinConfigs) {
+			continue
+		}
+		candidates := FormatsForExtension(f.Name)
+		if len(candidates) == 0 || !candidates[0].Info().Editable {
+			continue
+		}
+
+		data, err := archive.ReadFile(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		format, err := FormatFor(f.Name, data)
+		if err != nil || !format.Info().Editable {
+			continue
+		}
+		if edited++; edited > maxEditedEntries {
+			return nil, nil, fmt.Errorf("archive has more than %d editable files", maxEditedEntries)
+		}
+
+		editable, err := EditableContent(format, data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		result, err := edit(f.Name, editable)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to edit %s: %w", f.Name, err)
+		}
+		output, err := format.Rewrite(data, result)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		if !bytes.Equal(output, data) {
+			changes[f.Name] = output
+			changed = append(changed, f.Name)
+		}
+	}
+
+	if len(changes) == 0 {
+		return content, nil, nil
+	}
+	output, err := RewriteArchive(content, changes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return output, changed, nil
+}
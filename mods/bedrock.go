@@ -0,0 +1,700 @@
+package mods
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Bedrock pack kinds, from the module types in a pack's manifest
+const (
+	BedrockBehaviorPack  = "behavior_pack"  // data and script modules
+	BedrockResourcePack  = "resource_pack"  // resources modules
+	BedrockSkinPack      = "skin_pack"      // skin_pack modules
+	BedrockWorldTemplate = "world_template" // world_template modules
+)
+
+// bedrockModuleTypes maps each manifest module type to the pack kind it makes
+var bedrockModuleTypes = map[string]string{
+	"data":           BedrockBehaviorPack,
+	"script":         BedrockBehaviorPack,
+	"javascript":     BedrockBehaviorPack,
+	"client_data":    BedrockBehaviorPack,
+	"resources":      BedrockResourcePack,
+	"interface":      BedrockResourcePack,
+	"skin_pack":      BedrockSkinPack,
+	"world_template": BedrockWorldTemplate,
+}
+
+// bedrockDefinitions maps the top-level key of an entity, item or block file
+// to the kind of identifier it defines
+var bedrockDefinitions = map[string]string{
+	"minecraft:entity":        "entity",
+	"minecraft:client_entity": "client_entity",
+	"minecraft:item":          "item",
+	"minecraft:block":         "block",
+	"minecraft:attachable":    "attachable",
+}
+
+// newBedrockUUID generates the UUIDs of derived packs
+var newBedrockUUID = uuid.NewString
+
+// BedrockVersion is a major, minor, patch version triple
+type BedrockVersion [3]int
+
+func (v BedrockVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+// Bump returns the version with its patch number raised by one
+func (v BedrockVersion) Bump() BedrockVersion {
+	return BedrockVersion{v[0], v[1], v[2] + 1}
+}
+
+// parseBedrockVersion reads a version written as [1, 2, 3] or, from
+// format_version 3, as "1.2.3" with an optional pre-release suffix
+func parseBedrockVersion(v interface{}) (BedrockVersion, error) {
+	var version BedrockVersion
+	switch val := v.(type) {
+	case []interface{}:
+		if len(val) != 3 {
+			return version, fmt.Errorf("version %v must have three numbers", val)
+		}
+		for i, part := range val {
+			n, err := strconv.Atoi(fmt.Sprint(part))
+			if err != nil || n < 0 {
+				return version, fmt.Errorf("version %v must have three numbers", val)
+			}
+			version[i] = n
+		}
+	case string:
+		core, _, _ := strings.Cut(val, "-")
+		parts := strings.Split(core, ".")
+		if len(parts) != 3 {
+			return version, fmt.Errorf("version %q must be major.minor.patch", val)
+		}
+		for i, part := range parts {
+			n, err := strconv.Atoi(part)
+			if err != nil || n < 0 {
+				return version, fmt.Errorf("version %q must be major.minor.patch", val)
+			}
+			version[i] = n
+		}
+	default:
+		return version, fmt.Errorf("version is missing")
+	}
+	return version, nil
+}
+
+// BedrockModule is one module of a pack's manifest
+type BedrockModule struct {
+	Type     string         `json:"type"`
+	UUID     string         `json:"uuid"`
+	Version  BedrockVersion `json:"version"`
+	Entry    string         `json:"entry,omitempty"`    // script modules
+	Language string         `json:"language,omitempty"` // script modules
+}
+
+// BedrockDependency is a pack a manifest depends on by UUID, or a script API
+// module by name such as @minecraft/server
+type BedrockDependency struct {
+	UUID       string `json:"uuid,omitempty"`
+	ModuleName string `json:"module_name,omitempty"`
+	Version    string `json:"version"`
+}
+
+// BedrockManifest is a behavior or resource pack's manifest.json
+type BedrockManifest struct {
+	FormatVersion    int                 `json:"format_version"`
+	Name             string              `json:"name"`
+	Description      string              `json:"description,omitempty"`
+	UUID             string              `json:"uuid"`
+	Version          BedrockVersion      `json:"version"`
+	MinEngineVersion string              `json:"min_engine_version,omitempty"`
+	Modules          []BedrockModule     `json:"modules"`
+	Dependencies     []BedrockDependency `json:"dependencies,omitempty"`
+}
+
+// Kind returns the pack kind the manifest's modules make
+func (m *BedrockManifest) Kind() string {
+	for _, module := range m.Modules {
+		if kind, ok := bedrockModuleTypes[module.Type]; ok {
+			return kind
+		}
+	}
+	return ""
+}
+
+// ParseBedrockManifest reads and validates a pack's manifest.json
+func ParseBedrockManifest(data []byte) (*BedrockManifest, error) {
+	data = stripJSONComments(data)
+	if err := validateJSON(data); err != nil {
+		return nil, fmt.Errorf("manifest.json: %w", err)
+	}
+	var raw struct {
+		FormatVersion int                      `json:"format_version"`
+		Header        map[string]interface{}   `json:"header"`
+		Modules       []map[string]interface{} `json:"modules"`
+		Dependencies  []map[string]interface{} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("manifest.json: %w", err)
+	}
+	if raw.FormatVersion < 1 || raw.FormatVersion > 3 {
+		return nil, fmt.Errorf("manifest.json format_version %d is not 1, 2 or 3", raw.FormatVersion)
+	}
+	if raw.Header == nil {
+		return nil, fmt.Errorf("manifest.json has no header")
+	}
+
+	m := &BedrockManifest{FormatVersion: raw.FormatVersion}
+	m.Name, _ = raw.Header["name"].(string)
+	m.Description, _ = raw.Header["description"].(string)
+	m.UUID, _ = raw.Header["uuid"].(string)
+	if _, err := uuid.Parse(m.UUID); err != nil {
+		return nil, fmt.Errorf("manifest.json header uuid %q is not a UUID", m.UUID)
+	}
+	version, err := parseBedrockVersion(raw.Header["version"])
+	if err != nil {
+		return nil, fmt.Errorf("manifest.json header %w", err)
+	}
+	m.Version = version
+	if engine, ok := raw.Header["min_engine_version"]; ok {
+		if v, err := parseBedrockVersion(engine); err == nil {
+			m.MinEngineVersion = v.String()
+		}
+	}
+
+	if len(raw.Modules) == 0 {
+		return nil, fmt.Errorf("manifest.json has no modules")
+	}
+	seen := map[string]bool{strings.ToLower(m.UUID): true}
+	for i, rawModule := range raw.Modules {
+		module := BedrockModule{}
+		module.Type, _ = rawModule["type"].(string)
+		module.UUID, _ = rawModule["uuid"].(string)
+		module.Entry, _ = rawModule["entry"].(string)
+		module.Language, _ = rawModule["language"].(string)
+		if _, ok := bedrockModuleTypes[module.Type]; !ok {
+			return nil, fmt.Errorf("manifest.json module %d has unknown type %q", i, module.Type)
+		}
+		if _, err := uuid.Parse(module.UUID); err != nil {
+			return nil, fmt.Errorf("manifest.json module %d uuid %q is not a UUID", i, module.UUID)
+		}
+		if seen[strings.ToLower(module.UUID)] {
+			return nil, fmt.Errorf("manifest.json module %d reuses uuid %s", i, module.UUID)
+		}
+		seen[strings.ToLower(module.UUID)] = true
+		if module.Version, err = parseBedrockVersion(rawModule["version"]); err != nil {
+			return nil, fmt.Errorf("manifest.json module %d %w", i, err)
+		}
+		m.Modules = append(m.Modules, module)
+	}
+
+	for i, rawDep := range raw.Dependencies {
+		dep := BedrockDependency{}
+		dep.UUID, _ = rawDep["uuid"].(string)
+		dep.ModuleName, _ = rawDep["module_name"].(string)
+		switch v := rawDep["version"].(type) {
+		case string:
+			dep.Version = v
+		default:
+			version, err := parseBedrockVersion(v)
+			if err != nil {
+				return nil, fmt.Errorf("manifest.json dependency %d %w", i, err)
+			}
+			dep.Version = version.String()
+		}
+		if dep.UUID == "" && dep.ModuleName == "" {
+			return nil, fmt.Errorf("manifest.json dependency %d has neither a uuid nor a module_name", i)
+		}
+		if dep.UUID != "" {
+			if _, err := uuid.Parse(dep.UUID); err != nil {
+				return nil, fmt.Errorf("manifest.json dependency %d uuid %q is not a UUID", i, dep.UUID)
+			}
+		}
+		m.Dependencies = append(m.Dependencies, dep)
+	}
+	return m, nil
+}
+
+// isBedrockManifest reports whether JSON content has a manifest's header and modules
+func isBedrockManifest(data []byte) bool {
+	var doc map[string]interface{}
+	if json.Unmarshal(stripJSONComments(data), &doc) != nil {
+		return false
+	}
+	header, _ := doc["header"].(map[string]interface{})
+	_, modules := doc["modules"].([]interface{})
+	return header != nil && header["uuid"] != nil && modules
+}
+
+// BedrockLang is a texts/<locale>.lang file of key=value lines
+type BedrockLang struct {
+	Path    string            `json:"path"`
+	Locale  string            `json:"locale"`
+	Keys    []string          `json:"-"` // in file order
+	Entries map[string]string `json:"-"`
+}
+
+// ParseBedrockLang reads a .lang file. Lines starting with ## are comments,
+// and a tab followed by ## ends a value early.
+func ParseBedrockLang(name string, data []byte) *BedrockLang {
+	lang := &BedrockLang{Path: name, Locale: strings.TrimSuffix(path.Base(name), ".lang"), Entries: make(map[string]string)}
+	for _, line := range strings.Split(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(strings.TrimSpace(line), "##") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			continue
+		}
+		if i := strings.Index(value, "\t##"); i >= 0 {
+			value = value[:i]
+		}
+		key = strings.TrimSpace(key)
+		if _, ok := lang.Entries[key]; !ok {
+			lang.Keys = append(lang.Keys, key)
+		}
+		lang.Entries[key] = strings.TrimRight(value, " \t")
+	}
+	return lang
+}
+
+// BedrockPack is one behavior, resource or skin pack
+type BedrockPack struct {
+	Path     string              `json:"path"` // of manifest.json; entries of a nested pack are prefixed pack.mcpack!/
+	Root     string              `json:"root"`
+	Kind     string              `json:"kind"`
+	Manifest *BedrockManifest    `json:"manifest"`
+	Defines  map[string][]string `json:"defines,omitempty"` // entity, item and block identifiers by kind
+	Langs    []*BedrockLang      `json:"langs,omitempty"`
+}
+
+// BedrockAddon is every pack in an .mcaddon, .mcpack or zip
+type BedrockAddon struct {
+	Packs      []*BedrockPack      `json:"packs"`
+	Unresolved []BedrockDependency `json:"unresolved,omitempty"` // pack dependencies the upload does not include
+}
+
+// LangCoverage reports how much of each pack's en_US.lang every other locale translates
+func (a *BedrockAddon) LangCoverage() []LocaleCoverage {
+	var coverage []LocaleCoverage
+	for _, pack := range a.Packs {
+		var source *BedrockLang
+		for _, lang := range pack.Langs {
+			if lang.Locale == "en_US" {
+				source = lang
+			}
+		}
+		if source == nil {
+			continue
+		}
+		for _, lang := range pack.Langs {
+			if lang == source {
+				continue
+			}
+			c := LocaleCoverage{Namespace: pack.Manifest.Name, Locale: lang.Locale, Total: len(source.Keys)}
+			for _, key := range source.Keys {
+				if _, ok := lang.Entries[key]; ok {
+					c.Translated++
+				} else if len(c.Missing) < maxReportedMissingKeys {
+					c.Missing = append(c.Missing, key)
+				}
+			}
+			for key := range lang.Entries {
+				if _, ok := source.Entries[key]; !ok {
+					c.Extra++
+				}
+			}
+			c.Percent = 100
+			if c.Total > 0 {
+				c.Percent = float64(c.Translated*1000/c.Total) / 10
+			}
+			coverage = append(coverage, c)
+		}
+	}
+	return coverage
+}
+
+// ParseBedrockAddon reads every pack in an add-on: manifests at the root or
+// in a top folder, and packs nested as .mcpack or .zip entries
+func ParseBedrockAddon(content []byte) (*BedrockAddon, error) {
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return nil, err
+	}
+	packs, err := readBedrockPacks(archive, "", true)
+	if err != nil {
+		return nil, err
+	}
+	if len(packs) == 0 {
+		return nil, fmt.Errorf("archive has no Bedrock pack manifest.json")
+	}
+
+	addon := &BedrockAddon{Packs: packs}
+	included := make(map[string]bool)
+	for _, pack := range packs {
+		if included[strings.ToLower(pack.Manifest.UUID)] {
+			return nil, fmt.Errorf("%s reuses the header uuid of another pack", pack.Path)
+		}
+		included[strings.ToLower(pack.Manifest.UUID)] = true
+	}
+	for _, pack := range packs {
+		for _, dep := range pack.Manifest.Dependencies {
+			if dep.UUID != "" && !included[strings.ToLower(dep.UUID)] {
+				addon.Unresolved = append(addon.Unresolved, dep)
+			}
+		}
+	}
+	return addon, nil
+}
+
+// readBedrockPacks reads the packs of one archive, prefixing paths for nested ones
+func readBedrockPacks(archive *Archive, prefix string, nested bool) ([]*BedrockPack, error) {
+	var packs []*BedrockPack
+	for _, f := range archive.Files() {
+		lower := strings.ToLower(f.Name)
+		if nested && (strings.HasSuffix(lower, ".mcpack") || strings.HasSuffix(lower, ".zip")) {
+			inner, err := archive.OpenNested(f)
+			if err != nil {
+				return nil, err
+			}
+			innerPacks, err := readBedrockPacks(inner, prefix+f.Name+"!/", false)
+			if err != nil {
+				return nil, err
+			}
+			packs = append(packs, innerPacks...)
+			continue
+		}
+		if path.Base(f.Name) != "manifest.json" || strings.Count(f.Name, "/") > 1 {
+			continue
+		}
+		data, err := archive.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if !isBedrockManifest(data) {
+			continue
+		}
+		manifest, err := ParseBedrockManifest(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s%s: %w", prefix, f.Name, err)
+		}
+		root := strings.TrimSuffix(f.Name, "manifest.json")
+		pack := &BedrockPack{Path: prefix + f.Name, Root: prefix + root, Kind: manifest.Kind(), Manifest: manifest, Defines: make(map[string][]string)}
+		if err := pack.read(archive, root); err != nil {
+			return nil, err
+		}
+		packs = append(packs, pack)
+	}
+	return packs, nil
+}
+
+// read collects the identifiers a pack defines and its language files
+func (p *BedrockPack) read(archive *Archive, root string) error {
+	for _, f := range archive.Files() {
+		if !strings.HasPrefix(f.Name, root) || f.FileInfo().IsDir() {
+			continue
+		}
+		rel := strings.TrimPrefix(f.Name, root)
+		switch {
+		case strings.HasPrefix(rel, "texts/") && strings.HasSuffix(rel, ".lang"):
+			data, err := archive.ReadFile(f)
+			if err != nil {
+				return err
+			}
+			p.Langs = append(p.Langs, ParseBedrockLang(p.Root+rel, data))
+		case strings.HasSuffix(rel, ".json") && rel != "manifest.json" && !strings.HasPrefix(rel, "texts/"):
+			data, err := archive.ReadFile(f)
+			if err != nil {
+				return err
+			}
+			var doc map[string]interface{}
+			if json.Unmarshal(stripJSONComments(data), &doc) != nil {
+				continue
+			}
+			for key, kind := range bedrockDefinitions {
+				definition, _ := doc[key].(map[string]interface{})
+				description, _ := definition["description"].(map[string]interface{})
+				if id, ok := description["identifier"].(string); ok {
+					p.Defines[kind] = append(p.Defines[kind], id)
+				}
+			}
+		}
+	}
+	for _, ids := range p.Defines {
+		sort.Strings(ids)
+	}
+	return nil
+}
+
+// IsBedrockEditable reports whether an archive entry of a Bedrock pack is
+// content the AI may edit: not its manifest, whose identity DeriveBedrockPacks
+// manages, nor its texts
+func IsBedrockEditable(name string) bool {
+	base := path.Base(name)
+	return strings.HasSuffix(base, ".json") && base != "manifest.json" && path.Base(path.Dir(name)) != "texts"
+}
+
+// BedrockDerivation is the new identity DeriveBedrockPacks gave a pack
+type BedrockDerivation struct {
+	Pack       string         `json:"pack"`
+	Name       string         `json:"name"`
+	OldUUID    string         `json:"old_uuid"`
+	NewUUID    string         `json:"new_uuid"`
+	OldVersion BedrockVersion `json:"old_version"`
+	NewVersion BedrockVersion `json:"new_version"`
+}
+
+// DeriveBedrockPacks gives every pack of an add-on a fresh header UUID, fresh
+// module UUIDs and a bumped version, so an edited add-on installs beside the
+// original instead of colliding with it. Dependencies between the add-on's
+// own packs follow the new UUIDs and versions.
+func DeriveBedrockPacks(content []byte) ([]byte, []BedrockDerivation, error) {
+	addon, err := ParseBedrockAddon(content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	derived := make(map[string]BedrockDerivation)
+	var derivations []BedrockDerivation
+	for _, pack := range addon.Packs {
+		d := BedrockDerivation{
+			Pack:       pack.Path,
+			Name:       pack.Manifest.Name,
+			OldUUID:    pack.Manifest.UUID,
+			NewUUID:    newBedrockUUID(),
+			OldVersion: pack.Manifest.Version,
+			NewVersion: pack.Manifest.Version.Bump(),
+		}
+		derived[strings.ToLower(d.OldUUID)] = d
+		derivations = append(derivations, d)
+	}
+
+	// Manifests of nested packs are rewritten inside their own archive first
+	outer := make(map[string][]byte)
+	nested := make(map[string]map[string][]byte)
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, pack := range addon.Packs {
+		entry, inner, isNested := strings.Cut(pack.Path, "!/")
+		var data []byte
+		if isNested {
+			f := archive.File(entry)
+			nestedArchive, err := archive.OpenNested(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			if data, err = nestedArchive.ReadFileNamed(inner); err != nil {
+				return nil, nil, err
+			}
+		} else if data, err = archive.ReadFileNamed(pack.Path); err != nil {
+			return nil, nil, err
+		}
+
+		manifest, err := deriveBedrockManifest(data, derived)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", pack.Path, err)
+		}
+		if !isNested {
+			outer[pack.Path] = manifest
+			continue
+		}
+		if nested[entry] == nil {
+			nested[entry] = make(map[string][]byte)
+		}
+		nested[entry][inner] = manifest
+	}
+	for entry, changes := range nested {
+		data, err := archive.ReadFileNamed(entry)
+		if err != nil {
+			return nil, nil, err
+		}
+		if outer[entry], err = RewriteArchive(data, changes); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	output, err := RewriteArchive(content, outer)
+	if err != nil {
+		return nil, nil, err
+	}
+	return output, derivations, nil
+}
+
+// deriveBedrockManifest rewrites a manifest with its pack's new identity,
+// keeping key order and the way each version is written
+func deriveBedrockManifest(data []byte, derived map[string]BedrockDerivation) ([]byte, error) {
+	stripped := stripJSONComments(data)
+	doc, err := decodeOrdered(stripped)
+	if err != nil {
+		return nil, err
+	}
+	object, _ := doc.(*orderedObject)
+	header, _ := object.values["header"].(*orderedObject)
+	if header == nil {
+		return nil, fmt.Errorf("manifest has no header")
+	}
+	oldUUID, _ := header.values["uuid"].(string)
+	d, ok := derived[strings.ToLower(oldUUID)]
+	if !ok {
+		return nil, fmt.Errorf("pack %s was not derived", oldUUID)
+	}
+	header.set("uuid", d.NewUUID)
+	header.set("version", bedrockVersionValue(header.values["version"], d.NewVersion))
+
+	modules, _ := object.values["modules"].([]interface{})
+	for _, m := range modules {
+		module, ok := m.(*orderedObject)
+		if !ok {
+			continue
+		}
+		module.set("uuid", newBedrockUUID())
+		if version, err := parseBedrockVersion(jsonNumbersToInts(module.values["version"])); err == nil {
+			module.set("version", bedrockVersionValue(module.values["version"], version.Bump()))
+		}
+	}
+
+	dependencies, _ := object.values["dependencies"].([]interface{})
+	for _, dep := range dependencies {
+		dependency, ok := dep.(*orderedObject)
+		if !ok {
+			continue
+		}
+		id, _ := dependency.values["uuid"].(string)
+		if target, ok := derived[strings.ToLower(id)]; ok {
+			dependency.set("uuid", target.NewUUID)
+			dependency.set("version", bedrockVersionValue(dependency.values["version"], target.NewVersion))
+		}
+	}
+
+	var compact, out bytes.Buffer
+	if err := encodeOrdered(&compact, object); err != nil {
+		return nil, err
+	}
+	indent := jsonIndent(data)
+	if indent == "" {
+		return compact.Bytes(), nil
+	}
+	if err := json.Indent(&out, compact.Bytes(), "", indent); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// bedrockVersionValue writes a version the way the original value was written
+func bedrockVersionValue(original interface{}, version BedrockVersion) interface{} {
+	if _, ok := original.(string); ok {
+		return version.String()
+	}
+	return []interface{}{json.Number(strconv.Itoa(version[0])), json.Number(strconv.Itoa(version[1])), json.Number(strconv.Itoa(version[2]))}
+}
+
+// jsonNumbersToInts converts the json.Number parts of a decoded version for parseBedrockVersion
+func jsonNumbersToInts(v interface{}) interface{} {
+	parts, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+	out := make([]interface{}, len(parts))
+	for i, part := range parts {
+		out[i] = fmt.Sprint(part)
+	}
+	return out
+}
+
+// bedrockFormat handles Bedrock add-ons and packs
+type bedrockFormat struct{}
+
+func (bedrockFormat) Info() FormatInfo {
+	return FormatInfo{
+		Name:       "bedrock",
+		GameTypes:  []GameType{GameTypeBedrock},
+		Extensions: []string{".mcaddon", ".mcpack", ".zip"},
+		MIMETypes:  []string{"application/zip", "application/x-zip-compressed"},
+		MaxSize:    100 * 1024 * 1024,
+		Container:  true,
+	}
+}
+
+func (bedrockFormat) Detect(filename string, content []byte) bool {
+	if !bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		return false
+	}
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return false
+	}
+	return hasBedrockManifest(archive)
+}
+
+// hasBedrockManifest reports whether an archive has a pack manifest at its
+// root or in a top folder, or holds nested .mcpack files
+func hasBedrockManifest(archive *Archive) bool {
+	for _, f := range archive.Files() {
+		if strings.HasSuffix(strings.ToLower(f.Name), ".mcpack") {
+			return true
+		}
+		if path.Base(f.Name) != "manifest.json" || strings.Count(f.Name, "/") > 1 {
+			continue
+		}
+		if data, err := archive.ReadFile(f); err == nil && isBedrockManifest(data) {
+			return true
+		}
+	}
+	return false
+}
+
+func (bedrockFormat) Validate(filename string, content []byte) error {
+	if err := ScanArchive(content, DefaultArchiveLimits); err != nil {
+		return err
+	}
+	if _, err := ParseBedrockAddon(content); err != nil {
+		return fmt.Errorf("invalid Bedrock add-on: %w", err)
+	}
+	return nil
+}
+
+func (bedrockFormat) ExtractMetadata(content []byte) (map[string]interface{}, error) {
+	addon, err := ParseBedrockAddon(content)
+	if err != nil {
+		return nil, err
+	}
+	metadata := map[string]interface{}{
+		"format": "bedrock",
+		"addon":  addon,
+	}
+	if coverage := addon.LangCoverage(); len(coverage) > 0 {
+		metadata["lang_coverage"] = coverage
+	}
+	return metadata, nil
+}
+
+// Rewrite is not used for add-ons; their files are edited through EditArchive
+func (bedrockFormat) Rewrite(original, edited []byte) ([]byte, error) {
+	return nil, ErrRewriteUnsupported
+}
+
+// Package gives the edited packs new identities so they install beside the originals
+func (bedrockFormat) Package(content []byte) ([]byte, []string, error) {
+	output, derivations, err := DeriveBedrockPacks(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	var notes []string
+	for _, d := range derivations {
+		notes = append(notes, fmt.Sprintf("%s: new uuid %s, version %s -> %s", d.Name, d.NewUUID, d.OldVersion, d.NewVersion))
+	}
+	return output, notes, nil
+}
@@ -0,0 +1,338 @@
+package mods
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// Plugin header flags shared by the TES4 record of every supported game
+const (
+	pluginFlagMaster    uint32 = 0x00000001
+	pluginFlagLocalized uint32 = 0x00000080
+	pluginFlagLightSE   uint32 = 0x00000200 // Skyrim SE and Fallout 4 .esl flag
+	pluginFlagLightSF   uint32 = 0x00000100 // Starfield moved the light flag
+)
+
+// recordFlagCompressed marks a record whose subrecords are zlib compressed
+const recordFlagCompressed uint32 = 0x00040000
+
+// maxRecordSize bounds a decompressed record
+const maxRecordSize = 64 * 1024 * 1024
+
+// PluginHeader holds the fields read from a Bethesda plugin's TES4 record
+type PluginHeader struct {
+	Game         GameType `json:"game"`
+	Version      float32  `json:"version"`      // HEDR version
+	FormVersion  uint16   `json:"form_version"` // zero for Oblivion, which has no form version
+	Flags        uint32   `json:"flags"`
+	NumRecords   int32    `json:"num_records"`
+	NextObjectID uint32   `json:"next_object_id"`
+	Author       string   `json:"author,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	Masters      []string `json:"masters,omitempty"`
+}
+
+// IsMaster reports whether the plugin has the master (ESM) flag set
+func (h *PluginHeader) IsMaster() bool {
+	return h.Flags&pluginFlagMaster != 0
+}
+
+// IsLocalized reports whether the plugin stores its strings in external string tables
+func (h *PluginHeader) IsLocalized() bool {
+	return h.Flags&pluginFlagLocalized != 0
+}
+
+// IsLight reports whether the plugin has the light (ESL) flag for its game
+func (h *PluginHeader) IsLight() bool {
+	switch h.Game {
+	case GameTypeSkyrimSE, GameTypeFallout4:
+		return h.Flags&pluginFlagLightSE != 0
+	case GameTypeStarfield:
+		return h.Flags&pluginFlagLightSF != 0
+	}
+	return false
+}
+
+// ParsePluginHeader reads the TES4 header record at the start of a plugin and
+// identifies which game it was authored for
+func ParsePluginHeader(content []byte) (*PluginHeader, error) {
+	if len(content) < 20 || string(content[0:4]) != "TES4" {
+		return nil, fmt.Errorf("missing TES4 header record")
+	}
+
+	dataSize := binary.LittleEndian.Uint32(content[4:8])
+	h := &PluginHeader{
+		Flags: binary.LittleEndian.Uint32(content[8:12]),
+	}
+
+	// Oblivion uses a 20-byte record header; later games append a form
+	// version and an unknown field for 24 bytes in total
+	var headerSize int
+	switch {
+	case len(content) >= 24 && string(content[20:24]) == "HEDR":
+		headerSize = 20
+	case len(content) >= 28 && string(content[24:28]) == "HEDR":
+		headerSize = 24
+		h.FormVersion = binary.LittleEndian.Uint16(content[20:22])
+	default:
+		return nil, fmt.Errorf("missing HEDR subrecord")
+	}
+
+	end := headerSize + int(dataSize)
+	if end > len(content) {
+		return nil, fmt.Errorf("TES4 record is truncated")
+	}
+
+	for pos := headerSize; pos+6 <= end; {
+		sig := string(content[pos : pos+4])
+		size := int(binary.LittleEndian.Uint16(content[pos+4 : pos+6]))
+		pos += 6
+		if pos+size > end {
+			return nil, fmt.Errorf("subrecord %s is truncated", sig)
+		}
+		data := content[pos : pos+size]
+		pos += size
+
+		switch sig {
+		case "HEDR":
+			if size < 12 {
+				return nil, fmt.Errorf("HEDR subrecord is too short")
+			}
+			h.Version = math.Float32frombits(binary.LittleEndian.Uint32(data[0:4]))
+			h.NumRecords = int32(binary.LittleEndian.Uint32(data[4:8]))
+			h.NextObjectID = binary.LittleEndian.Uint32(data[8:12])
+		case "CNAM":
+			h.Author = zstring(data)
+		case "SNAM":
+			h.Description = zstring(data)
+		case "MAST":
+			h.Masters = append(h.Masters, zstring(data))
+		}
+	}
+
+	h.Game = identifyBethesdaGame(headerSize, h.Version, h.FormVersion)
+	if h.Game == GameTypeUnknown {
+		return h, fmt.Errorf("unrecognized plugin header (HEDR %.2f, form version %d)", h.Version, h.FormVersion)
+	}
+
+	return h, nil
+}
+
+// PluginRecord is a single record of a plugin, such as a weapon or an NPC
+type PluginRecord struct {
+	Signature string `json:"signature"`
+	FormID    uint32 `json:"form_id"`
+	Flags     uint32 `json:"flags"`
+	EditorID  string `json:"editor_id,omitempty"`
+	Data      []byte `json:"-"` // subrecord data as stored, compressed when the flag is set
+}
+
+// Key identifies the record across versions of a plugin
+func (r *PluginRecord) Key() string {
+	return fmt.Sprintf("%s:%08X", r.Signature, r.FormID)
+}
+
+// Subrecord is a typed field of a record
+type Subrecord struct {
+	Signature string
+	Data      []byte
+}
+
+// Subrecords decodes the record's fields, decompressing them if needed
+func (r *PluginRecord) Subrecords() ([]Subrecord, error) {
+	data := r.Data
+	if r.Flags&recordFlagCompressed != 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("compressed record %s is truncated", r.Key())
+		}
+		size := binary.LittleEndian.Uint32(data[0:4])
+		if size > maxRecordSize {
+			return nil, fmt.Errorf("record %s decompresses to %d bytes", r.Key(), size)
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(data[4:]))
+		if err != nil {
+			return nil, fmt.Errorf("record %s: %w", r.Key(), err)
+		}
+		defer zr.Close()
+		data, err = io.ReadAll(io.LimitReader(zr, int64(size)))
+		if err != nil {
+			return nil, fmt.Errorf("record %s: %w", r.Key(), err)
+		}
+	}
+
+	var subrecords []Subrecord
+	var largeSize int // XXXX subrecords carry the size of the next field when it exceeds 64KB
+	for pos := 0; pos+6 <= len(data); {
+		sig := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint16(data[pos+4 : pos+6]))
+		pos += 6
+		if largeSize > 0 {
+			size, largeSize = largeSize, 0
+		}
+		if pos+size > len(data) {
+			return nil, fmt.Errorf("record %s: subrecord %s is truncated", r.Key(), sig)
+		}
+		if sig == "XXXX" && size == 4 {
+			largeSize = int(binary.LittleEndian.Uint32(data[pos : pos+4]))
+		} else {
+			subrecords = append(subrecords, Subrecord{Signature: sig, Data: data[pos : pos+size]})
+		}
+		pos += size
+	}
+	return subrecords, nil
+}
+
+// ParsePluginRecords walks every record of a plugin, descending into groups
+func ParsePluginRecords(content []byte) ([]*PluginRecord, error) {
+	if len(content) < 24 || string(content[0:4]) != "TES4" {
+		return nil, fmt.Errorf("missing TES4 header record")
+	}
+	headerSize := 24
+	if string(content[20:24]) == "HEDR" {
+		headerSize = 20 // Oblivion
+	}
+
+	var records []*PluginRecord
+	var walk func(data []byte) error
+	walk = func(data []byte) error {
+		for pos := 0; pos < len(data); {
+			if pos+headerSize > len(data) {
+				return fmt.Errorf("record header at offset %d is truncated", pos)
+			}
+			sig := string(data[pos : pos+4])
+			size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+
+			if sig == "GRUP" {
+				// A group's size includes its own header
+				if size < headerSize || pos+size > len(data) {
+					return fmt.Errorf("group at offset %d is truncated", pos)
+				}
+				if err := walk(data[pos+headerSize : pos+size]); err != nil {
+					return err
+				}
+				pos += size
+				continue
+			}
+
+			end := pos + headerSize + size
+			if end > len(data) {
+				return fmt.Errorf("record %s at offset %d is truncated", sig, pos)
+			}
+			record := &PluginRecord{
+				Signature: sig,
+				Flags:     binary.LittleEndian.Uint32(data[pos+8 : pos+12]),
+				FormID:    binary.LittleEndian.Uint32(data[pos+12 : pos+16]),
+				Data:      data[pos+headerSize : end],
+			}
+			if subrecords, err := record.Subrecords(); err == nil {
+				for _, sub := range subrecords {
+					if sub.Signature == "EDID" {
+						record.EditorID = zstring(sub.Data)
+						break
+					}
+				}
+			}
+			records = append(records, record)
+			pos = end
+		}
+		return nil
+	}
+
+	if err := walk(content); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// identifyBethesdaGame maps the record header layout, HEDR version and form
+// version to the game that produced the plugin
+func identifyBethesdaGame(headerSize int, version float32, formVersion uint16) GameType {
+	if headerSize == 20 {
+		if versionIs(version, 0.8) || versionIs(version, 1.0) {
+			return GameTypeOblivion
+		}
+		return GameTypeUnknown
+	}
+
+	// The form version is the most reliable signal when the editor wrote one
+	switch {
+	case formVersion >= 500:
+		return GameTypeStarfield
+	case formVersion >= 100 && formVersion < 200:
+		return GameTypeFallout4
+	case formVersion == 44:
+		return GameTypeSkyrimSE
+	case formVersion > 0 && formVersion < 44:
+		if versionIs(version, 1.7) || versionIs(version, 1.71) {
+			return GameTypeSkyrimSE
+		}
+		if versionIs(version, 0.94) {
+			return GameTypeSkyrim
+		}
+	}
+
+	// Fall back to the HEDR version alone
+	switch {
+	case versionIs(version, 0.94):
+		return GameTypeSkyrim
+	case versionIs(version, 1.7), versionIs(version, 1.71):
+		return GameTypeSkyrimSE
+	case versionIs(version, 0.95), versionIs(version, 1.0):
+		return GameTypeFallout4
+	case versionIs(version, 0.96):
+		return GameTypeStarfield
+	}
+
+	return GameTypeUnknown
+}
+
+// ValidatePlugin checks that a parsed plugin header is consistent with the
+// rules of its game and the file extension it was uploaded with
+func ValidatePlugin(h *PluginHeader, filename string) error {
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	switch ext {
+	case ".esl":
+		if h.Game == GameTypeOblivion || h.Game == GameTypeSkyrim {
+			return fmt.Errorf("%s does not support light plugins (.esl)", h.Game)
+		}
+	case ".esm":
+		if !h.IsMaster() {
+			return fmt.Errorf("plugin has .esm extension but the master flag is not set")
+		}
+	}
+
+	if len(h.Masters) > 254 {
+		return fmt.Errorf("plugin declares %d masters, the maximum is 254", len(h.Masters))
+	}
+
+	return nil
+}
+
+// IsBethesda reports whether the game type is one of the Bethesda plugin games
+func (g GameType) IsBethesda() bool {
+	switch g {
+	case GameTypeOblivion, GameTypeSkyrim, GameTypeSkyrimSE, GameTypeFallout4, GameTypeStarfield:
+		return true
+	}
+	return false
+}
+
+// versionIs compares HEDR float versions, which are not stored exactly
+func versionIs(version, want float32) bool {
+	return math.Abs(float64(version-want)) < 0.005
+}
+
+// zstring decodes a null-terminated string subrecord
+func zstring(data []byte) string {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		data = data[:i]
+	}
+	return string(data)
+}
@@ -0,0 +1,424 @@
+package mods
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Bukkit plugin descriptors, at the root of a plugin jar
+const (
+	BukkitDescriptor = "plugin.yml"
+	PaperDescriptor  = "paper-plugin.yml"
+)
+
+var (
+	bukkitName       = regexp.MustCompile(`^[A-Za-z0-9 _.-]+$`)
+	bukkitAPIVersion = regexp.MustCompile(`^1\.\d+(\.\d+)?$`)
+	bukkitMainClass  = regexp.MustCompile(`^[A-Za-z_$][\w$]*(\.[A-Za-z_$][\w$]*)+$`)
+)
+
+// BukkitCommand is a command a plugin registers in plugin.yml
+type BukkitCommand struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Usage       string   `json:"usage,omitempty"`
+	Aliases     []string `json:"aliases,omitempty"`
+	Permission  string   `json:"permission,omitempty"`
+}
+
+// BukkitPermission is a permission node a plugin declares
+type BukkitPermission struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Default     string          `json:"default,omitempty"` // true, false, op or not op
+	Children    map[string]bool `json:"children,omitempty"`
+}
+
+// BukkitPlugin is a Bukkit, Spigot or Paper plugin's descriptor
+type BukkitPlugin struct {
+	Name        string             `json:"name"`
+	Version     string             `json:"version"`
+	Main        string             `json:"main"`
+	APIVersion  string             `json:"api_version,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Authors     []string           `json:"authors,omitempty"`
+	Website     string             `json:"website,omitempty"`
+	Depend      []string           `json:"depend,omitempty"`
+	SoftDepend  []string           `json:"softdepend,omitempty"`
+	LoadBefore  []string           `json:"loadbefore,omitempty"`
+	Commands    []BukkitCommand    `json:"commands,omitempty"`
+	Permissions []BukkitPermission `json:"permissions,omitempty"`
+	Paper       bool               `json:"paper"` // read from paper-plugin.yml
+}
+
+// ParseBukkitPlugin reads plugin.yml, or paper-plugin.yml when paper is set,
+// whose server dependencies replace depend and softdepend
+func ParseBukkitPlugin(data []byte, paper bool) (*BukkitPlugin, error) {
+	doc, err := decodeYAML(string(data))
+	if err != nil {
+		return nil, err
+	}
+	p := &BukkitPlugin{
+		Name:        yamlString(doc["name"]),
+		Version:     yamlString(doc["version"]),
+		Main:        yamlString(doc["main"]),
+		APIVersion:  yamlString(doc["api-version"]),
+		Description: yamlString(doc["description"]),
+		Website:     yamlString(doc["website"]),
+		Depend:      yamlStrings(doc["depend"]),
+		SoftDepend:  yamlStrings(doc["softdepend"]),
+		LoadBefore:  yamlStrings(doc["loadbefore"]),
+		Paper:       paper,
+	}
+	switch {
+	case p.Name == "":
+		return nil, fmt.Errorf("name is required")
+	case !bukkitName.MatchString(p.Name):
+		return nil, fmt.Errorf("name %q may only use letters, digits, spaces, underscores, periods and hyphens", p.Name)
+	case p.Version == "":
+		return nil, fmt.Errorf("version is required")
+	case p.Main == "":
+		return nil, fmt.Errorf("main is required")
+	case !bukkitMainClass.MatchString(p.Main):
+		return nil, fmt.Errorf("main %q is not a fully qualified class name", p.Main)
+	case p.APIVersion != "" && !bukkitAPIVersion.MatchString(p.APIVersion):
+		return nil, fmt.Errorf("api-version %q is not a Minecraft version such as 1.20", p.APIVersion)
+	}
+	if author := yamlString(doc["author"]); author != "" {
+		p.Authors = append(p.Authors, author)
+	}
+	p.Authors = append(p.Authors, yamlStrings(doc["authors"])...)
+
+	if paper {
+		// dependencies.server maps plugin names to {load, required}
+		deps, _ := doc["dependencies"].(map[string]interface{})
+		server, _ := deps["server"].(map[string]interface{})
+		for _, name := range sortedKeys(server) {
+			dep, _ := server[name].(map[string]interface{})
+			if required, ok := dep["required"].(bool); ok && !required {
+				p.SoftDepend = append(p.SoftDepend, name)
+			} else {
+				p.Depend = append(p.Depend, name)
+			}
+		}
+	}
+
+	commands, _ := doc["commands"].(map[string]interface{})
+	for _, name := range sortedKeys(commands) {
+		c, _ := commands[name].(map[string]interface{})
+		p.Commands = append(p.Commands, BukkitCommand{
+			Name:        name,
+			Description: yamlString(c["description"]),
+			Usage:       yamlString(c["usage"]),
+			Aliases:     yamlStrings(c["aliases"]),
+			Permission:  yamlString(c["permission"]),
+		})
+	}
+	permissions, _ := doc["permissions"].(map[string]interface{})
+	for _, name := range sortedKeys(permissions) {
+		perm, _ := permissions[name].(map[string]interface{})
+		bp := BukkitPermission{Name: name, Description: yamlString(perm["description"]), Default: yamlString(perm["default"])}
+		switch children := perm["children"].(type) {
+		case map[string]interface{}:
+			bp.Children = make(map[string]bool)
+			for child, v := range children {
+				granted, ok := v.(bool)
+				bp.Children[child] = granted || !ok
+			}
+		case []interface{}:
+			bp.Children = make(map[string]bool)
+			for _, child := range yamlStrings(children) {
+				bp.Children[child] = true
+			}
+		}
+		switch strings.ToLower(bp.Default) {
+		case "", "true", "false", "op", "isop", "operator", "isoperator", "admin", "isadmin",
+			"not op", "notop", "!op", "not operator", "notoperator", "!operator", "not admin", "notadmin", "!admin":
+		default:
+			return nil, fmt.Errorf("permission %s has unknown default %q", name, bp.Default)
+		}
+		p.Permissions = append(p.Permissions, bp)
+	}
+	return p, nil
+}
+
+// yamlString returns a decoded scalar as text, or an empty string for
+// anything else
+func yamlString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// yamlStrings returns a list of scalars, accepting a single scalar as a list of one
+func yamlStrings(v interface{}) []string {
+	list, ok := v.([]interface{})
+	if !ok {
+		if s := yamlString(v); s != "" {
+			return []string{s}
+		}
+		return nil
+	}
+	var strs []string
+	for _, item := range list {
+		if s := yamlString(item); s != "" {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+// ParseBukkitJar reads the descriptor of a plugin jar, preferring
+// paper-plugin.yml, and checks that the main class is in the jar. It returns
+// nil if the jar has neither descriptor.
+func ParseBukkitJar(archive *Archive) (*BukkitPlugin, error) {
+	name, paper := PaperDescriptor, true
+	if archive.File(name) == nil {
+		name, paper = BukkitDescriptor, false
+	}
+	data, err := archive.ReadFileNamed(name)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	plugin, err := ParseBukkitPlugin(data, paper)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if class := strings.ReplaceAll(plugin.Main, ".", "/") + ".class"; archive.File(class) == nil {
+		return nil, fmt.Errorf("%s: main class %s is not in the jar", name, plugin.Main)
+	}
+	return plugin, nil
+}
+
+// IsBukkitJar reports whether content is a jar with a plugin descriptor
+func IsBukkitJar(content []byte) bool {
+	if !bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		return false
+	}
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return false
+	}
+	return archive.File(BukkitDescriptor) != nil || archive.File(PaperDescriptor) != nil
+}
+
+// bukkitDescriptor describes a plugin for dependency checks. depend becomes a
+// hard dependency and softdepend a recommendation.
+func bukkitDescriptor(source string, plugin *BukkitPlugin) ModDescriptor {
+	d := ModDescriptor{
+		ID:       plugin.Name,
+		Name:     plugin.Name,
+		Version:  plugin.Version,
+		Loader:   LoaderBukkit,
+		GameType: GameTypeMinecraft,
+		Source:   source,
+	}
+	if plugin.Paper {
+		d.Loader = LoaderPaper
+	}
+	for _, dep := range plugin.Depend {
+		d.Relations = append(d.Relations, Relation{Kind: RelationDepends, ModID: dep})
+	}
+	for _, dep := range plugin.SoftDepend {
+		d.Relations = append(d.Relations, Relation{Kind: RelationRecommends, ModID: dep})
+	}
+	return d
+}
+
+// IsBukkitEditable reports whether the AI may edit a plugin jar entry: the
+// bundled config.yml, messages.yml and message files under lang/ or messages/
+func IsBukkitEditable(name string) bool {
+	switch name {
+	case "config.yml", "messages.yml":
+		return true
+	}
+	dir := path.Base(path.Dir(name))
+	return path.Ext(name) == ".yml" && (dir == "lang" || dir == "messages")
+}
+
+// bukkitMessageTarget returns where a message file's translation into locale
+// belongs, or false if the file is not an English source
+func bukkitMessageTarget(name, locale string) (string, bool) {
+	if name == "messages.yml" {
+		return "messages_" + locale + ".yml", true
+	}
+	dir, base := path.Split(name)
+	if d := path.Base(dir); d != "lang" && d != "messages" {
+		return "", false
+	}
+	switch strings.ToLower(base) {
+	case "en.yml", "en_us.yml":
+		return dir + locale + ".yml", true
+	case "messages_en.yml", "messages_en_us.yml":
+		return dir + "messages_" + locale + ".yml", true
+	}
+	return "", false
+}
+
+var (
+	// &a and §a color codes, &#RRGGBB and §x hex colors
+	bukkitColorCode = regexp.MustCompile(`(?i)[&§](#[0-9a-f]{6}|[0-9a-fk-orx])`)
+	// MiniMessage tags such as <red>, </bold> and <click:run_command:/spawn>
+	bukkitMiniMessageTag = regexp.MustCompile(`</?[#!a-zA-Z_][a-zA-Z0-9_#:/.-]*`)
+	// %player%, {player}, {0} and Java format arguments such as %1$s
+	bukkitPlaceholder = regexp.MustCompile(`%[A-Za-z0-9_.-]+%|\{[A-Za-z0-9_.-]+\}|%(\d+\$)?[sdf]`)
+)
+
+// CheckBukkitMessage verifies that a translated message keeps the color codes,
+// MiniMessage tags and placeholders of the source message
+func CheckBukkitMessage(source, translated string) error {
+	if translated == "" && source != "" {
+		return fmt.Errorf("translation is empty")
+	}
+	if want, got := sortedMatches(bukkitColorCode, strings.ToLower(source)), sortedMatches(bukkitColorCode, strings.ToLower(translated)); want != got {
+		return fmt.Errorf("color codes changed from [%s] to [%s]", want, got)
+	}
+	if want, got := sortedMatches(bukkitMiniMessageTag, source), sortedMatches(bukkitMiniMessageTag, translated); want != got {
+		return fmt.Errorf("MiniMessage tags changed from [%s] to [%s]", want, got)
+	}
+	if want, got := sortedMatches(bukkitPlaceholder, source), sortedMatches(bukkitPlaceholder, translated); want != got {
+		return fmt.Errorf("placeholders changed from [%s] to [%s]", want, got)
+	}
+	return nil
+}
+
+// bukkitMessages returns the translatable entries of a message file: string
+// scalars that are not numbers, booleans or collections
+func bukkitMessages(text string) ([]ConfigEntry, map[string]string, error) {
+	nodes, err := scanYAML(text)
+	if err != nil {
+		return nil, nil, err
+	}
+	var entries []ConfigEntry
+	values := make(map[string]string)
+	for _, node := range nodes {
+		if node.container {
+			continue
+		}
+		value, err := yamlValue(node.entry.Value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", node.entry.Line, err)
+		}
+		s, ok := value.(string)
+		if !ok || strings.TrimSpace(s) == "" {
+			continue
+		}
+		if _, err := strconv.ParseFloat(s, 64); err == nil && node.entry.Value[0] != '"' && node.entry.Value[0] != '\'' {
+			continue
+		}
+		entries = append(entries, node.entry)
+		values[node.entry.Key] = s
+	}
+	return entries, values, nil
+}
+
+// TranslateBukkitMessages writes a translation of each English message file
+// of a plugin jar, such as messages.yml to messages_de_de.yml. Each
+// translation is the source file with its values replaced, so comments, key
+// order and quoting carry over; messages an existing translation already has
+// are kept. Block scalars are left in English and reported.
+func TranslateBukkitMessages(content []byte, locales []string, translate TranslateFunc) ([]byte, *TranslationReport, error) {
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return nil, nil, err
+	}
+	if archive.File(BukkitDescriptor) == nil && archive.File(PaperDescriptor) == nil {
+		return nil, nil, fmt.Errorf("jar has no %s or %s", BukkitDescriptor, PaperDescriptor)
+	}
+	for _, locale := range locales {
+		if !ValidLocale(locale) {
+			return nil, nil, fmt.Errorf("invalid target locale %q", locale)
+		}
+	}
+
+	report := &TranslationReport{Files: []string{}}
+	changes := make(map[string][]byte)
+	sources := 0
+	for _, f := range archive.Files() {
+		if _, ok := bukkitMessageTarget(f.Name, SourceLocale); !ok {
+			continue
+		}
+		data, err := archive.ReadFile(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		text := strings.TrimPrefix(string(data), "\ufeff")
+		entries, values, err := bukkitMessages(text)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		sources++
+
+		for _, locale := range locales {
+			target, _ := bukkitMessageTarget(f.Name, locale)
+			existing := make(map[string]string) // raw values of an earlier translation
+			if data, err := archive.ReadFileNamed(target); err == nil && data != nil {
+				translatedEntries, _, err := bukkitMessages(strings.TrimPrefix(string(data), "\ufeff"))
+				if err != nil {
+					return nil, nil, fmt.Errorf("%s: %w", target, err)
+				}
+				for _, e := range translatedEntries {
+					existing[e.Key] = e.Value
+				}
+			}
+
+			var missing []string
+			for _, e := range entries {
+				if _, ok := existing[e.Key]; ok {
+					continue
+				}
+				if e.Value[0] == '|' || e.Value[0] == '>' {
+					report.Issues = append(report.Issues, LangIssue{Locale: locale, Key: e.Key, Reason: "block scalars are not translated"})
+					continue
+				}
+				missing = append(missing, e.Key)
+			}
+			translated, issues, err := translateBatches(locale, missing, values, translate, CheckBukkitMessage)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s %s: %w", f.Name, locale, err)
+			}
+			report.Issues = append(report.Issues, issues...)
+			if len(translated) == 0 {
+				continue
+			}
+
+			// Replace values from the end so earlier offsets stay valid
+			output := text
+			for i := len(entries) - 1; i >= 0; i-- {
+				e := entries[i]
+				value, ok := existing[e.Key]
+				if !ok {
+					message, ok := translated[e.Key]
+					if !ok {
+						continue
+					}
+					value = quoteYAMLString(message, e.Value)
+				}
+				output = output[:e.start] + value + output[e.end:]
+			}
+			changes[target] = []byte(output)
+			report.Files = append(report.Files, target)
+			report.Translated += len(translated)
+		}
+	}
+	if sources == 0 {
+		return nil, nil, fmt.Errorf("plugin has no messages.yml or lang/en.yml to translate from")
+	}
+
+	if len(changes) == 0 {
+		return content, report, nil
+	}
+	output, err := RewriteArchive(content, changes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return output, report, nil
+}
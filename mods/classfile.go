@@ -0,0 +1,419 @@
+package mods
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Constant pool tags from the JVM specification
+const (
+	constUtf8               = 1
+	constInteger            = 3
+	constFloat              = 4
+	constLong               = 5
+	constDouble             = 6
+	constClass              = 7
+	constString             = 8
+	constFieldref           = 9
+	constMethodref          = 10
+	constInterfaceMethodref = 11
+	constNameAndType        = 12
+	constMethodHandle       = 15
+	constMethodType         = 16
+	constDynamic            = 17
+	constInvokeDynamic      = 18
+	constModule             = 19
+	constPackage            = 20
+)
+
+var errClassTruncated = errors.New("class file is truncated")
+
+// MemberRef is a field or method referenced from a class
+type MemberRef struct {
+	Owner      string `json:"owner"`
+	Name       string `json:"name"`
+	Descriptor string `json:"descriptor"`
+}
+
+// ClassAnnotation is a class-level annotation with its elements flattened to
+// strings. Class elements are given as internal names.
+type ClassAnnotation struct {
+	Type     string              `json:"type"`
+	Elements map[string][]string `json:"elements,omitempty"`
+}
+
+// ClassInfo is what a class file's constant pool and header reveal without
+// decompiling it. Class names use the internal form, e.g. net/minecraft/world/item/Item.
+type ClassInfo struct {
+	Name         string            `json:"name"`
+	SuperName    string            `json:"super_name,omitempty"`
+	Interfaces   []string          `json:"interfaces,omitempty"`
+	MajorVersion uint16            `json:"major_version"`
+	Classes      []string          `json:"classes"`
+	Methods      []MemberRef       `json:"methods"`
+	Fields       []MemberRef       `json:"fields"`
+	Strings      []string          `json:"strings"`
+	Annotations  []ClassAnnotation `json:"annotations,omitempty"`
+}
+
+// cpEntry is one constant pool slot; a and b hold indices or raw values
+type cpEntry struct {
+	tag  byte
+	a, b uint16
+	utf8 string
+}
+
+// classReader reads big-endian values and remembers the first short read
+type classReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *classReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || r.pos+n > len(r.data) {
+		r.err = errClassTruncated
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *classReader) u1() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *classReader) u2() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *classReader) u4() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// ParseClass reads a Java class file's constant pool, header and class-level
+// annotations
+func ParseClass(data []byte) (*ClassInfo, error) {
+	r := &classReader{data: data}
+	if r.u4() != 0xCAFEBABE {
+		return nil, fmt.Errorf("not a class file")
+	}
+	r.u2() // minor version
+	info := &ClassInfo{MajorVersion: r.u2()}
+
+	count := int(r.u2())
+	pool := make([]cpEntry, count)
+	for i := 1; i < count; i++ {
+		e := cpEntry{tag: r.u1()}
+		switch e.tag {
+		case constUtf8:
+			e.utf8 = decodeModifiedUTF8(r.bytes(int(r.u2())))
+		case constInteger, constFloat:
+			e.a, e.b = r.u2(), r.u2()
+		case constLong, constDouble:
+			r.bytes(8)
+			pool[i] = e
+			i++ // eight-byte constants take two slots
+			continue
+		case constClass, constString, constMethodType, constModule, constPackage:
+			e.a = r.u2()
+		case constFieldref, constMethodref, constInterfaceMethodref, constNameAndType, constDynamic, constInvokeDynamic:
+			e.a, e.b = r.u2(), r.u2()
+		case constMethodHandle:
+			e.a, e.b = uint16(r.u1()), r.u2()
+		default:
+			if r.err == nil {
+				return nil, fmt.Errorf("unknown constant pool tag %d at index %d", e.tag, i)
+			}
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		pool[i] = e
+	}
+
+	utf8 := func(index uint16) string {
+		if int(index) < len(pool) && pool[index].tag == constUtf8 {
+			return pool[index].utf8
+		}
+		return ""
+	}
+	className := func(index uint16) string {
+		if int(index) < len(pool) && pool[index].tag == constClass {
+			return utf8(pool[index].a)
+		}
+		return ""
+	}
+
+	classes := make(map[string]bool)
+	addType := func(name string) {
+		for _, c := range descriptorClasses(name) {
+			classes[c] = true
+		}
+	}
+	for _, e := range pool {
+		switch e.tag {
+		case constClass:
+			addType(utf8(e.a))
+		case constString:
+			info.Strings = append(info.Strings, utf8(e.a))
+		case constFieldref, constMethodref, constInterfaceMethodref:
+			owner := className(e.a)
+			if int(e.b) >= len(pool) || pool[e.b].tag != constNameAndType {
+				continue
+			}
+			ref := MemberRef{Owner: owner, Name: utf8(pool[e.b].a), Descriptor: utf8(pool[e.b].b)}
+			addType(ref.Descriptor)
+			if e.tag == constFieldref {
+				info.Fields = append(info.Fields, ref)
+			} else {
+				info.Methods = append(info.Methods, ref)
+			}
+		}
+	}
+
+	r.u2() // access flags
+	info.Name = className(r.u2())
+	info.SuperName = className(r.u2())
+	for n := int(r.u2()); n > 0 && r.err == nil; n-- {
+		info.Interfaces = append(info.Interfaces, className(r.u2()))
+	}
+
+	// Skip fields and methods to reach the class attributes
+	for member := 0; member < 2 && r.err == nil; member++ {
+		for n := int(r.u2()); n > 0 && r.err == nil; n-- {
+			r.bytes(6) // access flags, name, descriptor
+			skipAttributes(r)
+		}
+	}
+	for n := int(r.u2()); n > 0 && r.err == nil; n-- {
+		name := utf8(r.u2())
+		body := r.bytes(int(r.u4()))
+		if name == "RuntimeVisibleAnnotations" || name == "RuntimeInvisibleAnnotations" {
+			ar := &classReader{data: body}
+			for count := int(ar.u2()); count > 0 && ar.err == nil; count-- {
+				info.Annotations = append(info.Annotations, readAnnotation(ar, pool, utf8))
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	delete(classes, info.Name)
+	info.Classes = sortedKeys(classes)
+	return info, nil
+}
+
+func skipAttributes(r *classReader) {
+	for n := int(r.u2()); n > 0 && r.err == nil; n-- {
+		r.u2()
+		r.bytes(int(r.u4()))
+	}
+}
+
+// readAnnotation reads an annotation structure, flattening element values
+func readAnnotation(r *classReader, pool []cpEntry, utf8 func(uint16) string) ClassAnnotation {
+	a := ClassAnnotation{Type: strings.TrimSuffix(strings.TrimPrefix(utf8(r.u2()), "L"), ";"), Elements: map[string][]string{}}
+	for n := int(r.u2()); n > 0 && r.err == nil; n-- {
+		name := utf8(r.u2())
+		a.Elements[name] = readElementValue(r, pool, utf8)
+	}
+	return a
+}
+
+func readElementValue(r *classReader, pool []cpEntry, utf8 func(uint16) string) []string {
+	switch tag := r.u1(); tag {
+	case 's':
+		return []string{utf8(r.u2())}
+	case 'c':
+		return descriptorClasses(utf8(r.u2()))
+	case 'e':
+		r.u2() // enum type
+		return []string{utf8(r.u2())}
+	case 'B', 'C', 'I', 'S', 'Z', 'D', 'F', 'J':
+		return []string{constantString(pool, r.u2(), tag)}
+	case '@':
+		readAnnotation(r, pool, utf8)
+		return nil
+	case '[':
+		var values []string
+		for n := int(r.u2()); n > 0 && r.err == nil; n-- {
+			values = append(values, readElementValue(r, pool, utf8)...)
+		}
+		return values
+	default:
+		r.err = fmt.Errorf("unknown annotation element tag %q", tag)
+		return nil
+	}
+}
+
+// constantString formats a numeric constant used as an annotation element
+func constantString(pool []cpEntry, index uint16, tag byte) string {
+	if int(index) >= len(pool) {
+		return ""
+	}
+	e := pool[index]
+	raw := uint32(e.a)<<16 | uint32(e.b)
+	switch tag {
+	case 'F':
+		return fmt.Sprint(math.Float32frombits(raw))
+	case 'Z':
+		return fmt.Sprint(raw != 0)
+	case 'D', 'J':
+		return "" // eight-byte values are not kept
+	}
+	return fmt.Sprint(int32(raw))
+}
+
+// descriptorClasses returns the classes named by an internal class name, an
+// array class name or a field or method descriptor
+func descriptorClasses(s string) []string {
+	if s == "" {
+		return nil
+	}
+	if !strings.ContainsAny(s[:1], "[(") && !strings.HasSuffix(s, ";") {
+		return []string{s} // plain internal name
+	}
+	var classes []string
+	for {
+		start := strings.IndexByte(s, 'L')
+		if start < 0 {
+			return classes
+		}
+		end := strings.IndexByte(s[start:], ';')
+		if end < 0 {
+			return classes
+		}
+		classes = append(classes, s[start+1:start+end])
+		s = s[start+end+1:]
+	}
+}
+
+// decodeModifiedUTF8 decodes the JVM's modified UTF-8, which encodes NUL as
+// two bytes and supplementary characters as surrogate pairs
+func decodeModifiedUTF8(b []byte) string {
+	var runes []rune
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c < 0x80:
+			runes = append(runes, rune(c))
+			i++
+		case c&0xE0 == 0xC0 && i+1 < len(b):
+			runes = append(runes, rune(c&0x1F)<<6|rune(b[i+1]&0x3F))
+			i += 2
+		case c&0xF0 == 0xE0 && i+2 < len(b):
+			r := rune(c&0x0F)<<12 | rune(b[i+1]&0x3F)<<6 | rune(b[i+2]&0x3F)
+			if len(runes) > 0 && r >= 0xDC00 && r <= 0xDFFF {
+				if prev := runes[len(runes)-1]; prev >= 0xD800 && prev <= 0xDBFF {
+					runes[len(runes)-1] = (prev-0xD800)<<10 + (r - 0xDC00) + 0x10000
+					i += 3
+					continue
+				}
+			}
+			runes = append(runes, r)
+			i += 3
+		default:
+			runes = append(runes, 0xFFFD)
+			i++
+		}
+	}
+	return string(runes)
+}
+
+// maxAnalyzedClasses caps how many class files of an archive are parsed
+const maxAnalyzedClasses = 5000
+
+// maxReportedClasses caps each class list in an analysis report
+const maxReportedClasses = 1000
+
+// ClassReport summarises the classes of a jar
+type ClassReport struct {
+	Classes           int      `json:"classes"`
+	Unreadable        int      `json:"unreadable"`
+	MinecraftClasses  []string `json:"minecraft_classes"`
+	ExternalPackages  []string `json:"external_packages"`
+	ReferencedStrings int      `json:"referenced_strings"`
+	Truncated         bool     `json:"truncated,omitempty"`
+}
+
+// AnalyzeClasses parses the class files of an archive and reports the
+// Minecraft classes they reference and the packages of other libraries they
+// use. Classes defined in the archive and the Java platform are left out.
+func AnalyzeClasses(archive *Archive) (*ClassReport, error) {
+	report := &ClassReport{MinecraftClasses: []string{}, ExternalPackages: []string{}}
+	defined := make(map[string]bool)
+	referenced := make(map[string]bool)
+
+	for _, f := range archive.Files() {
+		if !strings.HasSuffix(f.Name, ".class") || strings.HasPrefix(f.Name, "META-INF/") {
+			continue
+		}
+		if report.Classes >= maxAnalyzedClasses {
+			report.Truncated = true
+			break
+		}
+		data, err := archive.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		report.Classes++
+		info, err := ParseClass(data)
+		if err != nil {
+			report.Unreadable++
+			continue
+		}
+		defined[info.Name] = true
+		report.ReferencedStrings += len(info.Strings)
+		for _, c := range info.Classes {
+			referenced[c] = true
+		}
+	}
+
+	packages := make(map[string]bool)
+	for _, c := range sortedKeys(referenced) {
+		switch {
+		case defined[c] || isPlatformClass(c):
+		case strings.HasPrefix(c, "net/minecraft/") || strings.HasPrefix(c, "com/mojang/"):
+			if len(report.MinecraftClasses) < maxReportedClasses {
+				report.MinecraftClasses = append(report.MinecraftClasses, c)
+			} else {
+				report.Truncated = true
+			}
+		default:
+			if i := strings.LastIndexByte(c, '/'); i > 0 {
+				packages[c[:i]] = true
+			}
+		}
+	}
+	report.ExternalPackages = sortedKeys(packages)
+	if len(report.ExternalPackages) > maxReportedClasses {
+		report.ExternalPackages = report.ExternalPackages[:maxReportedClasses]
+		report.Truncated = true
+	}
+	return report, nil
+}
+
+// isPlatformClass reports whether a class ships with the Java runtime
+func isPlatformClass(name string) bool {
+	for _, prefix := range []string{"java/", "javax/", "jdk/", "sun/"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,637 @@
+package mods
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Pack kinds distinguished by pack_format
+const (
+	PackKindData     = "data"
+	PackKindResource = "resource"
+)
+
+// Compatibility statuses
+const (
+	CompatCompatible   = "compatible"
+	CompatIncompatible = "incompatible"
+	CompatUnknown      = "unknown" // nothing in the file declares compatibility
+)
+
+// packFormatEntry maps a range of Minecraft releases to their pack formats
+type packFormatEntry struct {
+	from, to     string // inclusive Minecraft version range
+	data, assets int
+}
+
+// packFormats lists data and resource pack formats by Minecraft release
+var packFormats = []packFormatEntry{
+	{"1.13", "1.14.4", 4, 4},
+	{"1.15", "1.16.1", 5, 5},
+	{"1.16.2", "1.16.5", 6, 6},
+	{"1.17", "1.17.1", 7, 7},
+	{"1.18", "1.18.1", 8, 8},
+	{"1.18.2", "1.18.2", 9, 8},
+	{"1.19", "1.19.2", 10, 9},
+	{"1.19.3", "1.19.3", 10, 12},
+	{"1.19.4", "1.19.4", 12, 13},
+	{"1.20", "1.20.1", 15, 15},
+	{"1.20.2", "1.20.2", 18, 18},
+	{"1.20.3", "1.20.4", 26, 22},
+	{"1.20.5", "1.20.6", 41, 32},
+	{"1.21", "1.21.1", 48, 34},
+	{"1.21.2", "1.21.3", 57, 42},
+	{"1.21.4", "1.21.4", 61, 46},
+	{"1.21.5", "1.21.5", 71, 55},
+	{"1.21.6", "1.21.6", 80, 63},
+	{"1.21.7", "1.21.8", 81, 64},
+}
+
+// PackFormatFor returns the data or resource pack format of a Minecraft release
+func PackFormatFor(minecraftVersion, kind string) (int, error) {
+	v := ParseVersion(minecraftVersion)
+	for _, entry := range packFormats {
+		if v.Compare(ParseVersion(entry.from)) >= 0 && v.Compare(ParseVersion(entry.to)) <= 0 {
+			if kind == PackKindResource {
+				return entry.assets, nil
+			}
+			return entry.data, nil
+		}
+	}
+	return 0, fmt.Errorf("no known pack format for Minecraft %s", minecraftVersion)
+}
+
+// minecraftReleases lists the releases packFormats covers, oldest first
+func minecraftReleases() []string {
+	var releases []string
+	for _, entry := range packFormats {
+		releases = append(releases, entry.from)
+		// Walk the patch releases between from and to within one minor version
+		major, patch, _ := strings.Cut(strings.TrimPrefix(entry.from, "1."), ".")
+		toMajor, toPatch, _ := strings.Cut(strings.TrimPrefix(entry.to, "1."), ".")
+		if major != toMajor {
+			releases = append(releases, entry.to)
+			continue
+		}
+		first, _ := strconv.Atoi(patch)
+		last, _ := strconv.Atoi(toPatch)
+		for n := first + 1; n <= last; n++ {
+			releases = append(releases, fmt.Sprintf("1.%s.%d", major, n))
+		}
+	}
+	return releases
+}
+
+// DeclaredMinecraftVersion returns the oldest Minecraft release a mod's
+// minecraft dependency or a pack's pack_format accepts, or "" when the file
+// declares neither or names no known release
+func DeclaredMinecraftVersion(filename string, content []byte) string {
+	descriptors, _ := ParseDescriptors(filename, content)
+	for _, d := range descriptors {
+		if d.Source != filename {
+			continue
+		}
+		for _, rel := range d.Relations {
+			if rel.Kind != RelationDepends || rel.ModID != "minecraft" {
+				continue
+			}
+			versionRange, err := rel.Range()
+			if err != nil {
+				return ""
+			}
+			for _, release := range minecraftReleases() {
+				if versionRange.Contains(ParseVersion(release)) {
+					return release
+				}
+			}
+			return ""
+		}
+	}
+
+	data, kind := content, PackKindData
+	if bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		archive, err := OpenArchive(content, DefaultArchiveLimits)
+		if err != nil {
+			return ""
+		}
+		data, kind = nil, ""
+		for _, f := range archive.Files() {
+			switch {
+			case f.Name == "pack.mcmeta":
+				if data, err = archive.ReadFile(f); err != nil {
+					return ""
+				}
+			case strings.HasPrefix(f.Name, "data/"):
+				kind = PackKindData
+			case strings.HasPrefix(f.Name, "assets/") && kind == "":
+				kind = PackKindResource
+			}
+		}
+		if data == nil {
+			return ""
+		}
+	} else if !strings.EqualFold(filepath.Base(filename), "pack.mcmeta") {
+		return ""
+	}
+	meta, err := parsePackMeta(data)
+	if err != nil {
+		return ""
+	}
+	low, high := meta.PackFormat, meta.PackFormat
+	if meta.SupportedMin > 0 {
+		low, high = meta.SupportedMin, meta.SupportedMax
+	}
+	for _, entry := range packFormats {
+		format := entry.data
+		if kind == PackKindResource {
+			format = entry.assets
+		}
+		if format >= low && format <= high {
+			return entry.from
+		}
+	}
+	return ""
+}
+
+// CompatTarget is the Minecraft installation a mod or pack is checked against
+type CompatTarget struct {
+	MinecraftVersion string `json:"minecraft_version"`
+	Loader           string `json:"loader,omitempty"` // fabric, quilt, forge or neoforge
+	LoaderVersion    string `json:"loader_version,omitempty"`
+}
+
+// CompatCheck is a single declared constraint compared against the target
+type CompatCheck struct {
+	Source     string `json:"source"`
+	Subject    string `json:"subject"` // minecraft, loader or pack_format
+	Required   string `json:"required"`
+	Target     string `json:"target"`
+	Compatible bool   `json:"compatible"`
+	Message    string `json:"message"`
+}
+
+// CompatResult is the outcome of a compatibility check
+type CompatResult struct {
+	Status string        `json:"status"`
+	Target CompatTarget  `json:"target"`
+	Checks []CompatCheck `json:"checks"`
+}
+
+// Compatible reports whether every declared constraint accepts the target
+func (r *CompatResult) Compatible() bool {
+	return r.Status == CompatCompatible
+}
+
+func (r *CompatResult) add(check CompatCheck) {
+	r.Checks = append(r.Checks, check)
+	switch {
+	case !check.Compatible:
+		r.Status = CompatIncompatible
+	case r.Status == CompatUnknown:
+		r.Status = CompatCompatible
+	}
+}
+
+// loaderRelationIDs maps loaders to the mod ID their version is declared under
+var loaderRelationIDs = map[string]string{
+	LoaderFabric:   "fabricloader",
+	LoaderQuilt:    "quilt_loader",
+	LoaderForge:    "forge",
+	LoaderNeoForge: "neoforge",
+}
+
+// CheckCompatibility reports whether a jar, pack or pack.mcmeta declares
+// compatibility with the target Minecraft version and loader
+func CheckCompatibility(filename string, content []byte, target CompatTarget) (*CompatResult, error) {
+	if target.MinecraftVersion == "" {
+		return nil, fmt.Errorf("target Minecraft version is required")
+	}
+	target.Loader = strings.ToLower(target.Loader)
+	result := &CompatResult{Status: CompatUnknown, Target: target, Checks: []CompatCheck{}}
+
+	if !bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		if strings.EqualFold(filepath.Base(filename), "pack.mcmeta") {
+			if err := checkPackFormat(result, "pack.mcmeta", content, PackKindData, target); err != nil {
+				return nil, err
+			}
+			return result, nil
+		}
+		return nil, fmt.Errorf("compatibility can only be checked for jars, packs and pack.mcmeta files")
+	}
+
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	// Packs declare a pack_format; a pack with both data and assets is checked as a data pack
+	var mcmeta *zip.File
+	kind := ""
+	for _, f := range archive.Files() {
+		switch {
+		case f.Name == "pack.mcmeta":
+			mcmeta = f
+		case strings.HasPrefix(f.Name, "data/"):
+			kind = PackKindData
+		case strings.HasPrefix(f.Name, "assets/") && kind == "":
+			kind = PackKindResource
+		}
+	}
+
+	descriptors, err := ParseDescriptors(filename, content)
+	if err != nil {
+		return nil, err
+	}
+	if len(descriptors) == 0 && mcmeta != nil {
+		data, err := archive.ReadFile(mcmeta)
+		if err != nil {
+			return nil, err
+		}
+		if kind == "" {
+			kind = PackKindData
+		}
+		if err := checkPackFormat(result, "pack.mcmeta", data, kind, target); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	// Only the mods of the uploaded jar are checked, not nested libraries
+	var top []ModDescriptor
+	for _, d := range descriptors {
+		if d.Source == filename {
+			top = append(top, d)
+		}
+	}
+	if target.Loader != "" && len(top) > 0 && !loaderAccepts(target, top) {
+		result.add(CompatCheck{
+			Source:   top[0].Source,
+			Subject:  "loader",
+			Required: top[0].Loader,
+			Target:   target.Loader,
+			Message:  fmt.Sprintf("%s is a %s mod and cannot be loaded by %s", top[0].ID, top[0].Loader, target.Loader),
+		})
+	}
+
+	for _, d := range top {
+		for _, rel := range d.Relations {
+			if rel.Kind != RelationDepends {
+				continue
+			}
+			subject, targetVersion := "", ""
+			switch {
+			case rel.ModID == "minecraft":
+				subject, targetVersion = "minecraft", target.MinecraftVersion
+			case target.LoaderVersion != "" && rel.ModID == loaderRelationIDs[target.Loader]:
+				subject, targetVersion = "loader", target.LoaderVersion
+			default:
+				continue
+			}
+
+			versionRange, err := rel.Range()
+			if err != nil {
+				return nil, fmt.Errorf("%s declares an invalid %s range: %w", d.ID, rel.ModID, err)
+			}
+			ok := versionRange.Contains(ParseVersion(targetVersion))
+			message := fmt.Sprintf("%s requires %s %s", d.ID, rel.ModID, rangeText(rel))
+			if ok {
+				message = fmt.Sprintf("%s accepts %s %s", d.ID, rel.ModID, targetVersion)
+			}
+			result.add(CompatCheck{
+				Source:     d.Source,
+				Subject:    subject,
+				Required:   rel.VersionRange,
+				Target:     targetVersion,
+				Compatible: ok,
+				Message:    message,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// loaderAccepts reports whether the target loader can load any of the mods.
+// Quilt loads Fabric mods, and NeoForge for 1.20.1 still loads Forge mods.
+func loaderAccepts(target CompatTarget, descriptors []ModDescriptor) bool {
+	for _, d := range descriptors {
+		switch {
+		case d.Loader == target.Loader:
+			return true
+		case target.Loader == LoaderQuilt && d.Loader == LoaderFabric:
+			return true
+		case target.Loader == LoaderNeoForge && d.Loader == LoaderForge && strings.HasPrefix(target.MinecraftVersion, "1.20.1"):
+			return true
+		}
+	}
+	return false
+}
+
+// checkPackFormat compares pack.mcmeta's pack_format and supported_formats
+// against the format of the target version
+func checkPackFormat(result *CompatResult, source string, data []byte, kind string, target CompatTarget) error {
+	meta, err := parsePackMeta(data)
+	if err != nil {
+		return err
+	}
+
+	want, err := PackFormatFor(target.MinecraftVersion, kind)
+	if err != nil {
+		return err
+	}
+
+	low, high := meta.PackFormat, meta.PackFormat
+	required := fmt.Sprintf("%d", meta.PackFormat)
+	if meta.SupportedMin > 0 {
+		low, high = meta.SupportedMin, meta.SupportedMax
+		required = fmt.Sprintf("%d-%d", low, high)
+	}
+	ok := want >= low && want <= high
+
+	message := fmt.Sprintf("%s pack declares format %s, Minecraft %s uses %d", kind, required, target.MinecraftVersion, want)
+	result.add(CompatCheck{
+		Source:     source,
+		Subject:    "pack_format",
+		Required:   required,
+		Target:     fmt.Sprintf("%d", want),
+		Compatible: ok,
+		Message:    message,
+	})
+	return nil
+}
+
+// PackMeta is the pack section of pack.mcmeta
+type PackMeta struct {
+	PackFormat   int
+	SupportedMin int
+	SupportedMax int
+	Description  interface{}
+}
+
+// parsePackMeta reads pack.mcmeta, whose supported_formats may be a single
+// number, a [min, max] pair or a {min_inclusive, max_inclusive} object
+func parsePackMeta(data []byte) (*PackMeta, error) {
+	var raw struct {
+		Pack struct {
+			PackFormat       *int            `json:"pack_format"`
+			SupportedFormats json.RawMessage `json:"supported_formats"`
+			Description      interface{}     `json:"description"`
+		} `json:"pack"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid pack.mcmeta: %w", err)
+	}
+	if raw.Pack.PackFormat == nil {
+		return nil, fmt.Errorf("pack.mcmeta is missing pack.pack_format")
+	}
+
+	meta := &PackMeta{PackFormat: *raw.Pack.PackFormat, Description: raw.Pack.Description}
+	if len(raw.Pack.SupportedFormats) == 0 {
+		return meta, nil
+	}
+
+	var single int
+	var pair []int
+	var object struct {
+		Min int `json:"min_inclusive"`
+		Max int `json:"max_inclusive"`
+	}
+	switch {
+	case json.Unmarshal(raw.Pack.SupportedFormats, &single) == nil:
+		meta.SupportedMin, meta.SupportedMax = single, single
+	case json.Unmarshal(raw.Pack.SupportedFormats, &pair) == nil && len(pair) == 2:
+		meta.SupportedMin, meta.SupportedMax = pair[0], pair[1]
+	case json.Unmarshal(raw.Pack.SupportedFormats, &object) == nil:
+		meta.SupportedMin, meta.SupportedMax = object.Min, object.Max
+	default:
+		return nil, fmt.Errorf("invalid supported_formats in pack.mcmeta")
+	}
+	return meta, nil
+}
+
+// SetPackFormat rewrites pack.mcmeta to declare a new pack format, dropping
+// supported_formats so the declaration is unambiguous
+func SetPackFormat(data []byte, format int) ([]byte, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid pack.mcmeta: %w", err)
+	}
+	pack, ok := doc["pack"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("pack.mcmeta is missing the pack section")
+	}
+	pack["pack_format"] = format
+	delete(pack, "supported_formats")
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// singularFoldersFormat is the data pack format of Minecraft 1.21, which
+// reads each registry folder from its singular name
+const singularFoldersFormat = 48
+
+// recipeResultIDFormat is the data pack format of Minecraft 1.20.5, where a
+// recipe's result.item became result.id
+const recipeResultIDFormat = 41
+
+// singularFolders maps the data pack folders Minecraft 1.21 renamed to their
+// new names
+var singularFolders = map[string]string{
+	"advancements":      "advancement",
+	"functions":         "function",
+	"item_modifiers":    "item_modifier",
+	"loot_tables":       "loot_table",
+	"predicates":        "predicate",
+	"recipes":           "recipe",
+	"structures":        "structure",
+	"tags/blocks":       "tags/block",
+	"tags/entity_types": "tags/entity_type",
+	"tags/fluids":       "tags/fluid",
+	"tags/functions":    "tags/function",
+	"tags/game_events":  "tags/game_event",
+	"tags/items":        "tags/item",
+}
+
+// legacyFolder returns the renamed folder a data pack entry is in, and the
+// namespace prefix and the rest of its path
+func legacyFolder(name string) (folder, prefix, rest string, ok bool) {
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) < 3 || parts[0] != "data" {
+		return "", "", "", false
+	}
+	prefix = parts[0] + "/" + parts[1] + "/"
+	for old := range singularFolders {
+		if strings.HasPrefix(parts[2], old+"/") {
+			return old, prefix, strings.TrimPrefix(parts[2], old+"/"), true
+		}
+	}
+	return "", "", "", false
+}
+
+// renameLegacyFolders moves every entry in a renamed folder to the
+// folder's 1.21 name
+func renameLegacyFolders(content []byte) ([]byte, error) {
+	return RenameArchive(content, func(name string) (string, bool) {
+		if folder, prefix, rest, ok := legacyFolder(name); ok {
+			return prefix + singularFolders[folder] + "/" + rest, true
+		}
+		return name, true
+	})
+}
+
+// checkPortedPack adds a check that a ported data pack no longer uses what
+// its new format dropped: the pre-1.21 folder names and result.item in
+// recipes
+func checkPortedPack(result *CompatResult, content []byte, format int) error {
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return err
+	}
+	legacy := make(map[string]bool)
+	var items []string
+	for _, f := range archive.Files() {
+		if folder, _, _, ok := legacyFolder(f.Name); ok && format >= singularFoldersFormat {
+			legacy[folder+"/"] = true
+		}
+		parts := strings.Split(f.Name, "/")
+		if format < recipeResultIDFormat || len(parts) < 4 || parts[0] != "data" || (parts[2] != "recipe" && parts[2] != "recipes") || !strings.HasSuffix(f.Name, ".json") {
+			continue
+		}
+		data, err := archive.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		var recipe struct {
+			Result json.RawMessage `json:"result"`
+		}
+		var result map[string]interface{}
+		if json.Unmarshal(data, &recipe) == nil && json.Unmarshal(recipe.Result, &result) == nil {
+			if _, ok := result["item"]; ok {
+				items = append(items, f.Name)
+			}
+		}
+	}
+
+	var problems []string
+	if len(legacy) > 0 {
+		problems = append(problems, "still uses the folders "+strings.Join(sortedKeys(legacy), ", "))
+	}
+	if len(items) > 0 {
+		sort.Strings(items)
+		problems = append(problems, "still uses result.item instead of result.id in "+strings.Join(items, ", "))
+	}
+	message := fmt.Sprintf("data pack layout matches format %d", format)
+	if len(problems) > 0 {
+		message = fmt.Sprintf("data pack format %d %s", format, strings.Join(problems, "; "))
+	}
+	result.add(CompatCheck{
+		Source:     "data/",
+		Subject:    "pack_layout",
+		Required:   fmt.Sprintf("%d", format),
+		Target:     fmt.Sprintf("%d", format),
+		Compatible: len(problems) == 0,
+		Message:    message,
+	})
+	return nil
+}
+
+// PortFunc rewrites one data pack JSON file for a newer Minecraft version
+type PortFunc func(name string, content []byte) ([]byte, error)
+
+// PortDataPack ports a data pack, or a standalone pack.mcmeta, to the pack
+// format of minecraftVersion. Each JSON file under data/ is passed to port and
+// pack.mcmeta is updated to the new format. Crossing into 1.21 also moves the
+// registry folders to their singular names. The result is re-checked, pack
+// layout included, so the caller can reject a port that was left incomplete.
+func PortDataPack(filename string, content []byte, minecraftVersion string, port PortFunc) ([]byte, *CompatResult, error) {
+	format, err := PackFormatFor(minecraftVersion, PackKindData)
+	if err != nil {
+		return nil, nil, err
+	}
+	target := CompatTarget{MinecraftVersion: minecraftVersion}
+
+	if !bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		if !strings.EqualFold(filepath.Base(filename), "pack.mcmeta") {
+			return nil, nil, fmt.Errorf("only data pack archives and pack.mcmeta files can be ported")
+		}
+		output, err := SetPackFormat(content, format)
+		if err != nil {
+			return nil, nil, err
+		}
+		result, err := CheckCompatibility(filename, output, target)
+		return output, result, err
+	}
+
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changes := make(map[string][]byte)
+	var mcmeta *zip.File
+	for _, f := range archive.Files() {
+		switch {
+		case f.Name == "pack.mcmeta":
+			mcmeta = f
+		case strings.HasPrefix(f.Name, "data/") && strings.HasSuffix(f.Name, ".json"):
+			if len(changes) >= maxEditedEntries {
+				return nil, nil, fmt.Errorf("data pack has more than %d JSON files", maxEditedEntries)
+			}
+			data, err := archive.ReadFile(f)
+			if err != nil {
+				return nil, nil, err
+			}
+			ported, err := port(f.Name, data)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to port %s: %w", f.Name, err)
+			}
+			ported = stripCodeFence(ported)
+			if err := validateJSON(ported); err != nil {
+				return nil, nil, fmt.Errorf("ported %s: %w", f.Name, err)
+			}
+			changes[f.Name] = ported
+		}
+	}
+	if mcmeta == nil {
+		return nil, nil, fmt.Errorf("archive is not a data pack: pack.mcmeta is missing")
+	}
+
+	data, err := archive.ReadFile(mcmeta)
+	if err != nil {
+		return nil, nil, err
+	}
+	meta, err := parsePackMeta(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if changes["pack.mcmeta"], err = SetPackFormat(data, format); err != nil {
+		return nil, nil, err
+	}
+
+	output, err := RewriteArchive(content, changes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if meta.PackFormat < singularFoldersFormat && format >= singularFoldersFormat {
+		if output, err = renameLegacyFolders(output); err != nil {
+			return nil, nil, err
+		}
+	}
+	result, err := CheckCompatibility(filename, output, target)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkPortedPack(result, output, format); err != nil {
+		return nil, nil, err
+	}
+	return output, result, nil
+}
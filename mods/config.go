@@ -0,0 +1,1100 @@
+package mods
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config syntaxes
+const (
+	ConfigTOML       = "toml"       // Forge and NeoForge *-common.toml, *-client.toml
+	ConfigProperties = "properties" // Java .properties such as server.properties
+	ConfigCfg        = "cfg"        // Forge 1.12 categories and BepInEx-style [Section] files
+	ConfigYAML       = "yaml"       // Bukkit config.yml and similar
+)
+
+// Config value kinds
+const (
+	ConfigString = "string"
+	ConfigNumber = "number"
+	ConfigBool   = "bool"
+	ConfigList   = "list"
+	ConfigTable  = "table"
+	ConfigText   = "text" // unquoted text, which these syntaxes read as a string
+)
+
+// maxReportedSettings caps the settings listed in a config's metadata
+const maxReportedSettings = 200
+
+// ConfigEntry is one setting of a config file. Sections, categories and
+// nesting are folded into the key with dots; list items add [index].
+type ConfigEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"` // as written, including quotes
+	Kind  string `json:"kind"`
+	Line  int    `json:"line"`
+	start int
+	end   int
+}
+
+// ConfigFile is a parsed config file. Comments, blank lines and layout are
+// not represented; edits splice new values into the original text.
+type ConfigFile struct {
+	Syntax  string        `json:"syntax"`
+	Entries []ConfigEntry `json:"entries"`
+}
+
+// Get returns the entry for a key
+func (c *ConfigFile) Get(key string) (ConfigEntry, bool) {
+	for _, e := range c.Entries {
+		if e.Key == key {
+			return e, true
+		}
+	}
+	return ConfigEntry{}, false
+}
+
+// ConfigChange is a setting whose value an edit changed
+type ConfigChange struct {
+	Key string `json:"key"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// ParseConfig reads the settings of a config file in the given syntax
+func ParseConfig(syntax string, content []byte) (*ConfigFile, error) {
+	if !utf8.Valid(content) {
+		return nil, fmt.Errorf("config is not valid UTF-8")
+	}
+	text := strings.TrimPrefix(string(content), "\ufeff")
+	offset := len(content) - len(text)
+
+	var entries []ConfigEntry
+	var err error
+	switch syntax {
+	case ConfigTOML:
+		var doc map[string]interface{}
+		if _, err := toml.Decode(text, &doc); err != nil {
+			return nil, err
+		}
+		entries, err = parseTOMLConfig(text)
+	case ConfigProperties:
+		entries, err = parsePropertiesConfig(text)
+	case ConfigCfg:
+		entries, err = parseCfgConfig(text)
+	case ConfigYAML:
+		entries, err = parseYAMLConfig(text)
+	default:
+		return nil, fmt.Errorf("unknown config syntax %q", syntax)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for i := range entries {
+		e := &entries[i]
+		if seen[e.Key] {
+			return nil, fmt.Errorf("line %d: duplicate key %s", e.Line, e.Key)
+		}
+		seen[e.Key] = true
+		e.start += offset
+		e.end += offset
+		if e.Kind == "" {
+			e.Kind = configValueKind(syntax, e.Value)
+		}
+	}
+	return &ConfigFile{Syntax: syntax, Entries: entries}, nil
+}
+
+var configNumber = regexp.MustCompile(`^[-+]?(0x[0-9a-fA-F_]+|[0-9][0-9_]*(\.[0-9_]+)?([eE][-+]?[0-9]+)?|\.[0-9]+|inf|nan)$`)
+
+// configValueKind classifies a value as written
+func configValueKind(syntax, value string) string {
+	switch {
+	case value == "":
+		return ConfigText
+	case value[0] == '"' || value[0] == '\'':
+		return ConfigString
+	case value[0] == '[':
+		return ConfigList
+	case value[0] == '{':
+		return ConfigTable
+	case value == "true" || value == "false":
+		return ConfigBool
+	case configNumber.MatchString(value):
+		return ConfigNumber
+	case syntax == ConfigYAML && (value[0] == '|' || value[0] == '>'):
+		return ConfigString
+	}
+	return ConfigText
+}
+
+// compatibleConfigKinds reports whether an edit may change a value of one
+// kind into the other. Quoted and unquoted strings are interchangeable, and
+// an empty value may take any kind.
+func compatibleConfigKinds(old ConfigEntry, updated ConfigEntry) bool {
+	text := func(kind string) bool { return kind == ConfigString || kind == ConfigText }
+	return old.Kind == updated.Kind || old.Value == "" || (text(old.Kind) && text(updated.Kind))
+}
+
+// EditConfigValues takes the values of an edited config and writes them into
+// the original text, keeping its comments, order and formatting. The edit
+// must have the same keys in the same order and keep each value's kind.
+func EditConfigValues(syntax string, original, edited []byte) ([]byte, []ConfigChange, error) {
+	before, err := ParseConfig(syntax, original)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid original config: %w", err)
+	}
+	after, err := ParseConfig(syntax, edited)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := sameConfigKeys(before, after); err != nil {
+		return nil, nil, err
+	}
+
+	var out strings.Builder
+	var changes []ConfigChange
+	last := 0
+	for i, old := range before.Entries {
+		updated := after.Entries[i]
+		if updated.Value == old.Value {
+			continue
+		}
+		if !compatibleConfigKinds(old, updated) {
+			return nil, nil, fmt.Errorf("%s changed from a %s to a %s", old.Key, old.Kind, updated.Kind)
+		}
+		out.Write(original[last:old.start])
+		out.WriteString(updated.Value)
+		last = old.end
+		changes = append(changes, ConfigChange{Key: old.Key, Old: old.Value, New: updated.Value})
+	}
+	if len(changes) == 0 {
+		return original, nil, nil
+	}
+	out.Write(original[last:])
+
+	output := []byte(out.String())
+	check, err := ParseConfig(syntax, output)
+	if err != nil {
+		return nil, nil, fmt.Errorf("edited values break the config: %w", err)
+	}
+	if err := sameConfigKeys(before, check); err != nil {
+		return nil, nil, fmt.Errorf("edited values break the config: %w", err)
+	}
+	return output, changes, nil
+}
+
+// sameConfigKeys checks that an edit kept every key in order
+func sameConfigKeys(before, after *ConfigFile) error {
+	for i, e := range before.Entries {
+		if i >= len(after.Entries) {
+			return fmt.Errorf("edit removed %s", e.Key)
+		}
+		if after.Entries[i].Key != e.Key {
+			if _, ok := after.Get(e.Key); !ok {
+				return fmt.Errorf("edit removed %s", e.Key)
+			}
+			if _, ok := before.Get(after.Entries[i].Key); !ok {
+				return fmt.Errorf("edit added %s", after.Entries[i].Key)
+			}
+			return fmt.Errorf("edit moved %s", e.Key)
+		}
+	}
+	if len(after.Entries) > len(before.Entries) {
+		return fmt.Errorf("edit added %s", after.Entries[len(before.Entries)].Key)
+	}
+	return nil
+}
+
+// configLine is one line of a config file without its line ending
+type configLine struct {
+	start int
+	text  string
+}
+
+func splitConfigLines(text string) []configLine {
+	var lines []configLine
+	start := 0
+	for start <= len(text) {
+		end := strings.IndexByte(text[start:], '\n')
+		if end < 0 {
+			if start < len(text) {
+				lines = append(lines, configLine{start, strings.TrimSuffix(text[start:], "\r")})
+			}
+			break
+		}
+		lines = append(lines, configLine{start, strings.TrimSuffix(text[start:start+end], "\r")})
+		start += end + 1
+	}
+	return lines
+}
+
+// indentOf returns the number of leading spaces and tabs
+func indentOf(s string) int {
+	return len(s) - len(strings.TrimLeft(s, " \t"))
+}
+
+// unquoteConfigKey removes the quotes around a key
+func unquoteConfigKey(key string) string {
+	key = strings.TrimSpace(key)
+	if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') && key[len(key)-1] == key[0] {
+		if key[0] == '"' {
+			if s, err := strconv.Unquote(key); err == nil {
+				return s
+			}
+		}
+		return key[1 : len(key)-1]
+	}
+	return key
+}
+
+// joinConfigKey appends a key to a dotted path
+func joinConfigKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// parseTOMLConfig finds the value of every key/value pair. toml.Decode has
+// already checked the syntax, so values are only scanned for their extent.
+func parseTOMLConfig(text string) ([]ConfigEntry, error) {
+	var entries []ConfigEntry
+	table := ""
+	arrays := make(map[string]int)
+	line := 1
+	pos := 0
+	advance := func(to int) {
+		line += strings.Count(text[pos:to], "\n")
+		pos = to
+	}
+	for pos < len(text) {
+		rest := text[pos:]
+		trimmed := strings.TrimLeft(rest, " \t\r\n")
+		advance(len(text) - len(trimmed))
+		if trimmed == "" {
+			break
+		}
+		eol := strings.IndexByte(trimmed, '\n')
+		if eol < 0 {
+			eol = len(trimmed)
+		}
+		switch {
+		case trimmed[0] == '#':
+			advance(pos + eol)
+		case strings.HasPrefix(trimmed, "[["):
+			end := strings.Index(trimmed, "]]")
+			name := tomlDottedKey(trimmed[2:end])
+			table = fmt.Sprintf("%s[%d]", name, arrays[name])
+			arrays[name]++
+			advance(pos + eol)
+		case trimmed[0] == '[':
+			end := strings.IndexByte(trimmed, ']')
+			table = tomlDottedKey(trimmed[1:end])
+			advance(pos + eol)
+		default:
+			eq := tomlKeyEnd(trimmed)
+			if eq < 0 {
+				return nil, fmt.Errorf("line %d: expected key = value", line)
+			}
+			key := joinConfigKey(table, tomlDottedKey(trimmed[:eq]))
+			valueStart := pos + eq + 1
+			valueStart += len(text[valueStart:]) - len(strings.TrimLeft(text[valueStart:], " \t"))
+			valueEnd := scanTOMLValue(text, valueStart)
+			advance(valueStart)
+			entries = append(entries, ConfigEntry{Key: key, Value: text[valueStart:valueEnd], Line: line, start: valueStart, end: valueEnd})
+			advance(valueEnd)
+			if eol := strings.IndexByte(text[pos:], '\n'); eol >= 0 {
+				advance(pos + eol)
+			} else {
+				advance(len(text))
+			}
+		}
+	}
+	return entries, nil
+}
+
+// tomlKeyEnd returns the index of the = after a possibly quoted, dotted key
+func tomlKeyEnd(s string) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '=':
+			return i
+		case c == '\n':
+			return -1
+		}
+	}
+	return -1
+}
+
+// tomlDottedKey normalises a dotted key, unquoting its parts
+func tomlDottedKey(s string) string {
+	var parts []string
+	var current strings.Builder
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			current.WriteByte(c)
+			if c == '\\' && quote == '"' && i+1 < len(s) {
+				i++
+				current.WriteByte(s[i])
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			current.WriteByte(c)
+		case c == '.':
+			parts = append(parts, unquoteConfigKey(current.String()))
+			current.Reset()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	parts = append(parts, unquoteConfigKey(current.String()))
+	return strings.Join(parts, ".")
+}
+
+// scanTOMLValue returns the end of the value starting at pos, before any
+// trailing whitespace and comment
+func scanTOMLValue(text string, pos int) int {
+	rest := text[pos:]
+	switch {
+	case strings.HasPrefix(rest, `"""`), strings.HasPrefix(rest, "'''"):
+		delim := rest[:3]
+		for i := 3; i < len(rest); i++ {
+			if delim == `"""` && rest[i] == '\\' {
+				i++
+				continue
+			}
+			if strings.HasPrefix(rest[i:], delim) {
+				end := i + 3
+				for end < len(rest) && rest[end] == delim[0] {
+					end++ // up to two quotes may end the content
+				}
+				return pos + end
+			}
+		}
+		return len(text)
+	case rest[0] == '"' || rest[0] == '\'':
+		return pos + scanQuoted(rest)
+	case rest[0] == '[' || rest[0] == '{':
+		depth := 0
+		for i := 0; i < len(rest); i++ {
+			switch c := rest[i]; c {
+			case '"', '\'':
+				if strings.HasPrefix(rest[i:], `"""`) || strings.HasPrefix(rest[i:], "'''") {
+					i += scanTOMLValue(rest, i) - i - 1
+				} else {
+					i += scanQuoted(rest[i:]) - 1
+				}
+			case '#':
+				for i < len(rest) && rest[i] != '\n' {
+					i++
+				}
+			case '[', '{':
+				depth++
+			case ']', '}':
+				if depth--; depth == 0 {
+					return pos + i + 1
+				}
+			}
+		}
+		return len(text)
+	}
+	end := strings.IndexAny(rest, "#\n")
+	if end < 0 {
+		end = len(rest)
+	}
+	return pos + len(strings.TrimRight(rest[:end], " \t\r"))
+}
+
+// scanQuoted returns the length of the single-line string at the start of s
+func scanQuoted(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote == '"':
+			i++
+		case s[i] == quote:
+			return i + 1
+		case s[i] == '\n':
+			return i
+		}
+	}
+	return len(s)
+}
+
+// parsePropertiesConfig reads Java properties: key=value, key:value or
+// key value, with backslash line continuations
+func parsePropertiesConfig(text string) ([]ConfigEntry, error) {
+	var entries []ConfigEntry
+	lines := splitConfigLines(text)
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		body := strings.TrimLeft(l.text, " \t\f")
+		if body == "" || body[0] == '#' || body[0] == '!' {
+			continue
+		}
+		lineNumber := i + 1
+		start := l.start + len(l.text) - len(body)
+
+		var key strings.Builder
+		j := 0
+		for ; j < len(body); j++ {
+			c := body[j]
+			if c == '\\' && j+1 < len(body) {
+				j++
+				key.WriteByte(body[j])
+				continue
+			}
+			if c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' {
+				break
+			}
+			key.WriteByte(c)
+		}
+		for j < len(body) && (body[j] == ' ' || body[j] == '\t' || body[j] == '\f') {
+			j++
+		}
+		if j < len(body) && (body[j] == '=' || body[j] == ':') {
+			j++
+			for j < len(body) && (body[j] == ' ' || body[j] == '\t' || body[j] == '\f') {
+				j++
+			}
+		}
+
+		valueStart := start + j
+		valueEnd := l.start + len(l.text)
+		for continuesProperty(lines[i].text) && i+1 < len(lines) {
+			i++
+			valueEnd = lines[i].start + len(lines[i].text)
+		}
+		entries = append(entries, ConfigEntry{Key: key.String(), Value: text[valueStart:valueEnd], Line: lineNumber, start: valueStart, end: valueEnd})
+	}
+	return entries, nil
+}
+
+// continuesProperty reports whether a line ends in an odd number of backslashes
+func continuesProperty(line string) bool {
+	n := len(line) - len(strings.TrimRight(line, "\\"))
+	return n%2 == 1
+}
+
+// cfgTypePrefix matches the type prefix of a Forge 1.12 setting such as I:
+var cfgTypePrefix = regexp.MustCompile(`^([BIDS]):`)
+
+// parseCfgConfig reads Forge 1.12 configs, where categories open with
+// name { and settings look like I:name=1 or S:list <, and INI-style files
+// with [Section] headers
+func parseCfgConfig(text string) ([]ConfigEntry, error) {
+	var entries []ConfigEntry
+	var categories []string
+	section := ""
+	lines := splitConfigLines(text)
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		body := strings.TrimSpace(l.text)
+		switch {
+		case body == "" || body[0] == '#' || body[0] == ';' || strings.HasPrefix(body, "//"):
+			continue
+		case body[0] == '[' && body[len(body)-1] == ']':
+			section, categories = strings.TrimSpace(body[1:len(body)-1]), nil
+			continue
+		case body == "}":
+			if len(categories) == 0 {
+				return nil, fmt.Errorf("line %d: } without an open category", i+1)
+			}
+			categories = categories[:len(categories)-1]
+			continue
+		case strings.HasSuffix(body, "{") && !strings.Contains(body, "="):
+			categories = append(categories, unquoteConfigKey(strings.TrimSuffix(body, "{")))
+			continue
+		}
+
+		path := strings.Join(append([]string{section}, categories...), ".")
+		path = strings.Trim(path, ".")
+		kind := ""
+		if m := cfgTypePrefix.FindStringSubmatch(body); m != nil {
+			kind = map[string]string{"B": ConfigBool, "I": ConfigNumber, "D": ConfigNumber, "S": ConfigText}[m[1]]
+			body = body[2:]
+		}
+
+		if strings.HasSuffix(body, "<") && !strings.Contains(body, "=") {
+			key := joinConfigKey(path, unquoteConfigKey(strings.TrimSuffix(body, "<")))
+			// the value is every line up to the closing >, with their line endings
+			j := i + 1
+			for j < len(lines) && strings.TrimSpace(lines[j].text) != ">" {
+				j++
+			}
+			if j == len(lines) {
+				return nil, fmt.Errorf("line %d: list %s is not closed with >", i+1, key)
+			}
+			start, end := lines[i+1].start, lines[j].start
+			entries = append(entries, ConfigEntry{Key: key, Value: text[start:end], Kind: ConfigList, Line: i + 1, start: start, end: end})
+			i = j
+			continue
+		}
+
+		eq := strings.IndexByte(l.text, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected name=value", i+1)
+		}
+		key := strings.TrimSpace(l.text[:eq])
+		key = unquoteConfigKey(cfgTypePrefix.ReplaceAllString(key, ""))
+		valueStart := l.start + eq + 1
+		value := l.text[eq+1:]
+		valueStart += len(value) - len(strings.TrimLeft(value, " \t"))
+		valueEnd := l.start + len(strings.TrimRight(l.text, " \t"))
+		valueEnd = max(valueEnd, valueStart)
+		if kind == ConfigBool && text[valueStart:valueEnd] != "true" && text[valueStart:valueEnd] != "false" {
+			return nil, fmt.Errorf("line %d: %s must be true or false", i+1, key)
+		}
+		if kind == ConfigNumber && !configNumber.MatchString(text[valueStart:valueEnd]) {
+			return nil, fmt.Errorf("line %d: %s must be a number", i+1, key)
+		}
+		entries = append(entries, ConfigEntry{Key: joinConfigKey(path, key), Value: text[valueStart:valueEnd], Kind: kind, Line: i + 1, start: valueStart, end: valueEnd})
+	}
+	if len(categories) > 0 {
+		return nil, fmt.Errorf("category %s is not closed", strings.Join(categories, "."))
+	}
+	return entries, nil
+}
+
+// yamlFrame is an open mapping key or sequence item while reading YAML
+type yamlFrame struct {
+	indent int
+	path   string
+	key    bool // a key whose value is on the following lines
+	value  bool // a key or item whose value has been read
+	items  int  // sequence items seen under a key
+	segs   []interface{}
+}
+
+// yamlNode is a key or sequence item read from YAML. Its path holds mapping
+// keys as strings and sequence indexes as ints; a key with nothing after the
+// colon is a container, whose value is nested or missing.
+type yamlNode struct {
+	path      []interface{}
+	entry     ConfigEntry
+	container bool
+}
+
+// parseYAMLConfig reads the block mappings and sequences of a single YAML
+// document. Scalars, flow collections and block scalars are values; anchors,
+// tags and multi-line plain scalars are kept as written.
+func parseYAMLConfig(text string) ([]ConfigEntry, error) {
+	nodes, err := scanYAML(text)
+	if err != nil {
+		return nil, err
+	}
+	var entries []ConfigEntry
+	for _, node := range nodes {
+		if !node.container {
+			entries = append(entries, node.entry)
+		}
+	}
+	return entries, nil
+}
+
+// withSegment returns a copy of path with one more segment
+func withSegment(path []interface{}, seg interface{}) []interface{} {
+	return append(append([]interface{}{}, path...), seg)
+}
+
+// scanYAML reads the keys and sequence items of a single YAML document in order
+func scanYAML(text string) ([]yamlNode, error) {
+	var nodes []yamlNode
+	var stack []*yamlFrame
+	lines := splitConfigLines(text)
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		body := strings.TrimLeft(l.text, " ")
+		if strings.TrimSpace(body) == "" || body[0] == '#' {
+			continue
+		}
+		if body[0] == '\t' {
+			return nil, fmt.Errorf("line %d: tabs cannot indent YAML", i+1)
+		}
+		if strings.HasPrefix(body, "---") || strings.HasPrefix(body, "...") {
+			if len(nodes) > 0 || len(stack) > 0 {
+				return nil, fmt.Errorf("line %d: only one YAML document is supported", i+1)
+			}
+			continue
+		}
+		if body[0] == '%' {
+			continue // directive
+		}
+		indent := len(l.text) - len(body)
+		isItem := body == "-" || strings.HasPrefix(body, "- ")
+
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if indent > top.indent && top.value {
+				return nil, fmt.Errorf("line %d: %s already has a value", i+1, top.path)
+			}
+			if indent > top.indent || (indent == top.indent && top.key && isItem) {
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+
+		column := indent
+		path := ""
+		var segs []interface{}
+		if len(stack) > 0 {
+			path = stack[len(stack)-1].path
+			segs = stack[len(stack)-1].segs
+		}
+		if isItem {
+			if len(stack) == 0 || !stack[len(stack)-1].key {
+				return nil, fmt.Errorf("line %d: sequence item without a key", i+1)
+			}
+			parent := stack[len(stack)-1]
+			path = fmt.Sprintf("%s[%d]", parent.path, parent.items)
+			segs = withSegment(parent.segs, parent.items)
+			parent.items++
+			stack = append(stack, &yamlFrame{indent: column, path: path, segs: segs})
+			rest := strings.TrimPrefix(body, "-")
+			body = strings.TrimLeft(rest, " ")
+			column += 1 + len(rest) - len(body)
+			if body == "" || body[0] == '#' {
+				continue
+			}
+			if body == "-" || strings.HasPrefix(body, "- ") {
+				return nil, fmt.Errorf("line %d: nested sequences are not supported", i+1)
+			}
+			if yamlKeyEnd(body) < 0 {
+				start := l.start + column
+				end, next, err := scanYAMLValue(lines, i, start, column-1, text)
+				if err != nil {
+					return nil, err
+				}
+				entry := ConfigEntry{Key: path, Value: text[start:end], Line: i + 1, start: start, end: end}
+				nodes = append(nodes, yamlNode{path: segs, entry: entry})
+				stack[len(stack)-1].value = true
+				i = next
+				continue
+			}
+		}
+
+		colon := yamlKeyEnd(body)
+		if colon < 0 {
+			return nil, fmt.Errorf("line %d: expected key: value", i+1)
+		}
+		if body[0] == '?' {
+			return nil, fmt.Errorf("line %d: complex keys are not supported", i+1)
+		}
+		name := unquoteConfigKey(body[:colon])
+		key := joinConfigKey(path, name)
+		segs = withSegment(segs, name)
+		rest := body[colon+1:]
+		trimmed := strings.TrimLeft(rest, " ")
+		if trimmed == "" || trimmed[0] == '#' {
+			nodes = append(nodes, yamlNode{path: segs, entry: ConfigEntry{Key: key, Line: i + 1}, container: true})
+			stack = append(stack, &yamlFrame{indent: column, path: key, key: true, segs: segs})
+			continue
+		}
+		start := l.start + column + colon + 1 + len(rest) - len(trimmed)
+		end, next, err := scanYAMLValue(lines, i, start, column, text)
+		if err != nil {
+			return nil, err
+		}
+		entry := ConfigEntry{Key: key, Value: text[start:end], Line: i + 1, start: start, end: end}
+		nodes = append(nodes, yamlNode{path: segs, entry: entry})
+		i = next
+		stack = append(stack, &yamlFrame{indent: column, path: key, value: true, segs: segs})
+	}
+	return nodes, nil
+}
+
+// yamlKeyEnd returns the index of the colon ending a mapping key, or -1
+func yamlKeyEnd(s string) int {
+	if s[0] == '"' || s[0] == '\'' {
+		end := scanYAMLQuoted(s)
+		if end < len(s) && s[end] == ':' && (end+1 == len(s) || s[end+1] == ' ') {
+			return end
+		}
+		return -1
+	}
+	if s[0] == '[' || s[0] == '{' {
+		return -1
+	}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == ':' && (i+1 == len(s) || s[i+1] == ' '):
+			return i
+		case s[i] == '#' && i > 0 && s[i-1] == ' ':
+			return -1
+		}
+	}
+	return -1
+}
+
+// scanYAMLQuoted returns the length of the quoted scalar at the start of s;
+// single quotes escape themselves by doubling
+func scanYAMLQuoted(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote && quote == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == quote:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// scanYAMLValue finds the end of the value starting at start on line i. Block
+// scalars and flow collections may continue on later lines; it returns the
+// value's end and the last line it used.
+func scanYAMLValue(lines []configLine, i, start, indent int, text string) (int, int, error) {
+	l := lines[i]
+	value := text[start : l.start+len(l.text)]
+	switch value[0] {
+	case '|', '>':
+		end, last := start+len(strings.TrimRight(strings.SplitN(value, " #", 2)[0], " ")), i
+		for j := i + 1; j < len(lines); j++ {
+			body := strings.TrimSpace(lines[j].text)
+			if body != "" && indentOf(lines[j].text) <= indent {
+				break
+			}
+			if body != "" {
+				end, last = lines[j].start+len(strings.TrimRight(lines[j].text, " ")), j
+			}
+		}
+		return end, last, nil
+	case '"', '\'':
+		rest := text[start:]
+		n := scanYAMLQuoted(rest)
+		if n > len(rest) || rest[n-1] != value[0] || n == 1 {
+			return 0, 0, fmt.Errorf("line %d: unterminated string", i+1)
+		}
+		end := start + n
+		last := i + strings.Count(rest[:n], "\n")
+		tail := strings.TrimSpace(text[end : lines[last].start+len(lines[last].text)])
+		if tail != "" && tail[0] != '#' {
+			return 0, 0, fmt.Errorf("line %d: unexpected %q after string", last+1, tail)
+		}
+		return end, last, nil
+	case '[', '{':
+		depth := 0
+		rest := text[start:]
+		for k := 0; k < len(rest); k++ {
+			switch rest[k] {
+			case '"', '\'':
+				k += scanYAMLQuoted(rest[k:]) - 1
+			case '[', '{':
+				depth++
+			case ']', '}':
+				if depth--; depth == 0 {
+					end := start + k + 1
+					last := i + strings.Count(rest[:k], "\n")
+					return end, last, nil
+				}
+			}
+		}
+		return 0, 0, fmt.Errorf("line %d: unclosed %c", i+1, value[0])
+	}
+	if comment := strings.Index(value, " #"); comment >= 0 {
+		value = value[:comment]
+	}
+	end, last := start+len(strings.TrimRight(value, " ")), i
+	// plain scalars continue on more deeply indented lines
+	for j := i + 1; j < len(lines); j++ {
+		body := strings.TrimSpace(lines[j].text)
+		if body == "" || body[0] == '#' || indentOf(lines[j].text) <= indent || yamlKeyEnd(body) >= 0 || strings.HasPrefix(body, "- ") {
+			break
+		}
+		end, last = lines[j].start+len(strings.TrimRight(lines[j].text, " ")), j
+	}
+	return end, last, nil
+}
+
+// decodeYAML reads a single YAML document into maps, slices and scalars.
+// Strings, booleans and nulls are decoded; numbers stay as written.
+func decodeYAML(text string) (map[string]interface{}, error) {
+	nodes, err := scanYAML(strings.TrimPrefix(text, "\ufeff"))
+	if err != nil {
+		return nil, err
+	}
+	var root interface{} = map[string]interface{}{}
+	for _, node := range nodes {
+		var value interface{}
+		if !node.container {
+			if value, err = yamlValue(node.entry.Value); err != nil {
+				return nil, fmt.Errorf("line %d: %w", node.entry.Line, err)
+			}
+		}
+		root = setYAML(root, node.path, value, node.container)
+	}
+	return root.(map[string]interface{}), nil
+}
+
+// setYAML stores value at path below node, creating maps and slices on the way
+func setYAML(node interface{}, path []interface{}, value interface{}, container bool) interface{} {
+	if len(path) == 0 {
+		if container {
+			return node
+		}
+		return value
+	}
+	switch seg := path[0].(type) {
+	case int:
+		list, _ := node.([]interface{})
+		for len(list) <= seg {
+			list = append(list, nil)
+		}
+		list[seg] = setYAML(list[seg], path[1:], value, container)
+		return list
+	default:
+		m, _ := node.(map[string]interface{})
+		if m == nil {
+			m = make(map[string]interface{})
+		}
+		key := seg.(string)
+		m[key] = setYAML(m[key], path[1:], value, container)
+		return m
+	}
+}
+
+// yamlValue decodes a scalar, flow collection or block scalar as written in
+// the document
+func yamlValue(raw string) (interface{}, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	switch raw[0] {
+	case '|', '>':
+		return yamlBlockScalar(raw), nil
+	case '"':
+		folded := foldYAMLLines(raw)
+		s, err := strconv.Unquote(folded)
+		if err != nil {
+			// YAML allows escapes and raw characters that Go does not
+			s = strings.ReplaceAll(folded[1:len(folded)-1], `\"`, `"`)
+		}
+		return s, nil
+	case '\'':
+		return strings.ReplaceAll(foldYAMLLines(raw[1:len(raw)-1]), "''", "'"), nil
+	case '[':
+		var list []interface{}
+		for _, item := range splitYAMLFlow(raw[1 : len(raw)-1]) {
+			value, err := yamlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	case '{':
+		m := make(map[string]interface{})
+		for _, item := range splitYAMLFlow(raw[1 : len(raw)-1]) {
+			colon := yamlKeyEnd(item)
+			if colon < 0 {
+				m[unquoteConfigKey(item)] = nil
+				continue
+			}
+			value, err := yamlValue(item[colon+1:])
+			if err != nil {
+				return nil, err
+			}
+			m[unquoteConfigKey(item[:colon])] = value
+		}
+		return m, nil
+	}
+	switch strings.ToLower(raw) {
+	case "~", "null":
+		return nil, nil
+	case "true", "yes", "on":
+		return true, nil
+	case "false", "no", "off":
+		return false, nil
+	}
+	return foldYAMLLines(raw), nil
+}
+
+// foldYAMLLines joins the lines of a multi-line flow scalar with spaces
+func foldYAMLLines(s string) string {
+	if !strings.Contains(s, "\n") {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	return strings.Join(lines, " ")
+}
+
+// yamlBlockScalar decodes a literal (|) or folded (>) block scalar, keeping
+// a single final newline unless the header strips it
+func yamlBlockScalar(raw string) string {
+	header, body, _ := strings.Cut(raw, "\n")
+	lines := strings.Split(body, "\n")
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" && (indent < 0 || indentOf(line) < indent) {
+			indent = indentOf(line)
+		}
+	}
+	for i, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			lines[i] = strings.TrimRight(line[indent:], "\r")
+		} else {
+			lines[i] = strings.TrimSpace(line)
+		}
+	}
+	var s string
+	if header[0] == '>' {
+		s = strings.Join(lines, " ")
+	} else {
+		s = strings.Join(lines, "\n")
+	}
+	if !strings.Contains(header, "-") && s != "" {
+		s += "\n"
+	}
+	return s
+}
+
+// splitYAMLFlow splits the inside of a flow collection at its top-level commas
+func splitYAMLFlow(s string) []string {
+	var items []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			i += scanYAMLQuoted(s[i:]) - 1
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	}
+	return items
+}
+
+// quoteYAMLString writes a string in the quote style of the value it
+// replaces. A plain string that YAML would read differently, such as one
+// starting with & or containing ": ", is single-quoted instead.
+func quoteYAMLString(message, original string) string {
+	switch {
+	case strings.HasPrefix(original, `"`) || strings.Contains(message, "\n"):
+		return strconv.Quote(message)
+	case !strings.HasPrefix(original, "'") && message != "" && !strings.ContainsRune("&*!%@`|>?:,-#[]{}'\" ", rune(message[0])) &&
+		!strings.Contains(message, ": ") && !strings.Contains(message, " #") && !strings.HasSuffix(message, " ") && !strings.HasSuffix(message, ":"):
+		if value, err := yamlValue(message); err == nil && value == message {
+			if _, err := strconv.ParseFloat(message, 64); err != nil {
+				return message
+			}
+		}
+	}
+	return "'" + strings.ReplaceAll(message, "'", "''") + "'"
+}
+
+// configFormat handles a mod config syntax. The AI may change values only:
+// the edited values are written into the original text.
+type configFormat struct {
+	syntax string
+}
+
+func (f configFormat) Info() FormatInfo {
+	info := FormatInfo{
+		Name:      f.syntax,
+		GameTypes: []GameType{GameTypeMinecraft},
+		MIMETypes: []string{"text/plain"},
+		MaxSize:   1024 * 1024,
+		Editable:  true,
+	}
+	switch f.syntax {
+	case ConfigTOML:
+		info.Extensions = []string{".toml"}
+		info.MIMETypes = append(info.MIMETypes, "application/toml")
+	case ConfigProperties:
+		info.Extensions = []string{".properties"}
+		info.MIMETypes = append(info.MIMETypes, "text/x-java-properties")
+	case ConfigCfg:
+		info.Extensions = []string{".cfg"}
+	case ConfigYAML:
+		info.Extensions = []string{".yml", ".yaml"}
+		info.MIMETypes = append(info.MIMETypes, "application/yaml", "application/x-yaml", "text/yaml")
+	}
+	return info
+}
+
+func (f configFormat) Detect(filename string, content []byte) bool {
+	if !isText(content) {
+		return false
+	}
+	_, err := ParseConfig(f.syntax, content)
+	return err == nil
+}
+
+func (f configFormat) Validate(filename string, content []byte) error {
+	if _, err := ParseConfig(f.syntax, content); err != nil {
+		return fmt.Errorf("invalid %s config: %w", f.syntax, err)
+	}
+	return nil
+}
+
+func (f configFormat) ExtractMetadata(content []byte) (map[string]interface{}, error) {
+	config, err := ParseConfig(f.syntax, content)
+	if err != nil {
+		return nil, err
+	}
+	metadata := map[string]interface{}{
+		"format":   f.syntax,
+		"settings": len(config.Entries),
+		"entries":  config.Entries[:min(len(config.Entries), maxReportedSettings)],
+	}
+	return metadata, nil
+}
+
+// Rewrite writes the edited values into the original, rejecting edits that
+// add, remove or move settings or change a value's kind
+func (f configFormat) Rewrite(original, edited []byte) ([]byte, error) {
+	output, _, err := EditConfigValues(f.syntax, original, stripCodeFence(edited))
+	return output, err
+}
+
+// ReportChanges lists each setting the edit changed
+func (f configFormat) ReportChanges(original, edited []byte) ([]string, error) {
+	_, changes, err := EditConfigValues(f.syntax, original, stripCodeFence(edited))
+	if err != nil {
+		return nil, err
+	}
+	var notes []string
+	for _, c := range changes {
+		notes = append(notes, fmt.Sprintf("%s: %s -> %s", c.Key, strings.TrimSpace(c.Old), strings.TrimSpace(c.New)))
+	}
+	return notes, nil
+}
@@ -0,0 +1,277 @@
+package mods
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// platformIDs are provided by the game or loader rather than by an uploaded
+// mod. They are only checked when the caller supplies their versions.
+var platformIDs = map[string]bool{
+	"minecraft":    true,
+	"java":         true,
+	"fabricloader": true,
+	"quilt_loader": true,
+	"forge":        true,
+	"neoforge":     true,
+	"javafml":      true,
+}
+
+// baseGameMasters are the lowercased master files shipped with each Bethesda game
+var baseGameMasters = map[GameType][]string{
+	GameTypeOblivion:  {"oblivion.esm", "dlcshiveringisles.esp", "knights.esp"},
+	GameTypeSkyrim:    {"skyrim.esm", "update.esm", "dawnguard.esm", "hearthfires.esm", "dragonborn.esm"},
+	GameTypeSkyrimSE:  {"skyrim.esm", "update.esm", "dawnguard.esm", "hearthfires.esm", "dragonborn.esm", "_resourcepack.esl"},
+	GameTypeFallout4:  {"fallout4.esm", "dlcrobot.esm", "dlcworkshop01.esm", "dlccoast.esm", "dlcworkshop02.esm", "dlcworkshop03.esm", "dlcnukaworld.esm", "dlcultrahighresolution.esm"},
+	GameTypeStarfield: {"starfield.esm", "constellation.esm", "oldmars.esm", "blueprintships-starfield.esm", "shatteredspace.esm"},
+}
+
+// isBaseGameMaster reports whether a plugin master ships with the game.
+// Creation Club content (cc*) and Starfield's SFBGS masters are treated as
+// base game content.
+func isBaseGameMaster(gameType GameType, id string) bool {
+	for _, master := range baseGameMasters[gameType] {
+		if master == id {
+			return true
+		}
+	}
+	switch gameType {
+	case GameTypeSkyrimSE, GameTypeFallout4:
+		return strings.HasPrefix(id, "cc")
+	case GameTypeStarfield:
+		return strings.HasPrefix(id, "sfbgs")
+	}
+	return false
+}
+
+// DependencyIssue describes a single problem found while resolving a mod set
+type DependencyIssue struct {
+	ModID      string       `json:"mod_id"`
+	Dependency string       `json:"dependency"`
+	Kind       RelationKind `json:"kind"`
+	Required   string       `json:"required,omitempty"`
+	Found      string       `json:"found,omitempty"`
+	Message    string       `json:"message"`
+}
+
+// DependencyReport is the outcome of resolving a set of mods
+type DependencyReport struct {
+	Mods         []ModDescriptor   `json:"mods"`
+	Missing      []DependencyIssue `json:"missing"`
+	Incompatible []DependencyIssue `json:"incompatible"`
+	Cycles       [][]string        `json:"cycles"`
+	Warnings     []DependencyIssue `json:"warnings"`
+}
+
+// Loadable reports whether the mod set has no missing, incompatible or circular dependencies
+func (r *DependencyReport) Loadable() bool {
+	return len(r.Missing) == 0 && len(r.Incompatible) == 0 && len(r.Cycles) == 0
+}
+
+// isNestedSource reports whether a descriptor came from a jar inside another jar
+func isNestedSource(source string) bool {
+	return strings.Contains(source, "!/")
+}
+
+// ResolveDependencies builds the dependency graph of a mod set and reports
+// missing, incompatible and circular dependencies. environment optionally
+// gives the versions of platform IDs such as minecraft or fabricloader.
+func ResolveDependencies(descriptors []ModDescriptor, environment map[string]string) *DependencyReport {
+	report := &DependencyReport{
+		Mods:         descriptors,
+		Missing:      []DependencyIssue{},
+		Incompatible: []DependencyIssue{},
+		Cycles:       [][]string{},
+		Warnings:     []DependencyIssue{},
+	}
+
+	// Index every ID a mod answers to, including the IDs it provides. Jars
+	// often bundle the same library, and the loader keeps its highest version;
+	// only two top-level mods with one ID clash.
+	present := make(map[string]*ModDescriptor)
+	shadowed := make(map[*ModDescriptor]bool)
+	for i := range descriptors {
+		d := &descriptors[i]
+		if existing, ok := present[d.ID]; ok && existing.Source != d.Source {
+			if !isNestedSource(existing.Source) && !isNestedSource(d.Source) {
+				report.Incompatible = append(report.Incompatible, DependencyIssue{
+					ModID:   d.ID,
+					Found:   existing.Source,
+					Message: fmt.Sprintf("%s is present twice (%s and %s)", d.ID, existing.Source, d.Source),
+				})
+				continue
+			}
+			if ParseVersion(d.Version).Compare(ParseVersion(existing.Version)) <= 0 {
+				shadowed[d] = true
+				continue
+			}
+			shadowed[existing] = true
+		}
+		present[d.ID] = d
+		for _, provided := range d.Provides {
+			if existing, ok := present[provided]; !ok || shadowed[existing] {
+				present[provided] = d
+			}
+		}
+	}
+
+	lookup := func(id string) (string, bool) {
+		if d, ok := present[id]; ok {
+			return d.Version, true
+		}
+		if version, ok := environment[id]; ok {
+			return version, true
+		}
+		return "", false
+	}
+
+	edges := make(map[string][]string)
+	for i := range descriptors {
+		d := &descriptors[i]
+		if shadowed[d] {
+			continue
+		}
+		for _, rel := range d.Relations {
+			version, found := lookup(rel.ModID)
+
+			// Platform and base game dependencies can only be checked against a supplied environment
+			if !found && (platformIDs[rel.ModID] || (d.Loader == LoaderPlugin && isBaseGameMaster(d.GameType, rel.ModID))) {
+				continue
+			}
+
+			versionRange, err := rel.Range()
+			if err != nil {
+				report.Warnings = append(report.Warnings, issue(d, rel, "", fmt.Sprintf("%s declares an unparseable version range for %s: %v", d.ID, rel.ModID, err)))
+				continue
+			}
+			matches := found && (version == "" || versionRange.Contains(ParseVersion(version)))
+
+			switch rel.Kind {
+			case RelationDepends:
+				if !found {
+					report.Missing = append(report.Missing, issue(d, rel, "", fmt.Sprintf("%s requires %s %s, which is not present", d.ID, rel.ModID, rangeText(rel))))
+					continue
+				}
+				if target, ok := present[rel.ModID]; ok && target != d {
+					edges[d.ID] = append(edges[d.ID], target.ID)
+				}
+				if version == "" && rel.VersionRange != "" {
+					report.Warnings = append(report.Warnings, issue(d, rel, version, fmt.Sprintf("cannot check %s against %s because its version is unknown", rel.ModID, rel.VersionRange)))
+				} else if !matches {
+					report.Incompatible = append(report.Incompatible, issue(d, rel, version, fmt.Sprintf("%s requires %s %s, found %s", d.ID, rel.ModID, rangeText(rel), version)))
+				}
+			case RelationRecommends:
+				if !found {
+					report.Warnings = append(report.Warnings, issue(d, rel, "", fmt.Sprintf("%s recommends %s %s", d.ID, rel.ModID, rangeText(rel))))
+				} else if !matches {
+					report.Warnings = append(report.Warnings, issue(d, rel, version, fmt.Sprintf("%s recommends %s %s, found %s", d.ID, rel.ModID, rangeText(rel), version)))
+				}
+			case RelationBreaks:
+				if matches {
+					report.Incompatible = append(report.Incompatible, issue(d, rel, version, fmt.Sprintf("%s is incompatible with %s %s", d.ID, rel.ModID, version)))
+				}
+			case RelationConflicts:
+				if matches {
+					report.Warnings = append(report.Warnings, issue(d, rel, version, fmt.Sprintf("%s may conflict with %s %s", d.ID, rel.ModID, version)))
+				}
+			}
+		}
+	}
+
+	report.Cycles = findCycles(edges)
+	return report
+}
+
+func issue(d *ModDescriptor, rel Relation, found, message string) DependencyIssue {
+	return DependencyIssue{
+		ModID:      d.ID,
+		Dependency: rel.ModID,
+		Kind:       rel.Kind,
+		Required:   rel.VersionRange,
+		Found:      found,
+		Message:    message,
+	}
+}
+
+func rangeText(rel Relation) string {
+	if rel.VersionRange == "" {
+		return "(any version)"
+	}
+	return rel.VersionRange
+}
+
+// findCycles returns every strongly connected component of the dependency
+// graph that forms a cycle, using Tarjan's algorithm
+func findCycles(edges map[string][]string) [][]string {
+	var (
+		index   = 0
+		indices = make(map[string]int)
+		lowlink = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		cycles  = [][]string{}
+	)
+
+	var visit func(node string)
+	visit = func(node string) {
+		indices[node] = index
+		lowlink[node] = index
+		index++
+		stack = append(stack, node)
+		onStack[node] = true
+
+		for _, next := range edges[node] {
+			if _, seen := indices[next]; !seen {
+				visit(next)
+				lowlink[node] = min(lowlink[node], lowlink[next])
+			} else if onStack[next] {
+				lowlink[node] = min(lowlink[node], indices[next])
+			}
+		}
+
+		if lowlink[node] != indices[node] {
+			return
+		}
+		var component []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == node {
+				break
+			}
+		}
+		if len(component) > 1 || selfLoop(edges, node) {
+			sort.Strings(component)
+			cycles = append(cycles, component)
+		}
+	}
+
+	for _, node := range sortedKeys(edges) {
+		if _, seen := indices[node]; !seen {
+			visit(node)
+		}
+	}
+	return cycles
+}
+
+func selfLoop(edges map[string][]string, node string) bool {
+	for _, next := range edges[node] {
+		if next == node {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of a string-keyed map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
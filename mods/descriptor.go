@@ -0,0 +1,450 @@
+package mods
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Loaders that declare mod descriptors
+const (
+	LoaderFabric   = "fabric"
+	LoaderQuilt    = "quilt"
+	LoaderForge    = "forge"
+	LoaderNeoForge = "neoforge"
+	LoaderPlugin   = "plugin" // Bethesda master list
+	LoaderBukkit   = "bukkit" // Bukkit, Spigot and Paper plugin.yml
+	LoaderPaper    = "paper"  // paper-plugin.yml
+)
+
+// RelationKind is the kind of relationship a mod declares with another mod
+type RelationKind string
+
+const (
+	RelationDepends    RelationKind = "depends"
+	RelationRecommends RelationKind = "recommends"
+	RelationBreaks     RelationKind = "breaks"
+	RelationConflicts  RelationKind = "conflicts" // a soft break that only warns
+)
+
+// Range syntaxes used by descriptors
+const (
+	RangeSemver = "semver"
+	RangeMaven  = "maven"
+)
+
+// Relation is a declared dependency, recommendation or incompatibility
+type Relation struct {
+	Kind         RelationKind `json:"kind"`
+	ModID        string       `json:"mod_id"`
+	VersionRange string       `json:"version_range,omitempty"`
+	RangeSyntax  string       `json:"range_syntax,omitempty"`
+}
+
+// Range parses the relation's version range in its declared syntax
+func (r Relation) Range() (VersionRange, error) {
+	if r.VersionRange == "" {
+		return AnyVersion, nil
+	}
+	if r.RangeSyntax == RangeMaven {
+		return ParseMavenRange(r.VersionRange)
+	}
+	return ParseSemverRange(r.VersionRange)
+}
+
+// ModDescriptor is the identity and declared relationships of a single mod
+type ModDescriptor struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name,omitempty"`
+	Version   string     `json:"version,omitempty"`
+	Loader    string     `json:"loader"`
+	GameType  GameType   `json:"game_type"`
+	Source    string     `json:"source"` // file the descriptor was read from
+	Provides  []string   `json:"provides,omitempty"`
+	Relations []Relation `json:"relations,omitempty"`
+}
+
+// ParseDescriptors reads every mod descriptor from an uploaded file, including
+// mods nested inside jars
+func ParseDescriptors(filename string, content []byte) ([]ModDescriptor, error) {
+	switch {
+	case bytes.HasPrefix(content, []byte("PK\x03\x04")):
+		archive, err := OpenArchive(content, DefaultArchiveLimits)
+		if err != nil {
+			return nil, err
+		}
+		return parseArchiveDescriptors(filename, archive)
+	case bytes.HasPrefix(content, []byte("TES4")):
+		header, err := ParsePluginHeader(content)
+		if err != nil {
+			return nil, err
+		}
+		return []ModDescriptor{pluginDescriptor(filepath.Base(filename), header)}, nil
+	}
+
+	switch strings.ToLower(filepath.Base(filename)) {
+	case "fabric.mod.json":
+		d, err := parseFabricDescriptor(content)
+		if err != nil {
+			return nil, err
+		}
+		d.Source = filename
+		return []ModDescriptor{*d}, nil
+	case "quilt.mod.json":
+		d, err := parseQuiltDescriptor(content)
+		if err != nil {
+			return nil, err
+		}
+		d.Source = filename
+		return []ModDescriptor{*d}, nil
+	}
+
+	return nil, nil
+}
+
+// parseArchiveDescriptors reads the descriptors of a jar and any jars nested inside it
+func parseArchiveDescriptors(source string, archive *Archive) ([]ModDescriptor, error) {
+	var descriptors []ModDescriptor
+	read := func(name string) []byte {
+		data, err := archive.ReadFileNamed(name)
+		if err != nil {
+			return nil
+		}
+		return data
+	}
+
+	if data := read("fabric.mod.json"); data != nil {
+		d, err := parseFabricDescriptor(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: fabric.mod.json: %w", source, err)
+		}
+		d.Source = source
+		descriptors = append(descriptors, *d)
+	}
+	if data := read("quilt.mod.json"); data != nil {
+		d, err := parseQuiltDescriptor(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: quilt.mod.json: %w", source, err)
+		}
+		d.Source = source
+		descriptors = append(descriptors, *d)
+	}
+	for _, name := range []string{"META-INF/mods.toml", "META-INF/neoforge.mods.toml"} {
+		if data := read(name); data != nil {
+			loader := LoaderForge
+			if strings.Contains(name, "neoforge") {
+				loader = LoaderNeoForge
+			}
+			ds, err := parseForgeDescriptors(data, manifestVersion(read("META-INF/MANIFEST.MF")), loader)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", source, name, err)
+			}
+			for i := range ds {
+				ds[i].Source = source
+			}
+			descriptors = append(descriptors, ds...)
+		}
+	}
+	if data := read("mcmod.info"); data != nil && len(descriptors) == 0 {
+		ds, err := parseMcmodInfo(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: mcmod.info: %w", source, err)
+		}
+		for i := range ds {
+			ds[i].Source = source
+		}
+		descriptors = append(descriptors, ds...)
+	}
+
+	if plugin, err := ParseBukkitJar(archive); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	} else if plugin != nil {
+		descriptors = append(descriptors, bukkitDescriptor(source, plugin))
+	}
+
+	// Jar-in-jar: Fabric uses META-INF/jars, Forge uses META-INF/jarjar.
+	// Nesting deeper than the archive limits allow is skipped.
+	for _, f := range archive.Files() {
+		dir := path.Dir(f.Name)
+		if (dir != "META-INF/jars" && dir != "META-INF/jarjar") || !strings.HasSuffix(f.Name, ".jar") {
+			continue
+		}
+		nestedArchive, err := archive.OpenNested(f)
+		if err != nil {
+			continue
+		}
+		nested, err := parseArchiveDescriptors(source+"!/"+f.Name, nestedArchive)
+		if err != nil {
+			continue
+		}
+		descriptors = append(descriptors, nested...)
+	}
+
+	return descriptors, nil
+}
+
+// parseFabricDescriptor reads fabric.mod.json, whose relation values are a
+// predicate string or an array of alternative predicates
+func parseFabricDescriptor(data []byte) (*ModDescriptor, error) {
+	var raw struct {
+		ID         string                     `json:"id"`
+		Name       string                     `json:"name"`
+		Version    string                     `json:"version"`
+		Provides   []string                   `json:"provides"`
+		Depends    map[string]json.RawMessage `json:"depends"`
+		Recommends map[string]json.RawMessage `json:"recommends"`
+		Breaks     map[string]json.RawMessage `json:"breaks"`
+		Conflicts  map[string]json.RawMessage `json:"conflicts"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if raw.ID == "" {
+		return nil, fmt.Errorf("missing mod id")
+	}
+
+	d := &ModDescriptor{
+		ID:       raw.ID,
+		Name:     raw.Name,
+		Version:  raw.Version,
+		Loader:   LoaderFabric,
+		GameType: GameTypeMinecraft,
+		Provides: raw.Provides,
+	}
+	groups := []struct {
+		kind   RelationKind
+		values map[string]json.RawMessage
+	}{
+		{RelationDepends, raw.Depends},
+		{RelationRecommends, raw.Recommends},
+		{RelationBreaks, raw.Breaks},
+		{RelationConflicts, raw.Conflicts},
+	}
+	for _, group := range groups {
+		for _, id := range sortedKeys(group.values) {
+			predicate, err := fabricPredicate(group.values[id])
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", group.kind, id, err)
+			}
+			d.Relations = append(d.Relations, Relation{
+				Kind:         group.kind,
+				ModID:        id,
+				VersionRange: predicate,
+				RangeSyntax:  RangeSemver,
+			})
+		}
+	}
+	return d, nil
+}
+
+// fabricPredicate joins array predicates into a single "||" semver range
+func fabricPredicate(raw json.RawMessage) (string, error) {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return single, nil
+	}
+	var alternatives []string
+	if err := json.Unmarshal(raw, &alternatives); err != nil {
+		return "", fmt.Errorf("version predicate must be a string or array of strings")
+	}
+	return strings.Join(alternatives, " || "), nil
+}
+
+// parseQuiltDescriptor reads quilt.mod.json
+func parseQuiltDescriptor(data []byte) (*ModDescriptor, error) {
+	type quiltRelation struct {
+		ID       string          `json:"id"`
+		Versions json.RawMessage `json:"versions"`
+		Optional bool            `json:"optional"`
+	}
+	var raw struct {
+		QuiltLoader struct {
+			ID       string            `json:"id"`
+			Version  string            `json:"version"`
+			Provides []json.RawMessage `json:"provides"`
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Depends []json.RawMessage `json:"depends"`
+			Breaks  []json.RawMessage `json:"breaks"`
+		} `json:"quilt_loader"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	loader := raw.QuiltLoader
+	if loader.ID == "" {
+		return nil, fmt.Errorf("missing mod id")
+	}
+
+	d := &ModDescriptor{
+		ID:       loader.ID,
+		Name:     loader.Metadata.Name,
+		Version:  loader.Version,
+		Loader:   LoaderQuilt,
+		GameType: GameTypeMinecraft,
+	}
+
+	// Entries are either a bare mod id or an object with optional versions
+	parse := func(entry json.RawMessage) (quiltRelation, error) {
+		var rel quiltRelation
+		if err := json.Unmarshal(entry, &rel.ID); err == nil {
+			return rel, nil
+		}
+		err := json.Unmarshal(entry, &rel)
+		return rel, err
+	}
+	for _, entry := range loader.Provides {
+		if rel, err := parse(entry); err == nil {
+			d.Provides = append(d.Provides, rel.ID)
+		}
+	}
+	for _, group := range []struct {
+		kind    RelationKind
+		entries []json.RawMessage
+	}{{RelationDepends, loader.Depends}, {RelationBreaks, loader.Breaks}} {
+		for _, entry := range group.entries {
+			rel, err := parse(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s entry: %w", group.kind, err)
+			}
+			kind := group.kind
+			if kind == RelationDepends && rel.Optional {
+				kind = RelationRecommends
+			}
+			predicate := ""
+			if len(rel.Versions) > 0 {
+				if predicate, err = fabricPredicate(rel.Versions); err != nil {
+					return nil, fmt.Errorf("%s %s: %w", kind, rel.ID, err)
+				}
+			}
+			d.Relations = append(d.Relations, Relation{Kind: kind, ModID: rel.ID, VersionRange: predicate, RangeSyntax: RangeSemver})
+		}
+	}
+	return d, nil
+}
+
+// parseForgeDescriptors reads a Forge or NeoForge mods.toml, which can declare
+// several mods that share one dependency table
+func parseForgeDescriptors(data []byte, jarVersion, loader string) ([]ModDescriptor, error) {
+	type forgeDependency struct {
+		ModID        string `toml:"modId"`
+		Mandatory    *bool  `toml:"mandatory"`
+		Type         string `toml:"type"`
+		VersionRange string `toml:"versionRange"`
+	}
+	var raw struct {
+		Mods []struct {
+			ModID       string `toml:"modId"`
+			Version     string `toml:"version"`
+			DisplayName string `toml:"displayName"`
+		} `toml:"mods"`
+		Dependencies map[string][]forgeDependency `toml:"dependencies"`
+	}
+	if _, err := toml.Decode(string(data), &raw); err != nil {
+		return nil, err
+	}
+
+	var descriptors []ModDescriptor
+	for _, mod := range raw.Mods {
+		version := mod.Version
+		if version == "${file.jarVersion}" {
+			version = jarVersion
+		}
+		d := ModDescriptor{
+			ID:       mod.ModID,
+			Name:     mod.DisplayName,
+			Version:  version,
+			Loader:   loader,
+			GameType: GameTypeMinecraft,
+		}
+		for _, dep := range raw.Dependencies[mod.ModID] {
+			kind := RelationDepends
+			switch strings.ToLower(dep.Type) {
+			case "optional":
+				kind = RelationRecommends
+			case "incompatible":
+				kind = RelationBreaks
+			case "discouraged":
+				kind = RelationConflicts
+			case "":
+				if dep.Mandatory != nil && !*dep.Mandatory {
+					kind = RelationRecommends
+				}
+			}
+			d.Relations = append(d.Relations, Relation{
+				Kind:         kind,
+				ModID:        dep.ModID,
+				VersionRange: dep.VersionRange,
+				RangeSyntax:  RangeMaven,
+			})
+		}
+		descriptors = append(descriptors, d)
+	}
+	return descriptors, nil
+}
+
+// parseMcmodInfo reads the legacy Forge mcmod.info, whose dependencies use modid@range
+func parseMcmodInfo(data []byte) ([]ModDescriptor, error) {
+	type entry struct {
+		ModID        string   `json:"modid"`
+		Name         string   `json:"name"`
+		Version      string   `json:"version"`
+		RequiredMods []string `json:"requiredMods"`
+	}
+	var entries []entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		// Version 2 of the format wraps the list in an object
+		var wrapped struct {
+			ModList []entry `json:"modList"`
+		}
+		if err := json.Unmarshal(data, &wrapped); err != nil {
+			return nil, err
+		}
+		entries = wrapped.ModList
+	}
+
+	var descriptors []ModDescriptor
+	for _, e := range entries {
+		d := ModDescriptor{ID: e.ModID, Name: e.Name, Version: e.Version, Loader: LoaderForge, GameType: GameTypeMinecraft}
+		for _, required := range e.RequiredMods {
+			id, versionRange, _ := strings.Cut(required, "@")
+			d.Relations = append(d.Relations, Relation{Kind: RelationDepends, ModID: id, VersionRange: versionRange, RangeSyntax: RangeMaven})
+		}
+		descriptors = append(descriptors, d)
+	}
+	return descriptors, nil
+}
+
+// manifestVersion reads Implementation-Version from a jar manifest
+func manifestVersion(manifest []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), ":"); ok && strings.TrimSpace(key) == "Implementation-Version" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// pluginDescriptor describes a Bethesda plugin, whose masters are its dependencies.
+// Plugin names are case-insensitive, so IDs are lowercased.
+func pluginDescriptor(filename string, header *PluginHeader) ModDescriptor {
+	d := ModDescriptor{
+		ID:       strings.ToLower(filename),
+		Name:     filename,
+		Loader:   LoaderPlugin,
+		GameType: header.Game,
+		Source:   filename,
+	}
+	for _, master := range header.Masters {
+		d.Relations = append(d.Relations, Relation{Kind: RelationDepends, ModID: strings.ToLower(master)})
+	}
+	return d
+}
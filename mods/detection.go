@@ -0,0 +1,378 @@
+package mods
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ConfidenceThreshold is the minimum confidence at which a detected game type
+// is used without asking the user to confirm it
+const ConfidenceThreshold = 0.6
+
+// Evidence kinds describe where a detection signal came from
+const (
+	EvidenceDescriptor = "descriptor" // a mod descriptor file such as fabric.mod.json
+	EvidenceMagic      = "magic"      // magic bytes or a binary header
+	EvidenceSchema     = "schema"     // parsed content matches a known schema
+	EvidenceExtension  = "extension"  // the file extension alone
+	EvidenceContent    = "content"    // textual patterns in the content
+)
+
+// Evidence is a single signal supporting a candidate game type
+type Evidence struct {
+	Kind   string  `json:"kind"`
+	Detail string  `json:"detail"`
+	Weight float64 `json:"weight"`
+}
+
+// Candidate is a possible game type with a confidence score between 0 and 1
+type Candidate struct {
+	GameType   GameType   `json:"game_type"`
+	Confidence float64    `json:"confidence"`
+	Evidence   []Evidence `json:"evidence"`
+}
+
+// Detection holds the ranked candidates for an uploaded file
+type Detection struct {
+	Candidates []Candidate `json:"candidates"`
+}
+
+// Best returns the highest ranked candidate, or an unknown candidate if there are none
+func (d *Detection) Best() Candidate {
+	if len(d.Candidates) == 0 {
+		return Candidate{GameType: GameTypeUnknown}
+	}
+	return d.Candidates[0]
+}
+
+// IsConfident reports whether the best candidate clears the confidence threshold
+func (d *Detection) IsConfident() bool {
+	return d.Best().Confidence >= ConfidenceThreshold
+}
+
+// detector accumulates evidence per game type while a file is inspected
+type detector struct {
+	evidence map[GameType][]Evidence
+}
+
+func (d *detector) add(gameType GameType, kind, detail string, weight float64) {
+	d.evidence[gameType] = append(d.evidence[gameType], Evidence{Kind: kind, Detail: detail, Weight: weight})
+}
+
+// Detect inspects a file and returns every plausible game type ranked by confidence
+func Detect(filename string, content []byte) *Detection {
+	d := &detector{evidence: make(map[GameType][]Evidence)}
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	switch {
+	case bytes.HasPrefix(content, []byte("PK\x03\x04")):
+		d.inspectArchive(content)
+	case bytes.HasPrefix(content, []byte("TES4")):
+		d.inspectPlugin(content)
+	case isNBT(content):
+		d.inspectNBT(content)
+	case isContentPatch(content):
+		d.add(GameTypeStardew, EvidenceSchema, "Content Patcher schema with a Changes list", 0.85)
+	case json.Valid(content):
+		d.inspectJSON(filename, content)
+	default:
+		d.inspectLua(content)
+	}
+
+	switch ext {
+	case ".esp", ".esm", ".esl":
+		// The header decides the precise game; the extension only suggests a family
+		if len(d.evidence) == 0 {
+			d.add(GameTypeSkyrim, EvidenceExtension, "plugin extension "+ext+" without a readable header", 0.3)
+		}
+	case ".lua":
+		d.add(GameTypeLua, EvidenceExtension, "file extension .lua", 0.4)
+	case ".mcmeta", ".jar":
+		d.add(GameTypeMinecraft, EvidenceExtension, "file extension "+ext, 0.4)
+	case ".toml", ".properties", ".cfg", ".yml", ".yaml":
+		d.add(GameTypeMinecraft, EvidenceExtension, "config file extension "+ext, 0.3)
+		d.inspectConfig(ext, content)
+	case ".mcaddon", ".mcpack":
+		d.add(GameTypeBedrock, EvidenceExtension, "file extension "+ext, 0.6)
+	}
+
+	return d.rank()
+}
+
+// rank combines evidence per game type and sorts candidates by confidence
+func (d *detector) rank() *Detection {
+	detection := &Detection{}
+	for gameType, evidence := range d.evidence {
+		sort.SliceStable(evidence, func(i, j int) bool { return evidence[i].Weight > evidence[j].Weight })
+		detection.Candidates = append(detection.Candidates, Candidate{
+			GameType:   gameType,
+			Confidence: combinedConfidence(evidence),
+			Evidence:   evidence,
+		})
+	}
+
+	sort.Slice(detection.Candidates, func(i, j int) bool {
+		a, b := detection.Candidates[i], detection.Candidates[j]
+		if a.Confidence != b.Confidence {
+			return a.Confidence > b.Confidence
+		}
+		return a.GameType < b.GameType
+	})
+	return detection
+}
+
+// combinedConfidence treats each signal as independent: a candidate is wrong
+// only if every signal is
+func combinedConfidence(evidence []Evidence) float64 {
+	miss := 1.0
+	for _, e := range evidence {
+		miss *= 1 - e.Weight
+	}
+	return 1 - miss
+}
+
+// minecraftDescriptors maps archive entries to the loader or pack type they identify
+var minecraftDescriptors = map[string]string{
+	"fabric.mod.json":             "Fabric mod",
+	"quilt.mod.json":              "Quilt mod",
+	"META-INF/mods.toml":          "Forge mod",
+	"META-INF/neoforge.mods.toml": "NeoForge mod",
+	"mcmod.info":                  "legacy Forge mod",
+	"pack.mcmeta":                 "data or resource pack",
+	"plugin.yml":                  "Bukkit plugin",
+	"paper-plugin.yml":            "Paper plugin",
+}
+
+// inspectArchive looks for descriptor files inside a zip or jar
+func (d *detector) inspectArchive(content []byte) {
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return
+	}
+
+	luaFiles, minecraftClasses := 0, 0
+	for _, f := range archive.Files() {
+		if kind, ok := minecraftDescriptors[f.Name]; ok {
+			d.add(GameTypeMinecraft, EvidenceDescriptor, fmt.Sprintf("%s found (%s)", f.Name, kind), 0.95)
+			continue
+		}
+		if strings.HasSuffix(f.Name, ".class") && strings.HasPrefix(f.Name, "net/minecraft/") {
+			minecraftClasses++
+		}
+		if strings.EqualFold(path.Ext(f.Name), ".lua") {
+			luaFiles++
+		}
+	}
+
+	if minecraftClasses > 0 {
+		d.add(GameTypeMinecraft, EvidenceContent, fmt.Sprintf("archive contains %d net/minecraft classes", minecraftClasses), 0.5)
+	}
+	if luaFiles > 0 {
+		d.add(GameTypeLua, EvidenceContent, fmt.Sprintf("archive contains %d Lua files", luaFiles), 0.5)
+	}
+	if hasSMAPIManifest(archive) {
+		d.add(GameTypeStardew, EvidenceDescriptor, "SMAPI manifest.json with a UniqueID", 0.95)
+	}
+	if hasBedrockManifest(archive) {
+		d.add(GameTypeBedrock, EvidenceDescriptor, "Bedrock pack manifest.json with header and modules", 0.95)
+	}
+	if ecosystem := detectArchiveEcosystem(archive); ecosystem != nil {
+		d.add(GameTypeLua, EvidenceDescriptor, fmt.Sprintf("%s layout (%s)", ecosystem.Ecosystem, ecosystem.Evidence[0].Detail), ecosystem.Confidence*0.95)
+	}
+}
+
+// inspectPlugin identifies the Bethesda game from the TES4 header
+func (d *detector) inspectPlugin(content []byte) {
+	header, err := ParsePluginHeader(content)
+	if err != nil {
+		d.add(GameTypeSkyrim, EvidenceMagic, "TES4 magic with unreadable header: "+err.Error(), 0.3)
+		return
+	}
+	detail := fmt.Sprintf("TES4 header (HEDR %.2f, form version %d)", header.Version, header.FormVersion)
+	d.add(header.Game, EvidenceMagic, detail, 0.99)
+}
+
+// inspectNBT identifies Minecraft NBT files by the keys of their root compound
+func (d *detector) inspectNBT(content []byte) {
+	file, err := ReadNBT(content)
+	if err != nil {
+		return
+	}
+	switch kind := NBTKind(file.Root); kind {
+	case NBTKindStructure, NBTKindLevel:
+		d.add(GameTypeMinecraft, EvidenceSchema, "NBT "+kind+" file", 0.9)
+	case NBTKindSchematic, NBTKindLitematic:
+		d.add(GameTypeMinecraft, EvidenceSchema, "NBT "+kind+" file", 0.85)
+	default:
+		d.add(GameTypeMinecraft, EvidenceMagic, "NBT file with a compound root", 0.6)
+	}
+}
+
+// namespacedID matches namespaced resource locations such as minecraft:diamond
+var namespacedID = regexp.MustCompile(`^#?[a-z0-9_.-]+:[a-z0-9_./-]+$`)
+
+// inspectJSON matches parsed JSON against known Minecraft schemas
+func (d *detector) inspectJSON(filename string, content []byte) {
+	var doc interface{}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return
+	}
+	obj, _ := doc.(map[string]interface{})
+
+	if obj != nil {
+		if pack, ok := obj["pack"].(map[string]interface{}); ok {
+			if _, ok := pack["pack_format"]; ok {
+				d.add(GameTypeMinecraft, EvidenceSchema, "pack.mcmeta schema with pack_format", 0.95)
+			}
+		}
+		if _, ok := obj["schemaVersion"]; ok {
+			if _, ok := obj["id"]; ok {
+				d.add(GameTypeMinecraft, EvidenceSchema, "fabric.mod.json schema", 0.9)
+			}
+		}
+		if t, ok := obj["type"].(string); ok && namespacedID.MatchString(t) {
+			switch {
+			case obj["pattern"] != nil && obj["key"] != nil, obj["ingredients"] != nil, obj["ingredient"] != nil:
+				d.add(GameTypeMinecraft, EvidenceSchema, "recipe schema of type "+t, 0.85)
+			case obj["pools"] != nil:
+				d.add(GameTypeMinecraft, EvidenceSchema, "loot table schema of type "+t, 0.85)
+			default:
+				d.add(GameTypeMinecraft, EvidenceSchema, "namespaced type "+t, 0.6)
+			}
+		}
+		if values, ok := obj["values"].([]interface{}); ok && len(values) > 0 && allNamespaced(values) {
+			d.add(GameTypeMinecraft, EvidenceSchema, "tag schema with namespaced values", 0.8)
+		}
+		if _, ok := obj["parent"].(string); ok {
+			if _, ok := obj["textures"]; ok {
+				d.add(GameTypeMinecraft, EvidenceSchema, "block or item model schema", 0.8)
+			}
+		}
+	}
+
+	if ids := countNamespacedIDs(doc); ids > 0 {
+		d.add(GameTypeMinecraft, EvidenceContent, fmt.Sprintf("%d namespaced resource IDs", ids), min(0.2+0.05*float64(ids), 0.5))
+	}
+
+	if strings.EqualFold(filepath.Base(filename), "pack.mcmeta") {
+		d.add(GameTypeMinecraft, EvidenceDescriptor, "pack.mcmeta file name", 0.5)
+	}
+}
+
+func allNamespaced(values []interface{}) bool {
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok || !namespacedID.MatchString(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// countNamespacedIDs counts string values that look like namespaced resource IDs
+func countNamespacedIDs(v interface{}) int {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		n := 0
+		for _, child := range val {
+			n += countNamespacedIDs(child)
+		}
+		return n
+	case []interface{}:
+		n := 0
+		for _, child := range val {
+			n += countNamespacedIDs(child)
+		}
+		return n
+	case string:
+		if namespacedID.MatchString(val) {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Lua syntax patterns, each matched against whole lines
+var (
+	luaLocalAssign = regexp.MustCompile(`(?m)^\s*local\s+[A-Za-z_][\w,\s]*=`)
+	luaFunctionDef = regexp.MustCompile(`(?m)^\s*(local\s+)?function\s+[A-Za-z_][\w.:]*\s*\(`)
+	luaBlockEnd    = regexp.MustCompile(`(?m)^\s*end\b`)
+	luaRequire     = regexp.MustCompile(`\brequire\s*\(?\s*["']`)
+	luaComment     = regexp.MustCompile(`(?m)^\s*--`)
+)
+
+// Minecraft config patterns, each matched against whole lines
+var (
+	forgeSpecComment   = regexp.MustCompile(`(?m)^\s*#\s*(Range|Allowed Values|Default):`)
+	forgeGeneralTable  = regexp.MustCompile(`(?m)^\s*\[general\]\s*$`)
+	forgeLegacyHeader  = regexp.MustCompile(`(?m)^# Configuration file\s*$`)
+	forgeLegacyTyped   = regexp.MustCompile(`(?m)^\s*[BDIS]:("[^"]*"|[\w.-]+)\s*[=<]`)
+	serverPropertyKeys = regexp.MustCompile(`(?m)^(level-name|online-mode|spawn-protection|view-distance)=`)
+)
+
+// inspectConfig looks for what the Forge and NeoForge config specs and the
+// Minecraft server write, since the extension alone fits any game
+func (d *detector) inspectConfig(ext string, content []byte) {
+	if !isText(content) {
+		return
+	}
+	switch ext {
+	case ".toml":
+		if forgeSpecComment.Match(content) {
+			d.add(GameTypeMinecraft, EvidenceContent, "Forge config spec comments such as #Range:", 0.6)
+		}
+		if forgeGeneralTable.Match(content) {
+			d.add(GameTypeMinecraft, EvidenceContent, "[general] table of a Forge config", 0.45)
+		}
+	case ".cfg":
+		if forgeLegacyHeader.Match(content) {
+			d.add(GameTypeMinecraft, EvidenceContent, "Forge configuration file header", 0.6)
+		}
+		if forgeLegacyTyped.Match(content) {
+			d.add(GameTypeMinecraft, EvidenceContent, "Forge typed settings such as I:name=", 0.6)
+		}
+	case ".properties":
+		if serverPropertyKeys.Match(content) {
+			d.add(GameTypeMinecraft, EvidenceContent, "server.properties settings", 0.6)
+		}
+	}
+}
+
+// inspectLua scores text content against Lua syntax rather than loose keywords
+func (d *detector) inspectLua(content []byte) {
+	if !isText(content) {
+		return
+	}
+
+	signals := []struct {
+		pattern *regexp.Regexp
+		detail  string
+		weight  float64
+	}{
+		{luaFunctionDef, "function definitions", 0.4},
+		{luaBlockEnd, "end-terminated blocks", 0.3},
+		{luaLocalAssign, "local assignments", 0.25},
+		{luaRequire, "require calls", 0.3},
+		{luaComment, "-- comments", 0.15},
+	}
+	for _, s := range signals {
+		if n := len(s.pattern.FindAllIndex(content, -1)); n > 0 {
+			d.add(GameTypeLua, EvidenceSchema, fmt.Sprintf("Lua syntax: %d %s", n, s.detail), s.weight)
+		}
+	}
+}
+
+// isText reports whether content looks like UTF-8 text rather than binary data
+func isText(content []byte) bool {
+	sample := content
+	if len(sample) > 8192 {
+		sample = sample[:8192]
+	}
+	return !bytes.ContainsRune(sample, 0)
+}
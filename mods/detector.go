@@ -3,7 +3,6 @@ package mods
 import (
 	"fmt"
 	"mime/multipart"
-	"path/filepath"
 	"strings"
 )
 
@@ -12,103 +11,48 @@ type GameType string
 
 const (
 	GameTypeMinecraft GameType = "minecraft"
-	GameTypeSkyrim    GameType = "skyrim"
+	GameTypeOblivion  GameType = "oblivion"
+	GameTypeSkyrim    GameType = "skyrim" // Skyrim Legendary Edition
+	GameTypeSkyrimSE  GameType = "skyrim_se"
+	GameTypeFallout4  GameType = "fallout4"
+	GameTypeStarfield GameType = "starfield"
 	GameTypeLua       GameType = "lua"
+	GameTypeStardew   GameType = "stardew"
+	GameTypeBedrock   GameType = "minecraft_bedrock"
 	GameTypeUnknown   GameType = "unknown"
 )
 
-// FileInfo contains information about an uploaded mod file
-type FileInfo struct {
-	Filename    string
-	Size        int64
-	GameType    GameType
-	ContentType string
-	Metadata    map[string]interface{}
+// GameTypes lists every game type that can be detected or chosen at upload
+var GameTypes = []GameType{
+	GameTypeMinecraft,
+	GameTypeOblivion,
+	GameTypeSkyrim,
+	GameTypeSkyrimSE,
+	GameTypeFallout4,
+	GameTypeStarfield,
+	GameTypeLua,
+	GameTypeStardew,
+	GameTypeBedrock,
 }
 
-// DetectGameType detects the game type from a file
-func DetectGameType(header *multipart.FileHeader, content []byte) GameType {
-	filename := strings.ToLower(header.Filename)
-	ext := filepath.Ext(filename)
-
-	// Detect by file extension first
-	switch ext {
-	case ".json":
-		if isMinecraftJSON(content) {
-			return GameTypeMinecraft
-		}
-	case ".esp", ".esm":
-		return GameTypeSkyrim
-	case ".lua":
-		return GameTypeLua
-	}
-
-	// Detect by content analysis
-	contentStr := string(content)
-
-	// Minecraft detection patterns
-	if strings.Contains(contentStr, "minecraft:") ||
-		strings.Contains(contentStr, "modid") ||
-		strings.Contains(contentStr, "block") && strings.Contains(contentStr, "item") {
-		return GameTypeMinecraft
-	}
-
-	// Skyrim detection patterns
-	if strings.Contains(contentStr, "GRUP") ||
-		strings.Contains(contentStr, "TES4") ||
-		strings.Contains(contentStr, "Skyrim.esm") {
-		return GameTypeSkyrim
-	}
-
-	// Lua detection patterns
-	if strings.Contains(contentStr, "function") ||
-		strings.Contains(contentStr, "local") ||
-		strings.Contains(contentStr, "require") {
-		return GameTypeLua
-	}
-
-	return GameTypeUnknown
-}
-
-// isMinecraftJSON checks if the JSON content appears to be a Minecraft mod
-func isMinecraftJSON(content []byte) bool {
-	contentStr := string(content)
-
-	// Look for common Minecraft mod JSON patterns
-	minecraftPatterns := []string{
-		"minecraft:",
-		"modid",
-		"forge",
-		"fabric",
-		"item",
-		"block",
-		"recipe",
-		"texture",
-	}
-
-	for _, pattern := range minecraftPatterns {
-		if strings.Contains(strings.ToLower(contentStr), pattern) {
-			return true
+// ParseGameType converts a user-supplied game type into a known GameType
+func ParseGameType(s string) (GameType, error) {
+	for _, gameType := range GameTypes {
+		if string(gameType) == strings.ToLower(strings.TrimSpace(s)) {
+			return gameType, nil
 		}
 	}
-
-	return false
+	return GameTypeUnknown, fmt.Errorf("unknown game type: %s", s)
 }
 
-// ExtractMetadata extracts metadata from mod content
-func ExtractMetadata(content []byte, gameType GameType) map[string]interface{} {
-	metadata := make(map[string]interface{})
-
-	switch gameType {
-	case GameTypeMinecraft:
-		return extractMinecraftMetadata(content)
-	case GameTypeSkyrim:
-		return extractSkyrimMetadata(content)
-	case GameTypeLua:
-		return extractLuaMetadata(content)
+// DetectGameType detects the game type from a file, returning GameTypeUnknown
+// when no candidate is confident enough to use without asking the user
+func DetectGameType(header *multipart.FileHeader, content []byte) GameType {
+	detection := Detect(header.Filename, content)
+	if !detection.IsConfident() {
+		return GameTypeUnknown
 	}
-
-	return metadata
+	return detection.Best().GameType
 }
 
 // extractMinecraftMetadata extracts metadata from Minecraft JSON
@@ -137,11 +81,30 @@ func extractMinecraftMetadata(content []byte) map[string]interface{} {
 	return metadata
 }
 
-// extractSkyrimMetadata extracts metadata from Skyrim ESP files
-func extractSkyrimMetadata(content []byte) map[string]interface{} {
+// extractPluginMetadata extracts metadata from Bethesda ESP/ESM/ESL plugins
+func extractPluginMetadata(content []byte) map[string]interface{} {
 	metadata := make(map[string]interface{})
 	metadata["format"] = "esp"
-	metadata["game"] = "skyrim"
+
+	header, err := ParsePluginHeader(content)
+	if err != nil {
+		return metadata
+	}
+
+	metadata["game"] = string(header.Game)
+	metadata["version"] = header.Version
+	metadata["form_version"] = header.FormVersion
+	metadata["num_records"] = header.NumRecords
+	metadata["is_master"] = header.IsMaster()
+	metadata["is_light"] = header.IsLight()
+	metadata["is_localized"] = header.IsLocalized()
+	metadata["masters"] = header.Masters
+	if header.Author != "" {
+		metadata["author"] = header.Author
+	}
+	if header.Description != "" {
+		metadata["description"] = header.Description
+	}
 	return metadata
 }
 
@@ -150,44 +113,19 @@ func extractLuaMetadata(content []byte) map[string]interface{} {
 	metadata := make(map[string]interface{})
 	metadata["format"] = "lua"
 	metadata["type"] = "script"
-	return metadata
-}
 
-// ValidateFile validates an uploaded mod file
-func ValidateFile(header *multipart.FileHeader, content []byte) error {
-	// Check file size (max 50MB for MVP)
-	maxSize := int64(50 * 1024 * 1024) // 50MB
-	if header.Size > maxSize {
-		return fmt.Errorf("file size exceeds maximum limit of 50MB")
+	script, err := AnalyzeLua(content)
+	if err != nil {
+		metadata["syntax_error"] = err
+		return metadata
 	}
-
-	// Check file extension
-	ext := strings.ToLower(filepath.Ext(header.Filename))
-	allowedExtensions := []string{".json", ".esp", ".esm", ".lua", ".txt"}
-
-	isAllowed := false
-	for _, allowed := range allowedExtensions {
-		if ext == allowed {
-			isAllowed = true
-			break
-		}
-	}
-
-	if !isAllowed {
-		return fmt.Errorf("unsupported file type: %s", ext)
-	}
-
-	// Detect game type
-	gameType := DetectGameType(header, content)
-	if gameType == GameTypeUnknown {
-		return fmt.Errorf("unable to detect game type from file content")
+	metadata["lines"] = script.Lines
+	metadata["globals"] = script.Globals
+	metadata["functions"] = script.Functions
+	metadata["requires"] = script.Requires
+	metadata["apis"] = script.APIs
+	if ecosystem := ecosystemFromScript(script); ecosystem != nil {
+		metadata["ecosystem"] = ecosystem
 	}
-
-	return nil
-}
-
-// DetectModType detects the mod type from content and filename (simpler version for handlers)
-func DetectModType(content []byte, filename string) (string, error) {
-	gameType := DetectGameType(&multipart.FileHeader{Filename: filename}, content)
-	return string(gameType), nil
+	return metadata
 }
@@ -0,0 +1,691 @@
+package mods
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// DiffKind is how two versions of a file were compared
+type DiffKind string
+
+// Diff kinds
+const (
+	DiffJSON    DiffKind = "json"
+	DiffText    DiffKind = "text"
+	DiffArchive DiffKind = "archive"
+	DiffPlugin  DiffKind = "plugin"
+	DiffNBT     DiffKind = "nbt"
+	DiffBinary  DiffKind = "binary"
+)
+
+// ChangeKind describes how an item differs between two versions
+type ChangeKind string
+
+// Change kinds
+const (
+	ChangeAdded     ChangeKind = "added"
+	ChangeRemoved   ChangeKind = "removed"
+	ChangeModified  ChangeKind = "modified"
+	ChangeUnchanged ChangeKind = "unchanged"
+)
+
+// diffContext is the number of unchanged lines shown around each hunk
+const diffContext = 3
+
+// maxDiffChanges bounds the structural changes reported for one file
+const maxDiffChanges = 10000
+
+// maxLineDiffCells bounds the line diff table; larger edits are reported as
+// replacing the whole changed region
+const maxLineDiffCells = 4 * 1024 * 1024
+
+// Change is a single structural difference: a JSON value by its JSON Pointer
+// path, or a plugin record by signature and form ID
+type Change struct {
+	ID     string      `json:"id,omitempty"`
+	Kind   ChangeKind  `json:"kind"`
+	Path   string      `json:"path"`
+	Old    interface{} `json:"old,omitempty"`
+	New    interface{} `json:"new,omitempty"`
+	Detail string      `json:"detail,omitempty"`
+}
+
+// DiffLine is a line of a hunk: " " for context, "-" removed, "+" added
+type DiffLine struct {
+	Op        string `json:"op"`
+	Text      string `json:"text"`
+	NoNewline bool   `json:"no_newline,omitempty"`
+}
+
+// Hunk is a run of changed lines with their surrounding context
+type Hunk struct {
+	ID       string     `json:"id,omitempty"`
+	OldStart int        `json:"old_start"`
+	OldLines int        `json:"old_lines"`
+	NewStart int        `json:"new_start"`
+	NewLines int        `json:"new_lines"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+// Diff compares two versions of a file. JSON files have structural changes
+// and line hunks, text files line hunks, plugins record changes and hunks
+// over their rendered records, NBT files tag changes and hunks over their
+// SNBT, and archives one diff per changed entry.
+// Reviewable units carry change IDs.
+type Diff struct {
+	ID        string     `json:"id,omitempty"` // set when the file is accepted or rejected whole
+	Path      string     `json:"path"`
+	Kind      DiffKind   `json:"kind"`
+	Status    ChangeKind `json:"status"`
+	Changes   []Change   `json:"changes,omitempty"`
+	Hunks     []Hunk     `json:"hunks,omitempty"`
+	Entries   []*Diff    `json:"entries,omitempty"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+// DiffFiles compares the original and processed versions of a file
+func DiffFiles(filename string, original, processed []byte) (*Diff, error) {
+	d, err := diffContent(filename, original, processed, true)
+	if err != nil {
+		return nil, err
+	}
+	assignChangeIDs(d, "")
+	return d, nil
+}
+
+// diffContent compares two versions of a file. A nil version is absent, so
+// the file was added or removed. Archives nested in archives are compared as
+// binary files.
+func diffContent(path string, original, processed []byte, archives bool) (*Diff, error) {
+	d := &Diff{Path: path}
+	switch {
+	case original == nil:
+		d.Status = ChangeAdded
+	case processed == nil:
+		d.Status = ChangeRemoved
+	case bytes.Equal(original, processed):
+		d.Status = ChangeUnchanged
+	default:
+		d.Status = ChangeModified
+	}
+
+	both := func(check func([]byte) bool) bool {
+		return (original == nil || check(original)) && (processed == nil || check(processed))
+	}
+	switch {
+	case archives && both(isZip):
+		d.Kind = DiffArchive
+	case both(isPlugin):
+		d.Kind = DiffPlugin
+	case both(isNBT):
+		d.Kind = DiffNBT
+	case both(json.Valid):
+		d.Kind = DiffJSON
+	case both(isDiffText):
+		d.Kind = DiffText
+	default:
+		d.Kind = DiffBinary
+	}
+	if d.Status == ChangeUnchanged {
+		return d, nil
+	}
+
+	switch d.Kind {
+	case DiffArchive:
+		return d, diffArchives(d, original, processed)
+	case DiffPlugin:
+		return d, diffPlugins(d, original, processed)
+	case DiffNBT:
+		return d, diffNBT(d, original, processed)
+	case DiffJSON:
+		if err := diffJSON(d, original, processed); err != nil {
+			return nil, err
+		}
+		d.Hunks = diffLines(original, processed)
+	case DiffText:
+		d.Hunks = diffLines(original, processed)
+	case DiffBinary:
+		d.Changes = []Change{{Kind: d.Status, Path: path, Detail: fmt.Sprintf("%d bytes -> %d bytes", len(original), len(processed))}}
+	}
+	return d, nil
+}
+
+func isZip(content []byte) bool {
+	return bytes.HasPrefix(content, []byte("PK\x03\x04"))
+}
+
+func isPlugin(content []byte) bool {
+	return bytes.HasPrefix(content, []byte("TES4"))
+}
+
+func isDiffText(content []byte) bool {
+	return utf8.Valid(content) && isText(content)
+}
+
+// add records a structural change, stopping at maxDiffChanges
+func (d *Diff) add(change Change) {
+	if len(d.Changes) >= maxDiffChanges {
+		d.Truncated = true
+		return
+	}
+	d.Changes = append(d.Changes, change)
+}
+
+// diffJSON records the changed values between two JSON documents by path
+func diffJSON(d *Diff, original, processed []byte) error {
+	decode := func(content []byte) (interface{}, error) {
+		if content == nil {
+			return nil, nil
+		}
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.UseNumber()
+		var v interface{}
+		return v, decoder.Decode(&v)
+	}
+	a, err := decode(original)
+	if err != nil {
+		return fmt.Errorf("invalid original JSON: %w", err)
+	}
+	b, err := decode(processed)
+	if err != nil {
+		return fmt.Errorf("invalid processed JSON: %w", err)
+	}
+
+	switch {
+	case original == nil:
+		d.add(Change{Kind: ChangeAdded, Path: "", New: b})
+	case processed == nil:
+		d.add(Change{Kind: ChangeRemoved, Path: "", Old: a})
+	default:
+		diffJSONValues(d, "", a, b)
+	}
+	return nil
+}
+
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func diffJSONValues(d *Diff, path string, a, b interface{}) {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := sortedKeys(av)
+		for _, k := range sortedKeys(bv) {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		for _, k := range keys {
+			child := path + "/" + jsonPointerEscaper.Replace(k)
+			old, inOld := av[k]
+			updated, inNew := bv[k]
+			switch {
+			case !inNew:
+				d.add(Change{Kind: ChangeRemoved, Path: child, Old: old})
+			case !inOld:
+				d.add(Change{Kind: ChangeAdded, Path: child, New: updated})
+			default:
+				diffJSONValues(d, child, old, updated)
+			}
+		}
+		return
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < max(len(av), len(bv)); i++ {
+			child := fmt.Sprintf("%s/%d", path, i)
+			switch {
+			case i >= len(bv):
+				d.add(Change{Kind: ChangeRemoved, Path: child, Old: av[i]})
+			case i >= len(av):
+				d.add(Change{Kind: ChangeAdded, Path: child, New: bv[i]})
+			default:
+				diffJSONValues(d, child, av[i], bv[i])
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(a, b) {
+		d.add(Change{Kind: ChangeModified, Path: path, Old: a, New: b})
+	}
+}
+
+// diffArchives compares archives entry by entry, in the original's order
+// followed by entries only the processed archive has
+func diffArchives(d *Diff, original, processed []byte) error {
+	open := func(content []byte) (*Archive, map[string]*zip.File, error) {
+		if content == nil {
+			return nil, nil, nil
+		}
+		archive, err := OpenArchive(content, DefaultArchiveLimits)
+		if err != nil {
+			return nil, nil, err
+		}
+		files := make(map[string]*zip.File)
+		for _, f := range archive.Files() {
+			files[f.Name] = f
+		}
+		return archive, files, nil
+	}
+	oldArchive, oldFiles, err := open(original)
+	if err != nil {
+		return err
+	}
+	newArchive, newFiles, err := open(processed)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, archive := range []*Archive{oldArchive, newArchive} {
+		if archive == nil {
+			continue
+		}
+		for _, f := range archive.Files() {
+			if strings.HasSuffix(f.Name, "/") {
+				continue
+			}
+			if archive == newArchive && oldFiles[f.Name] != nil {
+				continue
+			}
+			names = append(names, f.Name)
+		}
+	}
+
+	read := func(archive *Archive, f *zip.File) ([]byte, error) {
+		if f == nil {
+			return nil, nil
+		}
+		return archive.ReadFile(f)
+	}
+	for _, name := range names {
+		oldFile, newFile := oldFiles[name], newFiles[name]
+		if oldFile != nil && newFile != nil && oldFile.CRC32 == newFile.CRC32 && oldFile.UncompressedSize64 == newFile.UncompressedSize64 {
+			continue
+		}
+		a, err := read(oldArchive, oldFile)
+		if err != nil {
+			return err
+		}
+		b, err := read(newArchive, newFile)
+		if err != nil {
+			return err
+		}
+		entry, err := diffContent(name, a, b, false)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if entry.Status != ChangeUnchanged {
+			d.Entries = append(d.Entries, entry)
+		}
+	}
+	return nil
+}
+
+// diffPlugins compares plugins record by record
+func diffPlugins(d *Diff, original, processed []byte) error {
+	parse := func(content []byte) ([]*PluginRecord, error) {
+		if content == nil {
+			return nil, nil
+		}
+		return ParsePluginRecords(content)
+	}
+	oldRecords, err := parse(original)
+	if err != nil {
+		return fmt.Errorf("invalid original plugin: %w", err)
+	}
+	newRecords, err := parse(processed)
+	if err != nil {
+		return fmt.Errorf("invalid processed plugin: %w", err)
+	}
+
+	index := func(records []*PluginRecord) map[string]*PluginRecord {
+		byKey := make(map[string]*PluginRecord, len(records))
+		for _, r := range records {
+			byKey[r.Key()] = r
+		}
+		return byKey
+	}
+	oldByKey, newByKey := index(oldRecords), index(newRecords)
+
+	for _, r := range oldRecords {
+		updated, ok := newByKey[r.Key()]
+		switch {
+		case !ok:
+			d.add(Change{Kind: ChangeRemoved, Path: r.Key(), Old: r.EditorID})
+		case r.Flags != updated.Flags || !bytes.Equal(r.Data, updated.Data):
+			d.add(Change{Kind: ChangeModified, Path: r.Key(), Old: r.EditorID, New: updated.EditorID, Detail: recordChangeDetail(r, updated)})
+		}
+	}
+	for _, r := range newRecords {
+		if _, ok := oldByKey[r.Key()]; !ok {
+			d.add(Change{Kind: ChangeAdded, Path: r.Key(), New: r.EditorID})
+		}
+	}
+
+	d.Hunks = diffLines(renderPluginRecords(oldRecords, original != nil), renderPluginRecords(newRecords, processed != nil))
+	return nil
+}
+
+// diffNBT compares NBT files tag by tag, with hunks over their SNBT
+func diffNBT(d *Diff, original, processed []byte) error {
+	read := func(content []byte) (interface{}, []byte, error) {
+		if content == nil {
+			return nil, nil, nil
+		}
+		file, err := ReadNBT(content)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nbtDiffValue(file.Root), []byte(FormatSNBT(file.Root) + "\n"), nil
+	}
+	a, oldText, err := read(original)
+	if err != nil {
+		return fmt.Errorf("invalid original NBT: %w", err)
+	}
+	b, newText, err := read(processed)
+	if err != nil {
+		return fmt.Errorf("invalid processed NBT: %w", err)
+	}
+
+	switch {
+	case original == nil:
+		d.add(Change{Kind: ChangeAdded, Path: "", New: b})
+	case processed == nil:
+		d.add(Change{Kind: ChangeRemoved, Path: "", Old: a})
+	default:
+		diffJSONValues(d, "", a, b)
+	}
+	d.Hunks = diffLines(oldText, newText)
+	return nil
+}
+
+// nbtDiffValue converts compounds to maps and lists to slices for
+// diffJSONValues, writing other values as SNBT so changes show their type
+func nbtDiffValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case *NBTCompound:
+		m := make(map[string]interface{}, len(val.keys))
+		for _, key := range val.keys {
+			m[key] = nbtDiffValue(val.values[key])
+		}
+		return m
+	case *NBTList:
+		items := make([]interface{}, len(val.Items))
+		for i, item := range val.Items {
+			items[i] = nbtDiffValue(item)
+		}
+		return items
+	}
+	return inlineSNBT(v)
+}
+
+// recordChangeDetail names the subrecords that differ between two versions
+// of a record
+func recordChangeDetail(a, b *PluginRecord) string {
+	group := func(r *PluginRecord) (map[string][][]byte, error) {
+		subrecords, err := r.Subrecords()
+		if err != nil {
+			return nil, err
+		}
+		bySig := make(map[string][][]byte)
+		for _, sub := range subrecords {
+			bySig[sub.Signature] = append(bySig[sub.Signature], sub.Data)
+		}
+		return bySig, nil
+	}
+	oldSubs, err := group(a)
+	if err != nil {
+		return err.Error()
+	}
+	newSubs, err := group(b)
+	if err != nil {
+		return err.Error()
+	}
+
+	signatures := make(map[string]bool)
+	for sig, data := range oldSubs {
+		if !reflect.DeepEqual(data, newSubs[sig]) {
+			signatures[sig] = true
+		}
+	}
+	for sig := range newSubs {
+		if _, ok := oldSubs[sig]; !ok {
+			signatures[sig] = true
+		}
+	}
+	if a.Flags != b.Flags {
+		return fmt.Sprintf("flags %08X -> %08X; subrecords changed: %s", a.Flags, b.Flags, strings.Join(sortedKeys(signatures), ", "))
+	}
+	return "subrecords changed: " + strings.Join(sortedKeys(signatures), ", ")
+}
+
+// renderPluginRecords writes one line per record and per subrecord so plugin
+// changes can be shown as a line diff
+func renderPluginRecords(records []*PluginRecord, present bool) []byte {
+	if !present {
+		return nil
+	}
+	var b bytes.Buffer
+	for _, r := range records {
+		fmt.Fprintf(&b, "%s %s\n", r.Key(), r.EditorID)
+		subrecords, err := r.Subrecords()
+		if err != nil {
+			fmt.Fprintf(&b, "  ! %v\n", err)
+			continue
+		}
+		for _, sub := range subrecords {
+			fmt.Fprintf(&b, "  %s %s\n", sub.Signature, renderSubrecord(sub.Data))
+		}
+	}
+	return b.Bytes()
+}
+
+// renderSubrecord shows printable strings as text and other data as a
+// checksum and the first bytes in hex
+func renderSubrecord(data []byte) string {
+	if s := zstring(data); len(s) > 0 && len(s) >= len(data)-1 && utf8.ValidString(s) && isPrintable(s) {
+		return fmt.Sprintf("%q", s)
+	}
+	preview := data[:min(len(data), 16)]
+	return fmt.Sprintf("[%d bytes crc %08x] %x", len(data), crc32.ChecksumIEEE(data), preview)
+}
+
+func isPrintable(s string) bool {
+	for _, r := range s {
+		if r < ' ' && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// lineOp is one step of a line diff: the line at old index or new index is
+// kept (' '), removed ('-') or added ('+')
+type lineOp struct {
+	op       byte
+	old, new int
+}
+
+// splitLines splits content after each newline, keeping the newlines
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes the hunks of a line diff
+func diffLines(original, processed []byte) []Hunk {
+	a, b := splitLines(original), splitLines(processed)
+	ops := lineOps(a, b)
+
+	var hunks []Hunk
+	for i := 0; i < len(ops); {
+		if ops[i].op == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk while unchanged runs are short enough to share context
+		end := i + 1
+		for j := end; j < len(ops); j++ {
+			if ops[j].op != ' ' {
+				end = j + 1
+			} else if j-end+1 > 2*diffContext {
+				break
+			}
+		}
+		start, stop := max(i-diffContext, 0), min(end+diffContext, len(ops))
+
+		hunk := Hunk{OldStart: ops[start].old, NewStart: ops[start].new}
+		for _, op := range ops[start:stop] {
+			var text string
+			switch op.op {
+			case ' ':
+				text = a[op.old]
+				hunk.OldLines++
+				hunk.NewLines++
+			case '-':
+				text = a[op.old]
+				hunk.OldLines++
+			case '+':
+				text = b[op.new]
+				hunk.NewLines++
+			}
+			line := DiffLine{Op: string(op.op), Text: strings.TrimSuffix(text, "\n")}
+			line.NoNewline = !strings.HasSuffix(text, "\n")
+			hunk.Lines = append(hunk.Lines, line)
+		}
+		// Unified diffs number from one, except that an empty range names the line before it
+		if hunk.OldLines > 0 {
+			hunk.OldStart++
+		}
+		if hunk.NewLines > 0 {
+			hunk.NewStart++
+		}
+		hunks = append(hunks, hunk)
+		i = stop
+	}
+	return hunks
+}
+
+// lineOps aligns two line sequences by their longest common subsequence
+func lineOps(a, b []string) []lineOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []lineOp
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, lineOp{' ', i, i})
+	}
+
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(ma), len(mb)
+	if n*m > maxLineDiffCells {
+		for i := 0; i < n; i++ {
+			ops = append(ops, lineOp{'-', prefix + i, prefix})
+		}
+		for j := 0; j < m; j++ {
+			ops = append(ops, lineOp{'+', prefix + n, prefix + j})
+		}
+	} else {
+		// lcs[i*(m+1)+j] is the common subsequence length of ma[i:] and mb[j:]
+		lcs := make([]int32, (n+1)*(m+1))
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if ma[i] == mb[j] {
+					lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+				} else {
+					lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && ma[i] == mb[j]:
+				ops = append(ops, lineOp{' ', prefix + i, prefix + j})
+				i++
+				j++
+			case i < n && (j == m || lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]):
+				ops = append(ops, lineOp{'-', prefix + i, prefix + j})
+				i++
+			default:
+				ops = append(ops, lineOp{'+', prefix + i, prefix + j})
+				j++
+			}
+		}
+	}
+
+	for k := 0; k < suffix; k++ {
+		ops = append(ops, lineOp{' ', len(a) - suffix + k, len(b) - suffix + k})
+	}
+	return ops
+}
+
+// Unified renders the diff as unified diff text. Archive entries are named
+// archive!/entry, the way jar URLs address them.
+func (d *Diff) Unified() string {
+	var b strings.Builder
+	d.writeUnified(&b, "")
+	return b.String()
+}
+
+func (d *Diff) writeUnified(b *strings.Builder, prefix string) {
+	if d.Status == ChangeUnchanged {
+		return
+	}
+	name := prefix + d.Path
+
+	switch d.Kind {
+	case DiffArchive:
+		for _, entry := range d.Entries {
+			entry.writeUnified(b, name+"!/")
+		}
+		return
+	case DiffBinary:
+		fmt.Fprintf(b, "Binary files a/%s and b/%s differ\n", name, name)
+		return
+	}
+
+	oldName, newName := "a/"+name, "b/"+name
+	switch d.Status {
+	case ChangeAdded:
+		oldName = "/dev/null"
+	case ChangeRemoved:
+		newName = "/dev/null"
+	}
+	fmt.Fprintf(b, "--- %s\n+++ %s\n", oldName, newName)
+	for _, hunk := range d.Hunks {
+		fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines)
+		for _, line := range hunk.Lines {
+			b.WriteString(line.Op)
+			b.WriteString(line.Text)
+			b.WriteString("\n")
+			if line.NoNewline {
+				b.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package mods
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Ecosystem is the game a Lua mod is written for
+type Ecosystem string
+
+const (
+	EcosystemFactorio  Ecosystem = "factorio"
+	EcosystemGarrysMod Ecosystem = "gmod"
+	EcosystemWoW       Ecosystem = "wow"
+	EcosystemRoblox    Ecosystem = "roblox"
+)
+
+// Ecosystems lists every Lua ecosystem that can be detected or chosen at upload
+var Ecosystems = []Ecosystem{EcosystemFactorio, EcosystemGarrysMod, EcosystemWoW, EcosystemRoblox}
+
+// ParseEcosystem converts a user-supplied ecosystem into a known Ecosystem
+func ParseEcosystem(s string) (Ecosystem, error) {
+	for _, ecosystem := range Ecosystems {
+		if string(ecosystem) == strings.ToLower(strings.TrimSpace(s)) {
+			return ecosystem, nil
+		}
+	}
+	return "", fmt.Errorf("unknown Lua ecosystem: %s", s)
+}
+
+// EcosystemInfo is the detected ecosystem of a Lua mod and what its
+// descriptor declares
+type EcosystemInfo struct {
+	Ecosystem    Ecosystem              `json:"ecosystem"`
+	Confidence   float64                `json:"confidence"`
+	Root         string                 `json:"root,omitempty"` // folder inside the archive holding the mod
+	Name         string                 `json:"name,omitempty"`
+	Title        string                 `json:"title,omitempty"`
+	Version      string                 `json:"version,omitempty"`
+	Author       string                 `json:"author,omitempty"`
+	Description  string                 `json:"description,omitempty"`
+	GameVersion  string                 `json:"game_version,omitempty"` // Factorio version or WoW interface
+	Dependencies []string               `json:"dependencies,omitempty"` // required mods or addons
+	Details      map[string]interface{} `json:"details,omitempty"`      // fields only one ecosystem has
+	Evidence     []Evidence             `json:"evidence"`
+}
+
+// luaEcosystemAPIs are global APIs that only one ecosystem's scripts use,
+// matched against the APIs AnalyzeLua reports
+var luaEcosystemAPIs = map[Ecosystem][]string{
+	EcosystemFactorio:  {"data.extend", "data:extend", "data.raw", "script.on_event", "script.on_init", "script.on_configuration_changed", "defines.", "remote.add_interface", "settings.startup", "settings.global", "serpent."},
+	EcosystemGarrysMod: {"hook.Add", "net.Receive", "net.Start", "concommand.Add", "AddCSLuaFile", "util.AddNetworkString", "vgui.Create", "surface.", "ENT.", "SWEP.", "CLIENT", "SERVER"},
+	EcosystemWoW:       {"CreateFrame", "SlashCmdList", "C_Timer.", "UIParent", "DEFAULT_CHAT_FRAME", "hooksecurefunc", "GetAddOnMetadata", "C_AddOns.", "UnitName", "GetSpellInfo"},
+	EcosystemRoblox:    {"game:GetService", "Instance.new", "workspace", "script.Parent", "Enum.", "Vector3.new", "CFrame.new", "task.wait", "task.spawn"},
+}
+
+// ecosystemDetector accumulates evidence and descriptor fields per ecosystem
+type ecosystemDetector struct {
+	evidence map[Ecosystem][]Evidence
+	infos    map[Ecosystem]*EcosystemInfo
+}
+
+func (d *ecosystemDetector) add(ecosystem Ecosystem, kind, detail string, weight float64) {
+	d.evidence[ecosystem] = append(d.evidence[ecosystem], Evidence{Kind: kind, Detail: detail, Weight: weight})
+}
+
+// info returns the descriptor fields collected for an ecosystem
+func (d *ecosystemDetector) info(ecosystem Ecosystem) *EcosystemInfo {
+	if d.infos[ecosystem] == nil {
+		d.infos[ecosystem] = &EcosystemInfo{Ecosystem: ecosystem, Details: make(map[string]interface{})}
+	}
+	return d.infos[ecosystem]
+}
+
+// best returns the most likely ecosystem, or nil when none is confident
+func (d *ecosystemDetector) best() *EcosystemInfo {
+	var best *EcosystemInfo
+	for _, ecosystem := range Ecosystems {
+		evidence := d.evidence[ecosystem]
+		if len(evidence) == 0 {
+			continue
+		}
+		info := d.info(ecosystem)
+		info.Confidence = combinedConfidence(evidence)
+		sort.SliceStable(evidence, func(i, j int) bool { return evidence[i].Weight > evidence[j].Weight })
+		info.Evidence = evidence
+		if best == nil || info.Confidence > best.Confidence {
+			best = info
+		}
+	}
+	if best == nil || best.Confidence < ConfidenceThreshold {
+		return nil
+	}
+	if len(best.Details) == 0 {
+		best.Details = nil
+	}
+	return best
+}
+
+// DetectEcosystem identifies the Lua ecosystem of an archive from its layout
+// and descriptors, or of a single script from the game APIs it uses. It
+// returns nil when the ecosystem is not clear.
+func DetectEcosystem(filename string, content []byte) *EcosystemInfo {
+	if bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		archive, err := OpenArchive(content, DefaultArchiveLimits)
+		if err != nil {
+			return nil
+		}
+		return detectArchiveEcosystem(archive)
+	}
+	script, err := AnalyzeLua(content)
+	if err != nil {
+		return nil
+	}
+	return ecosystemFromScript(script)
+}
+
+// ecosystemFromScript identifies a script's ecosystem from the game APIs it
+// uses and from Luau syntax, which only Roblox runs
+func ecosystemFromScript(script *LuaScript) *EcosystemInfo {
+	d := &ecosystemDetector{evidence: make(map[Ecosystem][]Evidence), infos: make(map[Ecosystem]*EcosystemInfo)}
+	if script.Luau {
+		d.add(EcosystemRoblox, EvidenceSchema, "Luau syntax", 0.5)
+	}
+	for _, api := range script.APIs {
+		for _, ecosystem := range Ecosystems {
+			for _, prefix := range luaEcosystemAPIs[ecosystem] {
+				if apiMatches(api, prefix) {
+					d.add(ecosystem, EvidenceContent, "uses "+api, 0.4)
+					break
+				}
+			}
+		}
+	}
+	return d.best()
+}
+
+// apiMatches reports whether an API such as hook.Add or game:GetService
+// matches a pattern: the same API, or any field of a pattern ending in a dot
+func apiMatches(api, pattern string) bool {
+	if strings.HasSuffix(pattern, ".") {
+		return strings.HasPrefix(api, pattern)
+	}
+	return api == pattern || strings.HasPrefix(api, pattern+".") || strings.HasPrefix(api, pattern+":")
+}
+
+// detectArchiveEcosystem looks for the descriptors and folders each ecosystem uses
+func detectArchiveEcosystem(archive *Archive) *EcosystemInfo {
+	d := &ecosystemDetector{evidence: make(map[Ecosystem][]Evidence), infos: make(map[Ecosystem]*EcosystemInfo)}
+	names := make(map[string]bool)
+	for _, f := range archive.Files() {
+		names[f.Name] = true
+	}
+	read := func(name string) []byte {
+		data, err := archive.ReadFileNamed(name)
+		if err != nil {
+			return nil
+		}
+		return data
+	}
+
+	rbxm, rojoScripts := 0, 0
+	for _, f := range archive.Files() {
+		name := f.Name
+		dir, base := path.Split(name)
+		depth := strings.Count(name, "/")
+		lower := strings.ToLower(base)
+		switch {
+		case base == "info.json" && depth <= 1:
+			d.inspectFactorio(dir, read(name), names)
+		case base == "addon.json" && depth <= 1:
+			d.inspectGarrysModAddon(dir, read(name))
+		case strings.HasSuffix(lower, ".toc") && depth <= 1 && (depth == 0 || strings.HasPrefix(base, path.Base(dir))):
+			d.inspectTOC(dir, base, read(name))
+		case lower == "default.project.json" || (strings.HasSuffix(lower, ".project.json") && depth == 0):
+			d.inspectRojoProject(dir, base, read(name))
+		case strings.HasSuffix(lower, ".rbxm") || strings.HasSuffix(lower, ".rbxmx"):
+			rbxm++
+		case strings.HasSuffix(lower, ".server.lua") || strings.HasSuffix(lower, ".client.lua") || strings.HasSuffix(lower, ".luau"):
+			rojoScripts++
+		}
+	}
+
+	for _, marker := range []struct {
+		dir    string
+		weight float64
+	}{{"lua/autorun/", 0.6}, {"lua/entities/", 0.5}, {"lua/weapons/", 0.5}, {"lua/effects/", 0.4}, {"gamemodes/", 0.4}} {
+		for _, f := range archive.Files() {
+			name := f.Name
+			if i := strings.Index(name, marker.dir); i >= 0 && strings.Count(name[:i], "/") <= 1 {
+				info := d.info(EcosystemGarrysMod)
+				if info.Root == "" {
+					info.Root = name[:i]
+				}
+				d.add(EcosystemGarrysMod, EvidenceContent, marker.dir+" folder", marker.weight)
+				break
+			}
+		}
+	}
+	if rbxm > 0 {
+		d.add(EcosystemRoblox, EvidenceMagic, fmt.Sprintf("%d Roblox model files", rbxm), 0.7)
+		d.info(EcosystemRoblox).Details["models"] = rbxm
+	}
+	if rojoScripts > 0 {
+		d.add(EcosystemRoblox, EvidenceContent, fmt.Sprintf("%d Rojo script files", rojoScripts), 0.4)
+	}
+	return d.best()
+}
+
+// inspectFactorio reads a Factorio info.json and checks for the data and
+// control stage scripts beside it
+func (d *ecosystemDetector) inspectFactorio(dir string, data []byte, names map[string]bool) {
+	var info struct {
+		Name            string   `json:"name"`
+		Version         string   `json:"version"`
+		Title           string   `json:"title"`
+		Author          string   `json:"author"`
+		Description     string   `json:"description"`
+		FactorioVersion string   `json:"factorio_version"`
+		Dependencies    []string `json:"dependencies"`
+	}
+	if json.Unmarshal(data, &info) != nil || info.Name == "" || info.Version == "" {
+		return
+	}
+	d.add(EcosystemFactorio, EvidenceDescriptor, dir+"info.json with name and version", 0.6)
+	if info.FactorioVersion != "" {
+		d.add(EcosystemFactorio, EvidenceSchema, "factorio_version "+info.FactorioVersion, 0.5)
+	}
+	var stages []string
+	for _, stage := range []string{"settings.lua", "data.lua", "data-updates.lua", "data-final-fixes.lua", "control.lua"} {
+		if names[dir+stage] {
+			stages = append(stages, stage)
+		}
+	}
+	if len(stages) > 0 {
+		d.add(EcosystemFactorio, EvidenceContent, "stage scripts "+strings.Join(stages, ", "), 0.6)
+	}
+
+	e := d.info(EcosystemFactorio)
+	e.Root, e.Name, e.Version, e.Title, e.Author, e.Description = dir, info.Name, info.Version, info.Title, info.Author, info.Description
+	e.GameVersion = info.FactorioVersion
+	e.Details["stages"] = stages
+	var optional, incompatible []string
+	for _, dep := range info.Dependencies {
+		// Prefixes: ! incompatible, ? optional, (?) hidden optional, ~ no load order
+		dep = strings.TrimSpace(dep)
+		switch {
+		case strings.HasPrefix(dep, "!"):
+			incompatible = append(incompatible, factorioDependencyName(dep[1:]))
+		case strings.HasPrefix(dep, "?"), strings.HasPrefix(dep, "(?)"):
+			optional = append(optional, factorioDependencyName(strings.TrimPrefix(strings.TrimPrefix(dep, "(?)"), "?")))
+		default:
+			e.Dependencies = append(e.Dependencies, factorioDependencyName(strings.TrimPrefix(dep, "~")))
+		}
+	}
+	if len(optional) > 0 {
+		e.Details["optional_dependencies"] = optional
+	}
+	if len(incompatible) > 0 {
+		e.Details["incompatible"] = incompatible
+	}
+}
+
+// factorioDependencyName strips the version constraint from a dependency
+func factorioDependencyName(dep string) string {
+	fields := strings.Fields(dep)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// inspectGarrysModAddon reads a Garry's Mod addon.json
+func (d *ecosystemDetector) inspectGarrysModAddon(dir string, data []byte) {
+	var addon struct {
+		Title  string   `json:"title"`
+		Type   string   `json:"type"`
+		Tags   []string `json:"tags"`
+		Ignore []string `json:"ignore"`
+	}
+	if json.Unmarshal(data, &addon) != nil || addon.Title == "" {
+		return
+	}
+	weight := 0.5
+	if addon.Type != "" || len(addon.Tags) > 0 {
+		weight = 0.9
+	}
+	d.add(EcosystemGarrysMod, EvidenceDescriptor, dir+"addon.json", weight)
+
+	e := d.info(EcosystemGarrysMod)
+	e.Root, e.Title = dir, addon.Title
+	if addon.Type != "" {
+		e.Details["type"] = addon.Type
+	}
+	if len(addon.Tags) > 0 {
+		e.Details["tags"] = addon.Tags
+	}
+}
+
+// inspectTOC reads a World of Warcraft table of contents: ## Key: Value
+// metadata followed by the files the addon loads
+func (d *ecosystemDetector) inspectTOC(dir, base string, data []byte) {
+	fields := make(map[string]string)
+	var files []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if rest, ok := strings.CutPrefix(line, "##"); ok {
+			if key, value, ok := strings.Cut(rest, ":"); ok {
+				fields[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+			}
+		} else if line != "" && !strings.HasPrefix(line, "#") {
+			files = append(files, line)
+		}
+	}
+	if len(fields) == 0 {
+		return
+	}
+	weight := 0.6
+	if fields["interface"] != "" {
+		weight = 0.95
+	}
+	d.add(EcosystemWoW, EvidenceDescriptor, dir+base, weight)
+
+	e := d.info(EcosystemWoW)
+	e.Root, e.Name = dir, strings.TrimSuffix(base, path.Ext(base))
+	e.Title, e.Version, e.Author, e.Description = fields["title"], fields["version"], fields["author"], fields["notes"]
+	e.GameVersion = fields["interface"]
+	for key, value := range fields {
+		if key == "requireddeps" || strings.HasPrefix(key, "dep") {
+			e.Dependencies = append(e.Dependencies, splitList(value)...)
+		}
+	}
+	sort.Strings(e.Dependencies)
+	if deps := splitList(fields["optionaldeps"]); len(deps) > 0 {
+		e.Details["optional_dependencies"] = deps
+	}
+	if saved := splitList(fields["savedvariables"]); len(saved) > 0 {
+		e.Details["saved_variables"] = saved
+	}
+	e.Details["files"] = files
+}
+
+// inspectRojoProject reads a Rojo project file, whose tree maps Roblox
+// services to source folders
+func (d *ecosystemDetector) inspectRojoProject(dir, base string, data []byte) {
+	var project struct {
+		Name string                 `json:"name"`
+		Tree map[string]interface{} `json:"tree"`
+	}
+	if json.Unmarshal(data, &project) != nil || project.Tree == nil {
+		return
+	}
+	d.add(EcosystemRoblox, EvidenceDescriptor, dir+base+" (Rojo project)", 0.9)
+
+	e := d.info(EcosystemRoblox)
+	e.Root, e.Name = dir, project.Name
+	var services []string
+	for _, key := range sortedKeys(project.Tree) {
+		if !strings.HasPrefix(key, "$") {
+			services = append(services, key)
+		}
+	}
+	e.Details["services"] = services
+}
+
+// splitList splits a comma-separated descriptor value
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,644 @@
+package mods
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// FactorioSourceLocale is the locale Factorio translations are made from
+const FactorioSourceLocale = "en"
+
+var (
+	factorioModName     = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	factorioModVersion  = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+	factorioGameVersion = regexp.MustCompile(`^\d+\.\d+$`)
+	factorioDependency  = regexp.MustCompile(`^(!|\?|\(\?\)|~)?\s*(.+?)(?:\s*(<=|>=|<|>|=)\s*(\d+\.\d+(?:\.\d+)?))?$`)
+	factorioLocaleCode  = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z]{2,4})?$`)
+	factorioLocalePath  = regexp.MustCompile(`(?:^|/)locale/([^/]+)/([^/]+\.cfg)$`)
+
+	// Parameters such as __1__ and __ITEM__iron-plate__, and rich text tags
+	// such as [item=iron-plate] and [/color], which translations must keep
+	factorioPlaceholder = regexp.MustCompile(`__\d+__|__[A-Z]+(?:_[A-Z]+)*__[A-Za-z0-9._-]+__|\[[a-z-]+=[^\]]*\]|\[/[a-z-]+\]`)
+)
+
+// factorioDataStage are the data stage scripts Factorio runs in order, which
+// define prototypes
+var factorioDataStage = []string{"data.lua", "data-updates.lua", "data-final-fixes.lua"}
+
+// Factorio dependency kinds, from the prefix of each info.json dependency
+const (
+	FactorioRequired       = "required"
+	FactorioOptional       = "optional"        // ?
+	FactorioHiddenOptional = "hidden_optional" // (?)
+	FactorioIncompatible   = "incompatible"    // !
+	FactorioNoLoadOrder    = "no_load_order"   // ~, required without affecting load order
+)
+
+// FactorioDependency is one entry of an info.json dependencies list, such as
+// "? space-exploration >= 0.6"
+type FactorioDependency struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Operator string `json:"operator,omitempty"`
+	Version  string `json:"version,omitempty"`
+}
+
+// String formats the dependency the way info.json writes it
+func (d FactorioDependency) String() string {
+	prefix := map[string]string{FactorioOptional: "? ", FactorioHiddenOptional: "(?) ", FactorioIncompatible: "! ", FactorioNoLoadOrder: "~ "}[d.Kind]
+	if d.Operator == "" {
+		return prefix + d.Name
+	}
+	return fmt.Sprintf("%s%s %s %s", prefix, d.Name, d.Operator, d.Version)
+}
+
+// ParseFactorioDependency reads one info.json dependency string
+func ParseFactorioDependency(s string) (FactorioDependency, error) {
+	m := factorioDependency.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil || strings.ContainsAny(m[2], "<>=!") {
+		return FactorioDependency{}, fmt.Errorf("invalid dependency %q", s)
+	}
+	dep := FactorioDependency{Name: m[2], Kind: FactorioRequired, Operator: m[3], Version: m[4]}
+	switch m[1] {
+	case "?":
+		dep.Kind = FactorioOptional
+	case "(?)":
+		dep.Kind = FactorioHiddenOptional
+	case "!":
+		dep.Kind = FactorioIncompatible
+		if dep.Operator != "" {
+			return FactorioDependency{}, fmt.Errorf("incompatible dependency %q cannot have a version", s)
+		}
+	case "~":
+		dep.Kind = FactorioNoLoadOrder
+	}
+	return dep, nil
+}
+
+// FactorioInfo is a Factorio mod's info.json
+type FactorioInfo struct {
+	Name            string               `json:"name"`
+	Version         string               `json:"version"`
+	Title           string               `json:"title"`
+	Author          string               `json:"author"`
+	Contact         string               `json:"contact,omitempty"`
+	Homepage        string               `json:"homepage,omitempty"`
+	Description     string               `json:"description,omitempty"`
+	FactorioVersion string               `json:"factorio_version"`
+	Dependencies    []FactorioDependency `json:"dependencies"`
+}
+
+// PackageName is the folder and zip name Factorio requires, name_version
+func (i *FactorioInfo) PackageName() string {
+	return i.Name + "_" + i.Version
+}
+
+// ParseFactorioInfo reads and validates an info.json
+func ParseFactorioInfo(data []byte) (*FactorioInfo, error) {
+	var raw struct {
+		Name            string    `json:"name"`
+		Version         string    `json:"version"`
+		Title           string    `json:"title"`
+		Author          string    `json:"author"`
+		Contact         string    `json:"contact"`
+		Homepage        string    `json:"homepage"`
+		Description     string    `json:"description"`
+		FactorioVersion string    `json:"factorio_version"`
+		Dependencies    *[]string `json:"dependencies"`
+	}
+	if err := json.Unmarshal(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), &raw); err != nil {
+		return nil, fmt.Errorf("invalid info.json: %w", err)
+	}
+	if !factorioModName.MatchString(raw.Name) {
+		return nil, fmt.Errorf("info.json name %q must be letters, digits, - and _", raw.Name)
+	}
+	if !factorioModVersion.MatchString(raw.Version) {
+		return nil, fmt.Errorf("info.json version %q must be major.minor.patch", raw.Version)
+	}
+	if raw.FactorioVersion == "" {
+		raw.FactorioVersion = "0.12" // what Factorio assumes when it is missing
+	}
+	if !factorioGameVersion.MatchString(raw.FactorioVersion) {
+		return nil, fmt.Errorf("info.json factorio_version %q must be major.minor", raw.FactorioVersion)
+	}
+
+	info := &FactorioInfo{
+		Name: raw.Name, Version: raw.Version, Title: raw.Title, Author: raw.Author,
+		Contact: raw.Contact, Homepage: raw.Homepage, Description: raw.Description,
+		FactorioVersion: raw.FactorioVersion,
+	}
+	// A mod without a dependencies list depends on base
+	deps := []string{"base"}
+	if raw.Dependencies != nil {
+		deps = *raw.Dependencies
+	}
+	for _, s := range deps {
+		dep, err := ParseFactorioDependency(s)
+		if err != nil {
+			return nil, fmt.Errorf("info.json: %w", err)
+		}
+		info.Dependencies = append(info.Dependencies, dep)
+	}
+	return info, nil
+}
+
+// FactorioLocale is a locale file, locale/<language>/<file>.cfg: key=value
+// lines grouped under [section] headers. Keys are section.key.
+type FactorioLocale struct {
+	Path     string            `json:"path"`
+	Locale   string            `json:"locale"`
+	File     string            `json:"file"`
+	Keys     []string          `json:"-"` // in file order
+	Entries  map[string]string `json:"-"`
+	sections map[string]string // key to the section it is under
+	raw      []byte
+}
+
+// ValidFactorioLocale reports whether a locale code has Factorio's shape, such as de or pt-BR
+func ValidFactorioLocale(locale string) bool {
+	return factorioLocaleCode.MatchString(locale)
+}
+
+// ParseFactorioLocale reads a locale file named by its archive path
+func ParseFactorioLocale(name string, data []byte) (*FactorioLocale, error) {
+	m := factorioLocalePath.FindStringSubmatch(name)
+	if m == nil {
+		return nil, fmt.Errorf("%s is not a locale file", name)
+	}
+	locale := &FactorioLocale{
+		Path:     name,
+		Locale:   m[1],
+		File:     m[2],
+		Entries:  make(map[string]string),
+		sections: make(map[string]string),
+		raw:      data,
+	}
+
+	section := ""
+	for i, line := range strings.Split(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "", strings.HasPrefix(trimmed, ";"), strings.HasPrefix(trimmed, "#"):
+		case strings.HasPrefix(trimmed, "["):
+			if !strings.HasSuffix(trimmed, "]") {
+				return nil, fmt.Errorf("%s line %d: unterminated section header", name, i+1)
+			}
+			section = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("%s line %d: expected key=value", name, i+1)
+			}
+			locale.add(section, strings.TrimSpace(key), value)
+		}
+	}
+	return locale, nil
+}
+
+func (l *FactorioLocale) add(section, key, value string) {
+	full := key
+	if section != "" {
+		full = section + "." + key
+	}
+	if _, ok := l.Entries[full]; !ok {
+		l.Keys = append(l.Keys, full)
+		l.sections[full] = section
+	}
+	l.Entries[full] = value
+}
+
+// Encode writes the locale file. Existing lines, including comments, are
+// kept; new keys go at the end of their section, and new sections at the end
+// of the file.
+func (l *FactorioLocale) Encode() []byte {
+	existing := make(map[string]bool)
+	if len(l.raw) > 0 {
+		if parsed, err := ParseFactorioLocale(l.Path, l.raw); err == nil {
+			existing = make(map[string]bool, len(parsed.Keys))
+			for _, key := range parsed.Keys {
+				existing[key] = true
+			}
+		}
+	}
+
+	text := strings.TrimSuffix(strings.ReplaceAll(string(l.raw), "\r\n", "\n"), "\n")
+	var lines []string
+	if text != "" {
+		lines = strings.Split(text, "\n")
+	}
+
+	// The line after which each existing section's new keys are inserted
+	last := map[string]int{"": -1}
+	section := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "", strings.HasPrefix(trimmed, ";"), strings.HasPrefix(trimmed, "#"):
+		case strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"):
+			section = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			last[section] = i
+		default:
+			last[section] = i
+		}
+	}
+
+	inserts := make(map[int][]string)
+	var added []string // sections that do not exist yet, in first-key order
+	addedKeys := make(map[string][]string)
+	for _, key := range l.Keys {
+		if existing[key] {
+			continue
+		}
+		section, name := l.sections[key], key
+		if section != "" {
+			name = strings.TrimPrefix(key, section+".")
+		}
+		line := name + "=" + l.Entries[key]
+		if at, ok := last[section]; ok {
+			inserts[at] = append(inserts[at], line)
+			continue
+		}
+		if _, ok := addedKeys[section]; !ok {
+			added = append(added, section)
+		}
+		addedKeys[section] = append(addedKeys[section], line)
+	}
+
+	var out []string
+	out = append(out, inserts[-1]...)
+	for i, line := range lines {
+		out = append(out, line)
+		out = append(out, inserts[i]...)
+	}
+	for _, section := range added {
+		if len(out) > 0 {
+			out = append(out, "")
+		}
+		out = append(out, "["+section+"]")
+		out = append(out, addedKeys[section]...)
+	}
+
+	newline := "\n"
+	if bytes.Contains(l.raw, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+	return []byte(strings.Join(out, newline) + newline)
+}
+
+// CheckFactorioTranslation verifies that a translation keeps the parameters
+// (__1__, __ITEM__iron-plate__) and rich text tags of the source string and
+// stays on one line
+func CheckFactorioTranslation(source, translated string) error {
+	if translated == "" && source != "" {
+		return fmt.Errorf("translation is empty")
+	}
+	if strings.ContainsAny(translated, "\r\n") {
+		return fmt.Errorf("locale values cannot span lines")
+	}
+	if want, got := sortedMatches(factorioPlaceholder, source), sortedMatches(factorioPlaceholder, translated); want != got {
+		return fmt.Errorf("parameters changed from [%s] to [%s]", want, got)
+	}
+	return nil
+}
+
+// FactorioMod is a Factorio mod zip
+type FactorioMod struct {
+	Root       string            `json:"root"` // folder holding info.json, "" at the archive root
+	Info       *FactorioInfo     `json:"info"`
+	Stages     []string          `json:"stages"`     // settings, data and control stage scripts present
+	Prototypes []string          `json:"prototypes"` // scripts defining prototypes, relative to Root
+	Locales    []*FactorioLocale `json:"locales"`
+}
+
+// findFactorioInfo returns the shallowest info.json of an archive and the
+// folder it is in
+func findFactorioInfo(archive *Archive) (string, *FactorioInfo, error) {
+	var found string
+	for _, f := range archive.Files() {
+		if path.Base(f.Name) != "info.json" || strings.Count(f.Name, "/") > 1 {
+			continue
+		}
+		if found == "" || strings.Count(f.Name, "/") < strings.Count(found, "/") {
+			found = f.Name
+		}
+	}
+	if found == "" {
+		return "", nil, fmt.Errorf("archive has no info.json")
+	}
+	data, err := archive.ReadFileNamed(found)
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := ParseFactorioInfo(data)
+	if err != nil {
+		return "", nil, err
+	}
+	return strings.TrimSuffix(found, "info.json"), info, nil
+}
+
+// ParseFactorioMod reads a Factorio mod zip's info.json, scripts and locale files
+func ParseFactorioMod(content []byte) (*FactorioMod, error) {
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return nil, err
+	}
+	root, info, err := findFactorioInfo(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	mod := &FactorioMod{Root: root, Info: info, Stages: []string{}, Prototypes: []string{}, Locales: []*FactorioLocale{}}
+	for _, f := range archive.Files() {
+		if !strings.HasPrefix(f.Name, root) || f.FileInfo().IsDir() {
+			continue
+		}
+		rel := strings.TrimPrefix(f.Name, root)
+		switch rel {
+		case "settings.lua", "settings-updates.lua", "settings-final-fixes.lua", "data.lua", "data-updates.lua", "data-final-fixes.lua", "control.lua":
+			mod.Stages = append(mod.Stages, rel)
+		}
+		if isFactorioPrototypeScript(rel) {
+			mod.Prototypes = append(mod.Prototypes, rel)
+		}
+		if factorioLocalePath.MatchString(rel) && strings.HasPrefix(rel, "locale/") {
+			data, err := archive.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			locale, err := ParseFactorioLocale(f.Name, data)
+			if err != nil {
+				return nil, err
+			}
+			mod.Locales = append(mod.Locales, locale)
+		}
+	}
+	return mod, nil
+}
+
+// isFactorioPrototypeScript reports whether a path relative to the mod root
+// is a data stage script or a script under prototypes/
+func isFactorioPrototypeScript(rel string) bool {
+	for _, stage := range factorioDataStage {
+		if rel == stage {
+			return true
+		}
+	}
+	return strings.HasPrefix(rel, "prototypes/") && strings.HasSuffix(rel, ".lua")
+}
+
+// IsPrototypeScript reports whether an archive entry defines prototypes
+func (m *FactorioMod) IsPrototypeScript(name string) bool {
+	return strings.HasPrefix(name, m.Root) && isFactorioPrototypeScript(strings.TrimPrefix(name, m.Root))
+}
+
+// LocaleCoverage reports key coverage of every locale file against the en
+// file of the same name, which stands in for the namespace
+func (m *FactorioMod) LocaleCoverage() []LocaleCoverage {
+	sources := make(map[string]*FactorioLocale)
+	for _, locale := range m.Locales {
+		if locale.Locale == FactorioSourceLocale {
+			sources[locale.File] = locale
+		}
+	}
+	var coverage []LocaleCoverage
+	for _, locale := range m.Locales {
+		source, ok := sources[locale.File]
+		if !ok || locale == source {
+			continue
+		}
+		c := LocaleCoverage{Namespace: locale.File, Locale: locale.Locale, Total: len(source.Keys)}
+		for _, key := range source.Keys {
+			if _, ok := locale.Entries[key]; ok {
+				c.Translated++
+			} else if len(c.Missing) < maxReportedMissingKeys {
+				c.Missing = append(c.Missing, key)
+			}
+		}
+		for key := range locale.Entries {
+			if _, ok := source.Entries[key]; !ok {
+				c.Extra++
+			}
+		}
+		c.Percent = 100
+		if c.Total > 0 {
+			c.Percent = float64(c.Translated*1000/c.Total) / 10
+		}
+		coverage = append(coverage, c)
+	}
+	sort.Slice(coverage, func(i, j int) bool {
+		if coverage[i].Namespace != coverage[j].Namespace {
+			return coverage[i].Namespace < coverage[j].Namespace
+		}
+		return coverage[i].Locale < coverage[j].Locale
+	})
+	return coverage
+}
+
+// TranslateFactorioMod creates or completes the given locales of a Factorio
+// mod from its en locale files. Nothing outside locale/ is changed.
+func TranslateFactorioMod(content []byte, locales []string, translate TranslateFunc) ([]byte, *TranslationReport, error) {
+	mod, err := ParseFactorioMod(content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	existing := make(map[string]*FactorioLocale)
+	var sources []*FactorioLocale
+	for _, locale := range mod.Locales {
+		existing[locale.Locale+"/"+locale.File] = locale
+		if locale.Locale == FactorioSourceLocale {
+			sources = append(sources, locale)
+		}
+	}
+	if len(sources) == 0 {
+		return nil, nil, fmt.Errorf("mod has no %s locale files to translate from", FactorioSourceLocale)
+	}
+
+	report := &TranslationReport{Files: []string{}}
+	changes := make(map[string][]byte)
+	for _, source := range sources {
+		for _, locale := range locales {
+			if !ValidFactorioLocale(locale) || locale == FactorioSourceLocale {
+				return nil, nil, fmt.Errorf("invalid target locale %q", locale)
+			}
+			target := existing[locale+"/"+source.File]
+			if target == nil {
+				target = &FactorioLocale{
+					Path:     mod.Root + "locale/" + locale + "/" + source.File,
+					Locale:   locale,
+					File:     source.File,
+					Entries:  make(map[string]string),
+					sections: make(map[string]string),
+				}
+			}
+
+			var missing []string
+			for _, key := range source.Keys {
+				if _, ok := target.Entries[key]; !ok {
+					missing = append(missing, key)
+				}
+			}
+			translated, issues, err := translateBatches(locale, missing, source.Entries, translate, CheckFactorioTranslation)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s %s: %w", source.File, locale, err)
+			}
+			report.Issues = append(report.Issues, issues...)
+			if len(translated) == 0 {
+				continue
+			}
+			for _, key := range missing {
+				if value, ok := translated[key]; ok {
+					section, name := source.sections[key], key
+					if section != "" {
+						name = strings.TrimPrefix(key, section+".")
+					}
+					target.add(section, name, value)
+				}
+			}
+			changes[target.Path] = target.Encode()
+			report.Files = append(report.Files, target.Path)
+			report.Translated += len(translated)
+		}
+	}
+
+	if len(changes) == 0 {
+		return content, report, nil
+	}
+	output, err := RewriteArchive(content, changes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return output, report, nil
+}
+
+// PackageFactorioMod moves a mod's files into the name_version/ folder
+// Factorio requires, using the name and version from its info.json. Files
+// outside the folder holding info.json are never loaded and are left out;
+// their names are returned.
+func PackageFactorioMod(content []byte) ([]byte, []string, error) {
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return nil, nil, err
+	}
+	root, info, err := findFactorioInfo(archive)
+	if err != nil {
+		return nil, nil, err
+	}
+	want := info.PackageName() + "/"
+
+	var dropped []string
+	moved := false
+	renames := make(map[string]string)
+	for _, f := range archive.Files() {
+		if !strings.HasPrefix(f.Name, root) || f.Name == root {
+			if f.Name != root {
+				dropped = append(dropped, f.Name)
+			}
+			continue
+		}
+		renames[f.Name] = want + strings.TrimPrefix(f.Name, root)
+		moved = moved || renames[f.Name] != f.Name
+	}
+	if !moved && len(dropped) == 0 && root == want {
+		return content, nil, nil
+	}
+
+	output, err := RenameArchive(content, func(name string) (string, bool) {
+		renamed, ok := renames[name]
+		return renamed, ok
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return output, dropped, nil
+}
+
+// factorioFormat handles Factorio mod zips, identified by an info.json at the
+// archive root or in its top folder
+type factorioFormat struct{}
+
+func (factorioFormat) Info() FormatInfo {
+	return FormatInfo{
+		Name:       "factorio",
+		GameTypes:  []GameType{GameTypeLua},
+		Extensions: []string{".zip"},
+		MIMETypes:  []string{"application/zip", "application/x-zip-compressed"},
+		MaxSize:    100 * 1024 * 1024,
+		Container:  true,
+	}
+}
+
+func (factorioFormat) Detect(filename string, content []byte) bool {
+	if !bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		return false
+	}
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return false
+	}
+	_, _, err = findFactorioInfo(archive)
+	return err == nil
+}
+
+func (factorioFormat) Validate(filename string, content []byte) error {
+	if err := ScanArchive(content, DefaultArchiveLimits); err != nil {
+		return err
+	}
+	if _, err := ParseFactorioMod(content); err != nil {
+		return fmt.Errorf("invalid Factorio mod: %w", err)
+	}
+	return nil
+}
+
+func (factorioFormat) ExtractMetadata(content []byte) (map[string]interface{}, error) {
+	mod, err := ParseFactorioMod(content)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := map[string]interface{}{
+		"format":            "factorio",
+		"entries":           len(archive.Files()),
+		"factorio":          mod,
+		"package":           mod.Info.PackageName(),
+		"needs_repackaging": mod.Root != mod.Info.PackageName()+"/",
+	}
+	if coverage := mod.LocaleCoverage(); len(coverage) > 0 {
+		metadata["lang_coverage"] = coverage
+	}
+	if ecosystem := detectArchiveEcosystem(archive); ecosystem != nil {
+		metadata["ecosystem"] = ecosystem
+	}
+	return metadata, nil
+}
+
+// Rewrite is not used for Factorio mods; their scripts are edited through EditArchive
+func (factorioFormat) Rewrite(original, edited []byte) ([]byte, error) {
+	return nil, ErrRewriteUnsupported
+}
+
+// Package lays the edited mod out in its name_version/ folder
+func (factorioFormat) Package(content []byte) ([]byte, []string, error) {
+	output, dropped, err := PackageFactorioMod(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	var notes []string
+	if !bytes.Equal(output, content) {
+		notes = append(notes, "Repackaged into the name_version/ folder Factorio requires")
+	}
+	for _, name := range dropped {
+		notes = append(notes, fmt.Sprintf("Left out %s, which is outside the mod folder", name))
+	}
+	return output, notes, nil
+}
@@ -0,0 +1,72 @@
+package mods
+
+import (
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+)
+
+// Fingerprints identify a file the way mod platforms do: Modrinth looks files
+// up by SHA-1 or SHA-512, CurseForge by its MurmurHash2 fingerprint
+type Fingerprints struct {
+	SHA1       string `json:"sha1"`
+	SHA512     string `json:"sha512"`
+	CurseForge uint32 `json:"curseforge"`
+}
+
+// Fingerprint computes every platform fingerprint of a file
+func Fingerprint(content []byte) Fingerprints {
+	sum1 := sha1.Sum(content)
+	sum512 := sha512.Sum512(content)
+	return Fingerprints{
+		SHA1:       hex.EncodeToString(sum1[:]),
+		SHA512:     hex.EncodeToString(sum512[:]),
+		CurseForge: CurseForgeFingerprint(content),
+	}
+}
+
+// CurseForgeFingerprint is CurseForge's file fingerprint: MurmurHash2 with
+// seed 1 over the file with tabs, newlines, carriage returns and spaces removed
+func CurseForgeFingerprint(content []byte) uint32 {
+	normalized := make([]byte, 0, len(content))
+	for _, b := range content {
+		if b != '\t' && b != '\n' && b != '\r' && b != ' ' {
+			normalized = append(normalized, b)
+		}
+	}
+	return MurmurHash2(normalized, 1)
+}
+
+// MurmurHash2 is the 32-bit MurmurHash2 of Austin Appleby
+func MurmurHash2(data []byte, seed uint32) uint32 {
+	const m, r = 0x5bd1e995, 24
+
+	h := seed ^ uint32(len(data))
+	for len(data) >= 4 {
+		k := binary.LittleEndian.Uint32(data)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+		data = data[4:]
+	}
+
+	switch len(data) {
+	case 3:
+		h ^= uint32(data[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[0])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
@@ -0,0 +1,184 @@
+package mods
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrRewriteUnsupported is returned by formats whose files cannot be rebuilt from AI output
+var ErrRewriteUnsupported = errors.New("format does not support rewriting")
+
+// FormatInfo declares the upload policy for a mod format
+type FormatInfo struct {
+	Name       string     `json:"name"`
+	GameTypes  []GameType `json:"game_types"`
+	Extensions []string   `json:"extensions"`
+	MIMETypes  []string   `json:"mime_types"`
+	MaxSize    int64      `json:"max_size"`
+	Editable   bool       `json:"editable"`  // whether AI output can be rewritten into this format
+	Container  bool       `json:"container"` // whether the AI edits the editable files inside it instead
+}
+
+// Format is implemented by every supported mod file format
+type Format interface {
+	// Info returns the format's extensions, size limit and MIME types
+	Info() FormatInfo
+	// Detect reports whether content is in this format
+	Detect(filename string, content []byte) bool
+	// Validate checks that content is a well-formed file of this format
+	Validate(filename string, content []byte) error
+	// ExtractMetadata returns format-specific metadata for the content
+	ExtractMetadata(content []byte) (map[string]interface{}, error)
+	// Rewrite builds the file to store from the original content and the edited
+	// content returned by the AI, rejecting edits that break the format
+	Rewrite(original, edited []byte) ([]byte, error)
+}
+
+// Packager is implemented by container formats whose edited archives must be
+// laid out a particular way for the game to load them
+type Packager interface {
+	// Package rebuilds an edited archive in the required layout and returns
+	// changelog notes describing what it changed
+	Package(content []byte) ([]byte, []string, error)
+}
+
+// Decoder is implemented by binary formats the AI edits in a text form. The
+// edited text is passed to Rewrite in place of edited file content.
+type Decoder interface {
+	// Decode returns the text form of content
+	Decode(content []byte) ([]byte, error)
+}
+
+// ChangeReporter is implemented by formats that can list what an edit changed
+type ChangeReporter interface {
+	// ReportChanges returns a changelog line for each value the edited
+	// content changes in the original
+	ReportChanges(original, edited []byte) ([]string, error)
+}
+
+// EditableContent returns the content the AI edits for a file of the format
+func EditableContent(format Format, content []byte) ([]byte, error) {
+	if decoder, ok := format.(Decoder); ok {
+		return decoder.Decode(content)
+	}
+	return content, nil
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []Format
+)
+
+// RegisterFormat adds a format to the registry. Formats registered earlier take
+// precedence when several accept the same file.
+func RegisterFormat(f Format) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, f)
+}
+
+// Formats returns every registered format
+func Formats() []Format {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]Format(nil), registry...)
+}
+
+// FormatsForExtension returns the registered formats that accept the file's extension
+func FormatsForExtension(filename string) []Format {
+	ext := strings.ToLower(filepath.Ext(filename))
+	var matches []Format
+	for _, f := range Formats() {
+		for _, e := range f.Info().Extensions {
+			if e == ext {
+				matches = append(matches, f)
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// FormatFor returns the format of a file, matching on extension and content
+func FormatFor(filename string, content []byte) (Format, error) {
+	candidates := FormatsForExtension(filename)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("unsupported file type: %s", strings.ToLower(filepath.Ext(filename)))
+	}
+	for _, f := range candidates {
+		if f.Detect(filename, content) {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("file content does not match its %s extension", strings.ToLower(filepath.Ext(filename)))
+}
+
+// AllowedExtensions lists every extension accepted by a registered format
+func AllowedExtensions() []string {
+	seen := make(map[string]bool)
+	var exts []string
+	for _, f := range Formats() {
+		for _, ext := range f.Info().Extensions {
+			if !seen[ext] {
+				seen[ext] = true
+				exts = append(exts, ext)
+			}
+		}
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// MaxUploadSize is the largest size limit of any registered format, used to
+// reject oversized uploads before they are read
+func MaxUploadSize() int64 {
+	var max int64
+	for _, f := range Formats() {
+		if size := f.Info().MaxSize; size > max {
+			max = size
+		}
+	}
+	return max
+}
+
+// ValidateUpload applies the upload policy of the file's format and returns the format
+func ValidateUpload(filename string, size int64, contentType string, content []byte) (Format, error) {
+	format, err := FormatFor(filename, content)
+	if err != nil {
+		return nil, err
+	}
+
+	info := format.Info()
+	if size > info.MaxSize {
+		return nil, fmt.Errorf("file size exceeds maximum limit of %dMB for %s files", info.MaxSize/(1024*1024), info.Name)
+	}
+
+	if !acceptsMIMEType(info, contentType) {
+		return nil, fmt.Errorf("content type %s is not valid for %s files", contentType, info.Name)
+	}
+
+	if err := format.Validate(filename, content); err != nil {
+		return nil, err
+	}
+
+	return format, nil
+}
+
+// acceptsMIMEType checks a client-supplied content type, which browsers often
+// leave empty or generic for mod files
+func acceptsMIMEType(info FormatInfo, contentType string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	if contentType == "" || contentType == "application/octet-stream" {
+		return true
+	}
+	for _, mime := range info.MIMETypes {
+		if mime == contentType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,283 @@
+package mods
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+func init() {
+	RegisterFormat(contentPatchFormat{}) // before jsonFormat, which accepts any JSON
+	RegisterFormat(jsonFormat{})
+	RegisterFormat(factorioFormat{}) // before archiveFormat, which accepts any zip
+	RegisterFormat(stardewFormat{})
+	RegisterFormat(bedrockFormat{})
+	RegisterFormat(archiveFormat{})
+	RegisterFormat(pluginFormat{})
+	RegisterFormat(nbtFormat{})
+	RegisterFormat(luaFormat{})
+	RegisterFormat(configFormat{ConfigTOML})
+	RegisterFormat(configFormat{ConfigProperties})
+	RegisterFormat(configFormat{ConfigCfg})
+	RegisterFormat(configFormat{ConfigYAML})
+}
+
+// jsonFormat handles standalone Minecraft JSON files such as recipes and pack.mcmeta
+type jsonFormat struct{}
+
+func (jsonFormat) Info() FormatInfo {
+	return FormatInfo{
+		Name:       "json",
+		GameTypes:  []GameType{GameTypeMinecraft},
+		Extensions: []string{".json", ".mcmeta"},
+		MIMETypes:  []string{"application/json", "text/json", "text/plain"},
+		MaxSize:    50 * 1024 * 1024,
+		Editable:   true,
+	}
+}
+
+func (jsonFormat) Detect(filename string, content []byte) bool {
+	return json.Valid(content)
+}
+
+func (jsonFormat) Validate(filename string, content []byte) error {
+	return validateJSON(content)
+}
+
+func (jsonFormat) ExtractMetadata(content []byte) (map[string]interface{}, error) {
+	if err := validateJSON(content); err != nil {
+		return nil, err
+	}
+	return extractMinecraftMetadata(content), nil
+}
+
+func (jsonFormat) Rewrite(original, edited []byte) ([]byte, error) {
+	edited = stripCodeFence(edited)
+	if err := validateJSON(edited); err != nil {
+		return nil, err
+	}
+
+	// The AI must not change the top-level shape of the document
+	if firstNonSpace(original) != firstNonSpace(edited) {
+		return nil, fmt.Errorf("edited JSON changed the top-level structure")
+	}
+	return edited, nil
+}
+
+// archiveFormat handles jar and zip archives
+type archiveFormat struct{}
+
+func (archiveFormat) Info() FormatInfo {
+	return FormatInfo{
+		Name:       "archive",
+		GameTypes:  []GameType{GameTypeMinecraft},
+		Extensions: []string{".jar", ".zip"},
+		MIMETypes:  []string{"application/java-archive", "application/x-java-archive", "application/zip", "application/x-zip-compressed"},
+		MaxSize:    100 * 1024 * 1024,
+		Container:  true,
+	}
+}
+
+func (archiveFormat) Detect(filename string, content []byte) bool {
+	return bytes.HasPrefix(content, []byte("PK\x03\x04"))
+}
+
+func (archiveFormat) Validate(filename string, content []byte) error {
+	if err := ScanArchive(content, DefaultArchiveLimits); err != nil {
+		return err
+	}
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return err
+	}
+	if _, err := ParseBukkitJar(archive); err != nil {
+		return fmt.Errorf("invalid plugin: %w", err)
+	}
+	return nil
+}
+
+func (archiveFormat) ExtractMetadata(content []byte) (map[string]interface{}, error) {
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := make(map[string]interface{})
+	var descriptors []string
+	for _, f := range archive.Files() {
+		if _, ok := minecraftDescriptors[f.Name]; ok {
+			descriptors = append(descriptors, f.Name)
+		}
+	}
+	metadata["format"] = "archive"
+	metadata["entries"] = len(archive.Files())
+	metadata["descriptors"] = descriptors
+	if mods, err := ParseDescriptors("", content); err == nil {
+		metadata["mods"] = mods
+	}
+	if classes, err := AnalyzeClasses(archive); err == nil && classes.Classes > 0 {
+		metadata["classes"] = classes
+	}
+	if mixins, err := AnalyzeMixins(archive); err == nil && len(mixins) > 0 {
+		metadata["mixins"] = mixins
+	}
+	if langs, err := ExtractLang(archive); err == nil && len(langs) > 0 {
+		metadata["lang_coverage"] = LangCoverage(langs)
+	}
+	if ecosystem := detectArchiveEcosystem(archive); ecosystem != nil {
+		metadata["ecosystem"] = ecosystem
+	}
+	if structures, err := ExtractStructures(archive); err == nil && len(structures) > 0 {
+		metadata["structures"] = structures
+	}
+	if plugin, err := ParseBukkitJar(archive); err == nil && plugin != nil {
+		metadata["bukkit_plugin"] = plugin
+	}
+	return metadata, nil
+}
+
+// Rewrite is not used for archives; their entries are edited through EditArchive
+func (archiveFormat) Rewrite(original, edited []byte) ([]byte, error) {
+	return nil, ErrRewriteUnsupported
+}
+
+// pluginFormat handles Bethesda ESP, ESM and ESL plugins
+type pluginFormat struct{}
+
+func (pluginFormat) Info() FormatInfo {
+	return FormatInfo{
+		Name:       "plugin",
+		GameTypes:  []GameType{GameTypeOblivion, GameTypeSkyrim, GameTypeSkyrimSE, GameTypeFallout4, GameTypeStarfield},
+		Extensions: []string{".esp", ".esm", ".esl"},
+		MIMETypes:  []string{"application/octet-stream"},
+		MaxSize:    100 * 1024 * 1024,
+	}
+}
+
+func (pluginFormat) Detect(filename string, content []byte) bool {
+	return bytes.HasPrefix(content, []byte("TES4"))
+}
+
+func (pluginFormat) Validate(filename string, content []byte) error {
+	header, err := ParsePluginHeader(content)
+	if err != nil {
+		return fmt.Errorf("invalid plugin: %w", err)
+	}
+	if err := ValidatePlugin(header, filename); err != nil {
+		return fmt.Errorf("invalid plugin: %w", err)
+	}
+	return nil
+}
+
+func (pluginFormat) ExtractMetadata(content []byte) (map[string]interface{}, error) {
+	if _, err := ParsePluginHeader(content); err != nil {
+		return nil, fmt.Errorf("invalid plugin: %w", err)
+	}
+	return extractPluginMetadata(content), nil
+}
+
+func (pluginFormat) Rewrite(original, edited []byte) ([]byte, error) {
+	return nil, ErrRewriteUnsupported
+}
+
+// luaFormat handles standalone Lua scripts
+type luaFormat struct{}
+
+func (luaFormat) Info() FormatInfo {
+	return FormatInfo{
+		Name:       "lua",
+		GameTypes:  []GameType{GameTypeLua},
+		Extensions: []string{".lua"},
+		MIMETypes:  []string{"text/x-lua", "application/x-lua", "text/plain"},
+		MaxSize:    10 * 1024 * 1024,
+		Editable:   true,
+	}
+}
+
+func (luaFormat) Detect(filename string, content []byte) bool {
+	return isText(content)
+}
+
+func (luaFormat) Validate(filename string, content []byte) error {
+	if !utf8.Valid(content) {
+		return fmt.Errorf("lua script is not valid UTF-8")
+	}
+	if _, err := ParseLua(content); err != nil {
+		return fmt.Errorf("invalid lua script: %w", err)
+	}
+	return nil
+}
+
+func (luaFormat) ExtractMetadata(content []byte) (map[string]interface{}, error) {
+	return extractLuaMetadata(content), nil
+}
+
+// Rewrite rejects edits that break the script or drop any of its functions
+func (f luaFormat) Rewrite(original, edited []byte) ([]byte, error) {
+	edited = stripCodeFence(edited)
+	if err := f.Validate("", edited); err != nil {
+		return nil, err
+	}
+	before, err := AnalyzeLua(original)
+	if err != nil {
+		return edited, nil // nothing to compare a broken original against
+	}
+	after, err := AnalyzeLua(edited)
+	if err != nil {
+		return nil, err
+	}
+	if dropped := DroppedLuaFunctions(before, after); len(dropped) > 0 {
+		return nil, fmt.Errorf("edited script drops functions: %s", strings.Join(dropped, ", "))
+	}
+	return edited, nil
+}
+
+// validateJSON reports JSON syntax errors with their line and column
+func validateJSON(content []byte) error {
+	var v interface{}
+	err := json.Unmarshal(content, &v)
+	if err == nil {
+		return nil
+	}
+	if syntaxErr, ok := err.(*json.SyntaxError); ok {
+		line, col := lineAndColumn(content, int(syntaxErr.Offset))
+		return fmt.Errorf("invalid JSON at line %d, column %d: %v", line, col, syntaxErr)
+	}
+	return fmt.Errorf("invalid JSON: %w", err)
+}
+
+// lineAndColumn converts a byte offset into a 1-based line and column
+func lineAndColumn(content []byte, offset int) (int, int) {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	before := content[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := offset - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// stripCodeFence removes a markdown code fence the AI may wrap its output in
+func stripCodeFence(content []byte) []byte {
+	trimmed := strings.TrimSpace(string(content))
+	if !strings.HasPrefix(trimmed, "```") {
+		return content
+	}
+	trimmed = strings.TrimPrefix(trimmed, "```")
+	if i := strings.IndexByte(trimmed, '\n'); i >= 0 {
+		trimmed = trimmed[i+1:] // drop the language tag
+	}
+	trimmed = strings.TrimSuffix(strings.TrimSpace(trimmed), "```")
+	return []byte(strings.TrimSpace(trimmed) + "\n")
+}
+
+// firstNonSpace returns the first non-whitespace byte of content
+func firstNonSpace(content []byte) byte {
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) == 0 {
+		return 0
+	}
+	return trimmed[0]
+}
@@ -0,0 +1,414 @@
+package mods
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SourceLocale is the locale every other translation is measured against
+const SourceLocale = "en_us"
+
+// langBatchSize is the number of strings sent to the translator at once
+const langBatchSize = 40
+
+// maxReportedMissingKeys bounds the missing keys listed per locale
+const maxReportedMissingKeys = 100
+
+var (
+	langPath       = regexp.MustCompile(`^assets/([^/]+)/lang/([^/]+)\.(json|lang)$`)
+	localeCode     = regexp.MustCompile(`^[a-z]{2,4}(_[a-z]{2,4})?$`)
+	langFormatArg  = regexp.MustCompile(`%(?:\d+\$)?[-#+ 0,(]*\d*(?:\.\d+)?[a-zA-Z%]`)
+	langFormatCode = regexp.MustCompile(`§[0-9a-fk-orA-FK-OR]`)
+)
+
+// LangFile is a Minecraft language file: assets/<namespace>/lang/<locale>.json,
+// or a .lang file of key=value lines before 1.13
+type LangFile struct {
+	Path      string            `json:"path"`
+	Namespace string            `json:"namespace"`
+	Locale    string            `json:"locale"` // lowercased, as 1.13+ names it
+	Legacy    bool              `json:"legacy"`
+	Keys      []string          `json:"-"` // in file order
+	Entries   map[string]string `json:"-"`
+	raw       []byte
+}
+
+// IsLangPath reports whether an archive entry is a language file
+func IsLangPath(name string) bool {
+	return langPath.MatchString(name)
+}
+
+// ValidLocale reports whether a locale code has Minecraft's shape, such as de_de
+func ValidLocale(locale string) bool {
+	return localeCode.MatchString(locale)
+}
+
+// ParseLang reads a language file named by its archive path
+func ParseLang(name string, data []byte) (*LangFile, error) {
+	m := langPath.FindStringSubmatch(name)
+	if m == nil {
+		return nil, fmt.Errorf("%s is not a language file", name)
+	}
+	lang := &LangFile{
+		Path:      name,
+		Namespace: m[1],
+		Locale:    strings.ToLower(m[2]),
+		Legacy:    m[3] == "lang",
+		Entries:   make(map[string]string),
+		raw:       data,
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	if lang.Legacy {
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimRight(line, "\r")
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			lang.add(key, value)
+		}
+		return lang, nil
+	}
+
+	doc, err := decodeOrdered(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid language file %s: %w", name, err)
+	}
+	object, ok := doc.(*orderedObject)
+	if !ok {
+		return nil, fmt.Errorf("language file %s is not a JSON object", name)
+	}
+	for _, key := range object.keys {
+		value, ok := object.values[key].(string)
+		if !ok {
+			return nil, fmt.Errorf("language file %s: value of %q is not a string", name, key)
+		}
+		lang.add(key, value)
+	}
+	return lang, nil
+}
+
+func (l *LangFile) add(key, value string) {
+	if _, ok := l.Entries[key]; !ok {
+		l.Keys = append(l.Keys, key)
+	}
+	l.Entries[key] = value
+}
+
+// Encode writes the language file. Legacy files keep their original lines,
+// including comments, with new keys appended; JSON files keep their key
+// order and indentation.
+func (l *LangFile) Encode() ([]byte, error) {
+	if l.Legacy {
+		var buf bytes.Buffer
+		buf.Write(l.raw)
+		if buf.Len() > 0 && !bytes.HasSuffix(l.raw, []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+		existing, _ := ParseLang(l.Path, l.raw)
+		for _, key := range l.Keys {
+			if existing != nil {
+				if _, ok := existing.Entries[key]; ok {
+					continue
+				}
+			}
+			fmt.Fprintf(&buf, "%s=%s\n", key, l.Entries[key])
+		}
+		return buf.Bytes(), nil
+	}
+
+	object := &orderedObject{values: make(map[string]interface{}, len(l.Keys))}
+	for _, key := range l.Keys {
+		object.set(key, l.Entries[key])
+	}
+	var compact, out bytes.Buffer
+	if err := encodeOrdered(&compact, object); err != nil {
+		return nil, err
+	}
+	indent := "  "
+	if len(l.raw) > 0 {
+		indent = jsonIndent(l.raw)
+	}
+	if indent == "" {
+		return compact.Bytes(), nil
+	}
+	if err := json.Indent(&out, compact.Bytes(), "", indent); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// ExtractLang reads every language file in an archive
+func ExtractLang(archive *Archive) ([]*LangFile, error) {
+	var files []*LangFile
+	for _, f := range archive.Files() {
+		if !IsLangPath(f.Name) {
+			continue
+		}
+		data, err := archive.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		lang, err := ParseLang(f.Name, data)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, lang)
+	}
+	return files, nil
+}
+
+// LocaleCoverage is how much of a namespace's en_us keys a locale translates
+type LocaleCoverage struct {
+	Namespace  string   `json:"namespace"`
+	Locale     string   `json:"locale"`
+	Total      int      `json:"total"`
+	Translated int      `json:"translated"`
+	Percent    float64  `json:"percent"`
+	Missing    []string `json:"missing,omitempty"` // the first maxReportedMissingKeys
+	Extra      int      `json:"extra,omitempty"`   // keys en_us does not have
+}
+
+// LangCoverage reports key coverage for every locale of every namespace
+// that has an en_us file
+func LangCoverage(files []*LangFile) []LocaleCoverage {
+	sources := langSources(files)
+	var coverage []LocaleCoverage
+	for _, lang := range files {
+		source, ok := sources[lang.Namespace]
+		if !ok || lang == source {
+			continue
+		}
+		c := LocaleCoverage{Namespace: lang.Namespace, Locale: lang.Locale, Total: len(source.Keys)}
+		for _, key := range source.Keys {
+			if _, ok := lang.Entries[key]; ok {
+				c.Translated++
+			} else if len(c.Missing) < maxReportedMissingKeys {
+				c.Missing = append(c.Missing, key)
+			}
+		}
+		for key := range lang.Entries {
+			if _, ok := source.Entries[key]; !ok {
+				c.Extra++
+			}
+		}
+		c.Percent = 100
+		if c.Total > 0 {
+			c.Percent = float64(c.Translated*1000/c.Total) / 10
+		}
+		coverage = append(coverage, c)
+	}
+	sort.Slice(coverage, func(i, j int) bool {
+		if coverage[i].Namespace != coverage[j].Namespace {
+			return coverage[i].Namespace < coverage[j].Namespace
+		}
+		return coverage[i].Locale < coverage[j].Locale
+	})
+	return coverage
+}
+
+// langSources maps each namespace to its en_us file
+func langSources(files []*LangFile) map[string]*LangFile {
+	sources := make(map[string]*LangFile)
+	for _, lang := range files {
+		if lang.Locale == SourceLocale {
+			sources[lang.Namespace] = lang
+		}
+	}
+	return sources
+}
+
+// CheckTranslation verifies that a translation keeps the format arguments
+// (%s, %1$s, %d, ...) and § formatting codes of the source string
+func CheckTranslation(source, translated string) error {
+	if translated == "" && source != "" {
+		return fmt.Errorf("translation is empty")
+	}
+	if want, got := sortedMatches(langFormatArg, source), sortedMatches(langFormatArg, translated); want != got {
+		return fmt.Errorf("format arguments changed from [%s] to [%s]", want, got)
+	}
+	if want, got := sortedMatches(langFormatCode, strings.ToLower(source)), sortedMatches(langFormatCode, strings.ToLower(translated)); want != got {
+		return fmt.Errorf("formatting codes changed from [%s] to [%s]", want, got)
+	}
+	return nil
+}
+
+// sortedMatches lists a pattern's matches in sorted order, since translations
+// may reorder positional arguments
+func sortedMatches(pattern *regexp.Regexp, s string) string {
+	matches := pattern.FindAllString(s, -1)
+	sort.Strings(matches)
+	return strings.Join(matches, " ")
+}
+
+// TranslateFunc translates a batch of source strings by key into a locale
+type TranslateFunc func(locale string, batch map[string]string) (map[string]string, error)
+
+// LangIssue is a translated string that was rejected
+type LangIssue struct {
+	Locale string `json:"locale"`
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+}
+
+// TranslationReport summarizes a translation run
+type TranslationReport struct {
+	Files      []string    `json:"files"`
+	Translated int         `json:"translated"`
+	Issues     []LangIssue `json:"issues,omitempty"`
+}
+
+// TranslateLang fills the keys of source that target lacks, translating them
+// in batches. target may be nil to create the locale. Translations that fail
+// CheckTranslation are left out and reported. It returns the completed file
+// and the number of strings added.
+func TranslateLang(source, target *LangFile, locale string, translate TranslateFunc) (*LangFile, int, []LangIssue, error) {
+	if target == nil {
+		name := locale
+		if source.Legacy {
+			// Legacy files name the region in upper case, such as de_DE.lang
+			if lang, region, ok := strings.Cut(locale, "_"); ok {
+				name = lang + "_" + strings.ToUpper(region)
+			}
+		}
+		target = &LangFile{
+			Path:      path.Join(path.Dir(source.Path), name+path.Ext(source.Path)),
+			Namespace: source.Namespace,
+			Locale:    locale,
+			Legacy:    source.Legacy,
+			Entries:   make(map[string]string),
+		}
+	}
+
+	var missing []string
+	for _, key := range source.Keys {
+		if _, ok := target.Entries[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	translated, issues, err := translateBatches(locale, missing, source.Entries, translate, func(source, translated string) error {
+		if strings.ContainsAny(translated, "\r\n") && target.Legacy {
+			return fmt.Errorf("legacy lang values cannot span lines")
+		}
+		return CheckTranslation(source, translated)
+	})
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("%s %s: %w", source.Namespace, locale, err)
+	}
+	for _, key := range missing {
+		if value, ok := translated[key]; ok {
+			target.add(key, value)
+		}
+	}
+	return target, len(translated), issues, nil
+}
+
+// translateBatches translates the given keys of source into a locale in
+// batches, returning the translations check accepts and the rejected ones
+func translateBatches(locale string, keys []string, source map[string]string, translate TranslateFunc, check func(source, translated string) error) (map[string]string, []LangIssue, error) {
+	accepted := make(map[string]string)
+	var issues []LangIssue
+	for start := 0; start < len(keys); start += langBatchSize {
+		batchKeys := keys[start:min(start+langBatchSize, len(keys))]
+		batch := make(map[string]string, len(batchKeys))
+		for _, key := range batchKeys {
+			batch[key] = source[key]
+		}
+		translated, err := translate(locale, batch)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, key := range batchKeys {
+			value, ok := translated[key]
+			if !ok {
+				issues = append(issues, LangIssue{Locale: locale, Key: key, Reason: "translation missing from batch"})
+				continue
+			}
+			if err := check(source[key], value); err != nil {
+				issues = append(issues, LangIssue{Locale: locale, Key: key, Reason: err.Error()})
+				continue
+			}
+			accepted[key] = value
+		}
+	}
+	return accepted, issues, nil
+}
+
+// TranslateArchive creates or completes the given locales for every namespace
+// with an en_us file and writes the language files back into the archive.
+// Nothing outside assets/*/lang is changed.
+func TranslateArchive(content []byte, locales []string, translate TranslateFunc) ([]byte, *TranslationReport, error) {
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return nil, nil, err
+	}
+	files, err := ExtractLang(archive)
+	if err != nil {
+		return nil, nil, err
+	}
+	sources := langSources(files)
+	if len(sources) == 0 {
+		return nil, nil, fmt.Errorf("archive has no %s language files to translate from", SourceLocale)
+	}
+
+	existing := make(map[string]*LangFile)
+	for _, lang := range files {
+		existing[lang.Namespace+"/"+lang.Locale] = lang
+	}
+
+	report := &TranslationReport{Files: []string{}}
+	changes := make(map[string][]byte)
+	for _, namespace := range sortedKeys(sources) {
+		source := sources[namespace]
+		for _, locale := range locales {
+			if !ValidLocale(locale) || locale == SourceLocale {
+				return nil, nil, fmt.Errorf("invalid target locale %q", locale)
+			}
+			target, added, issues, err := TranslateLang(source, existing[namespace+"/"+locale], locale, translate)
+			if err != nil {
+				return nil, nil, err
+			}
+			report.Issues = append(report.Issues, issues...)
+			if added == 0 {
+				continue
+			}
+			data, err := target.Encode()
+			if err != nil {
+				return nil, nil, err
+			}
+			changes[target.Path] = data
+			report.Files = append(report.Files, target.Path)
+			report.Translated += added
+		}
+	}
+
+	if len(changes) == 0 {
+		return content, report, nil
+	}
+	output, err := RewriteArchive(content, changes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return output, report, nil
+}
+
+// DecodeLangBatch reads a translated batch returned as a JSON object of
+// strings, tolerating a surrounding code fence
+func DecodeLangBatch(data []byte) (map[string]string, error) {
+	var batch map[string]string
+	if err := json.Unmarshal(stripCodeFence(data), &batch); err != nil {
+		return nil, fmt.Errorf("translation is not a JSON object of strings: %w", err)
+	}
+	return batch, nil
+}
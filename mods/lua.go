@@ -0,0 +1,456 @@
+package mods
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/yuin/gopher-lua/ast"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// luaStandardGlobals are the globals of the Lua standard library, which say
+// nothing about the game a script is written for
+var luaStandardGlobals = map[string]bool{
+	"_G": true, "_VERSION": true, "assert": true, "collectgarbage": true, "dofile": true, "error": true,
+	"getfenv": true, "getmetatable": true, "ipairs": true, "load": true, "loadfile": true, "loadstring": true,
+	"module": true, "next": true, "pairs": true, "pcall": true, "print": true, "rawequal": true, "rawget": true,
+	"rawlen": true, "rawset": true, "require": true, "select": true, "setfenv": true, "setmetatable": true,
+	"tonumber": true, "tostring": true, "type": true, "unpack": true, "xpcall": true, "self": true,
+	"bit32": true, "coroutine": true, "debug": true, "io": true, "math": true, "os": true, "package": true,
+	"string": true, "table": true, "utf8": true,
+}
+
+// LuaSyntaxError is a syntax error in a Lua script
+type LuaSyntaxError struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Near    string `json:"near,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *LuaSyntaxError) Error() string {
+	if e.Line <= 0 {
+		return fmt.Sprintf("syntax error at end of file: %s", e.Message)
+	}
+	if e.Near != "" {
+		return fmt.Sprintf("syntax error at line %d, column %d near '%s': %s", e.Line, e.Column, e.Near, e.Message)
+	}
+	return fmt.Sprintf("syntax error at line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// LuaScript is what static analysis finds in a Lua script
+type LuaScript struct {
+	Lines     int      `json:"lines"`
+	Globals   []string `json:"globals"`        // globals the script assigns
+	Functions []string `json:"functions"`      // named functions, as foo, M.foo or M:foo
+	Requires  []string `json:"requires"`       // modules loaded with require
+	APIs      []string `json:"apis"`           // globals used but not defined, such as data.extend or hook.Add
+	Luau      bool     `json:"luau,omitempty"` // only parses as Roblox's Luau
+}
+
+// ParseLua parses a Lua 5.1 script, also accepting goto and the C-style
+// operators and comments of Garry's Mod. A script that only parses as
+// Roblox's Luau is parsed without its Luau syntax, which is fit for analysis
+// but not for running.
+func ParseLua(content []byte) ([]ast.Stmt, error) {
+	chunk, _, err := parseLua(content, true)
+	return chunk, err
+}
+
+// parseLua parses a script, trying Luau when allowLuau is set and the script
+// is not plain Lua, and reports whether it parsed as Luau. Syntax errors are
+// those of plain Lua.
+func parseLua(content []byte, allowLuau bool) ([]ast.Stmt, bool, error) {
+	chunk, err := parse.Parse(bytes.NewReader(normalizeLuaDialect(content)), "script")
+	if err == nil {
+		return chunk, false, nil
+	}
+	if allowLuau {
+		if chunk, luauErr := parse.Parse(bytes.NewReader(normalizeLuau(content)), "script"); luauErr == nil {
+			return chunk, true, nil
+		}
+	}
+	var parseErr *parse.Error
+	if errors.As(err, &parseErr) {
+		return nil, false, &LuaSyntaxError{
+			Line:    parseErr.Pos.Line,
+			Column:  parseErr.Pos.Column,
+			Near:    parseErr.Token,
+			Message: parseErr.Message,
+		}
+	}
+	return nil, false, &LuaSyntaxError{Message: strings.TrimSpace(err.Error())}
+}
+
+// normalizeLuaDialect rewrites the Garry's Mod operators != && || ! and its
+// // and /* */ comments to standard Lua, leaving strings and comments alone
+// and keeping line numbers
+func normalizeLuaDialect(content []byte) []byte {
+	var out bytes.Buffer
+	out.Grow(len(content))
+	for i := 0; i < len(content); {
+		c := content[i]
+		next := byte(0)
+		if i+1 < len(content) {
+			next = content[i+1]
+		}
+		switch {
+		case c == '-' && next == '-':
+			end := luaCommentEnd(content, i)
+			out.Write(content[i:end])
+			i = end
+		case c == '[' && (next == '[' || next == '='):
+			end := luaLongBracketEnd(content, i)
+			out.Write(content[i:end])
+			i = end
+		case c == '"' || c == '\'':
+			end := luaStringEnd(content, i)
+			out.Write(content[i:end])
+			i = end
+		case c == '!' && next == '=':
+			out.WriteString("~=")
+			i += 2
+		case c == '!':
+			out.WriteString(" not ")
+			i++
+		case c == '&' && next == '&':
+			out.WriteString(" and ")
+			i += 2
+		case c == '|' && next == '|':
+			out.WriteString(" or ")
+			i += 2
+		case c == '/' && next == '/':
+			out.WriteString("--")
+			i += 2
+		case c == '/' && next == '*':
+			end := bytes.Index(content[i+2:], []byte("*/"))
+			if end < 0 {
+				out.Write(content[i:])
+				return out.Bytes()
+			}
+			// A block comment becomes one line comment per line
+			body := content[i+2 : i+2+end]
+			out.WriteString("--")
+			out.Write(bytes.ReplaceAll(body, []byte("\n"), []byte("\n--")))
+			out.WriteByte('\n')
+			i += end + 4
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes()
+}
+
+// luaCommentEnd returns the end of the comment starting at i
+func luaCommentEnd(content []byte, i int) int {
+	if i+2 < len(content) && content[i+2] == '[' {
+		if end := luaLongBracketEnd(content, i+2); end > i+3 {
+			return end
+		}
+	}
+	if end := bytes.IndexByte(content[i:], '\n'); end >= 0 {
+		return i + end
+	}
+	return len(content)
+}
+
+// luaStringEnd returns the end of the quoted string starting at i, or of its
+// line when it is not closed
+func luaStringEnd(content []byte, i int) int {
+	quote := content[i]
+	end := i + 1
+	for end < len(content) && content[end] != quote && content[end] != '\n' {
+		if content[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	return min(end+1, len(content))
+}
+
+// luaLongBracketEnd returns the end of the [[ ]] or [==[ ]==] string
+// starting at i, or i+1 when content[i] does not open one
+func luaLongBracketEnd(content []byte, i int) int {
+	level := 0
+	for i+1+level < len(content) && content[i+1+level] == '=' {
+		level++
+	}
+	if i+1+level >= len(content) || content[i+1+level] != '[' {
+		return i + 1
+	}
+	closing := "]" + strings.Repeat("=", level) + "]"
+	end := bytes.Index(content[i+2+level:], []byte(closing))
+	if end < 0 {
+		return len(content)
+	}
+	return i + 2 + level + end + len(closing)
+}
+
+// AnalyzeLua parses a Lua script and lists what it defines and uses
+func AnalyzeLua(content []byte) (*LuaScript, error) {
+	chunk, luau, err := parseLua(content, true)
+	if err != nil {
+		return nil, err
+	}
+	a := &luaAnalyzer{
+		globals:   make(map[string]bool),
+		functions: make(map[string]bool),
+		requires:  make(map[string]bool),
+		reads:     make(map[string]bool),
+	}
+	a.block(chunk)
+
+	script := &LuaScript{
+		Lines:     bytes.Count(content, []byte("\n")) + 1,
+		Globals:   sortedKeys(a.globals),
+		Functions: sortedKeys(a.functions),
+		Requires:  sortedKeys(a.requires),
+		APIs:      []string{},
+		Luau:      luau,
+	}
+	for _, api := range sortedKeys(a.reads) {
+		root, _, _ := strings.Cut(strings.Replace(api, ":", ".", 1), ".")
+		if !a.globals[root] && !luaStandardGlobals[root] {
+			script.APIs = append(script.APIs, api)
+		}
+	}
+	return script, nil
+}
+
+// DroppedLuaFunctions lists the named functions of a script that an edited
+// version of it no longer defines
+func DroppedLuaFunctions(original, edited *LuaScript) []string {
+	kept := make(map[string]bool, len(edited.Functions))
+	for _, name := range edited.Functions {
+		kept[name] = true
+	}
+	var dropped []string
+	for _, name := range original.Functions {
+		if !kept[name] {
+			dropped = append(dropped, name)
+		}
+	}
+	return dropped
+}
+
+// luaAnalyzer walks a chunk tracking which names are local in scope
+type luaAnalyzer struct {
+	scopes    []map[string]bool
+	globals   map[string]bool
+	functions map[string]bool
+	requires  map[string]bool
+	reads     map[string]bool // global roots read, with one constant key when indexed
+}
+
+func (a *luaAnalyzer) push(names ...string) {
+	scope := make(map[string]bool, len(names))
+	for _, name := range names {
+		scope[name] = true
+	}
+	a.scopes = append(a.scopes, scope)
+}
+
+func (a *luaAnalyzer) pop() { a.scopes = a.scopes[:len(a.scopes)-1] }
+
+func (a *luaAnalyzer) declare(names ...string) {
+	if len(a.scopes) == 0 {
+		a.push()
+	}
+	for _, name := range names {
+		a.scopes[len(a.scopes)-1][name] = true
+	}
+}
+
+func (a *luaAnalyzer) isLocal(name string) bool {
+	for i := len(a.scopes) - 1; i >= 0; i-- {
+		if a.scopes[i][name] {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *luaAnalyzer) block(stmts []ast.Stmt) {
+	a.push()
+	a.stmts(stmts)
+	a.pop()
+}
+
+func (a *luaAnalyzer) stmts(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		a.stmt(stmt)
+	}
+}
+
+func (a *luaAnalyzer) stmt(stmt ast.Stmt) {
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		a.exprs(s.Rhs)
+		for i, lhs := range s.Lhs {
+			if ident, ok := lhs.(*ast.IdentExpr); ok {
+				if !a.isLocal(ident.Value) {
+					a.globals[ident.Value] = true
+				}
+			} else {
+				a.expr(lhs)
+			}
+			if i < len(s.Rhs) {
+				if _, ok := s.Rhs[i].(*ast.FunctionExpr); ok {
+					if name := luaName(lhs); name != "" {
+						a.functions[name] = true
+					}
+				}
+			}
+		}
+	case *ast.LocalAssignStmt:
+		// local function f can call itself
+		if len(s.Exprs) == 1 && len(s.Names) == 1 {
+			if _, ok := s.Exprs[0].(*ast.FunctionExpr); ok {
+				a.declare(s.Names[0])
+				a.functions[s.Names[0]] = true
+			}
+		}
+		a.exprs(s.Exprs)
+		a.declare(s.Names...)
+	case *ast.FuncCallStmt:
+		a.expr(s.Expr)
+	case *ast.DoBlockStmt:
+		a.block(s.Stmts)
+	case *ast.WhileStmt:
+		a.expr(s.Condition)
+		a.block(s.Stmts)
+	case *ast.RepeatStmt:
+		// The condition sees the body's locals
+		a.push()
+		a.stmts(s.Stmts)
+		a.expr(s.Condition)
+		a.pop()
+	case *ast.IfStmt:
+		a.expr(s.Condition)
+		a.block(s.Then)
+		a.block(s.Else)
+	case *ast.NumberForStmt:
+		a.exprs([]ast.Expr{s.Init, s.Limit, s.Step})
+		a.push(s.Name)
+		a.stmts(s.Stmts)
+		a.pop()
+	case *ast.GenericForStmt:
+		a.exprs(s.Exprs)
+		a.push(s.Names...)
+		a.stmts(s.Stmts)
+		a.pop()
+	case *ast.FuncDefStmt:
+		var name string
+		if s.Name.Receiver != nil {
+			a.expr(s.Name.Receiver)
+			name = luaName(s.Name.Receiver) + ":" + s.Name.Method
+		} else {
+			if ident, ok := s.Name.Func.(*ast.IdentExpr); ok {
+				if !a.isLocal(ident.Value) {
+					a.globals[ident.Value] = true
+				}
+			} else {
+				a.expr(s.Name.Func)
+			}
+			name = luaName(s.Name.Func)
+		}
+		if name != "" && !strings.HasPrefix(name, ":") {
+			a.functions[name] = true
+		}
+		if s.Name.Receiver != nil {
+			a.function(s.Func, "self")
+		} else {
+			a.function(s.Func)
+		}
+	case *ast.ReturnStmt:
+		a.exprs(s.Exprs)
+	}
+}
+
+func (a *luaAnalyzer) function(fn *ast.FunctionExpr, implicit ...string) {
+	a.push(append(implicit, fn.ParList.Names...)...)
+	a.stmts(fn.Stmts)
+	a.pop()
+}
+
+func (a *luaAnalyzer) exprs(exprs []ast.Expr) {
+	for _, e := range exprs {
+		a.expr(e)
+	}
+}
+
+func (a *luaAnalyzer) expr(expr ast.Expr) {
+	switch e := expr.(type) {
+	case nil:
+	case *ast.IdentExpr:
+		if !a.isLocal(e.Value) {
+			a.reads[e.Value] = true
+		}
+	case *ast.AttrGetExpr:
+		if ident, ok := e.Object.(*ast.IdentExpr); ok && !a.isLocal(ident.Value) {
+			if key, ok := e.Key.(*ast.StringExpr); ok {
+				a.reads[ident.Value+"."+key.Value] = true
+			} else {
+				a.reads[ident.Value] = true
+				a.expr(e.Key)
+			}
+			return
+		}
+		a.expr(e.Object)
+		a.expr(e.Key)
+	case *ast.FuncCallExpr:
+		if ident, ok := e.Func.(*ast.IdentExpr); ok && ident.Value == "require" && !a.isLocal("require") && len(e.Args) > 0 {
+			if module, ok := e.Args[0].(*ast.StringExpr); ok {
+				a.requires[module.Value] = true
+			}
+		}
+		if ident, ok := e.Receiver.(*ast.IdentExpr); ok && !a.isLocal(ident.Value) {
+			a.reads[ident.Value+":"+e.Method] = true
+		} else {
+			a.expr(e.Receiver)
+		}
+		a.expr(e.Func)
+		a.exprs(e.Args)
+	case *ast.TableExpr:
+		for _, field := range e.Fields {
+			a.expr(field.Key)
+			a.expr(field.Value)
+		}
+	case *ast.LogicalOpExpr:
+		a.exprs([]ast.Expr{e.Lhs, e.Rhs})
+	case *ast.RelationalOpExpr:
+		a.exprs([]ast.Expr{e.Lhs, e.Rhs})
+	case *ast.StringConcatOpExpr:
+		a.exprs([]ast.Expr{e.Lhs, e.Rhs})
+	case *ast.ArithmeticOpExpr:
+		a.exprs([]ast.Expr{e.Lhs, e.Rhs})
+	case *ast.UnaryMinusOpExpr:
+		a.expr(e.Expr)
+	case *ast.UnaryNotOpExpr:
+		a.expr(e.Expr)
+	case *ast.UnaryLenOpExpr:
+		a.expr(e.Expr)
+	case *ast.FunctionExpr:
+		a.function(e)
+	}
+}
+
+// luaName renders a name or a chain of constant field accesses, such as
+// M.util.clamp, or returns "" for anything else
+func luaName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.IdentExpr:
+		return e.Value
+	case *ast.AttrGetExpr:
+		key, ok := e.Key.(*ast.StringExpr)
+		object := luaName(e.Object)
+		if !ok || object == "" {
+			return ""
+		}
+		return object + "." + key.Value
+	}
+	return ""
+}
@@ -0,0 +1,336 @@
+package mods
+
+import "bytes"
+
+// normalizeLuau blanks out what Luau, the Lua dialect of Roblox, adds to Lua
+// 5.1: type annotations, type declarations, casts and generics, the operator
+// of compound assignments and floor division, continue and interpolated
+// strings. Lines and columns are kept. a += b becomes a = b, so the result is
+// only fit for syntax checks and analysis, never for running.
+func normalizeLuau(content []byte) []byte {
+	s := &luauScanner{src: content, out: bytes.Clone(content)}
+	s.run()
+	return s.out
+}
+
+// luauScanner reads src and blanks Luau-only syntax in out, which has the
+// same length
+type luauScanner struct {
+	src, out []byte
+}
+
+func (s *luauScanner) at(i int) byte {
+	if i >= 0 && i < len(s.src) {
+		return s.src[i]
+	}
+	return 0
+}
+
+func (s *luauScanner) hasPrefix(i int, prefix string) bool {
+	return i >= 0 && i <= len(s.src) && bytes.HasPrefix(s.src[i:], []byte(prefix))
+}
+
+// blank replaces src[from:to] with spaces, keeping line breaks
+func (s *luauScanner) blank(from, to int) {
+	for i := from; i < to && i < len(s.out); i++ {
+		if s.out[i] != '\n' && s.out[i] != '\r' {
+			s.out[i] = ' '
+		}
+	}
+}
+
+func (s *luauScanner) run() {
+	for i := 0; i < len(s.src); {
+		c, next := s.at(i), s.at(i+1)
+		switch {
+		case c == '-' && next == '-':
+			i = luaCommentEnd(s.src, i)
+		case c == '[' && (next == '[' || next == '='):
+			i = luaLongBracketEnd(s.src, i)
+		case c == '"' || c == '\'':
+			i = luaStringEnd(s.src, i)
+		case c == '`':
+			// An interpolated string becomes an empty one
+			end := luaStringEnd(s.src, i)
+			if end-i >= 2 {
+				s.blank(i, end)
+				s.out[i], s.out[i+1] = '"', '"'
+			}
+			i = end
+		case isLuaIdentStart(c):
+			i = s.word(i)
+		case c >= '0' && c <= '9':
+			// Skip the whole number so 0x1F is not read as a name
+			i = luaIdentEnd(s.src, i)
+		case c == ':' && next == ':':
+			i = s.cast(i)
+		case c == '/' && next == '/':
+			// Floor division a // b and a //= b become a / b and a = b
+			if s.at(i+2) == '=' {
+				s.blank(i, i+2)
+				i += 3
+			} else {
+				s.blank(i, i+1)
+				i += 2
+			}
+		case s.hasPrefix(i, "..="):
+			s.blank(i, i+2)
+			i += 3
+		case bytes.IndexByte([]byte("+-*/%^"), c) >= 0 && next == '=':
+			s.blank(i, i+1)
+			i += 2
+		default:
+			i++
+		}
+	}
+}
+
+// word handles the name or keyword starting at i and returns where scanning
+// continues
+func (s *luauScanner) word(i int) int {
+	end := luaIdentEnd(s.src, i)
+	if prev := s.previous(i); prev == '.' || prev == ':' {
+		return end // a field or method name
+	}
+	switch string(s.src[i:end]) {
+	case "local", "for":
+		return s.names(end)
+	case "function":
+		return s.function(end)
+	case "type":
+		if declEnd, ok := s.typeDeclaration(end); ok {
+			s.blank(i, declEnd)
+			return declEnd
+		}
+	case "export":
+		j := s.skipSpace(end)
+		if s.hasPrefix(j, "type") && !isLuaIdentChar(s.at(j+4)) {
+			if declEnd, ok := s.typeDeclaration(j + 4); ok {
+				s.blank(i, declEnd)
+				return declEnd
+			}
+		}
+	case "continue":
+		// continue is only a keyword when the next statement, end or ; follows
+		if c := s.at(s.skipSpace(end)); c == 0 || c == ';' || isLuaIdentStart(c) {
+			s.blank(i, end)
+			copy(s.out[i:], "do end")
+		}
+	}
+	return end
+}
+
+// previous returns the last character before i that is not white space
+func (s *luauScanner) previous(i int) byte {
+	for i--; i >= 0; i-- {
+		if c := s.src[i]; c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			return c
+		}
+	}
+	return 0
+}
+
+// skipSpace returns the first position from i that is not white space or a
+// comment
+func (s *luauScanner) skipSpace(i int) int {
+	for i < len(s.src) {
+		switch c := s.src[i]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '-' && s.at(i+1) == '-':
+			i = luaCommentEnd(s.src, i)
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// names strips the annotations of the names a local or for declares
+func (s *luauScanner) names(i int) int {
+	for {
+		j := s.skipSpace(i)
+		if !isLuaIdentStart(s.at(j)) {
+			return j
+		}
+		end := luaIdentEnd(s.src, j)
+		if string(s.src[j:end]) == "function" {
+			return j
+		}
+		k := s.skipSpace(end)
+		if s.at(k) == ':' && s.at(k+1) != ':' {
+			typeEnd := s.skipType(k + 1)
+			s.blank(k, typeEnd)
+			k = s.skipSpace(typeEnd)
+		}
+		if s.at(k) != ',' {
+			return k
+		}
+		i = k + 1
+	}
+}
+
+// function strips the generics, parameter types and return type of the
+// function whose keyword ends at i
+func (s *luauScanner) function(i int) int {
+	j := s.skipSpace(i)
+	for isLuaIdentChar(s.at(j)) || s.at(j) == '.' || (s.at(j) == ':' && s.at(j+1) != ':') {
+		j++
+	}
+	j = s.skipSpace(j)
+	if s.at(j) == '<' {
+		end := s.balancedEnd(j)
+		s.blank(j, end)
+		j = s.skipSpace(end)
+	}
+	if s.at(j) != '(' {
+		return j
+	}
+	for j++; ; j++ {
+		j = s.skipSpace(j)
+		switch {
+		case s.hasPrefix(j, "..."):
+			j += 3
+		case isLuaIdentStart(s.at(j)):
+			j = luaIdentEnd(s.src, j)
+		}
+		j = s.skipSpace(j)
+		if s.at(j) == ':' {
+			end := s.skipType(j + 1)
+			s.blank(j, end)
+			j = s.skipSpace(end)
+		}
+		if s.at(j) != ',' {
+			break
+		}
+	}
+	if s.at(j) != ')' {
+		return j
+	}
+	j++
+	if k := s.skipSpace(j); s.at(k) == ':' && s.at(k+1) != ':' {
+		end := s.skipType(k + 1)
+		s.blank(k, end)
+		return end
+	}
+	return j
+}
+
+// typeDeclaration returns the end of "Name<T> = Type" after the type keyword
+// ending at i, and false when type is used as a name instead
+func (s *luauScanner) typeDeclaration(i int) (int, bool) {
+	j := s.skipSpace(i)
+	if j == i || !isLuaIdentStart(s.at(j)) {
+		return 0, false
+	}
+	j = s.skipSpace(luaIdentEnd(s.src, j))
+	if s.at(j) == '<' {
+		j = s.skipSpace(s.balancedEnd(j))
+	}
+	if s.at(j) != '=' || s.at(j+1) == '=' {
+		return 0, false
+	}
+	return s.skipType(j + 1), true
+}
+
+// cast strips "expr :: Type" and skips ::label::
+func (s *luauScanner) cast(i int) int {
+	j := s.skipSpace(i + 2)
+	if isLuaIdentStart(s.at(j)) {
+		if k := s.skipSpace(luaIdentEnd(s.src, j)); s.hasPrefix(k, "::") {
+			return k + 2
+		}
+	}
+	end := s.skipType(i + 2)
+	s.blank(i, end)
+	return end
+}
+
+// skipType returns the end of the type starting at i: simple types joined
+// by | and &, optional with ?, or a function type with ->
+func (s *luauScanner) skipType(i int) int {
+	i = s.skipSimpleType(i)
+	for {
+		j := s.skipSpace(i)
+		switch {
+		case s.at(j) == '?':
+			i = j + 1
+		case s.at(j) == '|' || s.at(j) == '&':
+			i = s.skipSimpleType(j + 1)
+		case s.hasPrefix(j, "->"):
+			return s.skipType(j + 2)
+		default:
+			return i
+		}
+	}
+}
+
+// skipSimpleType returns the end of a name such as Foo.Bar<T>, a typeof, a
+// string singleton, a variadic or a parenthesised or table type
+func (s *luauScanner) skipSimpleType(i int) int {
+	i = s.skipSpace(i)
+	switch c := s.at(i); {
+	case c == '(' || c == '{':
+		return s.balancedEnd(i)
+	case c == '"' || c == '\'':
+		return luaStringEnd(s.src, i)
+	case s.hasPrefix(i, "..."):
+		return s.skipSimpleType(i + 3)
+	case isLuaIdentStart(c):
+		end := luaIdentEnd(s.src, i)
+		for s.at(end) == '.' && isLuaIdentStart(s.at(end+1)) {
+			end = luaIdentEnd(s.src, end+1)
+		}
+		if string(s.src[i:end]) == "typeof" {
+			if j := s.skipSpace(end); s.at(j) == '(' {
+				return s.balancedEnd(j)
+			}
+		}
+		if s.at(end) == '<' {
+			return s.balancedEnd(end)
+		}
+		return end
+	}
+	return i
+}
+
+// balancedEnd returns the end of the bracketed span opening at i, skipping
+// strings and the > of ->
+func (s *luauScanner) balancedEnd(i int) int {
+	depth := 0
+	for i < len(s.src) {
+		switch c := s.src[i]; {
+		case c == '"' || c == '\'':
+			i = luaStringEnd(s.src, i)
+			continue
+		case c == '-' && s.at(i+1) == '>':
+			i += 2
+			continue
+		case c == '(' || c == '{' || c == '[' || c == '<':
+			depth++
+		case c == ')' || c == '}' || c == ']' || c == '>':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+		i++
+	}
+	return i
+}
+
+func isLuaIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isLuaIdentChar(c byte) bool {
+	return isLuaIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// luaIdentEnd returns the end of the name or number starting at i
+func luaIdentEnd(content []byte, i int) int {
+	for i < len(content) && isLuaIdentChar(content[i]) {
+		i++
+	}
+	return i
+}
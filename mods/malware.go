@@ -0,0 +1,194 @@
+package mods
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Severity ranks how strongly a finding suggests malicious intent
+type Severity string
+
+// Finding severities
+const (
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+// Finding is a single suspicious pattern found in an upload
+type Finding struct {
+	Scanner  string   `json:"scanner"`
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Entry    string   `json:"entry,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// ShouldQuarantine reports whether any finding is high or critical severity
+func ShouldQuarantine(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityHigh || f.Severity == SeverityCritical {
+			return true
+		}
+	}
+	return false
+}
+
+// knownMalwareIndicators are class names and string constants from known
+// Minecraft malware, such as the fractureiser stage loaders and their hosts
+var knownMalwareIndicators = []struct {
+	pattern, family string
+}{
+	{"dev/neko/nekoinjector", "fractureiser"},
+	{"dev/neko/nekoclient", "fractureiser"},
+	{"85.217.144.130", "fractureiser"},
+	{"107.189.3.101", "fractureiser"},
+	{"files-8ie.pages.dev", "fractureiser"},
+	{"skyrage", "fractureiser"},
+}
+
+// nativeLibraryExtensions are native code that runs outside the JVM sandbox
+var nativeLibraryExtensions = map[string]bool{".dll": true, ".so": true, ".dylib": true, ".jnilib": true, ".exe": true}
+
+var (
+	remoteURL     = regexp.MustCompile(`^https?://`)
+	base64Payload = regexp.MustCompile(`^[A-Za-z0-9+/]{200,}={0,2}$`)
+)
+
+// ScanForMalware applies static heuristics to an uploaded jar or zip. Class
+// constants are checked for known malware, remote class loading, process
+// execution, Unsafe class definition and embedded base64 payloads; native
+// libraries and nested jars are reported too, and nested jars are scanned.
+// Other file types have no heuristics and return no findings.
+func ScanForMalware(filename string, content []byte) ([]Finding, error) {
+	if !bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		return nil, nil
+	}
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return nil, err
+	}
+	findings := []Finding{}
+	if err := scanArchiveForMalware(archive, &findings); err != nil {
+		return nil, err
+	}
+	return findings, nil
+}
+
+func scanArchiveForMalware(archive *Archive, findings *[]Finding) error {
+	add := func(rule string, severity Severity, entry, detail string) {
+		*findings = append(*findings, Finding{Scanner: "heuristic", Rule: rule, Severity: severity, Entry: archive.path + entry, Detail: detail})
+	}
+
+	for _, f := range archive.Files() {
+		name := f.Name
+		ext := strings.ToLower(path.Ext(name))
+		switch {
+		case nativeLibraryExtensions[ext]:
+			add("native_library", SeverityMedium, name, "archive contains native code")
+		case isNestedArchive(name):
+			dir := path.Dir(name)
+			if dir != "META-INF/jars" && dir != "META-INF/jarjar" {
+				add("nested_jar", SeverityLow, name, "archive contains a jar outside the jar-in-jar directories")
+			}
+			nested, err := archive.OpenNested(f)
+			if err != nil {
+				return err
+			}
+			if err := scanArchiveForMalware(nested, findings); err != nil {
+				return err
+			}
+		case ext == ".class":
+			data, err := archive.ReadFile(f)
+			if err != nil {
+				return err
+			}
+			info, err := ParseClass(data)
+			if err != nil {
+				add("unreadable_class", SeverityLow, name, err.Error())
+				continue
+			}
+			for _, finding := range classFindings(info) {
+				add(finding.Rule, finding.Severity, name, finding.Detail)
+			}
+		}
+	}
+	return nil
+}
+
+// classFindings applies the class-level heuristics to a parsed class
+func classFindings(info *ClassInfo) []Finding {
+	var findings []Finding
+	add := func(rule string, severity Severity, detail string) {
+		findings = append(findings, Finding{Rule: rule, Severity: severity, Detail: detail})
+	}
+
+	classes := make(map[string]bool, len(info.Classes))
+	for _, c := range info.Classes {
+		classes[c] = true
+	}
+	calls := make(map[string]bool, len(info.Methods))
+	for _, m := range info.Methods {
+		calls[m.Owner+"."+m.Name] = true
+	}
+
+	for _, indicator := range knownMalwareIndicators {
+		matched := strings.HasPrefix(info.Name, indicator.pattern)
+		for _, c := range info.Classes {
+			matched = matched || strings.HasPrefix(c, indicator.pattern)
+		}
+		for _, s := range info.Strings {
+			matched = matched || strings.Contains(s, indicator.pattern)
+		}
+		if matched {
+			add("known_malware", SeverityCritical, fmt.Sprintf("matches %s indicator %q", indicator.family, indicator.pattern))
+		}
+	}
+
+	var urls, payloads []string
+	for _, s := range info.Strings {
+		if remoteURL.MatchString(s) {
+			urls = append(urls, s)
+		}
+		if base64Payload.MatchString(s) {
+			payloads = append(payloads, s)
+		}
+	}
+
+	// Defining classes at runtime is how stage loaders run downloaded code
+	definesClasses := classes["java/net/URLClassLoader"] || calls["java/lang/ClassLoader.defineClass"] ||
+		calls["java/lang/invoke/MethodHandles$Lookup.defineClass"] || calls["sun/misc/Unsafe.defineClass"] ||
+		calls["sun/misc/Unsafe.defineAnonymousClass"] || calls["jdk/internal/misc/Unsafe.defineClass"]
+
+	if classes["java/net/URLClassLoader"] && len(urls) > 0 {
+		add("remote_class_loading", SeverityHigh, fmt.Sprintf("URLClassLoader used alongside remote URL %s", urls[0]))
+	}
+	if calls["java/lang/Runtime.exec"] || calls["java/lang/ProcessBuilder.start"] {
+		add("process_execution", SeverityMedium, "starts external processes")
+	}
+	switch {
+	case calls["sun/misc/Unsafe.defineClass"] || calls["sun/misc/Unsafe.defineAnonymousClass"] || calls["jdk/internal/misc/Unsafe.defineClass"]:
+		add("unsafe_class_definition", SeverityHigh, "defines classes through Unsafe, bypassing class loaders")
+	case classes["sun/misc/Unsafe"] || classes["jdk/internal/misc/Unsafe"]:
+		add("unsafe_access", SeverityLow, "uses sun.misc.Unsafe")
+	}
+	if len(payloads) > 0 {
+		decodes := calls["java/util/Base64$Decoder.decode"]
+		switch {
+		case decodes && definesClasses:
+			add("base64_payload", SeverityHigh, fmt.Sprintf("decodes %d embedded base64 payload(s) and defines classes at runtime", len(payloads)))
+		case decodes:
+			add("base64_payload", SeverityMedium, fmt.Sprintf("decodes %d embedded base64 payload(s)", len(payloads)))
+		default:
+			add("base64_payload", SeverityLow, fmt.Sprintf("contains %d long base64 string(s)", len(payloads)))
+		}
+	}
+	if calls["java/lang/System.load"] || calls["java/lang/System.loadLibrary"] || calls["java/lang/Runtime.load"] {
+		add("native_loading", SeverityMedium, "loads native libraries")
+	}
+	return findings
+}
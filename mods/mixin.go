@@ -0,0 +1,153 @@
+package mods
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// mixinAnnotation is the internal name of Mixin's @Mixin annotation
+const mixinAnnotation = "org/spongepowered/asm/mixin/Mixin"
+
+// MixinConfig is a mixin configuration file such as example.mixins.json
+type MixinConfig struct {
+	Package            string   `json:"package"`
+	Required           bool     `json:"required"`
+	MinVersion         string   `json:"minVersion,omitempty"`
+	CompatibilityLevel string   `json:"compatibilityLevel,omitempty"`
+	Refmap             string   `json:"refmap,omitempty"`
+	Plugin             string   `json:"plugin,omitempty"`
+	Mixins             []string `json:"mixins,omitempty"`
+	Client             []string `json:"client,omitempty"`
+	Server             []string `json:"server,omitempty"`
+}
+
+// ParseMixinConfig reads a mixin configuration file
+func ParseMixinConfig(data []byte) (*MixinConfig, error) {
+	var config MixinConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid mixin config: %w", err)
+	}
+	if config.Package == "" {
+		return nil, fmt.Errorf("mixin config is missing package")
+	}
+	return &config, nil
+}
+
+// MixinTarget records which classes a single mixin class modifies
+type MixinTarget struct {
+	Config  string   `json:"config"`
+	Mixin   string   `json:"mixin"`
+	Side    string   `json:"side"` // common, client or server
+	Targets []string `json:"targets"`
+}
+
+// AnalyzeMixins finds the mixin configs of a jar and reads the @Mixin
+// annotation of every mixin class they list. Configs are found by name and
+// through fabric.mod.json and the MixinConfigs manifest attribute.
+func AnalyzeMixins(archive *Archive) ([]MixinTarget, error) {
+	targets := []MixinTarget{}
+	for _, name := range mixinConfigNames(archive) {
+		data, err := archive.ReadFileNamed(name)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			continue
+		}
+		config, err := ParseMixinConfig(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		for side, mixins := range map[string][]string{"common": config.Mixins, "client": config.Client, "server": config.Server} {
+			for _, mixin := range mixins {
+				className := config.Package + "." + mixin
+				target := MixinTarget{Config: name, Mixin: className, Side: side, Targets: []string{}}
+
+				data, err := archive.ReadFileNamed(strings.ReplaceAll(className, ".", "/") + ".class")
+				if err != nil {
+					return nil, err
+				}
+				if data != nil {
+					if info, err := ParseClass(data); err == nil {
+						target.Targets = mixinTargets(info)
+					}
+				}
+				targets = append(targets, target)
+			}
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].Config != targets[j].Config {
+			return targets[i].Config < targets[j].Config
+		}
+		return targets[i].Mixin < targets[j].Mixin
+	})
+	return targets, nil
+}
+
+// mixinTargets reads the classes named by a class's @Mixin annotation. Both
+// value (class literals) and targets (names, for private classes) count.
+func mixinTargets(info *ClassInfo) []string {
+	targets := []string{}
+	for _, a := range info.Annotations {
+		if a.Type != mixinAnnotation {
+			continue
+		}
+		targets = append(targets, a.Elements["value"]...)
+		for _, name := range a.Elements["targets"] {
+			targets = append(targets, strings.ReplaceAll(name, ".", "/"))
+		}
+	}
+	return targets
+}
+
+// mixinConfigNames returns the mixin configs a jar declares or contains
+func mixinConfigNames(archive *Archive) []string {
+	names := make(map[string]bool)
+	for _, f := range archive.Files() {
+		base := path.Base(f.Name)
+		if path.Dir(f.Name) == "." && (strings.HasSuffix(base, ".mixins.json") || (strings.HasPrefix(base, "mixins.") && strings.HasSuffix(base, ".json"))) {
+			names[f.Name] = true
+		}
+	}
+
+	// fabric.mod.json lists configs as strings or {"config": ..., "environment": ...}
+	if data, err := archive.ReadFileNamed("fabric.mod.json"); err == nil && data != nil {
+		var descriptor struct {
+			Mixins []json.RawMessage `json:"mixins"`
+		}
+		if json.Unmarshal(data, &descriptor) == nil {
+			for _, raw := range descriptor.Mixins {
+				var name string
+				var entry struct {
+					Config string `json:"config"`
+				}
+				if json.Unmarshal(raw, &name) == nil {
+					names[name] = true
+				} else if json.Unmarshal(raw, &entry) == nil && entry.Config != "" {
+					names[entry.Config] = true
+				}
+			}
+		}
+	}
+
+	// Forge lists configs in the manifest
+	if data, err := archive.ReadFileNamed("META-INF/MANIFEST.MF"); err == nil && data != nil {
+		for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+			if value, ok := strings.CutPrefix(line, "MixinConfigs:"); ok {
+				for _, name := range strings.Split(value, ",") {
+					if name = strings.TrimSpace(name); name != "" {
+						names[name] = true
+					}
+				}
+			}
+		}
+	}
+
+	return sortedKeys(names)
+}
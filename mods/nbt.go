@@ -0,0 +1,531 @@
+package mods
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf16"
+)
+
+// NBT tag types
+const (
+	TagEnd       byte = 0
+	TagByte      byte = 1
+	TagShort     byte = 2
+	TagInt       byte = 3
+	TagLong      byte = 4
+	TagFloat     byte = 5
+	TagDouble    byte = 6
+	TagByteArray byte = 7
+	TagString    byte = 8
+	TagList      byte = 9
+	TagCompound  byte = 10
+	TagIntArray  byte = 11
+	TagLongArray byte = 12
+)
+
+var nbtTagNames = []string{"end", "byte", "short", "int", "long", "float", "double", "byte_array", "string", "list", "compound", "int_array", "long_array"}
+
+// NBTTagName returns the name of a tag type
+func NBTTagName(tag byte) string {
+	if int(tag) < len(nbtTagNames) {
+		return nbtTagNames[tag]
+	}
+	return fmt.Sprintf("tag %d", tag)
+}
+
+// NBT compressions
+const (
+	NBTGzip         = "gzip"
+	NBTZlib         = "zlib"
+	NBTUncompressed = "none"
+)
+
+const (
+	// maxNBTSize caps the decompressed size of an NBT file
+	maxNBTSize = 64 * 1024 * 1024
+	// maxNBTDepth is the nesting limit Minecraft enforces
+	maxNBTDepth = 512
+)
+
+// NBTList is a list tag. Its items all have the list's tag type; an empty
+// list keeps the type it was written with.
+type NBTList struct {
+	Type  byte
+	Items []interface{}
+}
+
+// NBTCompound is a compound tag with its keys in file order
+type NBTCompound struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// NewNBTCompound returns an empty compound
+func NewNBTCompound() *NBTCompound {
+	return &NBTCompound{values: make(map[string]interface{})}
+}
+
+// Keys returns the compound's keys in order
+func (c *NBTCompound) Keys() []string {
+	return append([]string(nil), c.keys...)
+}
+
+// Len returns the number of keys
+func (c *NBTCompound) Len() int {
+	return len(c.keys)
+}
+
+// Get returns the value of a key
+func (c *NBTCompound) Get(key string) (interface{}, bool) {
+	v, ok := c.values[key]
+	return v, ok
+}
+
+// Set adds or replaces a key, keeping the position of an existing key
+func (c *NBTCompound) Set(key string, value interface{}) {
+	if _, ok := c.values[key]; !ok {
+		c.keys = append(c.keys, key)
+	}
+	c.values[key] = value
+}
+
+// Delete removes a key
+func (c *NBTCompound) Delete(key string) {
+	if _, ok := c.values[key]; !ok {
+		return
+	}
+	delete(c.values, key)
+	for i, k := range c.keys {
+		if k == key {
+			c.keys = append(c.keys[:i], c.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// nbtTagOf returns the tag type of a value. Values are int8, int16, int32,
+// int64, float32, float64, []int8, string, *NBTList, *NBTCompound, []int32
+// and []int64.
+func nbtTagOf(v interface{}) (byte, error) {
+	switch v.(type) {
+	case int8:
+		return TagByte, nil
+	case int16:
+		return TagShort, nil
+	case int32:
+		return TagInt, nil
+	case int64:
+		return TagLong, nil
+	case float32:
+		return TagFloat, nil
+	case float64:
+		return TagDouble, nil
+	case []int8:
+		return TagByteArray, nil
+	case string:
+		return TagString, nil
+	case *NBTList:
+		return TagList, nil
+	case *NBTCompound:
+		return TagCompound, nil
+	case []int32:
+		return TagIntArray, nil
+	case []int64:
+		return TagLongArray, nil
+	}
+	return TagEnd, fmt.Errorf("%T is not an NBT value", v)
+}
+
+// NBTFile is a Java Edition NBT file: a named root compound and the
+// compression it was stored with
+type NBTFile struct {
+	Name        string
+	Root        *NBTCompound
+	Compression string
+}
+
+// isNBT reports whether content is an NBT file with a compound root
+func isNBT(content []byte) bool {
+	switch {
+	case len(content) < 3:
+		return false
+	case content[0] == 0x1f && content[1] == 0x8b, isZlib(content):
+	case content[0] != TagCompound:
+		return false
+	}
+	_, err := ReadNBT(content)
+	return err == nil
+}
+
+// isZlib checks the zlib header: deflate with a valid header checksum
+func isZlib(content []byte) bool {
+	return len(content) >= 2 && content[0]&0x0f == 8 && (uint16(content[0])<<8|uint16(content[1]))%31 == 0
+}
+
+// ReadNBT decodes a gzip, zlib or uncompressed NBT file
+func ReadNBT(content []byte) (*NBTFile, error) {
+	file := &NBTFile{Compression: NBTUncompressed}
+	var r io.Reader
+	switch {
+	case len(content) >= 2 && content[0] == 0x1f && content[1] == 0x8b:
+		gz, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip: %w", err)
+		}
+		r, file.Compression = gz, NBTGzip
+	case len(content) > 0 && content[0] != TagCompound && isZlib(content):
+		zr, err := zlib.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("invalid zlib: %w", err)
+		}
+		r, file.Compression = zr, NBTZlib
+	}
+	data := content
+	if r != nil {
+		var err error
+		if data, err = io.ReadAll(io.LimitReader(r, maxNBTSize+1)); err != nil {
+			return nil, fmt.Errorf("failed to decompress NBT: %w", err)
+		}
+		if len(data) > maxNBTSize {
+			return nil, fmt.Errorf("NBT data exceeds %dMB", maxNBTSize/(1024*1024))
+		}
+	}
+
+	reader := &nbtReader{data: data}
+	tag, err := reader.byte()
+	if err != nil {
+		return nil, err
+	}
+	if tag != TagCompound {
+		return nil, fmt.Errorf("root tag is %s, want compound", NBTTagName(tag))
+	}
+	if file.Name, err = reader.string(); err != nil {
+		return nil, err
+	}
+	root, err := reader.payload(TagCompound)
+	if err != nil {
+		return nil, err
+	}
+	if reader.pos != len(data) {
+		return nil, fmt.Errorf("%d bytes after the root compound", len(data)-reader.pos)
+	}
+	file.Root = root.(*NBTCompound)
+	return file, nil
+}
+
+type nbtReader struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+func (r *nbtReader) next(n int) ([]byte, error) {
+	if n < 0 || n > len(r.data)-r.pos {
+		return nil, fmt.Errorf("NBT truncated at offset %d", r.pos)
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *nbtReader) byte() (byte, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *nbtReader) string() (string, error) {
+	n, err := r.next(2)
+	if err != nil {
+		return "", err
+	}
+	b, err := r.next(int(binary.BigEndian.Uint16(n)))
+	if err != nil {
+		return "", err
+	}
+	return decodeModifiedUTF8(b), nil
+}
+
+// length reads an array or list length, checking that the remaining data
+// could hold that many items of at least size bytes each
+func (r *nbtReader) length(size int) (int, error) {
+	b, err := r.next(4)
+	if err != nil {
+		return 0, err
+	}
+	n := int(int32(binary.BigEndian.Uint32(b)))
+	if n < 0 || n > (len(r.data)-r.pos)/max(size, 1) {
+		return 0, fmt.Errorf("invalid length %d at offset %d", n, r.pos-4)
+	}
+	return n, nil
+}
+
+func (r *nbtReader) payload(tag byte) (interface{}, error) {
+	switch tag {
+	case TagByte:
+		b, err := r.byte()
+		return int8(b), err
+	case TagShort:
+		b, err := r.next(2)
+		if err != nil {
+			return nil, err
+		}
+		return int16(binary.BigEndian.Uint16(b)), nil
+	case TagInt:
+		b, err := r.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return int32(binary.BigEndian.Uint32(b)), nil
+	case TagLong:
+		b, err := r.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return int64(binary.BigEndian.Uint64(b)), nil
+	case TagFloat:
+		b, err := r.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), nil
+	case TagDouble:
+		b, err := r.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case TagByteArray:
+		n, err := r.length(1)
+		if err != nil {
+			return nil, err
+		}
+		b, _ := r.next(n)
+		out := make([]int8, n)
+		for i := range b {
+			out[i] = int8(b[i])
+		}
+		return out, nil
+	case TagString:
+		return r.string()
+	case TagIntArray:
+		n, err := r.length(4)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]int32, n)
+		for i := range out {
+			b, _ := r.next(4)
+			out[i] = int32(binary.BigEndian.Uint32(b))
+		}
+		return out, nil
+	case TagLongArray:
+		n, err := r.length(8)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]int64, n)
+		for i := range out {
+			b, _ := r.next(8)
+			out[i] = int64(binary.BigEndian.Uint64(b))
+		}
+		return out, nil
+	case TagList, TagCompound:
+		if r.depth++; r.depth > maxNBTDepth {
+			return nil, fmt.Errorf("NBT nested deeper than %d", maxNBTDepth)
+		}
+		defer func() { r.depth-- }()
+		if tag == TagList {
+			return r.list()
+		}
+		return r.compound()
+	}
+	return nil, fmt.Errorf("unknown tag type %d at offset %d", tag, r.pos)
+}
+
+func (r *nbtReader) list() (interface{}, error) {
+	itemType, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	n, err := r.length(nbtMinSize(itemType))
+	if err != nil {
+		return nil, err
+	}
+	if itemType == TagEnd && n > 0 {
+		return nil, fmt.Errorf("list of %d end tags", n)
+	}
+	list := &NBTList{Type: itemType}
+	for i := 0; i < n; i++ {
+		item, err := r.payload(itemType)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, item)
+	}
+	return list, nil
+}
+
+func (r *nbtReader) compound() (interface{}, error) {
+	c := NewNBTCompound()
+	for {
+		tag, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if tag == TagEnd {
+			return c, nil
+		}
+		name, err := r.string()
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.payload(tag)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		c.Set(name, value)
+	}
+}
+
+// nbtMinSize is the fewest bytes a payload of the tag type takes
+func nbtMinSize(tag byte) int {
+	switch tag {
+	case TagShort, TagString:
+		return 2
+	case TagInt, TagFloat, TagByteArray, TagIntArray, TagLongArray:
+		return 4
+	case TagLong, TagDouble:
+		return 8
+	}
+	return 1
+}
+
+// Encode writes the file with its original compression
+func (f *NBTFile) Encode() ([]byte, error) {
+	var payload bytes.Buffer
+	payload.WriteByte(TagCompound)
+	if err := writeNBTString(&payload, f.Name); err != nil {
+		return nil, err
+	}
+	if err := writeNBTPayload(&payload, f.Root, 0); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	var w io.WriteCloser
+	switch f.Compression {
+	case NBTGzip:
+		w = gzip.NewWriter(&out)
+	case NBTZlib:
+		w = zlib.NewWriter(&out)
+	default:
+		return payload.Bytes(), nil
+	}
+	if _, err := w.Write(payload.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func writeNBTString(buf *bytes.Buffer, s string) error {
+	encoded := encodeModifiedUTF8(s)
+	if len(encoded) > math.MaxUint16 {
+		return fmt.Errorf("string of %d bytes is too long for NBT", len(encoded))
+	}
+	binary.Write(buf, binary.BigEndian, uint16(len(encoded)))
+	buf.Write(encoded)
+	return nil
+}
+
+func writeNBTPayload(buf *bytes.Buffer, v interface{}, depth int) error {
+	if depth > maxNBTDepth {
+		return fmt.Errorf("NBT nested deeper than %d", maxNBTDepth)
+	}
+	switch val := v.(type) {
+	case int8, int16, int32, int64, float32, float64:
+		binary.Write(buf, binary.BigEndian, val)
+	case []int8, []int32, []int64:
+		binary.Write(buf, binary.BigEndian, int32(nbtArrayLen(val)))
+		binary.Write(buf, binary.BigEndian, val)
+	case string:
+		return writeNBTString(buf, val)
+	case *NBTList:
+		itemType := val.Type
+		for i, item := range val.Items {
+			tag, err := nbtTagOf(item)
+			if err != nil {
+				return err
+			}
+			if i == 0 {
+				itemType = tag
+			} else if tag != itemType {
+				return fmt.Errorf("list mixes %s and %s", NBTTagName(itemType), NBTTagName(tag))
+			}
+		}
+		buf.WriteByte(itemType)
+		binary.Write(buf, binary.BigEndian, int32(len(val.Items)))
+		for _, item := range val.Items {
+			if err := writeNBTPayload(buf, item, depth+1); err != nil {
+				return err
+			}
+		}
+	case *NBTCompound:
+		for _, key := range val.keys {
+			tag, err := nbtTagOf(val.values[key])
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			buf.WriteByte(tag)
+			if err := writeNBTString(buf, key); err != nil {
+				return err
+			}
+			if err := writeNBTPayload(buf, val.values[key], depth+1); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		buf.WriteByte(TagEnd)
+	default:
+		return fmt.Errorf("%T is not an NBT value", v)
+	}
+	return nil
+}
+
+func nbtArrayLen(v interface{}) int {
+	switch a := v.(type) {
+	case []int8:
+		return len(a)
+	case []int32:
+		return len(a)
+	case []int64:
+		return len(a)
+	}
+	return 0
+}
+
+// encodeModifiedUTF8 encodes a string as the JVM's modified UTF-8, the
+// reverse of decodeModifiedUTF8
+func encodeModifiedUTF8(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, u := range utf16.Encode([]rune(s)) {
+		switch {
+		case u != 0 && u < 0x80:
+			out = append(out, byte(u))
+		case u < 0x800:
+			out = append(out, 0xc0|byte(u>>6), 0x80|byte(u&0x3f))
+		default:
+			out = append(out, 0xe0|byte(u>>12), 0x80|byte(u>>6&0x3f), 0x80|byte(u&0x3f))
+		}
+	}
+	return out
+}
@@ -0,0 +1,642 @@
+package mods
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// vanillaSnapshots holds the vanilla registry snapshot of each supported
+// Minecraft version; see vanilla/*.txt for the file format
+//
+//go:embed vanilla/*.txt
+var vanillaSnapshots embed.FS
+
+// Kinds of reference checked by CheckReferences
+const (
+	RefItem     = "item"
+	RefBlock    = "block"
+	RefItemTag  = "item_tag"
+	RefBlockTag = "block_tag"
+	RefModel    = "model"
+	RefTexture  = "texture"
+)
+
+// maxReportedReferences bounds the unresolved references listed in a report
+const maxReportedReferences = 200
+
+var (
+	recipePath     = regexp.MustCompile(`^data/([^/]+)/recipes?/(.+)\.json$`)
+	lootTablePath  = regexp.MustCompile(`^data/([^/]+)/loot_tables?/(.+)\.json$`)
+	tagPath        = regexp.MustCompile(`^data/([^/]+)/tags/(items?|blocks?)/(.+)\.json$`)
+	modelPath      = regexp.MustCompile(`^assets/([^/]+)/models/(.+)\.json$`)
+	blockstatePath = regexp.MustCompile(`^assets/([^/]+)/blockstates/(.+)\.json$`)
+	texturePath    = regexp.MustCompile(`^assets/([^/]+)/textures/(.+)\.png$`)
+	namespacePath  = regexp.MustCompile(`^(?:assets|data)/([^/]+)/`)
+	langItemKey    = regexp.MustCompile(`^(item|block)\.([^.]+)\.([^.]+)$`)
+)
+
+// conventionTagNamespaces hold the shared tags that loaders define
+var conventionTagNamespaces = map[string]bool{"c": true, "forge": true, "neoforge": true, "fabric": true}
+
+// builtinModels are vanilla models that no item or block is named after
+var builtinModels = map[string]bool{
+	"item/generated": true, "item/handheld": true, "item/handheld_rod": true, "item/handheld_mace": true,
+	"block/block": true, "block/cube": true, "block/cube_all": true, "block/cube_column": true,
+	"block/cube_column_horizontal": true, "block/cube_bottom_top": true, "block/cube_top": true,
+	"block/cube_directional": true, "block/cube_mirrored_all": true, "block/orientable": true,
+	"block/orientable_with_bottom": true, "block/orientable_vertical": true, "block/cross": true,
+	"block/tinted_cross": true, "block/crop": true, "block/stem_growth0": true, "block/slab": true,
+	"block/slab_top": true, "block/stairs": true, "block/inner_stairs": true, "block/outer_stairs": true,
+	"block/button": true, "block/button_pressed": true, "block/button_inventory": true,
+	"block/fence_post": true, "block/fence_side": true, "block/fence_inventory": true,
+	"block/pressure_plate_up": true, "block/pressure_plate_down": true, "block/leaves": true,
+	"block/carpet": true, "block/thin_block": true, "block/rail_flat": true, "block/rail_curved": true,
+	"block/rail_raised_ne": true, "block/rail_raised_sw": true, "block/pane_post": true,
+	"block/pane_side": true, "block/pane_side_alt": true, "block/pane_noside": true, "block/pane_noside_alt": true,
+}
+
+// textureSuffixes are the trailing name parts vanilla adds to the model or
+// texture of an item or block, as in oak_log_top or bow_pulling_0
+var textureSuffixes = map[string]bool{
+	"top": true, "bottom": true, "side": true, "front": true, "back": true, "end": true,
+	"inner": true, "outer": true, "on": true, "off": true, "open": true, "closed": true,
+	"inventory": true, "post": true, "noside": true, "lit": true, "overlay": true, "pulling": true,
+	"standby": true, "arrow": true, "firework": true, "empty": true, "filled": true, "head": true,
+	"foot": true, "stage": true, "age": true, "tip": true, "base": true, "still": true, "flow": true,
+	"hanging": true, "upper": true, "lower": true, "left": true, "right": true, "alt": true,
+}
+
+// Reference is an ID used by a file of a mod or pack
+type Reference struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+	Source string `json:"source"` // file the reference appears in
+}
+
+// ReferenceReport lists the references of a mod or pack that resolve to
+// nothing: not vanilla, not defined by the mod, not from a declared dependency
+type ReferenceReport struct {
+	Registry   string      `json:"registry"` // vanilla snapshot checked against, empty when none covers the version
+	Checked    int         `json:"checked"`
+	Unresolved []Reference `json:"unresolved,omitempty"` // the first maxReportedReferences
+	Truncated  bool        `json:"truncated,omitempty"`
+
+	all []Reference
+}
+
+// NewUnresolved returns the unresolved references of a report that another
+// report, usually of the file before an edit, does not have
+func (r *ReferenceReport) NewUnresolved(before *ReferenceReport) []Reference {
+	known := make(map[string]bool)
+	if before != nil {
+		for _, ref := range before.all {
+			known[ref.Kind+" "+ref.ID] = true
+		}
+	}
+	var added []Reference
+	for _, ref := range r.all {
+		if !known[ref.Kind+" "+ref.ID] {
+			added = append(added, ref)
+		}
+	}
+	return added
+}
+
+// vanillaRegistry is one version's snapshot of vanilla IDs by reference kind,
+// without the minecraft namespace
+type vanillaRegistry struct {
+	version string
+	through string // last version the snapshot covers, empty when open-ended
+	ids     map[string]map[string]bool
+}
+
+var (
+	vanillaOnce       sync.Once
+	vanillaRegistries []*vanillaRegistry // oldest first
+	vanillaErr        error
+)
+
+// loadVanillaRegistries parses every embedded snapshot
+func loadVanillaRegistries() ([]*vanillaRegistry, error) {
+	vanillaOnce.Do(func() {
+		entries, err := vanillaSnapshots.ReadDir("vanilla")
+		if err != nil {
+			vanillaErr = err
+			return
+		}
+		// Snapshots are parsed oldest first so each can extend an older one
+		sort.Slice(entries, func(i, j int) bool {
+			return ParseVersion(strings.TrimSuffix(entries[i].Name(), ".txt")).Compare(ParseVersion(strings.TrimSuffix(entries[j].Name(), ".txt"))) < 0
+		})
+		byVersion := make(map[string]*vanillaRegistry)
+		for _, entry := range entries {
+			data, err := vanillaSnapshots.ReadFile("vanilla/" + entry.Name())
+			if err != nil {
+				vanillaErr = err
+				return
+			}
+			version := strings.TrimSuffix(entry.Name(), ".txt")
+			registry, err := parseVanillaSnapshot(version, data, byVersion)
+			if err != nil {
+				vanillaErr = fmt.Errorf("vanilla snapshot %s: %w", entry.Name(), err)
+				return
+			}
+			byVersion[version] = registry
+			vanillaRegistries = append(vanillaRegistries, registry)
+		}
+	})
+	return vanillaRegistries, vanillaErr
+}
+
+// parseVanillaSnapshot reads a snapshot: [kind] sections of one ID per line,
+// an optional "@extends <version>" of an already loaded snapshot, an optional
+// "@through <version>" naming the last version it covers, and lines starting
+// with - that remove an inherited ID
+func parseVanillaSnapshot(version string, data []byte, loaded map[string]*vanillaRegistry) (*vanillaRegistry, error) {
+	registry := &vanillaRegistry{version: version, ids: make(map[string]map[string]bool)}
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "@extends "):
+			base, ok := loaded[strings.TrimSpace(strings.TrimPrefix(line, "@extends "))]
+			if !ok {
+				return nil, fmt.Errorf("extends unknown snapshot %q", line)
+			}
+			for kind, ids := range base.ids {
+				registry.ids[kind] = make(map[string]bool, len(ids))
+				for id := range ids {
+					registry.ids[kind][id] = true
+				}
+			}
+		case strings.HasPrefix(line, "@through "):
+			registry.through = strings.TrimSpace(strings.TrimPrefix(line, "@through "))
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = line[1 : len(line)-1]
+			if registry.ids[section] == nil {
+				registry.ids[section] = make(map[string]bool)
+			}
+		case section == "":
+			return nil, fmt.Errorf("%q is outside a section", line)
+		case strings.HasPrefix(line, "-"):
+			delete(registry.ids[section], line[1:])
+		default:
+			registry.ids[section][line] = true
+		}
+	}
+	return registry, scanner.Err()
+}
+
+// vanillaRegistryFor returns the newest snapshot not newer than a Minecraft
+// version, the oldest for older versions and the newest when none is given.
+// It returns nil for a version past the last one the newest snapshot covers.
+func vanillaRegistryFor(minecraftVersion string) (*vanillaRegistry, error) {
+	registries, err := loadVanillaRegistries()
+	if err != nil {
+		return nil, err
+	}
+	if len(registries) == 0 {
+		return nil, fmt.Errorf("no vanilla registry snapshots")
+	}
+	if minecraftVersion == "" {
+		return registries[len(registries)-1], nil
+	}
+	v := ParseVersion(minecraftVersion)
+	chosen := registries[0]
+	for _, registry := range registries {
+		if ParseVersion(registry.version).Compare(v) <= 0 {
+			chosen = registry
+		}
+	}
+	if chosen.through != "" && v.Compare(ParseVersion(chosen.through)) > 0 {
+		return nil, nil
+	}
+	return chosen, nil
+}
+
+// has reports whether a vanilla ID (without namespace) exists for a kind
+func (v *vanillaRegistry) has(kind, name string) bool {
+	switch kind {
+	case RefBlock:
+		return v.ids[RefItem][name] || v.ids[RefBlock][name]
+	case RefModel, RefTexture:
+		if builtinModels[name] || strings.HasPrefix(name, "builtin/") {
+			return true
+		}
+		dir, base, ok := strings.Cut(name, "/")
+		if !ok || (dir != "item" && dir != "block") {
+			return false
+		}
+		if strings.HasPrefix(base, "template_") {
+			return true
+		}
+		// Strip suffixes like _top or _0 until a registry name is left
+		parts := strings.Split(base, "_")
+		for n := len(parts); n > 0; n-- {
+			candidate := strings.Join(parts[:n], "_")
+			if v.ids[RefItem][candidate] || v.ids[RefBlock][candidate] {
+				return true
+			}
+			last := parts[n-1]
+			if !textureSuffixes[last] && strings.Trim(last, "0123456789") != "" {
+				return false
+			}
+		}
+		return false
+	default:
+		return v.ids[kind][name]
+	}
+}
+
+// referenceIndex is what a mod or pack defines and depends on
+type referenceIndex struct {
+	vanilla      *vanillaRegistry // nil when no snapshot covers the version
+	standalone   bool             // a single file; only vanilla IDs can be checked
+	namespaces   map[string]bool  // namespaces of the mod itself
+	dependencies map[string]bool  // namespaces of declared dependencies
+	evidenced    map[string]bool  // namespaces with item models, blockstates or lang keys
+	defined      map[string]map[string]bool
+}
+
+func newReferenceIndex(vanilla *vanillaRegistry) *referenceIndex {
+	return &referenceIndex{
+		vanilla:      vanilla,
+		namespaces:   make(map[string]bool),
+		dependencies: make(map[string]bool),
+		evidenced:    make(map[string]bool),
+		defined:      make(map[string]map[string]bool),
+	}
+}
+
+func (x *referenceIndex) define(kind, id string) {
+	if x.defined[kind] == nil {
+		x.defined[kind] = make(map[string]bool)
+	}
+	x.defined[kind][id] = true
+}
+
+// resolves reports whether a reference is vanilla, defined by the mod or in
+// the namespace of a declared dependency. Items and blocks of a namespace the
+// mod has no models or lang keys for are registered in code the checker
+// cannot see, so they resolve.
+func (x *referenceIndex) resolves(ref Reference) bool {
+	namespace, name := splitResourceID(ref.ID)
+	if x.defined[ref.Kind][namespace+":"+name] {
+		return true
+	}
+	// Blocks usually have an item of the same name
+	if (ref.Kind == RefItem || ref.Kind == RefBlock) && (x.defined[RefItem][namespace+":"+name] || x.defined[RefBlock][namespace+":"+name]) {
+		return true
+	}
+	if namespace == "minecraft" {
+		return x.vanilla == nil || x.vanilla.has(ref.Kind, name)
+	}
+	if x.standalone || x.dependencies[namespace] {
+		return true
+	}
+	switch ref.Kind {
+	case RefItemTag, RefBlockTag:
+		return conventionTagNamespaces[namespace]
+	case RefItem, RefBlock:
+		return x.namespaces[namespace] && !x.evidenced[namespace]
+	}
+	return false
+}
+
+// splitResourceID splits a namespaced ID, defaulting to the minecraft namespace
+func splitResourceID(id string) (string, string) {
+	if namespace, name, ok := strings.Cut(id, ":"); ok {
+		return namespace, name
+	}
+	return "minecraft", id
+}
+
+// CheckReferences checks that the items, blocks, tags, models and textures a
+// data pack, resource pack or mod jar references exist. A standalone JSON file
+// has no context, so only its minecraft references are checked. An empty
+// Minecraft version checks against the newest vanilla snapshot; a version
+// newer than every snapshot leaves minecraft references unchecked.
+func CheckReferences(filename string, content []byte, minecraftVersion string) (*ReferenceReport, error) {
+	vanilla, err := vanillaRegistryFor(minecraftVersion)
+	if err != nil {
+		return nil, err
+	}
+	index := newReferenceIndex(vanilla)
+	var refs []Reference
+
+	if bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		archive, err := OpenArchive(content, DefaultArchiveLimits)
+		if err != nil {
+			return nil, err
+		}
+		descriptors, _ := ParseDescriptors(filename, content)
+		for _, d := range descriptors {
+			index.namespaces[d.ID] = true
+			for _, id := range d.Provides {
+				index.namespaces[id] = true
+			}
+			for _, rel := range d.Relations {
+				if rel.Kind == RelationDepends || rel.Kind == RelationRecommends {
+					index.dependencies[rel.ModID] = true
+				}
+			}
+		}
+		if refs, err = indexArchive(index, archive); err != nil {
+			return nil, err
+		}
+	} else {
+		var v interface{}
+		if err := json.Unmarshal(content, &v); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		index.standalone = true
+		refs = fileReferences(guessReferenceFile(v), filename, v)
+	}
+
+	report := &ReferenceReport{Checked: len(refs)}
+	if vanilla != nil {
+		report.Registry = vanilla.version
+	}
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		key := ref.Kind + " " + ref.ID + " " + ref.Source
+		if seen[key] || index.resolves(ref) {
+			continue
+		}
+		seen[key] = true
+		report.all = append(report.all, ref)
+	}
+	report.Unresolved = report.all
+	if len(report.Unresolved) > maxReportedReferences {
+		report.Unresolved = report.Unresolved[:maxReportedReferences]
+		report.Truncated = true
+	}
+	return report, nil
+}
+
+// indexArchive records what an archive defines and returns every reference
+// its data and asset files make
+func indexArchive(index *referenceIndex, archive *Archive) ([]Reference, error) {
+	type dataFile struct{ kind, name string }
+	var files []dataFile
+
+	for _, f := range archive.Files() {
+		name := f.Name
+		if m := namespacePath.FindStringSubmatch(name); m != nil && m[1] != "minecraft" {
+			index.namespaces[m[1]] = true
+		}
+		switch {
+		case recipePath.MatchString(name):
+			files = append(files, dataFile{"recipe", name})
+		case lootTablePath.MatchString(name):
+			files = append(files, dataFile{"loot_table", name})
+		case tagPath.MatchString(name):
+			m := tagPath.FindStringSubmatch(name)
+			kind := RefItemTag
+			if strings.HasPrefix(m[2], "block") {
+				kind = RefBlockTag
+			}
+			index.define(kind, m[1]+":"+m[3])
+			files = append(files, dataFile{kind, name})
+		case modelPath.MatchString(name):
+			m := modelPath.FindStringSubmatch(name)
+			index.define(RefModel, m[1]+":"+m[2])
+			if base, ok := strings.CutPrefix(m[2], "item/"); ok {
+				index.define(RefItem, m[1]+":"+base)
+				index.evidenced[m[1]] = true
+			}
+			files = append(files, dataFile{"model", name})
+		case blockstatePath.MatchString(name):
+			m := blockstatePath.FindStringSubmatch(name)
+			index.define(RefBlock, m[1]+":"+m[2])
+			index.evidenced[m[1]] = true
+			files = append(files, dataFile{"blockstate", name})
+		case texturePath.MatchString(name):
+			m := texturePath.FindStringSubmatch(name)
+			index.define(RefTexture, m[1]+":"+m[2])
+		}
+	}
+
+	// Lang keys name the items and blocks a mod registers
+	langs, err := ExtractLang(archive)
+	if err != nil {
+		return nil, err
+	}
+	for _, lang := range langs {
+		for _, key := range lang.Keys {
+			if m := langItemKey.FindStringSubmatch(key); m != nil {
+				index.define(m[1], m[2]+":"+m[3])
+				index.evidenced[m[2]] = true
+			}
+		}
+	}
+
+	var refs []Reference
+	for _, file := range files {
+		data, err := archive.ReadFileNamed(file.name)
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			continue // malformed JSON is the validator's concern
+		}
+		refs = append(refs, fileReferences(file.kind, file.name, v)...)
+	}
+	return refs, nil
+}
+
+// guessReferenceFile names the kind of a standalone JSON file from its shape
+func guessReferenceFile(v interface{}) string {
+	obj, _ := v.(map[string]interface{})
+	has := func(key string) bool { _, ok := obj[key]; return ok }
+	switch {
+	case has("pools"):
+		return "loot_table"
+	case has("values"):
+		return RefItemTag
+	case has("variants") || has("multipart"):
+		return "blockstate"
+	case has("parent") || has("textures") || has("elements"):
+		return "model"
+	case has("type"):
+		return "recipe"
+	}
+	return ""
+}
+
+// fileReferences returns the references one data or asset file makes
+func fileReferences(kind, source string, v interface{}) []Reference {
+	var refs []Reference
+	add := func(refKind, id string) {
+		if id == "" {
+			return
+		}
+		refs = append(refs, Reference{Kind: refKind, ID: normalizeResourceID(id), Source: source})
+	}
+	ingredient := func(id string) {
+		if tag, ok := strings.CutPrefix(id, "#"); ok {
+			add(RefItemTag, tag)
+		} else {
+			add(RefItem, id)
+		}
+	}
+
+	switch kind {
+	case "recipe":
+		walkRecipe(v, ingredient, add)
+	case "loot_table":
+		walkLootTable(v, ingredient, add)
+	case RefItemTag, RefBlockTag:
+		member := RefItem
+		if kind == RefBlockTag {
+			member = RefBlock
+		}
+		obj, _ := v.(map[string]interface{})
+		values, _ := obj["values"].([]interface{})
+		for _, value := range values {
+			id, _ := value.(string)
+			if entry, ok := value.(map[string]interface{}); ok {
+				if required, ok := entry["required"].(bool); ok && !required {
+					continue
+				}
+				id, _ = entry["id"].(string)
+			}
+			if tag, ok := strings.CutPrefix(id, "#"); ok {
+				add(kind, tag)
+			} else {
+				add(member, id)
+			}
+		}
+	case "model":
+		obj, _ := v.(map[string]interface{})
+		if parent, ok := obj["parent"].(string); ok && !strings.HasPrefix(parent, "builtin/") {
+			add(RefModel, parent)
+		}
+		textures, _ := obj["textures"].(map[string]interface{})
+		for _, key := range sortedKeys(textures) {
+			if texture, ok := textures[key].(string); ok && !strings.HasPrefix(texture, "#") {
+				add(RefTexture, texture)
+			}
+		}
+		overrides, _ := obj["overrides"].([]interface{})
+		for _, override := range overrides {
+			if o, ok := override.(map[string]interface{}); ok {
+				if model, ok := o["model"].(string); ok {
+					add(RefModel, model)
+				}
+			}
+		}
+	case "blockstate":
+		walkJSON(v, func(key string, value interface{}) {
+			if model, ok := value.(string); ok && key == "model" {
+				add(RefModel, model)
+			}
+		})
+	}
+	return refs
+}
+
+// walkRecipe finds the items and tags of a recipe: ingredient objects with an
+// item or tag, the ingredient strings of 1.21.2 and later, and the result
+func walkRecipe(v interface{}, ingredient func(string), add func(string, string)) {
+	walkJSON(v, func(key string, value interface{}) {
+		switch key {
+		case "item":
+			if id, ok := value.(string); ok {
+				add(RefItem, id)
+			}
+		case "tag":
+			if id, ok := value.(string); ok {
+				add(RefItemTag, id)
+			}
+		case "ingredient", "ingredients", "key", "base", "addition", "template":
+			forEachString(value, ingredient)
+		case "result":
+			switch result := value.(type) {
+			case string:
+				add(RefItem, result)
+			case map[string]interface{}:
+				if id, ok := result["id"].(string); ok {
+					add(RefItem, id)
+				}
+			}
+		}
+	})
+}
+
+// walkLootTable finds the item and tag entries of a loot table and the items
+// its match_tool conditions test for
+func walkLootTable(v interface{}, ingredient func(string), add func(string, string)) {
+	walkJSON(v, func(key string, value interface{}) {
+		if key == "items" {
+			forEachString(value, ingredient)
+		}
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		name, _ := obj["name"].(string)
+		switch entryType, _ := obj["type"].(string); normalizeResourceID(entryType) {
+		case "minecraft:item":
+			add(RefItem, name)
+		case "minecraft:tag":
+			add(RefItemTag, name)
+		}
+	})
+}
+
+// walkJSON calls visit for every key and value of every object and for every
+// array element, which has an empty key
+func walkJSON(v interface{}, visit func(key string, value interface{})) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			visit(key, v[key])
+			walkJSON(v[key], visit)
+		}
+	case []interface{}:
+		for _, element := range v {
+			visit("", element)
+			walkJSON(element, visit)
+		}
+	}
+}
+
+// forEachString calls fn for a string, or the strings directly inside an
+// array or object
+func forEachString(v interface{}, fn func(string)) {
+	switch v := v.(type) {
+	case string:
+		fn(v)
+	case []interface{}:
+		for _, element := range v {
+			if s, ok := element.(string); ok {
+				fn(s)
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			if s, ok := v[key].(string); ok {
+				fn(s)
+			}
+		}
+	}
+}
+
+// normalizeResourceID adds the default minecraft namespace to a bare ID
+func normalizeResourceID(id string) string {
+	if id == "" || strings.Contains(id, ":") {
+		return id
+	}
+	return "minecraft:" + id
+}
@@ -0,0 +1,575 @@
+package mods
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Change IDs name the units a user accepts or rejects when reviewing a
+// diff. They are the file's path inside the archive (empty for the file
+// itself), "#", then a JSON Pointer for JSON and NBT values, "hunk-N" for text hunks
+// or "file" for files that are only accepted whole: added, removed, binary
+// and plugin files.
+const wholeFileID = "file"
+
+// assignChangeIDs gives every reviewable unit of a diff its change ID
+func assignChangeIDs(d *Diff, scope string) {
+	if d.Status == ChangeUnchanged {
+		return
+	}
+	switch {
+	case d.Kind == DiffArchive:
+		for _, entry := range d.Entries {
+			assignChangeIDs(entry, entry.Path)
+		}
+	case d.Status != ChangeModified || d.Kind == DiffBinary || d.Kind == DiffPlugin || ((d.Kind == DiffJSON || d.Kind == DiffNBT) && d.Truncated):
+		d.ID = scope + "#" + wholeFileID
+	case d.Kind == DiffJSON, d.Kind == DiffNBT:
+		for i := range d.Changes {
+			d.Changes[i].ID = scope + "#" + d.Changes[i].Path
+		}
+	case d.Kind == DiffText:
+		for i := range d.Hunks {
+			d.Hunks[i].ID = fmt.Sprintf("%s#hunk-%d", scope, i)
+		}
+	}
+}
+
+// ChangeIDs lists the IDs of every reviewable change in the diff
+func (d *Diff) ChangeIDs() []string {
+	var ids []string
+	if d.ID != "" {
+		ids = append(ids, d.ID)
+	}
+	for _, c := range d.Changes {
+		if c.ID != "" {
+			ids = append(ids, c.ID)
+		}
+	}
+	for _, h := range d.Hunks {
+		if h.ID != "" {
+			ids = append(ids, h.ID)
+		}
+	}
+	for _, entry := range d.Entries {
+		ids = append(ids, entry.ChangeIDs()...)
+	}
+	return ids
+}
+
+// Apply builds the file that results from applying only the accepted
+// changes of the diff to the original. The diff must have been computed from
+// the same original and processed content. A nil result means the file is
+// absent, such as a rejected addition.
+func (d *Diff) Apply(original, processed []byte, accepted map[string]bool) ([]byte, error) {
+	ids := d.ChangeIDs()
+	count := 0
+	for _, id := range ids {
+		if accepted[id] {
+			count++
+		}
+	}
+	switch count {
+	case 0:
+		return original, nil
+	case len(ids):
+		return processed, nil
+	}
+
+	switch d.Kind {
+	case DiffArchive:
+		return applyArchive(d, original, processed, accepted)
+	case DiffJSON:
+		return applyJSON(d, original, processed, accepted)
+	case DiffNBT:
+		return applyNBT(d, original, processed, accepted)
+	case DiffText:
+		return applyHunks(d.Hunks, original, accepted), nil
+	}
+	return nil, fmt.Errorf("%s changes cannot be applied separately", d.Kind)
+}
+
+// BuildFromSelection diffs a file and applies only the accepted changes
+func BuildFromSelection(filename string, original, processed []byte, accepted map[string]bool) ([]byte, error) {
+	diff, err := DiffFiles(filename, original, processed)
+	if err != nil {
+		return nil, err
+	}
+	return diff.Apply(original, processed, accepted)
+}
+
+// applyArchive rebuilds the original archive with each changed entry built
+// from its accepted changes
+func applyArchive(d *Diff, original, processed []byte, accepted map[string]bool) ([]byte, error) {
+	oldArchive, err := OpenArchive(original, DefaultArchiveLimits)
+	if err != nil {
+		return nil, err
+	}
+	newArchive, err := OpenArchive(processed, DefaultArchiveLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string][]byte)
+	for _, entry := range d.Entries {
+		a, err := readEntryOrNil(oldArchive, entry.Path)
+		if err != nil {
+			return nil, err
+		}
+		b, err := readEntryOrNil(newArchive, entry.Path)
+		if err != nil {
+			return nil, err
+		}
+		result, err := entry.Apply(a, b, accepted)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Path, err)
+		}
+		if (a == nil && result == nil) || (a != nil && bytes.Equal(a, result)) {
+			continue
+		}
+		changes[entry.Path] = result
+	}
+	if len(changes) == 0 {
+		return original, nil
+	}
+	return RewriteArchive(original, changes)
+}
+
+// readEntryOrNil reads the named entry, returning nil when it is absent
+func readEntryOrNil(archive *Archive, name string) ([]byte, error) {
+	f := archive.File(name)
+	if f == nil {
+		return nil, nil
+	}
+	return archive.ReadFile(f)
+}
+
+// applyHunks rebuilds text from the original, taking the new lines of
+// accepted hunks and the old lines of the rest
+func applyHunks(hunks []Hunk, original []byte, accepted map[string]bool) []byte {
+	lines := splitLines(original)
+	var out bytes.Buffer
+	pos := 0
+	for _, hunk := range hunks {
+		start := hunk.OldStart
+		if hunk.OldLines > 0 {
+			start--
+		}
+		for _, line := range lines[pos:start] {
+			out.WriteString(line)
+		}
+		take := accepted[hunk.ID]
+		for _, line := range hunk.Lines {
+			if line.Op == " " || (line.Op == "+" && take) || (line.Op == "-" && !take) {
+				out.WriteString(line.Text)
+				if !line.NoNewline {
+					out.WriteByte('\n')
+				}
+			}
+		}
+		pos = start + hunk.OldLines
+	}
+	for _, line := range lines[pos:] {
+		out.WriteString(line)
+	}
+	return out.Bytes()
+}
+
+// orderedObject is a decoded JSON object that remembers its key order, so
+// documents rebuilt from a selection keep their layout
+type orderedObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *orderedObject) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *orderedObject) delete(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// decodeOrdered decodes a JSON document into orderedObjects, slices and
+// json.Number values
+func decodeOrdered(content []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var decode func() (interface{}, error)
+	decode = func() (interface{}, error) {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch token {
+		case json.Delim('{'):
+			object := &orderedObject{values: make(map[string]interface{})}
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decode()
+				if err != nil {
+					return nil, err
+				}
+				object.set(key.(string), value)
+			}
+			_, err := decoder.Token()
+			return object, err
+		case json.Delim('['):
+			array := []interface{}{}
+			for decoder.More() {
+				value, err := decode()
+				if err != nil {
+					return nil, err
+				}
+				array = append(array, value)
+			}
+			_, err := decoder.Token()
+			return array, err
+		}
+		return token, nil
+	}
+	return decode()
+}
+
+// encodeOrdered writes a decoded document as compact JSON without escaping HTML
+func encodeOrdered(buf *bytes.Buffer, v interface{}) error {
+	switch value := v.(type) {
+	case *orderedObject:
+		buf.WriteByte('{')
+		for i, key := range value.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeOrdered(buf, key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := encodeOrdered(buf, value.values[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range value {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeOrdered(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		encoder := json.NewEncoder(buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(value); err != nil {
+			return err
+		}
+		buf.Truncate(buf.Len() - 1) // Encode appends a newline
+	}
+	return nil
+}
+
+// parsePointer splits a JSON Pointer into its unescaped reference tokens
+func parsePointer(pointer string) []string {
+	if pointer == "" {
+		return nil
+	}
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens
+}
+
+// lookupPointer returns the value at a JSON Pointer
+func lookupPointer(doc interface{}, tokens []string) (interface{}, bool) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case *orderedObject:
+			value, ok := node.values[token]
+			if !ok {
+				return nil, false
+			}
+			doc = value
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			doc = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// updatePointer sets or, when remove is true, deletes the value at a JSON
+// Pointer. Array values past the end are appended. It returns the updated
+// document, since replacing the root or growing an array makes a new value.
+func updatePointer(doc interface{}, tokens []string, value interface{}, remove bool) (interface{}, error) {
+	if len(tokens) == 0 {
+		if remove {
+			return nil, fmt.Errorf("cannot remove the document root")
+		}
+		return value, nil
+	}
+
+	token, rest := tokens[0], tokens[1:]
+	switch node := doc.(type) {
+	case *orderedObject:
+		if len(rest) == 0 {
+			if remove {
+				node.delete(token)
+			} else {
+				node.set(token, value)
+			}
+			return node, nil
+		}
+		child, ok := node.values[token]
+		if !ok {
+			return nil, fmt.Errorf("path segment %q does not exist", token)
+		}
+		updated, err := updatePointer(child, rest, value, remove)
+		if err != nil {
+			return nil, err
+		}
+		node.values[token] = updated
+		return node, nil
+	case []interface{}:
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 {
+			return nil, fmt.Errorf("invalid array index %q", token)
+		}
+		if len(rest) == 0 {
+			switch {
+			case remove && i < len(node):
+				return append(node[:i], node[i+1:]...), nil
+			case remove:
+				return node, nil
+			case i < len(node):
+				node[i] = value
+				return node, nil
+			default:
+				return append(node, value), nil
+			}
+		}
+		if i >= len(node) {
+			return nil, fmt.Errorf("array index %d does not exist", i)
+		}
+		updated, err := updatePointer(node[i], rest, value, remove)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = updated
+		return node, nil
+	}
+	return nil, fmt.Errorf("path segment %q is not inside an object or array", token)
+}
+
+// applyJSON applies the accepted value changes to the original document.
+// Accepted values are taken from the processed document so their key order
+// is kept, and removals run last in reverse so array indices stay valid.
+func applyJSON(d *Diff, original, processed []byte, accepted map[string]bool) ([]byte, error) {
+	doc, err := decodeOrdered(original)
+	if err != nil {
+		return nil, fmt.Errorf("invalid original JSON: %w", err)
+	}
+	updated, err := decodeOrdered(processed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid processed JSON: %w", err)
+	}
+
+	var removals []Change
+	for _, change := range d.Changes {
+		if !accepted[change.ID] {
+			continue
+		}
+		if change.Kind == ChangeRemoved {
+			removals = append(removals, change)
+			continue
+		}
+		tokens := parsePointer(change.Path)
+		value, ok := lookupPointer(updated, tokens)
+		if !ok {
+			return nil, fmt.Errorf("%s is missing from the processed document", change.Path)
+		}
+		if doc, err = updatePointer(doc, tokens, value, false); err != nil {
+			return nil, fmt.Errorf("%s: %w", change.Path, err)
+		}
+	}
+	for i := len(removals) - 1; i >= 0; i-- {
+		if doc, err = updatePointer(doc, parsePointer(removals[i].Path), nil, true); err != nil {
+			return nil, fmt.Errorf("%s: %w", removals[i].Path, err)
+		}
+	}
+
+	return encodeOrderedLike(doc, original)
+}
+
+// encodeOrderedLike encodes an ordered document with the indentation and
+// trailing newline of the original it was decoded from
+func encodeOrderedLike(doc interface{}, original []byte) ([]byte, error) {
+	var compact bytes.Buffer
+	if err := encodeOrdered(&compact, doc); err != nil {
+		return nil, err
+	}
+	indent := jsonIndent(original)
+	if indent == "" {
+		return compact.Bytes(), nil
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, compact.Bytes(), "", indent); err != nil {
+		return nil, err
+	}
+	if bytes.HasSuffix(original, []byte("\n")) {
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// applyNBT applies the accepted tag changes to the original NBT file, keeping
+// its root name and compression. Structures must still be valid afterwards.
+func applyNBT(d *Diff, original, processed []byte, accepted map[string]bool) ([]byte, error) {
+	file, err := ReadNBT(original)
+	if err != nil {
+		return nil, fmt.Errorf("invalid original NBT: %w", err)
+	}
+	updated, err := ReadNBT(processed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid processed NBT: %w", err)
+	}
+
+	var removals []Change
+	for _, change := range d.Changes {
+		if !accepted[change.ID] {
+			continue
+		}
+		if change.Kind == ChangeRemoved {
+			removals = append(removals, change)
+			continue
+		}
+		tokens := parsePointer(change.Path)
+		value, ok := lookupNBT(updated.Root, tokens)
+		if !ok {
+			return nil, fmt.Errorf("%s is missing from the processed file", change.Path)
+		}
+		if err := updateNBT(file.Root, tokens, value, false); err != nil {
+			return nil, fmt.Errorf("%s: %w", change.Path, err)
+		}
+	}
+	for i := len(removals) - 1; i >= 0; i-- {
+		if err := updateNBT(file.Root, parsePointer(removals[i].Path), nil, true); err != nil {
+			return nil, fmt.Errorf("%s: %w", removals[i].Path, err)
+		}
+	}
+
+	if err := checkNBTLists(file.Root, ""); err != nil {
+		return nil, fmt.Errorf("accepted changes: %w", err)
+	}
+	if NBTKind(file.Root) == NBTKindStructure {
+		if _, err := ParseNBTStructure(file.Root); err != nil {
+			return nil, fmt.Errorf("accepted changes leave the structure invalid: %w", err)
+		}
+	}
+	return file.Encode()
+}
+
+// lookupNBT returns the tag at a JSON Pointer into an NBT tree
+func lookupNBT(v interface{}, tokens []string) (interface{}, bool) {
+	for _, token := range tokens {
+		switch node := v.(type) {
+		case *NBTCompound:
+			value, ok := node.Get(token)
+			if !ok {
+				return nil, false
+			}
+			v = value
+		case *NBTList:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node.Items) {
+				return nil, false
+			}
+			v = node.Items[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// updateNBT sets or, when remove is true, deletes the tag at a JSON Pointer
+// into an NBT tree. List items past the end are appended.
+func updateNBT(root *NBTCompound, tokens []string, value interface{}, remove bool) error {
+	if len(tokens) == 0 {
+		return fmt.Errorf("cannot replace the root compound")
+	}
+	parent, ok := lookupNBT(root, tokens[:len(tokens)-1])
+	if !ok {
+		return fmt.Errorf("parent tag does not exist")
+	}
+	token := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case *NBTCompound:
+		if remove {
+			node.Delete(token)
+		} else {
+			node.Set(token, value)
+		}
+		return nil
+	case *NBTList:
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i > len(node.Items) || (i == len(node.Items) && remove) {
+			return fmt.Errorf("list index %q is out of range", token)
+		}
+		switch {
+		case remove:
+			node.Items = append(node.Items[:i], node.Items[i+1:]...)
+		case i == len(node.Items):
+			node.Items = append(node.Items, value)
+		default:
+			node.Items[i] = value
+		}
+		if len(node.Items) == 1 && !remove {
+			node.Type, _ = nbtTagOf(value) // the list may have been empty
+		}
+		return nil
+	}
+	return fmt.Errorf("path segment %q is not inside a compound or list", token)
+}
+
+// jsonIndent detects the indentation of a JSON document, returning an empty
+// string for compact documents
+func jsonIndent(content []byte) string {
+	lines := strings.Split(string(content), "\n")
+	for _, line := range lines[min(1, len(lines)):] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) < len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	if len(lines) > 1 {
+		return "  "
+	}
+	return ""
+}
@@ -0,0 +1,591 @@
+package mods
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Rule operations
+const (
+	RuleSet      = "set"      // replace a value
+	RuleMultiply = "multiply" // multiply a number
+	RuleAdd      = "add"      // add to a number
+	RuleMin      = "min"      // cap a number at Value
+	RuleMax      = "max"      // raise a number to at least Value
+)
+
+// Rule is a declarative edit applied without AI. Path selects values with
+// dotted keys, [n] indexes, * for any one key or index and ** for any depth,
+// such as "result.count" or "**.durability". Config files are selected by
+// their setting keys. In JSON, a number op with a Default also adds the key
+// to every selected object that lacks it, starting from the default.
+type Rule struct {
+	Files   string      `json:"files,omitempty"` // glob of archive entries to edit, where ** spans folders; every entry when empty
+	Path    string      `json:"path"`
+	Op      string      `json:"op"`
+	Value   interface{} `json:"value"`
+	Default interface{} `json:"default,omitempty"` // number a missing JSON key is taken to be
+}
+
+// RuleChange is a value a rule changed
+type RuleChange struct {
+	File string `json:"file,omitempty"` // archive entry
+	Path string `json:"path"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// String formats a change for a changelog
+func (c RuleChange) String() string {
+	if c.File != "" {
+		return fmt.Sprintf("%s: %s: %s -> %s", c.File, c.Path, c.Old, c.New)
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Path, c.Old, c.New)
+}
+
+// ParseRules reads a JSON array of rules and checks each one
+func ParseRules(data []byte) ([]Rule, error) {
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+	if err := ValidateRules(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ValidateRules checks that every rule has a valid selector, operation and
+// value
+func ValidateRules(rules []Rule) error {
+	if len(rules) == 0 {
+		return fmt.Errorf("no rules given")
+	}
+	for i, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (r Rule) validate() error {
+	steps, err := parseSelector(r.Path)
+	if err != nil {
+		return err
+	}
+	if _, err := path.Match(r.Files, ""); err != nil {
+		return fmt.Errorf("invalid files pattern %q", r.Files)
+	}
+	switch r.Op {
+	case RuleSet:
+		switch r.Value.(type) {
+		case string, float64, bool:
+		default:
+			return fmt.Errorf("set needs a string, number or boolean value")
+		}
+	case RuleMultiply, RuleAdd, RuleMin, RuleMax:
+		if _, ok := r.Value.(float64); !ok {
+			return fmt.Errorf("%s needs a number value", r.Op)
+		}
+	default:
+		return fmt.Errorf("unknown op %q; use set, multiply, add, min or max", r.Op)
+	}
+	if r.Default != nil {
+		if _, ok := r.Default.(float64); !ok || r.Op == RuleSet {
+			return fmt.Errorf("default needs a number and a multiply, add, min or max op")
+		}
+		if steps[len(steps)-1].kind != stepKey {
+			return fmt.Errorf("default needs a path ending in a key")
+		}
+		for _, step := range steps {
+			if step.kind == stepDeep {
+				return fmt.Errorf("default cannot be used with ** in the path")
+			}
+		}
+	}
+	return nil
+}
+
+// Selector step kinds
+const (
+	stepKey = iota
+	stepIndex
+	stepAny      // * matches one key or index
+	stepAnyIndex // [*] matches one index
+	stepDeep     // ** matches any number of keys and indexes
+)
+
+type selectorStep struct {
+	kind  int
+	key   string
+	index int
+}
+
+// parseSelector reads a rule path such as "pools[*].entries[0].weight" or
+// `**["minecraft:count"]`. A leading "$." is allowed.
+func parseSelector(s string) ([]selectorStep, error) {
+	if s == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	rest := strings.TrimPrefix(strings.TrimPrefix(s, "$"), ".")
+	var steps []selectorStep
+	for rest != "" {
+		if rest[0] == '[' {
+			end := strings.IndexByte(rest, ']')
+			if len(rest) > 1 && (rest[1] == '"' || rest[1] == '\'') {
+				end = 1 + scanQuoted(rest[1:])
+				if end >= len(rest) || rest[end] != ']' {
+					return nil, fmt.Errorf("path %q has an unclosed [", s)
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("path %q has an unclosed [", s)
+			}
+			inner := rest[1:end]
+			switch {
+			case inner == "*":
+				steps = append(steps, selectorStep{kind: stepAnyIndex})
+			case len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\''):
+				steps = append(steps, selectorStep{kind: stepKey, key: unquoteConfigKey(inner)})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("path %q has an invalid index [%s]", s, inner)
+				}
+				steps = append(steps, selectorStep{kind: stepIndex, index: n})
+			}
+			rest = rest[end+1:]
+		} else {
+			end := strings.IndexAny(rest, ".[]")
+			if end == 0 && rest[0] == ']' {
+				end = 1
+			}
+			if end < 0 {
+				end = len(rest)
+			}
+			switch name := rest[:end]; name {
+			case "":
+				return nil, fmt.Errorf("path %q has an empty key", s)
+			case "]":
+				return nil, fmt.Errorf("path %q has an unopened ]", s)
+			case "*":
+				steps = append(steps, selectorStep{kind: stepAny})
+			case "**":
+				steps = append(steps, selectorStep{kind: stepDeep})
+			default:
+				steps = append(steps, selectorStep{kind: stepKey, key: name})
+			}
+			rest = rest[end:]
+		}
+		if strings.HasPrefix(rest, ".") {
+			if rest = rest[1:]; rest == "" {
+				return nil, fmt.Errorf("path %q ends with a period", s)
+			}
+		} else if rest != "" && rest[0] != '[' {
+			return nil, fmt.Errorf("path %q has unexpected %q", s, rest)
+		}
+	}
+	return steps, nil
+}
+
+// matchSelector reports whether a value's path of keys (strings) and
+// indexes (ints) is selected
+func matchSelector(steps []selectorStep, p []interface{}) bool {
+	if len(steps) == 0 {
+		return len(p) == 0
+	}
+	step := steps[0]
+	if step.kind == stepDeep {
+		for i := 0; i <= len(p); i++ {
+			if matchSelector(steps[1:], p[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(p) == 0 {
+		return false
+	}
+	switch seg := p[0].(type) {
+	case string:
+		if step.kind != stepAny && (step.kind != stepKey || step.key != seg) {
+			return false
+		}
+	case int:
+		if step.kind != stepAny && step.kind != stepAnyIndex && (step.kind != stepIndex || step.index != seg) {
+			return false
+		}
+	}
+	return matchSelector(steps[1:], p[1:])
+}
+
+// matchFiles reports whether an archive entry matches a files pattern. Each
+// folder is matched with path.Match, and a ** folder matches any number of
+// folders, so "data/*/recipe/**/*.json" includes nested recipes.
+func matchFiles(pattern, name string) bool {
+	return matchFileParts(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchFileParts(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchFileParts(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchFileParts(pattern[1:], name[1:])
+}
+
+// applyRuleNumber computes a rule's result for a number as written. Integers
+// stay integers, rounding half away from zero, and decimals keep a decimal
+// point. ok is false for text that is not a plain number.
+func applyRuleNumber(rule Rule, written string) (string, bool) {
+	current, err := strconv.ParseFloat(written, 64)
+	if err != nil || math.IsInf(current, 0) || math.IsNaN(current) || strings.ContainsAny(written, "xX_") {
+		return "", false
+	}
+	value, _ := rule.Value.(float64)
+	switch rule.Op {
+	case RuleMultiply:
+		current *= value
+	case RuleAdd:
+		current += value
+	case RuleMin:
+		current = math.Min(current, value)
+	case RuleMax:
+		current = math.Max(current, value)
+	}
+	if !strings.ContainsAny(written, ".eE") {
+		return strconv.FormatInt(int64(math.Round(current)), 10), true
+	}
+	s := strconv.FormatFloat(math.Round(current*1e6)/1e6, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s, true
+}
+
+// formatRuleValue writes a set rule's value as a JSON or config scalar
+func formatRuleValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(value)
+}
+
+// ApplyRules applies rules in order to a JSON file, a config file or the
+// JSON and config entries of an archive. JSON keeps its key order and
+// indentation, config values are written into the original text, and
+// content nothing matched is returned as is.
+func ApplyRules(filename string, content []byte, rules []Rule) ([]byte, []RuleChange, error) {
+	if err := ValidateRules(rules); err != nil {
+		return nil, nil, err
+	}
+	format, err := FormatFor(filename, content)
+	if err != nil {
+		return nil, nil, err
+	}
+	if format.Info().Container {
+		return applyRulesToArchive(content, rules)
+	}
+	output, changes, ok, err := applyRulesToFile(format, content, rules)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("rules cannot edit %s files", format.Info().Name)
+	}
+	return output, changes, nil
+}
+
+// applyRulesToFile applies rules to a JSON or config file; ok is false for
+// other formats
+func applyRulesToFile(format Format, content []byte, rules []Rule) ([]byte, []RuleChange, bool, error) {
+	switch f := format.(type) {
+	case jsonFormat:
+		output, changes, err := applyRulesToJSON(content, rules)
+		return output, changes, true, err
+	case configFormat:
+		output, changes, err := applyRulesToConfig(f.syntax, content, rules)
+		return output, changes, true, err
+	}
+	return nil, nil, false, nil
+}
+
+// applyRulesToArchive applies to each entry the rules whose files pattern
+// matches it. Entries of other formats are left alone.
+func applyRulesToArchive(content []byte, rules []Rule) ([]byte, []RuleChange, error) {
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return nil, nil, err
+	}
+	edits := make(map[string][]byte)
+	var changes []RuleChange
+	for _, f := range archive.Files() {
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "META-INF/") {
+			continue
+		}
+		var matched []Rule
+		for _, rule := range rules {
+			if rule.Files == "" || matchFiles(rule.Files, f.Name) {
+				matched = append(matched, rule)
+			}
+		}
+		if len(matched) == 0 || len(FormatsForExtension(f.Name)) == 0 {
+			continue
+		}
+		data, err := archive.ReadFile(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		format, err := FormatFor(f.Name, data)
+		if err != nil {
+			continue
+		}
+		output, entryChanges, ok, err := applyRulesToFile(format, data, matched)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		if !ok || len(entryChanges) == 0 {
+			continue
+		}
+		edits[f.Name] = output
+		for _, change := range entryChanges {
+			change.File = f.Name
+			changes = append(changes, change)
+		}
+	}
+	if len(edits) == 0 {
+		return content, nil, nil
+	}
+	output, err := RewriteArchive(content, edits)
+	if err != nil {
+		return nil, nil, err
+	}
+	return output, changes, nil
+}
+
+// applyRulesToJSON applies rules to the scalar values of a JSON document
+func applyRulesToJSON(content []byte, rules []Rule) ([]byte, []RuleChange, error) {
+	doc, err := decodeOrdered(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	var changes []RuleChange
+	for _, rule := range rules {
+		steps, _ := parseSelector(rule.Path)
+		doc = rewriteJSONScalars(doc, nil, func(p []interface{}, value interface{}) interface{} {
+			if !matchSelector(steps, p) {
+				return value
+			}
+			var updated interface{}
+			switch v := value.(type) {
+			case json.Number:
+				if rule.Op == RuleSet {
+					if _, ok := rule.Value.(float64); !ok {
+						return value // a set may not turn a number into text
+					}
+					updated = json.Number(formatRuleValue(rule.Value))
+				} else if s, ok := applyRuleNumber(rule, v.String()); ok {
+					updated = json.Number(s)
+				}
+			case string:
+				if s, ok := rule.Value.(string); ok && rule.Op == RuleSet {
+					updated = s
+				}
+			case bool:
+				if b, ok := rule.Value.(bool); ok && rule.Op == RuleSet {
+					updated = b
+				}
+			}
+			if updated == nil || updated == value {
+				return value
+			}
+			before, _ := json.Marshal(value)
+			after, _ := json.Marshal(updated)
+			changes = append(changes, RuleChange{Path: jsonPointer(p), Old: string(before), New: string(after)})
+			return updated
+		})
+		if rule.Default != nil {
+			changes = append(changes, insertRuleDefaults(doc, nil, rule, steps)...)
+		}
+	}
+	if len(changes) == 0 {
+		return content, nil, nil
+	}
+	output, err := encodeOrderedLike(doc, content)
+	if err != nil {
+		return nil, nil, err
+	}
+	return output, changes, nil
+}
+
+// insertRuleDefaults adds a rule's key, computed from its default, to each
+// object its path selects the parent of that does not have the key yet
+func insertRuleDefaults(node interface{}, p []interface{}, rule Rule, steps []selectorStep) []RuleChange {
+	var changes []RuleChange
+	switch v := node.(type) {
+	case *orderedObject:
+		key := steps[len(steps)-1].key
+		if _, ok := v.values[key]; !ok && matchSelector(steps[:len(steps)-1], p) {
+			start := formatRuleValue(rule.Default)
+			if updated, ok := applyRuleNumber(rule, start); ok {
+				v.set(key, json.Number(updated))
+				changes = append(changes, RuleChange{Path: jsonPointer(withSegment(p, key)), Old: start + " (default)", New: updated})
+			}
+		}
+		for _, k := range v.keys {
+			changes = append(changes, insertRuleDefaults(v.values[k], withSegment(p, k), rule, steps)...)
+		}
+	case []interface{}:
+		for i := range v {
+			changes = append(changes, insertRuleDefaults(v[i], withSegment(p, i), rule, steps)...)
+		}
+	}
+	return changes
+}
+
+// rewriteJSONScalars replaces each scalar of an ordered document with the
+// result of visit
+func rewriteJSONScalars(node interface{}, p []interface{}, visit func(p []interface{}, value interface{}) interface{}) interface{} {
+	switch v := node.(type) {
+	case *orderedObject:
+		for _, key := range v.keys {
+			v.values[key] = rewriteJSONScalars(v.values[key], withSegment(p, key), visit)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = rewriteJSONScalars(v[i], withSegment(p, i), visit)
+		}
+		return v
+	}
+	return visit(p, node)
+}
+
+// jsonPointer writes a value path as a JSON Pointer
+func jsonPointer(p []interface{}) string {
+	var b strings.Builder
+	for _, seg := range p {
+		b.WriteByte('/')
+		b.WriteString(jsonPointerEscaper.Replace(fmt.Sprint(seg)))
+	}
+	return b.String()
+}
+
+// configKeyPath splits a setting key such as "stress[0].max speed" into keys
+// and indexes
+func configKeyPath(key string) []interface{} {
+	var p []interface{}
+	for _, part := range strings.Split(key, ".") {
+		var indexes []interface{}
+		for strings.HasSuffix(part, "]") {
+			open := strings.LastIndexByte(part, '[')
+			n, err := strconv.Atoi(part[open+1 : len(part)-1])
+			if open < 0 || err != nil {
+				break
+			}
+			indexes = append([]interface{}{n}, indexes...)
+			part = part[:open]
+		}
+		if part != "" {
+			p = append(p, part)
+		}
+		p = append(p, indexes...)
+	}
+	return p
+}
+
+// applyRulesToConfig applies rules to the number, boolean and string
+// settings of a config file, writing each value in its original style
+func applyRulesToConfig(syntax string, content []byte, rules []Rule) ([]byte, []RuleChange, error) {
+	edited := content
+	for _, rule := range rules {
+		file, err := ParseConfig(syntax, edited)
+		if err != nil {
+			return nil, nil, err
+		}
+		steps, _ := parseSelector(rule.Path)
+		var out strings.Builder
+		last := 0
+		for _, e := range file.Entries {
+			if !matchSelector(steps, configKeyPath(e.Key)) {
+				continue
+			}
+			updated, ok := applyRuleConfigValue(syntax, rule, e)
+			if !ok || updated == e.Value {
+				continue
+			}
+			out.Write(edited[last:e.start])
+			out.WriteString(updated)
+			last = e.end
+		}
+		if last > 0 {
+			out.Write(edited[last:])
+			edited = []byte(out.String())
+		}
+	}
+
+	output, configChanges, err := EditConfigValues(syntax, content, edited)
+	if err != nil {
+		return nil, nil, err
+	}
+	var changes []RuleChange
+	for _, c := range configChanges {
+		changes = append(changes, RuleChange{Path: c.Key, Old: c.Old, New: c.New})
+	}
+	return output, changes, nil
+}
+
+// applyRuleConfigValue computes a rule's result for a setting as written, or
+// returns false if the rule does not apply to the setting's kind
+func applyRuleConfigValue(syntax string, rule Rule, e ConfigEntry) (string, bool) {
+	if rule.Op != RuleSet {
+		if e.Kind != ConfigNumber {
+			return "", false
+		}
+		return applyRuleNumber(rule, e.Value)
+	}
+	switch value := rule.Value.(type) {
+	case float64:
+		if e.Kind == ConfigNumber {
+			return formatRuleValue(value), true
+		}
+	case bool:
+		if e.Kind == ConfigBool {
+			return formatRuleValue(value), true
+		}
+	case string:
+		switch {
+		case syntax == ConfigYAML && (e.Kind == ConfigString || e.Kind == ConfigText) && e.Value[0] != '|' && e.Value[0] != '>':
+			return quoteYAMLString(value, e.Value), true
+		case syntax == ConfigTOML && e.Kind == ConfigString:
+			if e.Value[0] == '\'' && !strings.ContainsAny(value, "'\n") {
+				return "'" + value + "'", true // literal strings cannot escape
+			}
+			return strconv.Quote(value), true
+		case (syntax == ConfigProperties || syntax == ConfigCfg) && e.Kind == ConfigText && !strings.Contains(value, "\n"):
+			return value, true
+		}
+	}
+	return "", false
+}
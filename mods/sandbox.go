@@ -0,0 +1,808 @@
+package mods
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/ast"
+	"github.com/yuin/gopher-lua/pm"
+)
+
+// LuaLimits bound what a script may use while it loads in the sandbox
+type LuaLimits struct {
+	Instructions int64         // VM instructions
+	Memory       uint64        // bytes of strings and tables the script builds
+	Timeout      time.Duration // wall-clock time, enforced even inside a library call
+	CallStack    int           // nested calls
+	Registry     int           // value stack slots
+	StringLength int           // longest single string a script may build
+}
+
+// DefaultLuaLimits are generous for a mod's load-time code and stop runaway loops
+var DefaultLuaLimits = LuaLimits{
+	Instructions: 10_000_000,
+	Memory:       64 * 1024 * 1024,
+	Timeout:      5 * time.Second,
+	CallStack:    200,
+	Registry:     256 * 1024,
+	StringLength: 1024 * 1024,
+}
+
+// luaConcatGlobal and luaTableGlobal are the globals the sandbox routes the
+// .. operator and table constructors through. They are not valid Lua names,
+// so scripts cannot shadow them by accident.
+const (
+	luaConcatGlobal = "\x00concat"
+	luaTableGlobal  = "\x00table"
+)
+
+// Estimated sizes of the values a script allocates, charged to its memory
+const (
+	luaTableSize = 160 // an empty table
+	luaSlotSize  = 16  // one array or hash slot
+	luaMatchSize = 64  // one pattern match gsub collects
+)
+
+// maxRecordedArgs is the number of arguments recorded per game API call
+const maxRecordedArgs = 2
+
+var (
+	errInstructionLimit = errors.New("instruction limit exceeded")
+	errMemoryLimit      = errors.New("memory limit exceeded")
+	errTimeLimit        = errors.New("time limit exceeded")
+)
+
+// LuaLoadResult is what a script does when it loads: the error it raises, the
+// globals and module fields it defines, and the game API calls it makes, such
+// as hook.Add("Think", "hud") or data.extend
+type LuaLoadResult struct {
+	Error   string   `json:"error,omitempty"`
+	Globals []string `json:"globals"`
+	Exports []string `json:"exports"` // keys of the table the script returns
+	Calls   []string `json:"calls"`
+}
+
+// String describes a load result for a changelog or error message
+func (r *LuaLoadResult) String() string {
+	if r.Error != "" {
+		return "error: " + r.Error
+	}
+	return fmt.Sprintf("%d globals, %d exports, %d API calls", len(r.Globals), len(r.Exports), len(r.Calls))
+}
+
+// LuaSandboxReport compares loading an original script with an edited one
+type LuaSandboxReport struct {
+	Original       *LuaLoadResult `json:"original"`
+	Edited         *LuaLoadResult `json:"edited"`
+	RemovedGlobals []string       `json:"removed_globals,omitempty"`
+	AddedGlobals   []string       `json:"added_globals,omitempty"`
+	RemovedExports []string       `json:"removed_exports,omitempty"`
+	AddedExports   []string       `json:"added_exports,omitempty"`
+	RemovedCalls   []string       `json:"removed_calls,omitempty"`
+	AddedCalls     []string       `json:"added_calls,omitempty"`
+}
+
+// Problems lists what the edit broke: a load error the original did not
+// have, and definitions or registrations it no longer makes
+func (r *LuaSandboxReport) Problems() []string {
+	var problems []string
+	if r.Edited.Error != "" && r.Original.Error == "" {
+		problems = append(problems, "fails to load: "+r.Edited.Error)
+	}
+	if len(r.RemovedGlobals) > 0 {
+		problems = append(problems, "no longer defines "+strings.Join(r.RemovedGlobals, ", "))
+	}
+	if len(r.RemovedExports) > 0 {
+		problems = append(problems, "no longer exports "+strings.Join(r.RemovedExports, ", "))
+	}
+	if len(r.RemovedCalls) > 0 {
+		problems = append(problems, "no longer calls "+strings.Join(r.RemovedCalls, ", "))
+	}
+	return problems
+}
+
+// CompareLuaLoad loads an original and an edited script in the sandbox and
+// reports how they differ
+func CompareLuaLoad(original, edited []byte, limits LuaLimits) *LuaSandboxReport {
+	report := &LuaSandboxReport{
+		Original: LoadLua(original, limits),
+		Edited:   LoadLua(edited, limits),
+	}
+	report.RemovedGlobals, report.AddedGlobals = diffNames(report.Original.Globals, report.Edited.Globals)
+	report.RemovedExports, report.AddedExports = diffNames(report.Original.Exports, report.Edited.Exports)
+	report.RemovedCalls, report.AddedCalls = diffNames(report.Original.Calls, report.Edited.Calls)
+	return report
+}
+
+// diffNames returns the names only in a and the names only in b
+func diffNames(a, b []string) (removed, added []string) {
+	inA := make(map[string]bool, len(a))
+	for _, name := range a {
+		inA[name] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, name := range b {
+		inB[name] = true
+		if !inA[name] {
+			added = append(added, name)
+		}
+	}
+	for _, name := range a {
+		if !inB[name] {
+			removed = append(removed, name)
+		}
+	}
+	return removed, added
+}
+
+// LoadLua runs a script's top level in a sandbox without io, os, file loading
+// or network access. Globals the sandbox does not define resolve to a stub
+// game API that accepts any field access or call and records the calls.
+func LoadLua(content []byte, limits LuaLimits) *LuaLoadResult {
+	result := &LuaLoadResult{Globals: []string{}, Exports: []string{}, Calls: []string{}}
+	// Normalised Luau would run a += b as a = b, so only plain Lua loads
+	chunk, _, err := parseLua(content, false)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	routeLuaAllocations(chunk)
+	proto, err := lua.Compile(chunk, "script")
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	// The VM only checks its context between instructions, so a slow library
+	// call is cut off here. Its goroutine runs until the call returns and then
+	// stops at the next instruction, since the context has expired by then.
+	done := make(chan *LuaLoadResult, 1)
+	go func() { done <- runLua(proto, limits) }()
+	timer := time.NewTimer(limits.Timeout)
+	defer timer.Stop()
+	select {
+	case result := <-done:
+		return result
+	case <-timer.C:
+		result.Error = errTimeLimit.Error()
+		return result
+	}
+}
+
+// runLua runs a compiled script in a new sandboxed state
+func runLua(proto *lua.FunctionProto, limits LuaLimits) *LuaLoadResult {
+	result := &LuaLoadResult{Globals: []string{}, Exports: []string{}, Calls: []string{}}
+
+	L := lua.NewState(lua.Options{
+		SkipOpenLibs:    true,
+		CallStackSize:   limits.CallStack,
+		RegistrySize:    1024,
+		RegistryMaxSize: limits.Registry,
+	})
+	defer L.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), limits.Timeout)
+	defer cancel()
+	budget := &luaBudget{Context: ctx, remaining: limits.Instructions, memory: limits.Memory}
+
+	sandbox := &luaSandbox{L: L, budget: budget, limits: limits, calls: make(map[string]bool), stubs: make(map[string]*lua.LTable), paths: make(map[*lua.LTable]string)}
+	sandbox.openLibs()
+	sandbox.stubGlobals()
+	builtins := make(map[string]bool)
+	L.G.Global.ForEach(func(key, _ lua.LValue) { builtins[key.String()] = true })
+	L.SetContext(budget)
+
+	L.Push(L.NewFunctionFromProto(proto))
+	if err := L.PCall(0, 1, nil); err != nil {
+		result.Error = luaErrorMessage(err, budget)
+	} else if exports, ok := L.Get(-1).(*lua.LTable); ok {
+		exports.ForEach(func(key, _ lua.LValue) {
+			if key.Type() == lua.LTString {
+				result.Exports = append(result.Exports, key.String())
+			}
+		})
+	}
+
+	L.G.Global.ForEach(func(key, _ lua.LValue) {
+		if !builtins[key.String()] {
+			result.Globals = append(result.Globals, key.String())
+		}
+	})
+	result.Calls = sortedKeys(sandbox.calls)
+	sort.Strings(result.Globals)
+	sort.Strings(result.Exports)
+	return result
+}
+
+// luaErrorMessage returns a Lua error without its Go stack trace, naming the
+// limit the script hit if any
+func luaErrorMessage(err error, budget *luaBudget) string {
+	if budget.err != nil {
+		return budget.err.Error()
+	}
+	if errors.Is(budget.Context.Err(), context.DeadlineExceeded) {
+		return errTimeLimit.Error()
+	}
+	var apiErr *lua.ApiError
+	if errors.As(err, &apiErr) && apiErr.Object != nil {
+		return apiErr.Object.String()
+	}
+	return err.Error()
+}
+
+// luaBudget is a context that counts VM instructions: gopher-lua checks
+// Done before each instruction it executes. It also totals the bytes of the
+// strings the script builds.
+type luaBudget struct {
+	context.Context
+	remaining int64
+	memory    uint64
+	allocated uint64
+	err       error
+}
+
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+func (b *luaBudget) Done() <-chan struct{} {
+	if b.err != nil {
+		return closedChan
+	}
+	b.remaining--
+	if b.remaining < 0 {
+		b.err = errInstructionLimit
+		return closedChan
+	}
+	return b.Context.Done()
+}
+
+// allocate counts n more bytes against the memory limit
+func (b *luaBudget) allocate(n uint64) bool {
+	if n > b.memory-b.allocated {
+		b.err = errMemoryLimit
+		return false
+	}
+	b.allocated += n
+	return true
+}
+
+func (b *luaBudget) Err() error {
+	if b.err != nil {
+		return b.err
+	}
+	return b.Context.Err()
+}
+
+// luaSandbox holds the stub game API of one state
+type luaSandbox struct {
+	L      *lua.LState
+	budget *luaBudget
+	limits LuaLimits
+	calls  map[string]bool
+	stubs  map[string]*lua.LTable
+	paths  map[*lua.LTable]string
+	meta   *lua.LTable
+}
+
+// openLibs opens the safe standard libraries: no io, os, debug or package,
+// and no way to load files. Coroutines are left out because their threads
+// would run outside the instruction budget.
+func (s *luaSandbox) openLibs() {
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		s.L.Push(s.L.NewFunction(lib.open))
+		s.L.Push(lua.LString(lib.name))
+		s.L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile", "collectgarbage", "module", "_printregs", "newproxy", "require"} {
+		s.L.SetGlobal(name, lua.LNil)
+	}
+	s.L.SetGlobal("print", s.L.NewFunction(func(*lua.LState) int { return 0 }))
+
+	s.L.SetGlobal(luaConcatGlobal, s.L.NewFunction(s.concat))
+	s.L.SetGlobal(luaTableGlobal, s.L.NewFunction(s.table))
+
+	// Each function that builds a string is charged for the longest result
+	// it can return before it runs. Growing a table by assignment is left to
+	// the instruction limit.
+	for _, name := range []string{"upper", "lower", "reverse"} {
+		s.bound(lua.StringLibName, name, func(L *lua.LState) int64 {
+			return int64(len(L.CheckString(1)))
+		})
+	}
+	s.bound(lua.StringLibName, "char", func(L *lua.LState) int64 {
+		return int64(L.GetTop())
+	})
+	s.bound(lua.TabLibName, "insert", func(L *lua.LState) int64 {
+		return luaSlotSize
+	})
+	gsub := s.L.GetField(s.L.GetGlobal(lua.StringLibName), "gsub")
+	s.L.SetField(s.L.GetGlobal(lua.StringLibName), "gsub", s.L.NewFunction(func(L *lua.LState) int {
+		return s.gsub(L, gsub)
+	}))
+	s.bound(lua.StringLibName, "rep", func(L *lua.LState) int64 {
+		count := L.CheckInt(2)
+		if count <= 0 {
+			return 0
+		}
+		return luaProduct(len(L.CheckString(1)), count)
+	})
+	s.bound(lua.StringLibName, "format", func(L *lua.LState) int64 {
+		args := make([]lua.LValue, 0, L.GetTop())
+		for i := 2; i <= L.GetTop(); i++ {
+			args = append(args, L.Get(i))
+		}
+		return luaFormatBound(L.CheckString(1), args)
+	})
+	s.bound(lua.TabLibName, "concat", func(L *lua.LState) int64 {
+		table := L.CheckTable(1)
+		sep := len(L.OptString(2, ""))
+		first, last := L.OptInt(3, 1), L.OptInt(4, table.Len())
+		if first < 1 {
+			first = 1
+		}
+		if last > table.Len() {
+			last = table.Len()
+		}
+		var size int64
+		for i := first; i <= last; i++ {
+			if value := table.RawGetInt(i); lua.LVCanConvToString(value) {
+				size += int64(len(lua.LVAsString(value)))
+			}
+		}
+		if last > first {
+			size += luaProduct(sep, last-first)
+		}
+		return size
+	})
+}
+
+// bound replaces a library function with one that first charges the size
+// its result can reach
+func (s *luaSandbox) bound(lib, name string, size func(L *lua.LState) int64) {
+	table := s.L.GetGlobal(lib)
+	fn := s.L.GetField(table, name)
+	s.L.SetField(table, name, s.L.NewFunction(func(L *lua.LState) int {
+		s.allocate(L, lib+"."+name, size(L))
+		top := L.GetTop()
+		L.Push(fn)
+		for i := 1; i <= top; i++ {
+			L.Push(L.Get(i))
+		}
+		L.Call(top, 1)
+		return 1
+	}))
+}
+
+// allocate charges a string of n bytes to the budget, raising a Lua error
+// when it is too long or the script has used up its memory
+func (s *luaSandbox) allocate(L *lua.LState, op string, n int64) {
+	if n > int64(s.limits.StringLength) {
+		L.RaiseError("%s result longer than %d bytes", op, s.limits.StringLength)
+	}
+	s.charge(L, n)
+}
+
+// charge counts n bytes against the memory limit
+func (s *luaSandbox) charge(L *lua.LState, n int64) {
+	if !s.budget.allocate(uint64(n)) {
+		L.RaiseError("%s", errMemoryLimit.Error())
+	}
+}
+
+// table charges a table built by a constructor and returns it
+func (s *luaSandbox) table(L *lua.LState) int {
+	table := L.CheckTable(1)
+	slots := 0
+	table.ForEach(func(_, _ lua.LValue) { slots++ })
+	s.charge(L, luaTableSize+int64(slots)*luaSlotSize)
+	L.Push(table)
+	return 1
+}
+
+// gsub charges string.gsub for its matches and result before replacing. A
+// string replacement is measured up front; values from a function or table
+// are charged as each one is returned.
+func (s *luaSandbox) gsub(L *lua.LState, gsub lua.LValue) int {
+	str := L.CheckString(1)
+	pattern := L.CheckString(2)
+	L.CheckTypes(3, lua.LTString, lua.LTTable, lua.LTFunction)
+	limit := L.OptInt(4, -1)
+	n := L.Get(4)
+
+	// Collecting matches allocates too, so stop once they alone would
+	// exceed the memory left
+	room := int((s.budget.memory - s.budget.allocated) / luaMatchSize)
+	if limit < 0 || limit > room+1 {
+		limit = room + 1
+	}
+	matches, err := pm.Find(pattern, []byte(str), 0, limit)
+	if err != nil {
+		L.RaiseError(err.Error())
+	}
+	s.charge(L, int64(len(matches))*luaMatchSize)
+
+	size := int64(len(str))
+	repl := L.Get(3)
+	switch r := repl.(type) {
+	case lua.LString:
+		text := string(r)
+		for _, m := range matches {
+			size += luaReplacementSize(text, str, m) - int64(m.Capture(1)-m.Capture(0))
+		}
+	case *lua.LTable:
+		repl = L.NewFunction(func(L *lua.LState) int {
+			return s.replacement(L, L.GetTable(r, L.Get(1)))
+		})
+	case *lua.LFunction:
+		repl = L.NewFunction(func(L *lua.LState) int {
+			top := L.GetTop()
+			L.Push(r)
+			for i := 1; i <= top; i++ {
+				L.Push(L.Get(i))
+			}
+			L.Call(top, 1)
+			return s.replacement(L, L.Get(-1))
+		})
+	}
+	s.allocate(L, "string.gsub", size)
+
+	L.Push(gsub)
+	L.Push(L.Get(1))
+	L.Push(L.Get(2))
+	L.Push(repl)
+	L.Push(n)
+	L.Call(4, 2)
+	return 2
+}
+
+// replacement charges one value a gsub function or table returned
+func (s *luaSandbox) replacement(L *lua.LState, value lua.LValue) int {
+	if lua.LVCanConvToString(value) {
+		s.allocate(L, "string.gsub", int64(len(lua.LVAsString(value))))
+	}
+	L.Push(value)
+	return 1
+}
+
+// luaReplacementSize is the length of a gsub replacement string for one
+// match, with each %0 to %9 expanded to the text it captures
+func luaReplacementSize(repl, str string, m *pm.MatchData) int64 {
+	var size int64
+	for i := 0; i < len(repl); i++ {
+		if repl[i] != '%' || i+1 == len(repl) {
+			size++
+			continue
+		}
+		i++
+		c := repl[i]
+		if c < '0' || c > '9' {
+			size += 2 // gopher-lua keeps unknown escapes as written
+			continue
+		}
+		idx := 2 * int(c-'0')
+		if idx >= m.CaptureLength() {
+			idx = 0
+		}
+		if m.IsPosCapture(idx) {
+			size += 20
+		} else {
+			size += int64(m.Capture(idx+1) - m.Capture(idx))
+		}
+	}
+	return size
+}
+
+// concat implements the .. operator: strings and numbers are joined after
+// their length is charged, anything else goes to a __concat metamethod
+func (s *luaSandbox) concat(L *lua.LState) int {
+	lhs, rhs := L.Get(1), L.Get(2)
+	if luaConcatenable(lhs) && luaConcatenable(rhs) {
+		a, b := lua.LVAsString(lhs), lua.LVAsString(rhs)
+		s.allocate(L, "concatenation", int64(len(a))+int64(len(b)))
+		L.Push(lua.LString(a + b))
+		return 1
+	}
+	handler := L.GetMetaField(lhs, "__concat")
+	if handler == lua.LNil {
+		handler = L.GetMetaField(rhs, "__concat")
+	}
+	if handler == lua.LNil {
+		bad := lhs
+		if luaConcatenable(lhs) {
+			bad = rhs
+		}
+		L.RaiseError("attempt to concatenate a %s value", bad.Type().String())
+	}
+	L.Push(handler)
+	L.Push(lhs)
+	L.Push(rhs)
+	L.Call(2, 1)
+	return 1
+}
+
+func luaConcatenable(v lua.LValue) bool {
+	return v.Type() == lua.LTString || v.Type() == lua.LTNumber
+}
+
+// luaProduct multiplies two lengths, saturating instead of overflowing
+func luaProduct(a, b int) int64 {
+	if a <= 0 || b <= 0 {
+		return 0
+	}
+	if int64(a) > math.MaxInt64/int64(b) {
+		return math.MaxInt64
+	}
+	return int64(a) * int64(b)
+}
+
+// luaFormatBound is the longest string.format can make its result: the
+// format itself, every argument as text, and every width and precision
+func luaFormatBound(format string, args []lua.LValue) int64 {
+	size := int64(len(format))
+	for _, arg := range args {
+		if arg.Type() == lua.LTString {
+			size += int64(len(lua.LVAsString(arg)))
+		} else {
+			size += 32
+		}
+	}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		var n int64
+		for i++; i < len(format) && strings.IndexByte("-+ #0123456789.", format[i]) >= 0; i++ {
+			if format[i] >= '0' && format[i] <= '9' {
+				if n < math.MaxInt32 {
+					n = n*10 + int64(format[i]-'0')
+				}
+			} else if format[i] == '.' {
+				size += n
+				n = 0
+			}
+		}
+		size += n
+	}
+	return size
+}
+
+// routeLuaAllocations rewrites every .. and table constructor in a chunk into
+// a call to the sandbox, since the VM's own instructions cannot be charged
+func routeLuaAllocations(stmts []ast.Stmt) {
+	r := luaAllocRouter{}
+	r.stmts(stmts)
+}
+
+type luaAllocRouter struct{}
+
+func (r luaAllocRouter) stmts(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		r.stmt(stmt)
+	}
+}
+
+func (r luaAllocRouter) stmt(stmt ast.Stmt) {
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		r.exprs(s.Lhs)
+		r.exprs(s.Rhs)
+	case *ast.LocalAssignStmt:
+		r.exprs(s.Exprs)
+	case *ast.FuncCallStmt:
+		s.Expr = r.expr(s.Expr)
+	case *ast.DoBlockStmt:
+		r.stmts(s.Stmts)
+	case *ast.WhileStmt:
+		s.Condition = r.expr(s.Condition)
+		r.stmts(s.Stmts)
+	case *ast.RepeatStmt:
+		r.stmts(s.Stmts)
+		s.Condition = r.expr(s.Condition)
+	case *ast.IfStmt:
+		s.Condition = r.expr(s.Condition)
+		r.stmts(s.Then)
+		r.stmts(s.Else)
+	case *ast.NumberForStmt:
+		s.Init, s.Limit, s.Step = r.expr(s.Init), r.expr(s.Limit), r.expr(s.Step)
+		r.stmts(s.Stmts)
+	case *ast.GenericForStmt:
+		r.exprs(s.Exprs)
+		r.stmts(s.Stmts)
+	case *ast.FuncDefStmt:
+		s.Name.Func, s.Name.Receiver = r.expr(s.Name.Func), r.expr(s.Name.Receiver)
+		r.stmts(s.Func.Stmts)
+	case *ast.ReturnStmt:
+		r.exprs(s.Exprs)
+	}
+}
+
+func (r luaAllocRouter) exprs(exprs []ast.Expr) {
+	for i := range exprs {
+		exprs[i] = r.expr(exprs[i])
+	}
+}
+
+func (r luaAllocRouter) expr(expr ast.Expr) ast.Expr {
+	switch e := expr.(type) {
+	case *ast.StringConcatOpExpr:
+		return luaSandboxCall(e, luaConcatGlobal, r.expr(e.Lhs), r.expr(e.Rhs))
+	case *ast.AttrGetExpr:
+		e.Object, e.Key = r.expr(e.Object), r.expr(e.Key)
+	case *ast.FuncCallExpr:
+		e.Func, e.Receiver = r.expr(e.Func), r.expr(e.Receiver)
+		r.exprs(e.Args)
+	case *ast.TableExpr:
+		for _, field := range e.Fields {
+			field.Key, field.Value = r.expr(field.Key), r.expr(field.Value)
+		}
+		return luaSandboxCall(e, luaTableGlobal, e)
+	case *ast.LogicalOpExpr:
+		e.Lhs, e.Rhs = r.expr(e.Lhs), r.expr(e.Rhs)
+	case *ast.RelationalOpExpr:
+		e.Lhs, e.Rhs = r.expr(e.Lhs), r.expr(e.Rhs)
+	case *ast.ArithmeticOpExpr:
+		e.Lhs, e.Rhs = r.expr(e.Lhs), r.expr(e.Rhs)
+	case *ast.UnaryMinusOpExpr:
+		e.Expr = r.expr(e.Expr)
+	case *ast.UnaryNotOpExpr:
+		e.Expr = r.expr(e.Expr)
+	case *ast.UnaryLenOpExpr:
+		e.Expr = r.expr(e.Expr)
+	case *ast.FunctionExpr:
+		r.stmts(e.Stmts)
+	}
+	return expr
+}
+
+// stubGlobals makes every undefined global, require included, a stub
+func (s *luaSandbox) stubGlobals() {
+	s.meta = s.L.NewTable()
+	s.L.SetField(s.meta, "__index", s.L.NewFunction(func(L *lua.LState) int {
+		table := L.CheckTable(1)
+		key := L.Get(2)
+		path := s.pathOf(table) + "." + key.String()
+		if key.Type() != lua.LTString {
+			path = s.pathOf(table) + "[" + key.String() + "]"
+		}
+		stub := s.stub(path)
+		L.RawSet(table, key, stub)
+		L.Push(stub)
+		return 1
+	}))
+	s.L.SetField(s.meta, "__call", s.L.NewFunction(func(L *lua.LState) int {
+		callee := L.CheckTable(1)
+		path := s.pathOf(callee)
+		var args []string
+		for i := 2; i <= L.GetTop() && len(args) < maxRecordedArgs; i++ {
+			arg := L.Get(i)
+			if i == 2 && arg == s.parentOf(path) {
+				continue // a method call's self
+			}
+			if rendered, ok := s.render(arg); ok {
+				args = append(args, rendered)
+			}
+		}
+		s.calls[path+"("+strings.Join(args, ", ")+")"] = true
+		L.Push(s.stub(path + "()"))
+		return 1
+	}))
+	s.L.SetField(s.meta, "__tostring", s.L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LString(s.pathOf(L.CheckTable(1))))
+		return 1
+	}))
+	s.L.SetField(s.meta, "__concat", s.L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LString(luaStubString(s, L.Get(1)) + luaStubString(s, L.Get(2))))
+		return 1
+	}))
+	zero := s.L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LNumber(0))
+		return 1
+	})
+	for _, op := range []string{"__add", "__sub", "__mul", "__div", "__mod", "__pow", "__unm", "__len"} {
+		s.L.SetField(s.meta, op, zero)
+	}
+	no := s.L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LFalse)
+		return 1
+	})
+	s.L.SetField(s.meta, "__lt", no)
+	s.L.SetField(s.meta, "__le", no)
+
+	s.L.SetGlobal("require", s.stub("require"))
+	globals := s.L.NewTable()
+	s.L.SetField(globals, "__index", s.L.NewFunction(func(L *lua.LState) int {
+		key := L.Get(2)
+		if key.Type() != lua.LTString {
+			L.Push(lua.LNil)
+			return 1
+		}
+		L.Push(s.stub(key.String()))
+		return 1
+	}))
+	s.L.SetMetatable(s.L.G.Global, globals)
+}
+
+// stub returns the stub table for a path, creating it on first use
+func (s *luaSandbox) stub(path string) *lua.LTable {
+	if stub, ok := s.stubs[path]; ok {
+		return stub
+	}
+	s.charge(s.L, luaTableSize)
+	stub := s.L.NewTable()
+	s.L.SetMetatable(stub, s.meta)
+	s.stubs[path] = stub
+	s.paths[stub] = path
+	return stub
+}
+
+func (s *luaSandbox) pathOf(table *lua.LTable) string {
+	if path, ok := s.paths[table]; ok {
+		return path
+	}
+	return "?"
+}
+
+// parentOf returns the stub a path was indexed from, so a method call's self
+// argument can be skipped
+func (s *luaSandbox) parentOf(path string) lua.LValue {
+	i := strings.LastIndexByte(path, '.')
+	if i < 0 {
+		return lua.LNil
+	}
+	if parent, ok := s.stubs[path[:i]]; ok {
+		return parent
+	}
+	return lua.LNil
+}
+
+// render formats a call argument worth recording: strings, numbers,
+// booleans and stubs
+func (s *luaSandbox) render(v lua.LValue) (string, bool) {
+	switch v := v.(type) {
+	case lua.LString:
+		return strconv.Quote(string(v)), true
+	case lua.LNumber, lua.LBool:
+		return v.String(), true
+	case *lua.LTable:
+		if path := s.pathOf(v); path != "?" {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// luaStubString converts either operand of a concatenation to a string
+func luaStubString(s *luaSandbox, v lua.LValue) string {
+	if table, ok := v.(*lua.LTable); ok {
+		return s.pathOf(table)
+	}
+	return v.String()
+}
+
+// luaSandboxCall builds a call to a sandbox global in place of expr
+func luaSandboxCall(expr ast.Expr, global string, args ...ast.Expr) ast.Expr {
+	call := &ast.FuncCallExpr{Func: &ast.IdentExpr{Value: global}, Args: args}
+	call.SetLine(expr.Line())
+	call.SetLastLine(expr.LastLine())
+	call.Func.SetLine(expr.Line())
+	call.Func.SetLastLine(expr.LastLine())
+	return call
+}
@@ -0,0 +1,700 @@
+package mods
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// snbtInlineWidth is the longest compound or list written on one line
+const snbtInlineWidth = 100
+
+// snbtBareKey matches keys that need no quotes
+var snbtBareKey = regexp.MustCompile(`^[A-Za-z0-9._+-]+$`)
+
+var snbtEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// FormatSNBT writes a value as indented SNBT, the text form of NBT used by
+// Minecraft commands. Short compounds and lists stay on one line.
+func FormatSNBT(v interface{}) string {
+	var b strings.Builder
+	writeSNBT(&b, v, "")
+	return b.String()
+}
+
+func writeSNBT(b *strings.Builder, v interface{}, indent string) {
+	inline := inlineSNBT(v)
+	if len(inline) <= snbtInlineWidth {
+		b.WriteString(inline)
+		return
+	}
+	inner := indent + "  "
+	switch val := v.(type) {
+	case *NBTCompound:
+		b.WriteString("{\n")
+		for i, key := range val.keys {
+			b.WriteString(inner + snbtKey(key) + ": ")
+			writeSNBT(b, val.values[key], inner)
+			if i < len(val.keys)-1 {
+				b.WriteByte(',')
+			}
+			b.WriteByte('\n')
+		}
+		b.WriteString(indent + "}")
+	case *NBTList:
+		b.WriteString("[\n")
+		for i, item := range val.Items {
+			b.WriteString(inner)
+			writeSNBT(b, item, inner)
+			if i < len(val.Items)-1 {
+				b.WriteByte(',')
+			}
+			b.WriteByte('\n')
+		}
+		b.WriteString(indent + "]")
+	default:
+		b.WriteString(inline)
+	}
+}
+
+// inlineSNBT writes a value as SNBT on one line
+func inlineSNBT(v interface{}) string {
+	switch val := v.(type) {
+	case int8:
+		return strconv.Itoa(int(val)) + "b"
+	case int16:
+		return strconv.Itoa(int(val)) + "s"
+	case int32:
+		return strconv.Itoa(int(val))
+	case int64:
+		return strconv.FormatInt(val, 10) + "L"
+	case float32:
+		return strconv.FormatFloat(float64(val), 'g', -1, 32) + "f"
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64) + "d"
+	case string:
+		return `"` + snbtEscaper.Replace(val) + `"`
+	case []int8:
+		parts := make([]string, len(val))
+		for i, n := range val {
+			parts[i] = strconv.Itoa(int(n)) + "b"
+		}
+		return "[B; " + strings.Join(parts, ", ") + "]"
+	case []int32:
+		parts := make([]string, len(val))
+		for i, n := range val {
+			parts[i] = strconv.Itoa(int(n))
+		}
+		return "[I; " + strings.Join(parts, ", ") + "]"
+	case []int64:
+		parts := make([]string, len(val))
+		for i, n := range val {
+			parts[i] = strconv.FormatInt(n, 10) + "L"
+		}
+		return "[L; " + strings.Join(parts, ", ") + "]"
+	case *NBTList:
+		parts := make([]string, len(val.Items))
+		for i, item := range val.Items {
+			parts[i] = inlineSNBT(item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case *NBTCompound:
+		parts := make([]string, len(val.keys))
+		for i, key := range val.keys {
+			parts[i] = snbtKey(key) + ": " + inlineSNBT(val.values[key])
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func snbtKey(key string) string {
+	if snbtBareKey.MatchString(key) {
+		return key
+	}
+	return `"` + snbtEscaper.Replace(key) + `"`
+}
+
+// ParseSNBT reads an SNBT value. Numbers take their type from their suffix:
+// b, s, L, f and d; plain integers are ints and plain decimals doubles.
+// true and false are bytes, and unquoted words are strings.
+func ParseSNBT(s string) (interface{}, error) {
+	p := &snbtParser{s: s}
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %q after value", p.s[p.pos])
+	}
+	return v, nil
+}
+
+type snbtParser struct {
+	s     string
+	pos   int
+	depth int
+}
+
+func (p *snbtParser) errorf(format string, args ...interface{}) error {
+	line, column := lineAndColumn([]byte(p.s), p.pos)
+	return fmt.Errorf("SNBT line %d, column %d: %s", line, column, fmt.Sprintf(format, args...))
+}
+
+func (p *snbtParser) skipSpace() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// expect consumes c after any whitespace
+func (p *snbtParser) expect(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *snbtParser) value() (interface{}, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return nil, p.errorf("unexpected end of input")
+	}
+	switch p.s[p.pos] {
+	case '{', '[':
+		if p.depth++; p.depth > maxNBTDepth {
+			return nil, p.errorf("nested deeper than %d", maxNBTDepth)
+		}
+		defer func() { p.depth-- }()
+		if p.s[p.pos] == '{' {
+			return p.compound()
+		}
+		return p.list()
+	case '"', '\'':
+		return p.quoted()
+	}
+	word := p.word()
+	if word == "" {
+		return nil, p.errorf("unexpected %q", p.s[p.pos])
+	}
+	return snbtScalar(word, p)
+}
+
+func (p *snbtParser) word() string {
+	start := p.pos
+	for p.pos < len(p.s) && isSNBTWordByte(p.s[p.pos]) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func isSNBTWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("._+-", c) >= 0
+}
+
+func (p *snbtParser) quoted() (string, error) {
+	quote := p.s[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && p.pos < len(p.s):
+			escaped := p.s[p.pos]
+			p.pos++
+			switch escaped {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '\\', '"', '\'':
+				b.WriteByte(escaped)
+			default:
+				return "", p.errorf("unknown escape \\%c", escaped)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *snbtParser) compound() (interface{}, error) {
+	p.pos++ // {
+	c := NewNBTCompound()
+	for {
+		if p.expect('}') {
+			return c, nil
+		}
+		p.skipSpace()
+		var key string
+		var err error
+		if p.pos < len(p.s) && (p.s[p.pos] == '"' || p.s[p.pos] == '\'') {
+			if key, err = p.quoted(); err != nil {
+				return nil, err
+			}
+		} else if key = p.word(); key == "" {
+			return nil, p.errorf("expected a key")
+		}
+		if _, ok := c.values[key]; ok {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		if !p.expect(':') {
+			return nil, p.errorf("expected ':' after key %q", key)
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		c.Set(key, value)
+		if !p.expect(',') {
+			if !p.expect('}') {
+				return nil, p.errorf("expected ',' or '}' in compound")
+			}
+			return c, nil
+		}
+	}
+}
+
+func (p *snbtParser) list() (interface{}, error) {
+	p.pos++ // [
+	p.skipSpace()
+	if p.pos+1 < len(p.s) && strings.IndexByte("BIL", p.s[p.pos]) >= 0 {
+		rest := strings.TrimLeft(p.s[p.pos+1:], " \t\r\n")
+		if strings.HasPrefix(rest, ";") {
+			kind := p.s[p.pos]
+			p.pos = len(p.s) - len(rest) + 1
+			return p.array(kind)
+		}
+	}
+
+	list := &NBTList{Type: TagEnd}
+	for {
+		if p.expect(']') {
+			return list, nil
+		}
+		item, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		tag, _ := nbtTagOf(item)
+		if len(list.Items) == 0 {
+			list.Type = tag
+		} else if tag != list.Type {
+			return nil, p.errorf("list mixes %s and %s", NBTTagName(list.Type), NBTTagName(tag))
+		}
+		list.Items = append(list.Items, item)
+		if !p.expect(',') {
+			if !p.expect(']') {
+				return nil, p.errorf("expected ',' or ']' in list")
+			}
+			return list, nil
+		}
+	}
+}
+
+// array reads the items of a [B;], [I;] or [L;] array
+func (p *snbtParser) array(kind byte) (interface{}, error) {
+	var bytesOut []int8
+	var ints []int32
+	var longs []int64
+	bits := map[byte]int{'B': 8, 'I': 32, 'L': 64}[kind]
+	for {
+		if p.expect(']') {
+			break
+		}
+		p.skipSpace()
+		word := p.word()
+		digits := strings.TrimRight(word, "bBsSlL")
+		n, err := strconv.ParseInt(digits, 10, bits)
+		if word == "" || err != nil || len(word)-len(digits) > 1 {
+			return nil, p.errorf("invalid %c array item %q", kind, word)
+		}
+		switch kind {
+		case 'B':
+			bytesOut = append(bytesOut, int8(n))
+		case 'I':
+			ints = append(ints, int32(n))
+		default:
+			longs = append(longs, n)
+		}
+		if !p.expect(',') {
+			if !p.expect(']') {
+				return nil, p.errorf("expected ',' or ']' in array")
+			}
+			break
+		}
+	}
+	switch kind {
+	case 'B':
+		return append([]int8{}, bytesOut...), nil
+	case 'I':
+		return append([]int32{}, ints...), nil
+	}
+	return append([]int64{}, longs...), nil
+}
+
+var (
+	snbtInteger = regexp.MustCompile(`^[-+]?[0-9]+([bBsSlL]?)$`)
+	snbtDecimal = regexp.MustCompile(`^[-+]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][-+]?[0-9]+)?([fFdD]?)$`)
+	snbtSpecial = regexp.MustCompile(`^([-+]?Inf|NaN)([fFdD])$`)
+)
+
+// snbtScalar interprets an unquoted word
+func snbtScalar(word string, p *snbtParser) (interface{}, error) {
+	switch word {
+	case "true":
+		return int8(1), nil
+	case "false":
+		return int8(0), nil
+	}
+	if m := snbtInteger.FindStringSubmatch(word); m != nil {
+		digits := strings.TrimSuffix(word, m[1])
+		bits := map[string]int{"": 32, "b": 8, "B": 8, "s": 16, "S": 16, "l": 64, "L": 64}[m[1]]
+		n, err := strconv.ParseInt(digits, 10, bits)
+		if err != nil {
+			return nil, p.errorf("%s is out of range", word)
+		}
+		switch bits {
+		case 8:
+			return int8(n), nil
+		case 16:
+			return int16(n), nil
+		case 64:
+			return n, nil
+		}
+		return int32(n), nil
+	}
+	m := snbtDecimal.FindStringSubmatch(word)
+	if m == nil {
+		m = snbtSpecial.FindStringSubmatch(word)
+		if m == nil {
+			return word, nil
+		}
+		m = []string{word, "", "", m[2]}
+	}
+	number := strings.TrimSuffix(word, m[3])
+	if strings.EqualFold(m[3], "f") {
+		f, err := strconv.ParseFloat(number, 32)
+		if err != nil && !math.IsInf(f, 0) {
+			return nil, p.errorf("invalid float %s", word)
+		}
+		return float32(f), nil
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil && !math.IsInf(f, 0) {
+		return nil, p.errorf("invalid double %s", word)
+	}
+	return f, nil
+}
+
+// NBTToJSON converts a value to indented JSON. Compounds become objects in
+// key order, lists and arrays become arrays and numbers lose their type, so
+// NBTFromJSON needs the original to restore them.
+func NBTToJSON(v interface{}) ([]byte, error) {
+	var compact, out bytes.Buffer
+	if err := encodeOrdered(&compact, nbtJSONValue(v)); err != nil {
+		return nil, err
+	}
+	if err := json.Indent(&out, compact.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func nbtJSONValue(v interface{}) interface{} {
+	number := func(f float64, bits int) interface{} {
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return strconv.FormatFloat(f, 'g', -1, bits)
+		}
+		return json.Number(strconv.FormatFloat(f, 'g', -1, bits))
+	}
+	switch val := v.(type) {
+	case int8:
+		return json.Number(strconv.Itoa(int(val)))
+	case int16:
+		return json.Number(strconv.Itoa(int(val)))
+	case int32:
+		return json.Number(strconv.Itoa(int(val)))
+	case int64:
+		return json.Number(strconv.FormatInt(val, 10))
+	case float32:
+		return number(float64(val), 32)
+	case float64:
+		return number(val, 64)
+	case []int8:
+		items := make([]interface{}, len(val))
+		for i, n := range val {
+			items[i] = json.Number(strconv.Itoa(int(n)))
+		}
+		return items
+	case []int32:
+		items := make([]interface{}, len(val))
+		for i, n := range val {
+			items[i] = json.Number(strconv.Itoa(int(n)))
+		}
+		return items
+	case []int64:
+		items := make([]interface{}, len(val))
+		for i, n := range val {
+			items[i] = json.Number(strconv.FormatInt(n, 10))
+		}
+		return items
+	case *NBTList:
+		items := make([]interface{}, len(val.Items))
+		for i, item := range val.Items {
+			items[i] = nbtJSONValue(item)
+		}
+		return items
+	case *NBTCompound:
+		object := &orderedObject{values: make(map[string]interface{})}
+		for _, key := range val.keys {
+			object.set(key, nbtJSONValue(val.values[key]))
+		}
+		return object
+	}
+	return v
+}
+
+// NBTFromJSON converts JSON back to NBT, taking each value's type from the
+// value at the same place in like, usually the original file. Values like
+// does not have become ints, longs or doubles, and lists.
+func NBTFromJSON(data []byte, like interface{}) (interface{}, error) {
+	doc, err := decodeOrdered(data)
+	if err != nil {
+		return nil, err
+	}
+	return nbtFromJSONValue(doc, like, "")
+}
+
+func nbtFromJSONValue(v, like interface{}, path string) (interface{}, error) {
+	switch val := v.(type) {
+	case *orderedObject:
+		likeCompound, _ := like.(*NBTCompound)
+		c := NewNBTCompound()
+		for _, key := range val.keys {
+			var child interface{}
+			if likeCompound != nil {
+				child = likeCompound.values[key]
+			}
+			converted, err := nbtFromJSONValue(val.values[key], child, path+"/"+key)
+			if err != nil {
+				return nil, err
+			}
+			c.Set(key, converted)
+		}
+		return c, nil
+	case []interface{}:
+		switch like.(type) {
+		case []int8, []int32, []int64:
+			items := make([]interface{}, len(val))
+			for i, item := range val {
+				n, ok := item.(json.Number)
+				if !ok {
+					return nil, fmt.Errorf("%s/%d: array items must be numbers", path, i)
+				}
+				items[i] = n
+			}
+			return nbtArrayFromJSON(items, like, path)
+		}
+		likeList, _ := like.(*NBTList)
+		list := &NBTList{Type: TagEnd}
+		if likeList != nil {
+			list.Type = likeList.Type
+		}
+		for i, item := range val {
+			var child interface{}
+			if likeList != nil && len(likeList.Items) > 0 {
+				child = likeList.Items[min(i, len(likeList.Items)-1)]
+			}
+			converted, err := nbtFromJSONValue(item, child, fmt.Sprintf("%s/%d", path, i))
+			if err != nil {
+				return nil, err
+			}
+			tag, _ := nbtTagOf(converted)
+			if i == 0 {
+				list.Type = tag
+			} else if tag != list.Type {
+				return nil, fmt.Errorf("%s: list mixes %s and %s", path, NBTTagName(list.Type), NBTTagName(tag))
+			}
+			list.Items = append(list.Items, converted)
+		}
+		return list, nil
+	case json.Number:
+		return nbtNumberLike(string(val), like, path)
+	case string:
+		switch like.(type) {
+		case float32, float64:
+			return nbtNumberLike(val, like, path)
+		}
+		return val, nil
+	case bool:
+		if val {
+			return int8(1), nil
+		}
+		return int8(0), nil
+	}
+	return nil, fmt.Errorf("%s: null has no NBT type", path)
+}
+
+// nbtNumberLike parses a number as the numeric type of like
+func nbtNumberLike(s string, like interface{}, path string) (interface{}, error) {
+	integer := func(bits int) (int64, error) {
+		n, err := strconv.ParseInt(s, 10, bits)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %s is not a %d-bit integer", path, s, bits)
+		}
+		return n, nil
+	}
+	switch like.(type) {
+	case int8:
+		n, err := integer(8)
+		return int8(n), err
+	case int16:
+		n, err := integer(16)
+		return int16(n), err
+	case int32:
+		n, err := integer(32)
+		return int32(n), err
+	case int64:
+		return integer(64)
+	case float32:
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil && !math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%s: %s is not a number", path, s)
+		}
+		return float32(f), nil
+	case float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil && !math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%s: %s is not a number", path, s)
+		}
+		return f, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n >= math.MinInt32 && n <= math.MaxInt32 {
+			return int32(n), nil
+		}
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && !math.IsInf(f, 0) {
+		return nil, fmt.Errorf("%s: %s is not a number", path, s)
+	}
+	return f, nil
+}
+
+func nbtArrayFromJSON(items []interface{}, like interface{}, path string) (interface{}, error) {
+	var out interface{}
+	switch like.(type) {
+	case []int8:
+		values := make([]int8, len(items))
+		for i, item := range items {
+			n, err := nbtNumberLike(string(item.(json.Number)), int8(0), fmt.Sprintf("%s/%d", path, i))
+			if err != nil {
+				return nil, err
+			}
+			values[i] = n.(int8)
+		}
+		out = values
+	case []int32:
+		values := make([]int32, len(items))
+		for i, item := range items {
+			n, err := nbtNumberLike(string(item.(json.Number)), int32(0), fmt.Sprintf("%s/%d", path, i))
+			if err != nil {
+				return nil, err
+			}
+			values[i] = n.(int32)
+		}
+		out = values
+	default:
+		values := make([]int64, len(items))
+		for i, item := range items {
+			n, err := nbtNumberLike(string(item.(json.Number)), int64(0), fmt.Sprintf("%s/%d", path, i))
+			if err != nil {
+				return nil, err
+			}
+			values[i] = n.(int64)
+		}
+		out = values
+	}
+	return out, nil
+}
+
+// checkNBTLists reports a list whose items do not all have its item type,
+// which conformNBT leaves when only some edited numbers fit the original type
+func checkNBTLists(v interface{}, path string) error {
+	switch val := v.(type) {
+	case *NBTCompound:
+		for _, key := range val.keys {
+			if err := checkNBTLists(val.values[key], path+"/"+key); err != nil {
+				return err
+			}
+		}
+	case *NBTList:
+		for i, item := range val.Items {
+			if tag, err := nbtTagOf(item); err != nil || tag != val.Type {
+				return fmt.Errorf("%s mixes %s and %s items", path, NBTTagName(val.Type), NBTTagName(tag))
+			}
+			if err := checkNBTLists(item, fmt.Sprintf("%s/%d", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// conformNBT gives edited numbers the type of the original value at the same
+// place when they fit, and empty lists the original's item type, so an edit
+// that writes 5 where the file had 5b keeps the byte
+func conformNBT(edited, original interface{}) interface{} {
+	switch val := edited.(type) {
+	case *NBTCompound:
+		if orig, ok := original.(*NBTCompound); ok {
+			for _, key := range val.keys {
+				if child, ok := orig.values[key]; ok {
+					val.values[key] = conformNBT(val.values[key], child)
+				}
+			}
+		}
+		return val
+	case *NBTList:
+		orig, ok := original.(*NBTList)
+		if !ok {
+			return val
+		}
+		if len(val.Items) == 0 {
+			val.Type = orig.Type
+			return val
+		}
+		if len(orig.Items) == 0 {
+			return val
+		}
+		for i := range val.Items {
+			val.Items[i] = conformNBT(val.Items[i], orig.Items[min(i, len(orig.Items)-1)])
+		}
+		if tag, _ := nbtTagOf(val.Items[0]); tag != val.Type {
+			val.Type = tag
+		}
+		return val
+	case int8, int16, int32, int64, float32, float64:
+		switch original.(type) {
+		case int8, int16, int32, int64, float32, float64:
+			if converted, err := nbtNumberLike(fmt.Sprint(val), original, ""); err == nil {
+				return converted
+			}
+		}
+	}
+	return edited
+}
@@ -0,0 +1,1022 @@
+package mods
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ContentPatcherID is the UniqueID of Content Patcher, which content packs
+// name in ContentPackFor
+const ContentPatcherID = "Pathoschild.ContentPatcher"
+
+// I18nSourceLocale is the SMAPI translation file every locale is made from
+const I18nSourceLocale = "default"
+
+// Content patch issue severities
+const (
+	IssueError   = "error"   // Content Patcher would refuse the patch
+	IssueWarning = "warning" // the patch loads but may not do what was meant
+)
+
+var (
+	smapiUniqueID   = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	smapiVersion    = regexp.MustCompile(`^\d+(\.\d+){0,2}(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+	smapiLocaleCode = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z]{2,4})?$`)
+)
+
+// contentPatcherActions are the patch actions and the fields each requires;
+// a list of alternatives needs any one of them
+var contentPatcherActions = map[string][][]string{
+	"load":      {{"Target"}, {"FromFile"}},
+	"editdata":  {{"Target"}, {"Entries", "Fields", "MoveEntries", "TextOperations", "TargetField"}},
+	"editimage": {{"Target"}, {"FromFile"}},
+	"editmap":   {{"Target"}, {"FromFile", "MapProperties", "MapTiles", "TextOperations", "AddWarps", "AddNpcWarps"}},
+	"include":   {{"FromFile"}},
+}
+
+// contentPatcherTokens are Content Patcher's built-in tokens, lowercased
+var contentPatcherTokens = map[string]bool{}
+
+func init() {
+	for _, name := range strings.Fields(`
+		Day DayEvent DayOfWeek DaysPlayed Season Time Weather Year
+		DailyLuck FarmhouseUpgrade HasActiveQuest HasCaughtFish HasConversationTopic
+		HasCookingRecipe HasCraftingRecipe HasDialogueAnswer HasFlag HasProfession
+		HasReadLetter HasSeenEvent HasVisitedLocation HasWalletItem IsMainPlayer
+		IsOutdoors LocationContext LocationName LocationOwnerId LocationUniqueName
+		PlayerGender PlayerName SkillLevel
+		ChildNames ChildGenders Hearts Relationship Roommate Spouse
+		FarmCave FarmName FarmType IsCommunityCenterComplete IsJojaMartComplete
+		HavingChild Pregnant
+		Count Query Random Range Round
+		Lowercase Uppercase Merge PathPart Render
+		FirstValidFile HasFile HasMod HasValue i18n Language ModId
+		Target TargetPathOnly TargetWithoutPath FromFile
+		AbsoluteFilePath FormatAssetName InternalAssetKey`) {
+		contentPatcherTokens[strings.ToLower(name)] = true
+	}
+}
+
+// SMAPIDependency is a mod a SMAPI mod needs or can use
+type SMAPIDependency struct {
+	UniqueID       string `json:"UniqueID"`
+	MinimumVersion string `json:"MinimumVersion,omitempty"`
+	IsRequired     bool   `json:"IsRequired"`
+}
+
+// SMAPIContentPackFor names the mod that reads a content pack
+type SMAPIContentPackFor struct {
+	UniqueID       string `json:"UniqueID"`
+	MinimumVersion string `json:"MinimumVersion,omitempty"`
+}
+
+// SMAPIManifest is a SMAPI mod's manifest.json
+type SMAPIManifest struct {
+	Name               string               `json:"Name"`
+	Author             string               `json:"Author"`
+	Version            string               `json:"Version"`
+	Description        string               `json:"Description,omitempty"`
+	UniqueID           string               `json:"UniqueID"`
+	EntryDll           string               `json:"EntryDll,omitempty"`
+	ContentPackFor     *SMAPIContentPackFor `json:"ContentPackFor,omitempty"`
+	MinimumApiVersion  string               `json:"MinimumApiVersion,omitempty"`
+	MinimumGameVersion string               `json:"MinimumGameVersion,omitempty"`
+	Dependencies       []SMAPIDependency    `json:"Dependencies,omitempty"`
+	UpdateKeys         []string             `json:"UpdateKeys,omitempty"`
+}
+
+// IsContentPatcherPack reports whether the manifest is a Content Patcher content pack
+func (m *SMAPIManifest) IsContentPatcherPack() bool {
+	return m.ContentPackFor != nil && strings.EqualFold(m.ContentPackFor.UniqueID, ContentPatcherID)
+}
+
+// ParseSMAPIManifest reads and validates a manifest.json
+func ParseSMAPIManifest(data []byte) (*SMAPIManifest, error) {
+	var raw struct {
+		SMAPIManifest
+		Dependencies []struct {
+			UniqueID       string `json:"UniqueID"`
+			MinimumVersion string `json:"MinimumVersion"`
+			IsRequired     *bool  `json:"IsRequired"`
+		} `json:"Dependencies"`
+	}
+	data = stripJSONComments(data)
+	if err := validateJSON(data); err != nil {
+		return nil, fmt.Errorf("manifest.json: %w", err)
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("manifest.json: %w", err)
+	}
+
+	m := raw.SMAPIManifest
+	switch {
+	case m.Name == "" || m.Author == "":
+		return nil, fmt.Errorf("manifest.json must have a Name and Author")
+	case !smapiUniqueID.MatchString(m.UniqueID):
+		return nil, fmt.Errorf("manifest.json UniqueID %q must be letters, digits, ., - and _", m.UniqueID)
+	case !smapiVersion.MatchString(m.Version):
+		return nil, fmt.Errorf("manifest.json Version %q is not a semantic version", m.Version)
+	case m.MinimumApiVersion != "" && !smapiVersion.MatchString(m.MinimumApiVersion):
+		return nil, fmt.Errorf("manifest.json MinimumApiVersion %q is not a semantic version", m.MinimumApiVersion)
+	case (m.EntryDll == "") == (m.ContentPackFor == nil):
+		return nil, fmt.Errorf("manifest.json must have exactly one of EntryDll or ContentPackFor")
+	case m.ContentPackFor != nil && !smapiUniqueID.MatchString(m.ContentPackFor.UniqueID):
+		return nil, fmt.Errorf("manifest.json ContentPackFor needs the UniqueID of the mod that reads the pack")
+	}
+
+	m.Dependencies = nil
+	for _, dep := range raw.Dependencies {
+		if !smapiUniqueID.MatchString(dep.UniqueID) {
+			return nil, fmt.Errorf("manifest.json dependency UniqueID %q is invalid", dep.UniqueID)
+		}
+		if dep.MinimumVersion != "" && !smapiVersion.MatchString(dep.MinimumVersion) {
+			return nil, fmt.Errorf("manifest.json dependency %s MinimumVersion %q is not a semantic version", dep.UniqueID, dep.MinimumVersion)
+		}
+		// Dependencies are required unless they say otherwise
+		m.Dependencies = append(m.Dependencies, SMAPIDependency{
+			UniqueID:       dep.UniqueID,
+			MinimumVersion: dep.MinimumVersion,
+			IsRequired:     dep.IsRequired == nil || *dep.IsRequired,
+		})
+	}
+	return &m, nil
+}
+
+// stripJSONComments blanks out the // and /* */ comments and trailing commas
+// SMAPI accepts in JSON files, keeping byte offsets so errors still point at
+// the right line
+func stripJSONComments(data []byte) []byte {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	out := append([]byte(nil), data...)
+	inString := false
+	lastComma := -1
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+		switch {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString, lastComma = true, -1
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			stop := len(out)
+			if end >= 0 {
+				stop = i + 2 + end + 2
+			}
+			for ; i < stop; i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			i--
+		case c == ',':
+			lastComma = i
+		case c == '}' || c == ']':
+			if lastComma >= 0 {
+				out[lastComma] = ' '
+			}
+			lastComma = -1
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		default:
+			lastComma = -1
+		}
+	}
+	return out
+}
+
+// ContentPatchIssue is a problem found in a Content Patcher file
+type ContentPatchIssue struct {
+	File     string `json:"file"`
+	Path     string `json:"path,omitempty"` // such as Changes[2].Target
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// ContentPatch summarizes a Content Patcher content.json or included file
+type ContentPatch struct {
+	Format        string         `json:"format,omitempty"`
+	Changes       int            `json:"changes"`
+	Actions       map[string]int `json:"actions"`
+	Targets       []string       `json:"targets"`
+	Includes      []string       `json:"includes,omitempty"`
+	Config        []string       `json:"config,omitempty"`         // ConfigSchema options, which are tokens
+	DynamicTokens []string       `json:"dynamic_tokens,omitempty"` // DynamicTokens names
+	Tokens        []string       `json:"tokens"`                   // every token used, lowercased
+}
+
+// ContentPatchContext is what tokens in a content pack may refer to beyond
+// Content Patcher's built-in tokens
+type ContentPatchContext struct {
+	Tokens map[string]bool // config options and dynamic tokens, lowercased
+	I18n   map[string]bool // keys of i18n/default.json; nil skips the check
+}
+
+// CheckContentPatch validates the patch structure and tokens of a Content
+// Patcher file. Root files must declare a Format; included files need not.
+// The file's own ConfigSchema and DynamicTokens are added to context.
+func CheckContentPatch(file string, data []byte, context *ContentPatchContext, root bool) (*ContentPatch, []ContentPatchIssue, error) {
+	data = stripJSONComments(data)
+	if err := validateJSON(data); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", file, err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("%s is not a JSON object", file)
+	}
+	if context == nil {
+		context = &ContentPatchContext{}
+	}
+	if context.Tokens == nil {
+		context.Tokens = make(map[string]bool)
+	}
+
+	patch := &ContentPatch{Actions: make(map[string]int), Targets: []string{}, Tokens: []string{}}
+	var issues []ContentPatchIssue
+	report := func(path, severity, format string, args ...interface{}) {
+		issues = append(issues, ContentPatchIssue{File: file, Path: path, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	format, _ := fieldOf(doc, "Format").(string)
+	patch.Format = format
+	if root && !smapiVersion.MatchString(format) {
+		report("Format", IssueError, "Format must be the Content Patcher version the pack was written for, such as 2.0.0")
+	}
+
+	if schema, ok := fieldOf(doc, "ConfigSchema").(map[string]interface{}); ok {
+		for name := range schema {
+			patch.Config = append(patch.Config, name)
+			context.Tokens[strings.ToLower(name)] = true
+		}
+		sort.Strings(patch.Config)
+	}
+	if dynamic, ok := fieldOf(doc, "DynamicTokens").([]interface{}); ok {
+		for i, entry := range dynamic {
+			token, _ := entry.(map[string]interface{})
+			name, _ := fieldOf(token, "Name").(string)
+			if name == "" {
+				report(fmt.Sprintf("DynamicTokens[%d]", i), IssueError, "dynamic token has no Name")
+				continue
+			}
+			if !context.Tokens[strings.ToLower(name)] {
+				patch.DynamicTokens = append(patch.DynamicTokens, name)
+			}
+			context.Tokens[strings.ToLower(name)] = true
+		}
+	}
+	if aliases, ok := fieldOf(doc, "AliasTokenNames").(map[string]interface{}); ok {
+		for alias := range aliases {
+			context.Tokens[strings.ToLower(alias)] = true
+		}
+	}
+
+	used := make(map[string]bool)
+	checkTokens := func(path string, v interface{}) {
+		walkJSONStrings(v, func(s string) {
+			tokens, err := contentPatchTokens(s)
+			if err != nil {
+				report(path, IssueError, "%s in %q", err, s)
+				return
+			}
+			for _, token := range tokens {
+				used[token.name] = true
+				switch {
+				case contentPatcherTokens[token.name], context.Tokens[token.name], strings.Contains(token.name, "/"):
+				case token.name == "":
+					report(path, IssueError, "empty token in %q", s)
+					continue
+				default:
+					report(path, IssueWarning, "unknown token %q", token.name)
+					continue
+				}
+				if token.name == "i18n" && context.I18n != nil && token.input != "" && !strings.Contains(token.input, "{{") && !context.I18n[token.input] {
+					report(path, IssueWarning, "i18n key %q is not in i18n/default.json", token.input)
+				}
+			}
+		})
+	}
+
+	changes, ok := fieldOf(doc, "Changes").([]interface{})
+	if !ok {
+		if root || fieldOf(doc, "Changes") != nil {
+			report("Changes", IssueError, "Changes must be a list of patches")
+		}
+	}
+	patch.Changes = len(changes)
+	targets := make(map[string]bool)
+	for i, entry := range changes {
+		at := fmt.Sprintf("Changes[%d]", i)
+		change, ok := entry.(map[string]interface{})
+		if !ok {
+			report(at, IssueError, "patch is not a JSON object")
+			continue
+		}
+		action, _ := fieldOf(change, "Action").(string)
+		required, known := contentPatcherActions[strings.ToLower(action)]
+		if !known {
+			report(at+".Action", IssueError, "unknown Action %q; use Load, EditData, EditImage, EditMap or Include", action)
+		} else {
+			patch.Actions[action]++
+			for _, alternatives := range required {
+				found := false
+				for _, name := range alternatives {
+					if fieldOf(change, name) != nil {
+						found = true
+						break
+					}
+				}
+				if !found {
+					report(at, IssueError, "%s patch needs %s", action, strings.Join(alternatives, " or "))
+				}
+			}
+		}
+		if target, ok := fieldOf(change, "Target").(string); ok {
+			for _, t := range strings.Split(target, ",") {
+				if t = strings.TrimSpace(t); t != "" && !targets[t] {
+					targets[t] = true
+					patch.Targets = append(patch.Targets, t)
+				}
+			}
+		}
+		if strings.EqualFold(action, "Include") {
+			if from, ok := fieldOf(change, "FromFile").(string); ok {
+				for _, f := range strings.Split(from, ",") {
+					if f = strings.TrimSpace(f); f != "" {
+						patch.Includes = append(patch.Includes, f)
+					}
+				}
+			}
+		}
+		if when, ok := fieldOf(change, "When").(map[string]interface{}); ok {
+			for key, value := range when {
+				// Condition keys are token names, with or without braces
+				expr := key
+				if !strings.Contains(expr, "{{") {
+					expr = "{{" + expr + "}}"
+				}
+				checkTokens(at+".When", expr)
+				checkTokens(at+".When", value)
+			}
+		}
+		for key, value := range change {
+			if !strings.EqualFold(key, "When") {
+				checkTokens(at+"."+key, value)
+			}
+		}
+	}
+	if dynamic := fieldOf(doc, "DynamicTokens"); dynamic != nil {
+		checkTokens("DynamicTokens", dynamic)
+	}
+
+	patch.Tokens = sortedKeys(used)
+	return patch, issues, nil
+}
+
+// contentPatchToken is a token reference such as {{i18n:greeting}}
+type contentPatchToken struct {
+	name  string // lowercased
+	input string
+}
+
+// contentPatchTokens returns the tokens in a string, including nested ones,
+// or an error for unbalanced braces
+func contentPatchTokens(s string) ([]contentPatchToken, error) {
+	var tokens []contentPatchToken
+	var starts []int
+	for i := 0; i+1 < len(s); i++ {
+		switch s[i : i+2] {
+		case "{{":
+			starts = append(starts, i+2)
+			i++
+		case "}}":
+			if len(starts) == 0 {
+				return nil, fmt.Errorf("unmatched }}")
+			}
+			inner := s[starts[len(starts)-1]:i]
+			starts = starts[:len(starts)-1]
+			i++
+			if strings.HasPrefix(strings.TrimSpace(inner), "{{") {
+				continue // a token whose name is itself a token
+			}
+			name, input := inner, ""
+			if j := strings.IndexAny(inner, ":|"); j >= 0 {
+				name = inner[:j]
+				if inner[j] == ':' {
+					input, _, _ = strings.Cut(inner[j+1:], "|")
+				}
+			}
+			tokens = append(tokens, contentPatchToken{name: strings.ToLower(strings.TrimSpace(name)), input: strings.TrimSpace(input)})
+		}
+	}
+	if len(starts) > 0 {
+		return nil, fmt.Errorf("unclosed {{")
+	}
+	return tokens, nil
+}
+
+// fieldOf returns a field of a JSON object, matching its name case-insensitively
+// as SMAPI does
+func fieldOf(object map[string]interface{}, name string) interface{} {
+	if v, ok := object[name]; ok {
+		return v
+	}
+	for key, v := range object {
+		if strings.EqualFold(key, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+// walkJSONStrings calls visit for every string value and object key
+func walkJSONStrings(v interface{}, visit func(s string)) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for key, child := range val {
+			visit(key)
+			walkJSONStrings(child, visit)
+		}
+	case []interface{}:
+		for _, child := range val {
+			walkJSONStrings(child, visit)
+		}
+	case string:
+		visit(val)
+	}
+}
+
+// I18nFile is a SMAPI translation file, i18n/<locale>.json, mapping keys to strings
+type I18nFile struct {
+	Path    string            `json:"path"`
+	Locale  string            `json:"locale"` // default for the source strings
+	Keys    []string          `json:"-"`      // in file order
+	Entries map[string]string `json:"-"`
+	raw     []byte
+}
+
+// ValidSMAPILocale reports whether a locale code has the shape of a SMAPI
+// translation file name, such as de or pt-BR
+func ValidSMAPILocale(locale string) bool {
+	return smapiLocaleCode.MatchString(locale)
+}
+
+// ParseI18n reads a translation file named by its archive path
+func ParseI18n(name string, data []byte) (*I18nFile, error) {
+	dir, base := path.Split(name)
+	if path.Base(dir) != "i18n" || path.Ext(base) != ".json" {
+		return nil, fmt.Errorf("%s is not a translation file", name)
+	}
+	file := &I18nFile{
+		Path:    name,
+		Locale:  strings.TrimSuffix(base, ".json"),
+		Entries: make(map[string]string),
+		raw:     data,
+	}
+	stripped := stripJSONComments(data)
+	if err := validateJSON(stripped); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	doc, err := decodeOrdered(stripped)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	object, ok := doc.(*orderedObject)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a JSON object", name)
+	}
+	for _, key := range object.keys {
+		value, ok := object.values[key].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: value of %q is not a string", name, key)
+		}
+		file.add(key, value)
+	}
+	return file, nil
+}
+
+func (f *I18nFile) add(key, value string) {
+	if _, ok := f.Entries[key]; !ok {
+		f.Keys = append(f.Keys, key)
+	}
+	f.Entries[key] = value
+}
+
+// Encode writes the translation file. An existing file keeps its text,
+// including comments, with new keys inserted before its closing brace.
+func (f *I18nFile) Encode() ([]byte, error) {
+	existing, err := ParseI18n(f.Path, f.raw)
+	if len(f.raw) == 0 || err != nil {
+		object := &orderedObject{values: make(map[string]interface{}, len(f.Keys))}
+		for _, key := range f.Keys {
+			object.set(key, f.Entries[key])
+		}
+		var compact, out bytes.Buffer
+		if err := encodeOrdered(&compact, object); err != nil {
+			return nil, err
+		}
+		if err := json.Indent(&out, compact.Bytes(), "", "  "); err != nil {
+			return nil, err
+		}
+		out.WriteByte('\n')
+		return out.Bytes(), nil
+	}
+
+	bom := []byte("\xef\xbb\xbf")
+	raw := bytes.TrimPrefix(f.raw, bom)
+	stripped := stripJSONComments(raw)
+	end := bytes.LastIndexByte(stripped, '}')
+	last := len(bytes.TrimRight(stripped[:end], " \t\r\n"))
+	newline := "\n"
+	if bytes.Contains(f.raw, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+	indent := jsonIndent(f.raw)
+	if indent == "" {
+		indent = "  "
+	}
+
+	var added bytes.Buffer
+	for _, key := range f.Keys {
+		if _, ok := existing.Entries[key]; ok {
+			continue
+		}
+		var entry bytes.Buffer
+		if err := encodeOrdered(&entry, key); err != nil {
+			return nil, err
+		}
+		entry.WriteString(": ")
+		if err := encodeOrdered(&entry, f.Entries[key]); err != nil {
+			return nil, err
+		}
+		if added.Len() > 0 {
+			added.WriteString(",")
+		}
+		added.WriteString(newline + indent)
+		added.Write(entry.Bytes())
+	}
+	if added.Len() == 0 {
+		return f.raw, nil
+	}
+
+	// The raw text keeps comments; only the positions come from the stripped copy
+	var out bytes.Buffer
+	if bytes.HasPrefix(f.raw, bom) {
+		out.Write(bom)
+	}
+	out.Write(raw[:last])
+	if c := stripped[last-1]; c != '{' && c != ',' {
+		out.WriteByte(',')
+	}
+	out.Write(added.Bytes())
+	out.WriteString(newline)
+	out.Write(raw[end:])
+	return out.Bytes(), nil
+}
+
+// CheckI18nTranslation verifies that a translation keeps the {{tokens}} of
+// the source string
+func CheckI18nTranslation(source, translated string) error {
+	if translated == "" && source != "" {
+		return fmt.Errorf("translation is empty")
+	}
+	if want, got := sortedMatches(i18nToken, source), sortedMatches(i18nToken, translated); want != got {
+		return fmt.Errorf("tokens changed from [%s] to [%s]", want, got)
+	}
+	return nil
+}
+
+var i18nToken = regexp.MustCompile(`\{\{[^{}]*\}\}`)
+
+// StardewMod is one SMAPI mod or content pack in an upload
+type StardewMod struct {
+	Root         string              `json:"root"` // folder holding manifest.json
+	Manifest     *SMAPIManifest      `json:"manifest"`
+	ContentPatch *ContentPatch       `json:"content_patch,omitempty"`
+	I18n         []*I18nFile         `json:"i18n,omitempty"`
+	Issues       []ContentPatchIssue `json:"issues,omitempty"`
+}
+
+// Errors returns the issues Content Patcher would refuse to load
+func (m *StardewMod) Errors() []ContentPatchIssue {
+	var errs []ContentPatchIssue
+	for _, issue := range m.Issues {
+		if issue.Severity == IssueError {
+			errs = append(errs, issue)
+		}
+	}
+	return errs
+}
+
+// IsStardewEditable reports whether an archive entry of a Stardew mod is
+// content the AI may edit: not its manifest, translations or code
+func IsStardewEditable(name string) bool {
+	base := path.Base(name)
+	dir := path.Base(path.Dir(name))
+	return strings.EqualFold(path.Ext(base), ".json") && !strings.EqualFold(base, "manifest.json") && dir != "i18n"
+}
+
+// ParseStardewMods reads every SMAPI mod in a zip. Mods sit at the root, in
+// a folder, or in a folder of a collection, each beside its manifest.json.
+func ParseStardewMods(content []byte) ([]*StardewMod, error) {
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	var mods []*StardewMod
+	for _, f := range archive.Files() {
+		if !strings.EqualFold(path.Base(f.Name), "manifest.json") || strings.Count(f.Name, "/") > 2 {
+			continue
+		}
+		data, err := archive.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		manifest, err := ParseSMAPIManifest(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		mod := &StardewMod{Root: strings.TrimSuffix(f.Name, path.Base(f.Name)), Manifest: manifest}
+		if err := mod.read(archive); err != nil {
+			return nil, err
+		}
+		mods = append(mods, mod)
+	}
+	if len(mods) == 0 {
+		return nil, fmt.Errorf("archive has no SMAPI manifest.json")
+	}
+	return mods, nil
+}
+
+// read loads a mod's translations and, for content packs, checks content.json
+// and the files it includes
+func (m *StardewMod) read(archive *Archive) error {
+	var keys map[string]bool
+	for _, f := range archive.Files() {
+		if !strings.HasPrefix(f.Name, m.Root+"i18n/") || strings.Count(f.Name, "/") != strings.Count(m.Root, "/")+1 || !strings.HasSuffix(f.Name, ".json") {
+			continue
+		}
+		data, err := archive.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		file, err := ParseI18n(f.Name, data)
+		if err != nil {
+			return err
+		}
+		m.I18n = append(m.I18n, file)
+		if file.Locale == I18nSourceLocale {
+			keys = make(map[string]bool, len(file.Keys))
+			for _, key := range file.Keys {
+				keys[key] = true
+			}
+		}
+	}
+
+	if !m.Manifest.IsContentPatcherPack() {
+		return nil
+	}
+	data, err := archive.ReadFileNamed(m.Root + "content.json")
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return fmt.Errorf("%s: Content Patcher pack has no content.json", m.Root+"manifest.json")
+	}
+	context := &ContentPatchContext{I18n: keys}
+	patch, issues, err := CheckContentPatch(m.Root+"content.json", data, context, true)
+	if err != nil {
+		return err
+	}
+	m.ContentPatch = patch
+	m.Issues = issues
+
+	// Included files share content.json's tokens and may include more files
+	queue := append([]string(nil), patch.Includes...)
+	seen := make(map[string]bool)
+	for len(queue) > 0 {
+		include := queue[0]
+		queue = queue[1:]
+		name := path.Join(strings.TrimSuffix(m.Root, "/"), include)
+		if seen[name] || strings.Contains(include, "{{") {
+			continue
+		}
+		seen[name] = true
+		data, err := archive.ReadFileNamed(name)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			m.Issues = append(m.Issues, ContentPatchIssue{File: m.Root + "content.json", Severity: IssueError, Message: fmt.Sprintf("included file %s does not exist", include)})
+			continue
+		}
+		included, issues, err := CheckContentPatch(name, data, context, false)
+		if err != nil {
+			return err
+		}
+		m.Issues = append(m.Issues, issues...)
+		patch.Changes += included.Changes
+		for action, n := range included.Actions {
+			patch.Actions[action] += n
+		}
+		patch.Targets = append(patch.Targets, included.Targets...)
+		queue = append(queue, included.Includes...)
+	}
+	return nil
+}
+
+// LocaleCoverage reports key coverage of every translation against i18n/default.json
+func (m *StardewMod) LocaleCoverage() []LocaleCoverage {
+	var source *I18nFile
+	for _, file := range m.I18n {
+		if file.Locale == I18nSourceLocale {
+			source = file
+		}
+	}
+	if source == nil {
+		return nil
+	}
+	var coverage []LocaleCoverage
+	for _, file := range m.I18n {
+		if file == source {
+			continue
+		}
+		c := LocaleCoverage{Namespace: m.Manifest.UniqueID, Locale: file.Locale, Total: len(source.Keys)}
+		for _, key := range source.Keys {
+			if _, ok := file.Entries[key]; ok {
+				c.Translated++
+			} else if len(c.Missing) < maxReportedMissingKeys {
+				c.Missing = append(c.Missing, key)
+			}
+		}
+		for key := range file.Entries {
+			if _, ok := source.Entries[key]; !ok {
+				c.Extra++
+			}
+		}
+		c.Percent = 100
+		if c.Total > 0 {
+			c.Percent = float64(c.Translated*1000/c.Total) / 10
+		}
+		coverage = append(coverage, c)
+	}
+	sort.Slice(coverage, func(i, j int) bool { return coverage[i].Locale < coverage[j].Locale })
+	return coverage
+}
+
+// TranslateStardewMods creates or completes the given locales of every mod in
+// a zip from its i18n/default.json. Nothing outside i18n/ is changed.
+func TranslateStardewMods(content []byte, locales []string, translate TranslateFunc) ([]byte, *TranslationReport, error) {
+	mods, err := ParseStardewMods(content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := &TranslationReport{Files: []string{}}
+	changes := make(map[string][]byte)
+	sources := 0
+	for _, mod := range mods {
+		existing := make(map[string]*I18nFile)
+		var source *I18nFile
+		for _, file := range mod.I18n {
+			existing[file.Locale] = file
+			if file.Locale == I18nSourceLocale {
+				source = file
+			}
+		}
+		if source == nil {
+			continue
+		}
+		sources++
+
+		for _, locale := range locales {
+			if !ValidSMAPILocale(locale) {
+				return nil, nil, fmt.Errorf("invalid target locale %q", locale)
+			}
+			target := existing[locale]
+			if target == nil {
+				target = &I18nFile{Path: mod.Root + "i18n/" + locale + ".json", Locale: locale, Entries: make(map[string]string)}
+			}
+			var missing []string
+			for _, key := range source.Keys {
+				if _, ok := target.Entries[key]; !ok {
+					missing = append(missing, key)
+				}
+			}
+			translated, issues, err := translateBatches(locale, missing, source.Entries, translate, CheckI18nTranslation)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s %s: %w", mod.Manifest.UniqueID, locale, err)
+			}
+			report.Issues = append(report.Issues, issues...)
+			if len(translated) == 0 {
+				continue
+			}
+			for _, key := range missing {
+				if value, ok := translated[key]; ok {
+					target.add(key, value)
+				}
+			}
+			data, err := target.Encode()
+			if err != nil {
+				return nil, nil, err
+			}
+			changes[target.Path] = data
+			report.Files = append(report.Files, target.Path)
+			report.Translated += len(translated)
+		}
+	}
+	if sources == 0 {
+		return nil, nil, fmt.Errorf("archive has no i18n/%s.json to translate from", I18nSourceLocale)
+	}
+
+	if len(changes) == 0 {
+		return content, report, nil
+	}
+	output, err := RewriteArchive(content, changes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return output, report, nil
+}
+
+// stardewFormat handles zips of SMAPI mods and content packs
+type stardewFormat struct{}
+
+func (stardewFormat) Info() FormatInfo {
+	return FormatInfo{
+		Name:       "stardew",
+		GameTypes:  []GameType{GameTypeStardew},
+		Extensions: []string{".zip"},
+		MIMETypes:  []string{"application/zip", "application/x-zip-compressed"},
+		MaxSize:    100 * 1024 * 1024,
+		Container:  true,
+	}
+}
+
+func (stardewFormat) Detect(filename string, content []byte) bool {
+	if !bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		return false
+	}
+	archive, err := OpenArchive(content, DefaultArchiveLimits)
+	if err != nil {
+		return false
+	}
+	return hasSMAPIManifest(archive)
+}
+
+// hasSMAPIManifest reports whether an archive has a manifest.json naming a UniqueID
+func hasSMAPIManifest(archive *Archive) bool {
+	for _, f := range archive.Files() {
+		if !strings.EqualFold(path.Base(f.Name), "manifest.json") || strings.Count(f.Name, "/") > 2 {
+			continue
+		}
+		data, err := archive.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		var manifest map[string]interface{}
+		if json.Unmarshal(stripJSONComments(data), &manifest) == nil && fieldOf(manifest, "UniqueID") != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (stardewFormat) Validate(filename string, content []byte) error {
+	if err := ScanArchive(content, DefaultArchiveLimits); err != nil {
+		return err
+	}
+	mods, err := ParseStardewMods(content)
+	if err != nil {
+		return fmt.Errorf("invalid SMAPI mod: %w", err)
+	}
+	for _, mod := range mods {
+		if errs := mod.Errors(); len(errs) > 0 {
+			return fmt.Errorf("invalid Content Patcher pack: %s %s: %s", errs[0].File, errs[0].Path, errs[0].Message)
+		}
+	}
+	return nil
+}
+
+func (stardewFormat) ExtractMetadata(content []byte) (map[string]interface{}, error) {
+	mods, err := ParseStardewMods(content)
+	if err != nil {
+		return nil, err
+	}
+	metadata := map[string]interface{}{
+		"format": "stardew",
+		"mods":   mods,
+	}
+	var coverage []LocaleCoverage
+	for _, mod := range mods {
+		coverage = append(coverage, mod.LocaleCoverage()...)
+	}
+	if len(coverage) > 0 {
+		metadata["lang_coverage"] = coverage
+	}
+	return metadata, nil
+}
+
+// Rewrite is not used for Stardew mods; their files are edited through EditArchive
+func (stardewFormat) Rewrite(original, edited []byte) ([]byte, error) {
+	return nil, ErrRewriteUnsupported
+}
+
+// contentPatchFormat handles Content Patcher JSON files, such as content.json
+// and the files it includes, which may contain comments
+type contentPatchFormat struct{}
+
+func (contentPatchFormat) Info() FormatInfo {
+	return FormatInfo{
+		Name:       "content_patcher",
+		GameTypes:  []GameType{GameTypeStardew},
+		Extensions: []string{".json"},
+		MIMETypes:  []string{"application/json", "text/json", "text/plain"},
+		MaxSize:    10 * 1024 * 1024,
+		Editable:   true,
+	}
+}
+
+func (contentPatchFormat) Detect(filename string, content []byte) bool {
+	return isContentPatch(content)
+}
+
+// isContentPatch reports whether content is a JSON object with a Changes list
+func isContentPatch(content []byte) bool {
+	var doc map[string]interface{}
+	if json.Unmarshal(stripJSONComments(content), &doc) != nil {
+		return false
+	}
+	_, ok := fieldOf(doc, "Changes").([]interface{})
+	return ok
+}
+
+func (contentPatchFormat) Validate(filename string, content []byte) error {
+	_, issues, err := CheckContentPatch(filename, content, nil, hasContentPatchFormat(content))
+	if err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		if issue.Severity == IssueError {
+			return fmt.Errorf("invalid Content Patcher file: %s: %s", issue.Path, issue.Message)
+		}
+	}
+	return nil
+}
+
+// hasContentPatchFormat reports whether a patch file declares a Format, as
+// content.json must; included files need not
+func hasContentPatchFormat(content []byte) bool {
+	var doc map[string]interface{}
+	return json.Unmarshal(stripJSONComments(content), &doc) == nil && fieldOf(doc, "Format") != nil
+}
+
+func (contentPatchFormat) ExtractMetadata(content []byte) (map[string]interface{}, error) {
+	patch, issues, err := CheckContentPatch("content.json", content, nil, hasContentPatchFormat(content))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"format":        "content_patcher",
+		"content_patch": patch,
+		"issues":        issues,
+	}, nil
+}
+
+// Rewrite rejects edits that break the patch structure or introduce tokens
+// and i18n keys the original did not use
+func (contentPatchFormat) Rewrite(original, edited []byte) ([]byte, error) {
+	edited = stripCodeFence(edited)
+	root := hasContentPatchFormat(original)
+	_, before, err := CheckContentPatch("original", original, nil, root)
+	if err != nil {
+		return nil, err
+	}
+	_, after, err := CheckContentPatch("edited", edited, nil, root)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(before))
+	for _, issue := range before {
+		known[issue.Message] = true
+	}
+	for _, issue := range after {
+		if !known[issue.Message] {
+			return nil, fmt.Errorf("edited patch: %s: %s", issue.Path, issue.Message)
+		}
+	}
+	return edited, nil
+}
@@ -0,0 +1,264 @@
+package mods
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+)
+
+// NBT file kinds, from the keys of the root compound
+const (
+	NBTKindStructure = "structure" // structure block and data pack structures
+	NBTKindLevel     = "level"     // level.dat
+	NBTKindSchematic = "schematic" // Sponge .schem and MCEdit .schematic
+	NBTKindLitematic = "litematic" // Litematica
+)
+
+// NBTKind identifies what a root compound holds, or returns an empty string
+func NBTKind(root *NBTCompound) string {
+	has := func(keys ...string) bool {
+		for _, key := range keys {
+			if _, ok := root.values[key]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+	switch {
+	case has("size", "blocks") && (has("palette") || has("palettes")):
+		return NBTKindStructure
+	case has("Data"):
+		return NBTKindLevel
+	case has("Regions", "Metadata"):
+		return NBTKindLitematic
+	case has("Width", "Height", "Length"), has("Schematic"):
+		return NBTKindSchematic
+	}
+	return ""
+}
+
+// NBTStructure summarises a structure file
+type NBTStructure struct {
+	DataVersion int32          `json:"data_version,omitempty"`
+	Size        [3]int32       `json:"size"`
+	Blocks      int            `json:"blocks"`
+	Entities    int            `json:"entities"`
+	Palettes    int            `json:"palettes"`     // more than one when the game picks a palette at random
+	BlockCounts map[string]int `json:"block_counts"` // placed blocks by name, from the first palette
+}
+
+// ParseNBTStructure reads a structure's size, palette and blocks, checking
+// that every block uses a palette state and lies inside the structure
+func ParseNBTStructure(root *NBTCompound) (*NBTStructure, error) {
+	s := &NBTStructure{BlockCounts: make(map[string]int)}
+	if v, ok := root.values["DataVersion"].(int32); ok {
+		s.DataVersion = v
+	}
+	size, ok := root.values["size"].(*NBTList)
+	if !ok || len(size.Items) != 3 || size.Type != TagInt {
+		return nil, fmt.Errorf("structure size must be a list of three ints")
+	}
+	for i, item := range size.Items {
+		n, ok := item.(int32)
+		if !ok {
+			return nil, fmt.Errorf("structure size must be a list of three ints")
+		}
+		s.Size[i] = n
+	}
+
+	var palettes []*NBTList
+	if palette, ok := root.values["palette"].(*NBTList); ok {
+		palettes = append(palettes, palette)
+	} else if list, ok := root.values["palettes"].(*NBTList); ok {
+		for _, item := range list.Items {
+			if palette, ok := item.(*NBTList); ok {
+				palettes = append(palettes, palette)
+			}
+		}
+	}
+	if len(palettes) == 0 {
+		return nil, fmt.Errorf("structure has no palette")
+	}
+	s.Palettes = len(palettes)
+	states := len(palettes[0].Items)
+	for i, palette := range palettes {
+		if len(palette.Items) != states {
+			return nil, fmt.Errorf("palette %d has %d states, want %d", i, len(palette.Items), states)
+		}
+		for j, item := range palette.Items {
+			state, _ := item.(*NBTCompound)
+			if state == nil {
+				return nil, fmt.Errorf("palette %d state %d is not a compound", i, j)
+			}
+			if name, _ := state.values["Name"].(string); name == "" {
+				return nil, fmt.Errorf("palette %d state %d has no Name", i, j)
+			}
+		}
+	}
+
+	blocks, _ := root.values["blocks"].(*NBTList)
+	if blocks == nil {
+		return nil, fmt.Errorf("structure blocks must be a list")
+	}
+	for i, item := range blocks.Items {
+		block, _ := item.(*NBTCompound)
+		if block == nil {
+			return nil, fmt.Errorf("block %d is not a compound", i)
+		}
+		state, ok := block.values["state"].(int32)
+		if !ok || state < 0 || int(state) >= states {
+			return nil, fmt.Errorf("block %d uses state %v, but the palette has %d states", i, block.values["state"], states)
+		}
+		pos, _ := block.values["pos"].(*NBTList)
+		if pos == nil || len(pos.Items) != 3 || pos.Type != TagInt {
+			return nil, fmt.Errorf("block %d pos must be a list of three ints", i)
+		}
+		for axis, v := range pos.Items {
+			if n, ok := v.(int32); !ok || n < 0 || n >= s.Size[axis] {
+				return nil, fmt.Errorf("block %d at %s lies outside the structure size %v", i, inlineSNBT(pos), s.Size)
+			}
+		}
+		name := palettes[0].Items[state].(*NBTCompound).values["Name"].(string)
+		s.BlockCounts[name]++
+	}
+	s.Blocks = len(blocks.Items)
+	if entities, ok := root.values["entities"].(*NBTList); ok {
+		s.Entities = len(entities.Items)
+	}
+	return s, nil
+}
+
+// isStructurePath reports whether an archive entry is a data pack structure
+func isStructurePath(name string) bool {
+	parts := strings.Split(name, "/")
+	return len(parts) >= 4 && parts[0] == "data" && (parts[2] == "structure" || parts[2] == "structures") && path.Ext(name) == ".nbt"
+}
+
+// ExtractStructures summarises the structure files of a data pack or mod
+func ExtractStructures(archive *Archive) (map[string]*NBTStructure, error) {
+	structures := make(map[string]*NBTStructure)
+	for _, f := range archive.Files() {
+		if !isStructurePath(f.Name) {
+			continue
+		}
+		data, err := archive.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		file, err := ReadNBT(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		s, err := ParseNBTStructure(file.Root)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		structures[f.Name] = s
+	}
+	return structures, nil
+}
+
+// nbtFormat handles NBT files: structures, level.dat and schematics. The AI
+// edits them as SNBT.
+type nbtFormat struct{}
+
+func (nbtFormat) Info() FormatInfo {
+	return FormatInfo{
+		Name:       "nbt",
+		GameTypes:  []GameType{GameTypeMinecraft},
+		Extensions: []string{".nbt", ".dat", ".schem", ".schematic", ".litematic"},
+		MIMETypes:  []string{"application/gzip", "application/x-gzip"},
+		MaxSize:    10 * 1024 * 1024,
+		Editable:   true,
+	}
+}
+
+func (nbtFormat) Detect(filename string, content []byte) bool {
+	return isNBT(content)
+}
+
+func (nbtFormat) Validate(filename string, content []byte) error {
+	file, err := ReadNBT(content)
+	if err != nil {
+		return fmt.Errorf("invalid NBT: %w", err)
+	}
+	if NBTKind(file.Root) == NBTKindStructure {
+		if _, err := ParseNBTStructure(file.Root); err != nil {
+			return fmt.Errorf("invalid structure: %w", err)
+		}
+	}
+	return nil
+}
+
+func (nbtFormat) ExtractMetadata(content []byte) (map[string]interface{}, error) {
+	file, err := ReadNBT(content)
+	if err != nil {
+		return nil, err
+	}
+	metadata := map[string]interface{}{
+		"format":      "nbt",
+		"compression": file.Compression,
+		"root_keys":   file.Root.Keys(),
+	}
+	if kind := NBTKind(file.Root); kind != "" {
+		metadata["kind"] = kind
+	}
+	if v, ok := file.Root.values["DataVersion"].(int32); ok {
+		metadata["data_version"] = v
+	}
+	if NBTKind(file.Root) == NBTKindStructure {
+		if s, err := ParseNBTStructure(file.Root); err == nil {
+			metadata["structure"] = s
+		}
+	}
+	return metadata, nil
+}
+
+// Decode returns the file as SNBT for the AI to edit
+func (nbtFormat) Decode(content []byte) ([]byte, error) {
+	file, err := ReadNBT(content)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(FormatSNBT(file.Root) + "\n"), nil
+}
+
+// Rewrite parses the edited SNBT and writes it with the original's root name
+// and compression. Structures must still be valid after the edit.
+func (nbtFormat) Rewrite(original, edited []byte) ([]byte, error) {
+	file, err := ReadNBT(original)
+	if err != nil {
+		return nil, err
+	}
+	value, err := ParseSNBT(string(stripCodeFence(edited)))
+	if err != nil {
+		return nil, err
+	}
+	root, ok := value.(*NBTCompound)
+	if !ok {
+		return nil, fmt.Errorf("edited SNBT must be a compound")
+	}
+	root = conformNBT(root, file.Root).(*NBTCompound)
+	if err := checkNBTLists(root, ""); err != nil {
+		return nil, fmt.Errorf("edited SNBT: %w", err)
+	}
+	if reflect.DeepEqual(root, file.Root) {
+		return original, nil
+	}
+	if NBTKind(file.Root) == NBTKindStructure {
+		if _, err := ParseNBTStructure(root); err != nil {
+			return nil, fmt.Errorf("edited structure is invalid: %w", err)
+		}
+	}
+
+	file.Root = root
+	output, err := file.Encode()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ReadNBT(output); err != nil {
+		return nil, fmt.Errorf("edited NBT does not read back: %w", err)
+	}
+	return output, nil
+}
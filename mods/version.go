@@ -0,0 +1,317 @@
+package mods
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a leniently parsed mod version such as 1.20.1, 0.15.0+build.1
+// or 1.0.0-beta.2. Non-numeric components compare as strings.
+type Version struct {
+	Raw   string
+	parts []string
+	pre   []string
+}
+
+// ParseVersion parses a version string, ignoring build metadata after '+'
+func ParseVersion(s string) Version {
+	v := Version{Raw: s}
+	s = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "v"))
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.pre = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	if s != "" {
+		v.parts = strings.Split(s, ".")
+	}
+	return v
+}
+
+// Compare returns -1, 0 or 1 as v is lower than, equal to or higher than o
+func (v Version) Compare(o Version) int {
+	n := max(len(v.parts), len(o.parts))
+	for i := 0; i < n; i++ {
+		if c := compareComponent(component(v.parts, i), component(o.parts, i)); c != 0 {
+			return c
+		}
+	}
+
+	// A pre-release sorts before the release it precedes
+	switch {
+	case len(v.pre) == 0 && len(o.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(o.pre) == 0:
+		return -1
+	}
+	n = max(len(v.pre), len(o.pre))
+	for i := 0; i < n; i++ {
+		if i >= len(v.pre) {
+			return -1
+		}
+		if i >= len(o.pre) {
+			return 1
+		}
+		if c := compareComponent(v.pre[i], o.pre[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func (v Version) String() string {
+	return v.Raw
+}
+
+func component(parts []string, i int) string {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return "0"
+}
+
+// compareComponent compares numerically when both components are numbers
+func compareComponent(a, b string) int {
+	ai, aErr := strconv.Atoi(a)
+	bi, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		switch {
+		case ai < bi:
+			return -1
+		case ai > bi:
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		return -1 // numeric identifiers sort before alphanumeric ones
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// bump returns the version with the component at index incremented and every
+// later component dropped, e.g. bump(1.2.3, 1) is 1.3
+func (v Version) bump(index int) (Version, error) {
+	parts := make([]string, index+1)
+	for i := 0; i <= index; i++ {
+		parts[i] = component(v.parts, i)
+	}
+	n, err := strconv.Atoi(parts[index])
+	if err != nil {
+		return Version{}, fmt.Errorf("cannot increment non-numeric version component %q", parts[index])
+	}
+	parts[index] = strconv.Itoa(n + 1)
+	return ParseVersion(strings.Join(parts, ".")), nil
+}
+
+// interval is a contiguous version range; nil bounds are unbounded
+type interval struct {
+	lower, upper                   *Version
+	lowerInclusive, upperInclusive bool
+}
+
+func (in interval) contains(v Version) bool {
+	if in.lower != nil {
+		c := v.Compare(*in.lower)
+		if c < 0 || (c == 0 && !in.lowerInclusive) {
+			return false
+		}
+	}
+	if in.upper != nil {
+		c := v.Compare(*in.upper)
+		if c > 0 || (c == 0 && !in.upperInclusive) {
+			return false
+		}
+	}
+	return true
+}
+
+// intersect narrows the interval to the overlap with other
+func (in interval) intersect(other interval) interval {
+	if other.lower != nil {
+		if in.lower == nil {
+			in.lower, in.lowerInclusive = other.lower, other.lowerInclusive
+		} else if c := other.lower.Compare(*in.lower); c > 0 || (c == 0 && !other.lowerInclusive) {
+			in.lower, in.lowerInclusive = other.lower, other.lowerInclusive
+		}
+	}
+	if other.upper != nil {
+		if in.upper == nil {
+			in.upper, in.upperInclusive = other.upper, other.upperInclusive
+		} else if c := other.upper.Compare(*in.upper); c < 0 || (c == 0 && !other.upperInclusive) {
+			in.upper, in.upperInclusive = other.upper, other.upperInclusive
+		}
+	}
+	return in
+}
+
+// VersionRange is a union of version intervals parsed from semver or Maven syntax
+type VersionRange struct {
+	Raw       string
+	intervals []interval
+}
+
+// AnyVersion matches every version
+var AnyVersion = VersionRange{Raw: "*", intervals: []interval{{}}}
+
+// Contains reports whether the version falls inside the range
+func (r VersionRange) Contains(v Version) bool {
+	for _, in := range r.intervals {
+		if in.contains(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r VersionRange) String() string {
+	return r.Raw
+}
+
+// ParseSemverRange parses Fabric and Quilt style predicates. Space-separated
+// comparators must all match and "||" separates alternatives. Supported
+// comparators are =, >, >=, <, <=, ~, ^, x-wildcards and *.
+func ParseSemverRange(s string) (VersionRange, error) {
+	r := VersionRange{Raw: s}
+	for _, alternative := range strings.Split(s, "||") {
+		in := interval{}
+		fields := strings.Fields(alternative)
+		if len(fields) == 0 {
+			fields = []string{"*"}
+		}
+		for _, field := range fields {
+			c, err := parseComparator(field)
+			if err != nil {
+				return VersionRange{}, err
+			}
+			in = in.intersect(c)
+		}
+		r.intervals = append(r.intervals, in)
+	}
+	return r, nil
+}
+
+// parseComparator converts a single semver comparator into an interval
+func parseComparator(field string) (interval, error) {
+	for _, op := range []string{">=", "<=", ">", "<", "=", "~", "^"} {
+		if !strings.HasPrefix(field, op) {
+			continue
+		}
+		v := ParseVersion(field[len(op):])
+		if len(v.parts) == 0 {
+			return interval{}, fmt.Errorf("missing version after %s in %q", op, field)
+		}
+		switch op {
+		case ">=":
+			return interval{lower: &v, lowerInclusive: true}, nil
+		case ">":
+			return interval{lower: &v}, nil
+		case "<=":
+			return interval{upper: &v, upperInclusive: true}, nil
+		case "<":
+			return interval{upper: &v}, nil
+		case "=":
+			return interval{lower: &v, lowerInclusive: true, upper: &v, upperInclusive: true}, nil
+		case "~":
+			// ~1.2.3 allows patch updates, ~1 allows minor updates
+			upper, err := v.bump(min(1, len(v.parts)-1))
+			if err != nil {
+				return interval{}, err
+			}
+			return interval{lower: &v, lowerInclusive: true, upper: &upper}, nil
+		case "^":
+			// ^ allows updates that keep the first non-zero component
+			index := 0
+			for index < len(v.parts)-1 && v.parts[index] == "0" {
+				index++
+			}
+			upper, err := v.bump(index)
+			if err != nil {
+				return interval{}, err
+			}
+			return interval{lower: &v, lowerInclusive: true, upper: &upper}, nil
+		}
+	}
+
+	if field == "*" || field == "x" || field == "X" {
+		return interval{}, nil
+	}
+
+	// Wildcards such as 1.20.x match every version with that prefix
+	parts := strings.Split(field, ".")
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			if i == 0 {
+				return interval{}, nil
+			}
+			lower := ParseVersion(strings.Join(parts[:i], "."))
+			upper, err := lower.bump(i - 1)
+			if err != nil {
+				return interval{}, err
+			}
+			return interval{lower: &lower, lowerInclusive: true, upper: &upper}, nil
+		}
+	}
+
+	// A bare version is an exact match
+	v := ParseVersion(field)
+	return interval{lower: &v, lowerInclusive: true, upper: &v, upperInclusive: true}, nil
+}
+
+// ParseMavenRange parses Forge-style Maven version ranges such as
+// [1.20,1.21), [47,) or (,1.0],[1.2,). A bare version is a Maven soft
+// requirement and matches any version.
+func ParseMavenRange(s string) (VersionRange, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "*" {
+		return VersionRange{Raw: s, intervals: []interval{{}}}, nil
+	}
+	if !strings.ContainsAny(s[:1], "[(") {
+		return VersionRange{Raw: s, intervals: []interval{{}}}, nil
+	}
+
+	r := VersionRange{Raw: s}
+	rest := s
+	for rest != "" {
+		end := strings.IndexAny(rest, "])")
+		if end < 0 || !strings.ContainsAny(rest[:1], "[(") {
+			return VersionRange{}, fmt.Errorf("malformed version range %q", s)
+		}
+		spec := rest[1:end]
+		in := interval{lowerInclusive: rest[0] == '[', upperInclusive: rest[end] == ']'}
+
+		bounds := strings.Split(spec, ",")
+		switch len(bounds) {
+		case 1:
+			// [1.0] is an exact version
+			if !in.lowerInclusive || !in.upperInclusive {
+				return VersionRange{}, fmt.Errorf("single version range must use brackets in %q", s)
+			}
+			v := ParseVersion(bounds[0])
+			in.lower, in.upper = &v, &v
+		case 2:
+			if lower := strings.TrimSpace(bounds[0]); lower != "" {
+				v := ParseVersion(lower)
+				in.lower = &v
+			}
+			if upper := strings.TrimSpace(bounds[1]); upper != "" {
+				v := ParseVersion(upper)
+				in.upper = &v
+			}
+		default:
+			return VersionRange{}, fmt.Errorf("malformed version range %q", s)
+		}
+		r.intervals = append(r.intervals, in)
+
+		rest = strings.TrimPrefix(strings.TrimSpace(rest[end+1:]), ",")
+		rest = strings.TrimSpace(rest)
+	}
+	return r, nil
+}
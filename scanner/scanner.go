@@ -0,0 +1,106 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"modforge.ai/mods"
+)
+
+// Scanner checks an uploaded file and reports suspicious findings
+type Scanner interface {
+	// Name identifies the scanner in findings and errors
+	Name() string
+	// Scan returns the scanner's findings for a file
+	Scan(ctx context.Context, filename string, content []byte) ([]mods.Finding, error)
+}
+
+// Result is the combined outcome of every scanner
+type Result struct {
+	Findings    []mods.Finding `json:"findings"`
+	Errors      []string       `json:"errors,omitempty"`
+	Quarantined bool           `json:"quarantined"`
+	// Incomplete is set when the local heuristics or every scanner failed,
+	// so the findings cannot clear the file
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// maxConcurrentScans bounds how many uploads are scanned at once
+const maxConcurrentScans = 4
+
+// scanTimeout bounds a background scan, including remote lookups
+const scanTimeout = 10 * time.Minute
+
+// Service runs the configured scanners over uploads
+type Service struct {
+	scanners []Scanner
+	slots    chan struct{}
+}
+
+// New creates a scan service with the local heuristics, adding VirusTotal
+// when an API key is configured
+func New(virusTotalKey string) *Service {
+	scanners := []Scanner{Heuristic{}}
+	if virusTotalKey != "" {
+		scanners = append(scanners, NewVirusTotal(virusTotalKey))
+	}
+	return NewService(scanners...)
+}
+
+// NewService creates a scan service with the given scanners
+func NewService(scanners ...Scanner) *Service {
+	return &Service{scanners: scanners, slots: make(chan struct{}, maxConcurrentScans)}
+}
+
+// Scan runs every scanner over a file. A scanner that fails does not stop the
+// others; its error is recorded in the result, which is incomplete when the
+// heuristics or every scanner failed.
+func (s *Service) Scan(ctx context.Context, filename string, content []byte) *Result {
+	result := &Result{Findings: []mods.Finding{}}
+	for _, scanner := range s.scanners {
+		findings, err := scanner.Scan(ctx, filename, content)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", scanner.Name(), err))
+			if _, ok := scanner.(Heuristic); ok {
+				result.Incomplete = true
+			}
+			continue
+		}
+		for _, f := range findings {
+			f.Scanner = scanner.Name()
+			result.Findings = append(result.Findings, f)
+		}
+	}
+	if len(result.Errors) == len(s.scanners) {
+		result.Incomplete = true
+	}
+	result.Quarantined = mods.ShouldQuarantine(result.Findings)
+	return result
+}
+
+// ScanAsync scans a file in the background and passes the result to done.
+// Scans beyond the concurrency limit wait for a free slot.
+func (s *Service) ScanAsync(filename string, content []byte, done func(*Result)) {
+	go func() {
+		s.slots <- struct{}{}
+		defer func() { <-s.slots }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), scanTimeout)
+		defer cancel()
+		done(s.Scan(ctx, filename, content))
+	}()
+}
+
+// Heuristic is the local static analysis scanner
+type Heuristic struct{}
+
+// Name identifies the scanner
+func (Heuristic) Name() string {
+	return "heuristic"
+}
+
+// Scan applies the static malware heuristics
+func (Heuristic) Scan(ctx context.Context, filename string, content []byte) ([]mods.Finding, error) {
+	return mods.ScanForMalware(filename, content)
+}
@@ -0,0 +1,185 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"modforge.ai/mods"
+)
+
+// maxVirusTotalUpload is the largest file the direct upload endpoint accepts
+const maxVirusTotalUpload = 32 * 1024 * 1024
+
+// VirusTotal looks files up by hash and uploads unknown files for analysis
+type VirusTotal struct {
+	apiKey       string
+	baseURL      string
+	client       *http.Client
+	pollInterval time.Duration
+	timeout      time.Duration
+}
+
+// NewVirusTotal creates a VirusTotal scanner
+func NewVirusTotal(apiKey string) *VirusTotal {
+	return &VirusTotal{
+		apiKey:       apiKey,
+		baseURL:      "https://www.virustotal.com/api/v3",
+		client:       &http.Client{Timeout: 30 * time.Second},
+		pollInterval: 15 * time.Second,
+		timeout:      5 * time.Minute,
+	}
+}
+
+// Name identifies the scanner
+func (v *VirusTotal) Name() string {
+	return "virustotal"
+}
+
+// analysisStats are the engine verdict counts VirusTotal reports
+type analysisStats struct {
+	Malicious  int `json:"malicious"`
+	Suspicious int `json:"suspicious"`
+	Undetected int `json:"undetected"`
+	Harmless   int `json:"harmless"`
+}
+
+// Scan looks the file up by SHA-256, uploading it when VirusTotal has not
+// seen it, and reports engine detections
+func (v *VirusTotal) Scan(ctx context.Context, filename string, content []byte) ([]mods.Finding, error) {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	var report struct {
+		Data struct {
+			Attributes struct {
+				Stats analysisStats `json:"last_analysis_stats"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	status, err := v.get(ctx, "/files/"+hash, &report)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusOK {
+		return statsFindings(report.Data.Attributes.Stats), nil
+	}
+
+	stats, err := v.analyze(ctx, filename, content)
+	if err != nil {
+		return nil, err
+	}
+	return statsFindings(stats), nil
+}
+
+// analyze uploads a file and waits for its analysis to complete
+func (v *VirusTotal) analyze(ctx context.Context, filename string, content []byte) (analysisStats, error) {
+	if len(content) > maxVirusTotalUpload {
+		return analysisStats{}, fmt.Errorf("file is too large to upload (%dMB limit)", maxVirusTotalUpload/(1024*1024))
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return analysisStats{}, err
+	}
+	part.Write(content)
+	form.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.baseURL+"/files", &body)
+	if err != nil {
+		return analysisStats{}, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	var upload struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if _, err := v.do(req, &upload); err != nil {
+		return analysisStats{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+	for {
+		var analysis struct {
+			Data struct {
+				Attributes struct {
+					Status string        `json:"status"`
+					Stats  analysisStats `json:"stats"`
+				} `json:"attributes"`
+			} `json:"data"`
+		}
+		if _, err := v.get(ctx, "/analyses/"+upload.Data.ID, &analysis); err != nil {
+			return analysisStats{}, err
+		}
+		if analysis.Data.Attributes.Status == "completed" {
+			return analysis.Data.Attributes.Stats, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return analysisStats{}, fmt.Errorf("analysis did not complete: %w", ctx.Err())
+		case <-time.After(v.pollInterval):
+		}
+	}
+}
+
+func (v *VirusTotal) get(ctx context.Context, path string, out interface{}) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.baseURL+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	return v.do(req, out)
+}
+
+// do sends an authenticated request. A 404 is returned as a status rather
+// than an error so callers can treat unknown files as a cache miss.
+func (v *VirusTotal) do(req *http.Request, out interface{}) (int, error) {
+	req.Header.Set("x-apikey", v.apiKey)
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("VirusTotal request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return resp.StatusCode, nil
+	case resp.StatusCode != http.StatusOK:
+		return resp.StatusCode, fmt.Errorf("VirusTotal returned status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.StatusCode, fmt.Errorf("invalid VirusTotal response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// statsFindings converts engine verdict counts into findings
+func statsFindings(stats analysisStats) []mods.Finding {
+	engines := stats.Malicious + stats.Suspicious + stats.Undetected + stats.Harmless
+	var findings []mods.Finding
+	if stats.Malicious > 0 {
+		findings = append(findings, mods.Finding{
+			Rule:     "engine_detection",
+			Severity: mods.SeverityHigh,
+			Detail:   fmt.Sprintf("%d of %d engines flag this file as malicious", stats.Malicious, engines),
+		})
+	}
+	if stats.Suspicious > 0 {
+		findings = append(findings, mods.Finding{
+			Rule:     "engine_suspicion",
+			Severity: mods.SeverityMedium,
+			Detail:   fmt.Sprintf("%d of %d engines flag this file as suspicious", stats.Suspicious, engines),
+		})
+	}
+	return findings
+}
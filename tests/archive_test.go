@@ -0,0 +1,167 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"modforge.ai/mods"
+)
+
+func TestScanArchiveRejectsUnsafeArchives(t *testing.T) {
+	nested := buildZip(t, [2]string{"a.txt", "a"})
+	for i := 0; i < 4; i++ {
+		nested = buildZip(t, [2]string{"inner.jar", string(nested)})
+	}
+
+	cases := []struct {
+		name    string
+		archive []byte
+		want    mods.ArchiveViolation
+	}{
+		{"zip slip", buildZip(t, [2]string{"../../evil.sh", "x"}), mods.ViolationPathTraversal},
+		{"windows zip slip", buildZip(t, [2]string{`assets\..\..\evil.sh`, "x"}), mods.ViolationPathTraversal},
+		{"absolute path", buildZip(t, [2]string{"/etc/passwd", "x"}), mods.ViolationAbsolutePath},
+		{"long path", buildZip(t, [2]string{strings.Repeat("a/", 300) + "b", "x"}), mods.ViolationPathLength},
+		{"compression bomb", buildZip(t, [2]string{"zeros.bin", strings.Repeat("\x00", 8*1024*1024)}), mods.ViolationCompressionRatio},
+		{"nesting", nested, mods.ViolationNestingDepth},
+	}
+
+	for _, tc := range cases {
+		err := mods.ScanArchive(tc.archive, mods.DefaultArchiveLimits)
+		var archiveErr *mods.ArchiveError
+		if !errors.As(err, &archiveErr) {
+			t.Errorf("%s: got %v, want an archive error", tc.name, err)
+			continue
+		}
+		if archiveErr.Reason != tc.want {
+			t.Errorf("%s: reason %s, want %s", tc.name, archiveErr.Reason, tc.want)
+		}
+	}
+
+	ok := buildZip(t, [2]string{"fabric.mod.json", `{"id": "ok"}`}, [2]string{"assets/ok/lang/en_us.json", "{}"})
+	if err := mods.ScanArchive(ok, mods.DefaultArchiveLimits); err != nil {
+		t.Errorf("ordinary jar rejected: %v", err)
+	}
+}
+
+func TestRewriteArchivePreservesLayout(t *testing.T) {
+	modified := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, e := range []struct {
+		name, content string
+		method        uint16
+	}{
+		{"META-INF/MANIFEST.MF", "Manifest-Version: 1.0\r\n\r\nName: a/B.class\r\nSHA-256-Digest: abc=\r\n\r\n", zip.Deflate},
+		{"META-INF/SIGNER.SF", "Signature-Version: 1.0\r\n", zip.Deflate},
+		{"META-INF/SIGNER.RSA", "sig", zip.Store},
+		{"a/B.class", "\xca\xfe\xba\xbe", zip.Store},
+		{"assets/example/lang/en_us.json", `{"item.example.sword": "Sword"}`, zip.Deflate},
+	} {
+		f, err := w.CreateHeader(&zip.FileHeader{Name: e.name, Method: e.method, Modified: modified})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(e.content))
+	}
+	w.Close()
+
+	output, err := mods.RewriteArchive(buf.Bytes(), map[string][]byte{
+		"assets/example/lang/en_us.json": []byte(`{"item.example.sword": "Blade"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(output), int64(len(output)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range reader.File {
+		names = append(names, f.Name)
+		if !f.Modified.Equal(modified) {
+			t.Errorf("%s: modified %v, want %v", f.Name, f.Modified, modified)
+		}
+		if f.Name == "a/B.class" && f.Method != zip.Store {
+			t.Errorf("a/B.class: method %d, want stored", f.Method)
+		}
+		if f.Name == "META-INF/MANIFEST.MF" {
+			rc, _ := f.Open()
+			manifest, _ := io.ReadAll(rc)
+			rc.Close()
+			if strings.Contains(string(manifest), "Digest") {
+				t.Errorf("manifest still has digests: %q", manifest)
+			}
+		}
+	}
+	want := []string{"META-INF/MANIFEST.MF", "a/B.class", "assets/example/lang/en_us.json"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("entries = %v, want %v", names, want)
+	}
+
+	// Rewritten and renamed entries carry one extended timestamp field
+	renamed, err := mods.RenameArchive(output, func(name string) (string, bool) {
+		return strings.Replace(name, "example", "renamed", 1), true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, archive := range [][]byte{output, renamed} {
+		reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range reader.File {
+			if n := extraFieldCount(f.Extra, 0x5455); n > 1 {
+				t.Errorf("%s has %d extended timestamp fields", f.Name, n)
+			}
+			if !f.Modified.Equal(modified) {
+				t.Errorf("%s: modified %v, want %v", f.Name, f.Modified, modified)
+			}
+		}
+	}
+}
+
+// extraFieldCount counts the extra fields of a zip entry with a header ID
+func extraFieldCount(extra []byte, id uint16) int {
+	n := 0
+	for len(extra) >= 4 {
+		size := int(extra[2]) | int(extra[3])<<8
+		if int(extra[0])|int(extra[1])<<8 == int(id) {
+			n++
+		}
+		if len(extra) < 4+size {
+			break
+		}
+		extra = extra[4+size:]
+	}
+	return n
+}
+
+func TestEditArchiveSkipsLoaderFiles(t *testing.T) {
+	jar := buildZip(t,
+		[2]string{"fabric.mod.json", `{"id": "example", "mixins": ["example.client.json"]}`},
+		[2]string{"example.mixins.json", `{"package": "com.example.mixin", "refmap": "example-refmap.json"}`},
+		[2]string{"example.client.json", `{"package": "com.example.mixin.client"}`},
+		[2]string{"example-refmap.json", `{"mappings": {}}`},
+		[2]string{"pack.mcmeta", `{"pack": {"pack_format": 15, "description": "Example"}}`},
+		[2]string{"assets/example/lang/en_us.json", `{"item.example.ruby": "Ruby"}`},
+	)
+	var seen []string
+	_, changed, err := mods.EditArchive(jar, func(name string, content []byte) ([]byte, error) {
+		seen = append(seen, name)
+		return bytes.ReplaceAll(content, []byte("Ruby"), []byte("Rubin")), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 1 || seen[0] != "assets/example/lang/en_us.json" || len(changed) != 1 {
+		t.Errorf("edited %v, changed %v; want only the lang file", seen, changed)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+const (
+	bedrockBehaviorUUID = "a1b2c3d4-0000-4000-8000-000000000001"
+	bedrockResourceUUID = "a1b2c3d4-0000-4000-8000-000000000002"
+)
+
+func TestBedrockAddon(t *testing.T) {
+	resource := buildZip(t,
+		[2]string{"manifest.json", `{
+  "format_version": 2,
+  "header": {"name": "Golems RP", "uuid": "` + bedrockResourceUUID + `", "version": [1, 0, 0], "min_engine_version": [1, 20, 0]},
+  "modules": [{"type": "resources", "uuid": "a1b2c3d4-0000-4000-8000-000000000012", "version": [1, 0, 0]}]
+}`},
+		[2]string{"entity/golem.entity.json", `{"format_version": "1.10.0", "minecraft:client_entity": {"description": {"identifier": "demo:golem"}}}`},
+		[2]string{"texts/en_US.lang", "## names\nentity.demo:golem.name=Golem\t## shown in chat\nitem.demo:gear.name=Gear\n"},
+		[2]string{"texts/de_DE.lang", "entity.demo:golem.name=Golem\n"},
+	)
+	content := buildZip(t,
+		[2]string{"Golems BP/manifest.json", `{
+    "format_version": 3,
+    "header": {"name": "Golems BP", "uuid": "` + bedrockBehaviorUUID + `", "version": "1.2.3", "min_engine_version": "1.21.0"},
+    "modules": [{"type": "data", "uuid": "a1b2c3d4-0000-4000-8000-000000000011", "version": "1.2.3"}],
+    "dependencies": [
+        {"uuid": "` + bedrockResourceUUID + `", "version": [1, 0, 0]},
+        {"module_name": "@minecraft/server", "version": "1.11.0"},
+        {"uuid": "a1b2c3d4-0000-4000-8000-0000000000ff", "version": [1, 0, 0]}
+    ]
+}`},
+		[2]string{"Golems BP/entities/golem.json", `{"format_version": "1.20.0", "minecraft:entity": {"description": {"identifier": "demo:golem"}, "components": {"minecraft:health": {"value": 40}}}}`},
+		[2]string{"Golems BP/items/gear.json", `{"format_version": "1.20.0", "minecraft:item": {"description": {"identifier": "demo:gear"}}}`},
+		[2]string{"Golems RP.mcpack", string(resource)},
+	)
+
+	format, err := mods.FormatFor("golems.mcaddon", content)
+	if err != nil || format.Info().Name != "bedrock" {
+		t.Fatalf("FormatFor = %v, %v; want bedrock", format, err)
+	}
+	if best := mods.Detect("golems.mcaddon", content).Best(); best.GameType != mods.GameTypeBedrock {
+		t.Errorf("detected %s, want minecraft_bedrock", best.GameType)
+	}
+
+	addon, err := mods.ParseBedrockAddon(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addon.Packs) != 2 {
+		t.Fatalf("packs = %+v", addon.Packs)
+	}
+	behavior, rp := addon.Packs[0], addon.Packs[1]
+	if behavior.Kind != mods.BedrockBehaviorPack || rp.Kind != mods.BedrockResourcePack || rp.Path != "Golems RP.mcpack!/manifest.json" {
+		t.Errorf("packs = %+v, %+v", behavior, rp)
+	}
+	if got := behavior.Defines["entity"]; len(got) != 1 || got[0] != "demo:golem" || behavior.Defines["item"][0] != "demo:gear" {
+		t.Errorf("behavior defines %v", behavior.Defines)
+	}
+	if len(addon.Unresolved) != 1 || !strings.HasSuffix(addon.Unresolved[0].UUID, "ff") {
+		t.Errorf("unresolved = %+v", addon.Unresolved)
+	}
+	if lang := rp.Langs[0]; lang.Locale != "en_US" || lang.Entries["entity.demo:golem.name"] != "Golem" {
+		t.Errorf("en_US entries = %v", lang.Entries)
+	}
+	if coverage := addon.LangCoverage(); len(coverage) != 1 || coverage[0].Translated != 1 || coverage[0].Missing[0] != "item.demo:gear.name" {
+		t.Errorf("coverage = %+v", coverage)
+	}
+
+	if !mods.IsBedrockEditable("Golems BP/entities/golem.json") || mods.IsBedrockEditable("Golems BP/manifest.json") || mods.IsBedrockEditable("texts/languages.json") {
+		t.Error("IsBedrockEditable should accept content JSON only")
+	}
+
+	derived, derivations, err := mods.DeriveBedrockPacks(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(derivations) != 2 || derivations[0].NewVersion.String() != "1.2.4" || derivations[1].NewVersion.String() != "1.0.1" {
+		t.Fatalf("derivations = %+v", derivations)
+	}
+	manifest := readZipEntry(t, derived, "Golems BP/manifest.json")
+	if strings.Contains(manifest, bedrockBehaviorUUID) || strings.Contains(manifest, bedrockResourceUUID) || strings.Contains(manifest, "000000000011") {
+		t.Errorf("derived manifest kept old uuids:\n%s", manifest)
+	}
+	if !strings.Contains(manifest, derivations[1].NewUUID) || !strings.Contains(manifest, `"version": "1.2.4"`) || !strings.Contains(manifest, "\n    \"header\"") {
+		t.Errorf("derived manifest:\n%s", manifest)
+	}
+	redone, err := mods.ParseBedrockAddon(derived)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := redone.Packs[1].Manifest; got.UUID != derivations[1].NewUUID || got.Version.String() != "1.0.1" || got.Modules[0].Version.String() != "1.0.1" {
+		t.Errorf("derived nested manifest = %+v", got)
+	}
+	if _, err := mods.ParseBedrockManifest([]byte(`{"format_version": 2, "header": {"uuid": "nope", "version": [1, 0, 0]}, "modules": []}`)); err == nil {
+		t.Error("manifest with an invalid uuid should be rejected")
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"mime/multipart"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+// buildPluginHeader builds a minimal TES4 record with HEDR and MAST subrecords
+func buildPluginHeader(oblivion bool, version float32, formVersion uint16, flags uint32, masters ...string) []byte {
+	var data bytes.Buffer
+	subrecord := func(sig string, payload []byte) {
+		data.WriteString(sig)
+		binary.Write(&data, binary.LittleEndian, uint16(len(payload)))
+		data.Write(payload)
+	}
+
+	hedr := make([]byte, 12)
+	binary.LittleEndian.PutUint32(hedr[0:4], math.Float32bits(version))
+	binary.LittleEndian.PutUint32(hedr[4:8], 42)
+	binary.LittleEndian.PutUint32(hedr[8:12], 0x800)
+	subrecord("HEDR", hedr)
+	subrecord("CNAM", []byte("tester\x00"))
+	for _, master := range masters {
+		subrecord("MAST", append([]byte(master), 0))
+		subrecord("DATA", make([]byte, 8))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("TES4")
+	binary.Write(&buf, binary.LittleEndian, uint32(data.Len()))
+	binary.Write(&buf, binary.LittleEndian, flags)
+	binary.Write(&buf, binary.LittleEndian, uint32(0)) // form ID
+	binary.Write(&buf, binary.LittleEndian, uint32(0)) // version control
+	if !oblivion {
+		binary.Write(&buf, binary.LittleEndian, formVersion)
+		binary.Write(&buf, binary.LittleEndian, uint16(0))
+	}
+	buf.Write(data.Bytes())
+	return buf.Bytes()
+}
+
+func TestParsePluginHeaderIdentifiesGame(t *testing.T) {
+	cases := []struct {
+		name        string
+		oblivion    bool
+		version     float32
+		formVersion uint16
+		want        mods.GameType
+	}{
+		{"oblivion", true, 1.0, 0, mods.GameTypeOblivion},
+		{"skyrim le", false, 0.94, 43, mods.GameTypeSkyrim},
+		{"skyrim se", false, 1.7, 44, mods.GameTypeSkyrimSE},
+		{"fallout 4", false, 0.95, 131, mods.GameTypeFallout4},
+		{"starfield", false, 0.96, 555, mods.GameTypeStarfield},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			content := buildPluginHeader(tc.oblivion, tc.version, tc.formVersion, 0, "Base.esm")
+			header, err := mods.ParsePluginHeader(content)
+			if err != nil {
+				t.Fatalf("ParsePluginHeader: %v", err)
+			}
+			if header.Game != tc.want {
+				t.Errorf("game = %s, want %s", header.Game, tc.want)
+			}
+			if len(header.Masters) != 1 || header.Masters[0] != "Base.esm" {
+				t.Errorf("masters = %v", header.Masters)
+			}
+			if header.Author != "tester" {
+				t.Errorf("author = %q", header.Author)
+			}
+
+			got := mods.DetectGameType(&multipart.FileHeader{Filename: "test.esp"}, content)
+			if got != tc.want {
+				t.Errorf("DetectGameType = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidatePluginRejectsLightOblivion(t *testing.T) {
+	header, err := mods.ParsePluginHeader(buildPluginHeader(true, 1.0, 0, 0))
+	if err != nil {
+		t.Fatalf("ParsePluginHeader: %v", err)
+	}
+	if err := mods.ValidatePlugin(header, "test.esl"); err == nil {
+		t.Error("expected .esl to be rejected for Oblivion")
+	}
+}
+
+func TestDetectGameTypeRejectsInvalidPlugin(t *testing.T) {
+	got := mods.DetectGameType(&multipart.FileHeader{Filename: "test.esp"}, []byte("not a plugin"))
+	if got != mods.GameTypeUnknown {
+		t.Errorf("DetectGameType = %s, want unknown", got)
+	}
+}
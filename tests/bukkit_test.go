@@ -0,0 +1,134 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+const testPluginYML = `name: ShopKeeper
+version: 2.1.0
+main: com.example.shop.ShopPlugin
+api-version: '1.20'
+authors: [Alex, "Sam"]
+depend: [Vault]
+softdepend:
+  - Essentials
+commands:
+  shop:
+    description: Open the shop
+    aliases: store
+    permission: shop.use
+  sell:
+permissions:
+  shop.use:
+    description: Use the shop
+    default: true
+  shop.*:
+    default: op
+    children:
+      shop.use: true
+      shop.admin: false
+`
+
+func TestBukkitPlugin(t *testing.T) {
+	jar := buildZip(t,
+		[2]string{"plugin.yml", testPluginYML},
+		[2]string{"com/example/shop/ShopPlugin.class", "\xca\xfe\xba\xbe"},
+		[2]string{"config.yml", "cooldown: 30\n"},
+		[2]string{"messages.yml", "# Shop messages\nprefix: '&8[&6Shop&8] '\nbought: \"&aYou bought %amount% {item}!\"\nhelp:\n  - Use /shop to browse\n  - 'It''s open'\nmotd: |\n  Welcome\nlimit: 5\n"},
+	)
+	if best := mods.Detect("ShopKeeper.jar", jar).Best(); best.GameType != mods.GameTypeMinecraft {
+		t.Errorf("detected %s, want minecraft", best.GameType)
+	}
+
+	descriptors, err := mods.ParseDescriptors("ShopKeeper.jar", jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []mods.Relation{{Kind: mods.RelationDepends, ModID: "Vault"}, {Kind: mods.RelationRecommends, ModID: "Essentials"}}
+	if len(descriptors) != 1 || descriptors[0].Loader != mods.LoaderBukkit || descriptors[0].Version != "2.1.0" || !reflect.DeepEqual(descriptors[0].Relations, want) {
+		t.Errorf("descriptors = %+v", descriptors)
+	}
+
+	format, _ := mods.FormatFor("ShopKeeper.jar", jar)
+	metadata, err := format.ExtractMetadata(jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin := metadata["bukkit_plugin"].(*mods.BukkitPlugin)
+	if plugin.APIVersion != "1.20" || !reflect.DeepEqual(plugin.Authors, []string{"Alex", "Sam"}) || len(plugin.Commands) != 2 ||
+		!reflect.DeepEqual(plugin.Commands[1].Aliases, []string{"store"}) || plugin.Commands[0].Name != "sell" {
+		t.Errorf("plugin = %+v", plugin)
+	}
+	if len(plugin.Permissions) != 2 || plugin.Permissions[0].Name != "shop.*" || plugin.Permissions[0].Children["shop.admin"] || plugin.Permissions[1].Default != "true" {
+		t.Errorf("permissions = %+v", plugin.Permissions)
+	}
+	if !mods.IsBukkitEditable("messages.yml") || !mods.IsBukkitEditable("lang/en.yml") || mods.IsBukkitEditable("plugin.yml") {
+		t.Error("IsBukkitEditable should allow config.yml, messages.yml and lang files only")
+	}
+
+	paper, err := mods.ParseBukkitPlugin([]byte("name: Shop\nversion: '1'\nmain: a.B\ndependencies:\n  server:\n    Vault:\n      load: BEFORE\n    LuckPerms:\n      required: false\n"), true)
+	if err != nil || !reflect.DeepEqual(paper.Depend, []string{"Vault"}) || !reflect.DeepEqual(paper.SoftDepend, []string{"LuckPerms"}) {
+		t.Errorf("paper plugin = %+v, %v", paper, err)
+	}
+	for yml, want := range map[string]string{
+		"name: Shop\nversion: 1\n":                               "main is required",
+		"name: Shop!\nversion: 1\nmain: a.B\n":                   `name "Shop!" may only use letters, digits, spaces, underscores, periods and hyphens`,
+		"name: Shop\nversion: 1\nmain: a.B\napi-version: 1.2x\n": `api-version "1.2x" is not a Minecraft version such as 1.20`,
+	} {
+		if _, err := mods.ParseBukkitPlugin([]byte(yml), false); err == nil || err.Error() != want {
+			t.Errorf("%q: error %v, want %s", yml, err, want)
+		}
+	}
+	if err := format.Validate("Broken.jar", buildZip(t, [2]string{"plugin.yml", testPluginYML})); err == nil || !strings.Contains(err.Error(), "main class com.example.shop.ShopPlugin is not in the jar") {
+		t.Errorf("missing main class error = %v", err)
+	}
+}
+
+func TestBukkitMessageTranslation(t *testing.T) {
+	messages := "# Shop messages\nprefix: '&8[&6Shop&8] '\nbought: \"&aYou bought %amount% {item}!\"\nhelp:\n  - Use /shop to browse\n  - 'It''s open'\nmotd: |\n  Welcome\nlimit: 5\n"
+	jar := buildZip(t,
+		[2]string{"plugin.yml", testPluginYML},
+		[2]string{"com/example/shop/ShopPlugin.class", "\xca\xfe\xba\xbe"},
+		[2]string{"messages.yml", messages},
+	)
+	translations := map[string]string{
+		"prefix":  "&8[&6Laden&8] ",
+		"bought":  "&aDu hast {item} gekauft!", // drops %amount%
+		"help[0]": "Nutze /shop: Laden",
+		"help[1]": "Er hat 'offen'",
+	}
+	translate := func(locale string, batch map[string]string) (map[string]string, error) {
+		out := make(map[string]string)
+		for key := range batch {
+			out[key] = translations[key]
+		}
+		return out, nil
+	}
+	output, report, err := mods.TranslateBukkitMessages(jar, []string{"de_de"}, translate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Translated != 3 || !reflect.DeepEqual(report.Files, []string{"messages_de_de.yml"}) || len(report.Issues) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.Issues[0].Key != "motd" || report.Issues[1].Key != "bought" || !strings.Contains(report.Issues[1].Reason, "placeholders changed") {
+		t.Errorf("issues = %+v", report.Issues)
+	}
+
+	archive, err := mods.OpenArchive(output, mods.DefaultArchiveLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	translated, _ := archive.ReadFileNamed("messages_de_de.yml")
+	want := "# Shop messages\nprefix: '&8[&6Laden&8] '\nbought: \"&aYou bought %amount% {item}!\"\nhelp:\n  - 'Nutze /shop: Laden'\n  - 'Er hat ''offen'''\nmotd: |\n  Welcome\nlimit: 5\n"
+	if string(translated) != want {
+		t.Errorf("messages_de_de.yml:\n%q\nwant\n%q", translated, want)
+	}
+	if source, _ := archive.ReadFileNamed("messages.yml"); string(source) != messages {
+		t.Error("messages.yml should be unchanged")
+	}
+}
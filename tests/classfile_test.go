@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+// buildMixinClass assembles a minimal class file annotated with
+// @Mixin(Item.class) that references Level and a string constant
+func buildMixinClass() []byte {
+	var buf bytes.Buffer
+	u1 := func(v byte) { buf.WriteByte(v) }
+	u2 := func(v uint16) { binary.Write(&buf, binary.BigEndian, v) }
+	u4 := func(v uint32) { binary.Write(&buf, binary.BigEndian, v) }
+	utf8 := func(s string) {
+		u1(1)
+		u2(uint16(len(s)))
+		buf.WriteString(s)
+	}
+	ref := func(tag byte, index uint16) {
+		u1(tag)
+		u2(index)
+	}
+
+	u4(0xCAFEBABE)
+	u2(0)
+	u2(61) // Java 17
+	u2(13) // constant pool count
+
+	utf8("com/example/mixin/ItemMixin")         // 1
+	ref(7, 1)                                   // 2: class
+	utf8("java/lang/Object")                    // 3
+	ref(7, 3)                                   // 4: class
+	utf8("Lorg/spongepowered/asm/mixin/Mixin;") // 5
+	utf8("value")                               // 6
+	utf8("Lnet/minecraft/world/item/Item;")     // 7
+	utf8("RuntimeInvisibleAnnotations")         // 8
+	utf8("net/minecraft/world/level/Level")     // 9
+	ref(7, 9)                                   // 10: class
+	utf8("hello")                               // 11
+	ref(8, 11)                                  // 12: string
+
+	u2(0x21) // access flags
+	u2(2)    // this class
+	u2(4)    // super class
+	u2(0)    // interfaces
+	u2(0)    // fields
+	u2(0)    // methods
+	u2(1)    // attributes
+	u2(8)    // RuntimeInvisibleAnnotations
+	u4(2 + 2 + 2 + 2 + 1 + 2 + 1 + 2)
+	u2(1) // one annotation
+	u2(5) // @Mixin
+	u2(1) // one element
+	u2(6) // value
+	u1('[')
+	u2(1)
+	u1('c')
+	u2(7)
+	return buf.Bytes()
+}
+
+func TestParseClassReadsConstantPool(t *testing.T) {
+	info, err := mods.ParseClass(buildMixinClass())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "com/example/mixin/ItemMixin" || info.SuperName != "java/lang/Object" {
+		t.Errorf("name %q super %q", info.Name, info.SuperName)
+	}
+	want := map[string]bool{"java/lang/Object": true, "net/minecraft/world/level/Level": true}
+	for _, c := range info.Classes {
+		delete(want, c)
+	}
+	if len(want) > 0 {
+		t.Errorf("classes %v missing %v", info.Classes, want)
+	}
+	if len(info.Strings) != 1 || info.Strings[0] != "hello" {
+		t.Errorf("strings = %v", info.Strings)
+	}
+}
+
+func TestAnalyzeMixinsReadsTargets(t *testing.T) {
+	jar := buildZip(t,
+		[2]string{"example.mixins.json", `{"required": true, "package": "com.example.mixin", "mixins": ["ItemMixin"]}`},
+		[2]string{"com/example/mixin/ItemMixin.class", string(buildMixinClass())},
+	)
+	archive, err := mods.OpenArchive(jar, mods.DefaultArchiveLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets, err := mods.AnalyzeMixins(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 {
+		t.Fatalf("got %d mixins, want 1", len(targets))
+	}
+	if got := targets[0]; got.Mixin != "com.example.mixin.ItemMixin" || len(got.Targets) != 1 || got.Targets[0] != "net/minecraft/world/item/Item" {
+		t.Errorf("mixin = %+v", got)
+	}
+}
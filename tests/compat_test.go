@@ -0,0 +1,170 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+// buildZip creates an archive with the given entries in order
+func buildZip(t *testing.T, entries ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, e := range entries {
+		f, err := w.Create(e[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(e[1]))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCheckCompatibilityFabricJar(t *testing.T) {
+	jar := buildZip(t, [2]string{"fabric.mod.json", `{
+		"schemaVersion": 1, "id": "example", "version": "1.0.0",
+		"depends": {"minecraft": "~1.20", "fabricloader": ">=0.15.0"}
+	}`})
+
+	cases := []struct {
+		target mods.CompatTarget
+		want   string
+	}{
+		{mods.CompatTarget{MinecraftVersion: "1.20.1", Loader: "fabric", LoaderVersion: "0.15.3"}, mods.CompatCompatible},
+		{mods.CompatTarget{MinecraftVersion: "1.20.1", Loader: "quilt"}, mods.CompatCompatible},
+		{mods.CompatTarget{MinecraftVersion: "1.21", Loader: "fabric"}, mods.CompatIncompatible},
+		{mods.CompatTarget{MinecraftVersion: "1.20.1", Loader: "fabric", LoaderVersion: "0.14.22"}, mods.CompatIncompatible},
+		{mods.CompatTarget{MinecraftVersion: "1.20.1", Loader: "forge"}, mods.CompatIncompatible},
+	}
+	for _, tc := range cases {
+		result, err := mods.CheckCompatibility("example.jar", jar, tc.target)
+		if err != nil {
+			t.Fatalf("%+v: %v", tc.target, err)
+		}
+		if result.Status != tc.want {
+			t.Errorf("%+v: status %s, want %s (%+v)", tc.target, result.Status, tc.want, result.Checks)
+		}
+	}
+}
+
+func TestPortDataPackUpdatesPackFormat(t *testing.T) {
+	pack := buildZip(t,
+		[2]string{"pack.mcmeta", `{"pack": {"pack_format": 15, "description": "test"}}`},
+		[2]string{"data/example/recipes/sword.json", `{"type": "minecraft:crafting_shaped"}`},
+	)
+	target := mods.CompatTarget{MinecraftVersion: "1.21"}
+
+	before, err := mods.CheckCompatibility("pack.zip", pack, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Compatible() {
+		t.Fatal("format 15 pack should not be compatible with 1.21")
+	}
+
+	var seen []string
+	output, result, err := mods.PortDataPack("pack.zip", pack, "1.21", func(name string, content []byte) ([]byte, error) {
+		seen = append(seen, name)
+		return content, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Compatible() {
+		t.Errorf("ported pack is not compatible: %+v", result.Checks)
+	}
+	if len(seen) != 1 || seen[0] != "data/example/recipes/sword.json" {
+		t.Errorf("ported entries = %v", seen)
+	}
+	if _, err := zip.NewReader(bytes.NewReader(output), int64(len(output))); err != nil {
+		t.Errorf("ported pack is not a valid archive: %v", err)
+	}
+}
+
+func TestPortDataPackRenamesFolders(t *testing.T) {
+	pack := buildZip(t,
+		[2]string{"pack.mcmeta", `{"pack": {"pack_format": 26, "description": "test"}}`},
+		[2]string{"data/example/recipes/sword.json", `{"type": "minecraft:crafting_shapeless", "result": {"item": "minecraft:iron_sword"}}`},
+		[2]string{"data/example/tags/items/swords.json", `{"values": ["minecraft:iron_sword"]}`},
+		[2]string{"data/example/functions/load.mcfunction", "say hi\n"},
+	)
+	unchanged := func(name string, content []byte) ([]byte, error) { return content, nil }
+	_, result, err := mods.PortDataPack("pack.zip", pack, "1.21", unchanged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Compatible() || !strings.Contains(result.Checks[len(result.Checks)-1].Message, "result.item instead of result.id in data/example/recipe/sword.json") {
+		t.Errorf("a recipe left on result.item should fail the port: %+v", result.Checks)
+	}
+
+	output, result, err := mods.PortDataPack("pack.zip", pack, "1.21", func(name string, content []byte) ([]byte, error) {
+		return bytes.Replace(content, []byte(`"item"`), []byte(`"id"`), 1), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Compatible() {
+		t.Errorf("ported pack is not compatible: %+v", result.Checks)
+	}
+	archive, err := mods.OpenArchive(output, mods.DefaultArchiveLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range archive.Files() {
+		names = append(names, f.Name)
+	}
+	if want := []string{"pack.mcmeta", "data/example/recipe/sword.json", "data/example/tags/item/swords.json", "data/example/function/load.mcfunction"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("entries = %v, want %v", names, want)
+	}
+
+	// A pack already on the 1.21 layout that still has an old folder is rejected
+	stale := buildZip(t,
+		[2]string{"pack.mcmeta", `{"pack": {"pack_format": 48, "description": "test"}}`},
+		[2]string{"data/example/loot_tables/chest.json", `{"pools": []}`},
+	)
+	if _, result, err := mods.PortDataPack("pack.zip", stale, "1.21", unchanged); err != nil || result.Compatible() {
+		t.Errorf("leftover loot_tables/ should fail the port: %+v, %v", result, err)
+	}
+}
+
+func TestDeclaredMinecraftVersion(t *testing.T) {
+	cases := []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{"fabric.jar", buildZip(t, [2]string{"fabric.mod.json", `{"schemaVersion": 1, "id": "example", "depends": {"minecraft": "~1.20.4"}}`}), "1.20.4"},
+		{"forge.jar", buildZip(t, [2]string{"META-INF/mods.toml", `
+[[mods]]
+modId = "example"
+[[dependencies.example]]
+modId = "minecraft"
+mandatory = true
+versionRange = "[1.20.5,1.21)"
+`}), "1.20.5"},
+		{"data.zip", buildZip(t,
+			[2]string{"pack.mcmeta", `{"pack": {"pack_format": 48}}`},
+			[2]string{"data/example/recipes/a.json", `{}`},
+		), "1.21"},
+		{"resources.zip", buildZip(t,
+			[2]string{"pack.mcmeta", `{"pack": {"pack_format": 15, "supported_formats": [22, 34]}}`},
+			[2]string{"assets/example/lang/en_us.json", `{}`},
+		), "1.20.3"},
+		{"pack.mcmeta", []byte(`{"pack": {"pack_format": 16}}`), ""},
+		{"recipe.json", []byte(`{"type": "minecraft:crafting_shaped"}`), ""},
+	}
+	for _, tc := range cases {
+		if got := mods.DeclaredMinecraftVersion(tc.name, tc.content); got != tc.want {
+			t.Errorf("%s: DeclaredMinecraftVersion = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
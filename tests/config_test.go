@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+func TestConfigValueEdits(t *testing.T) {
+	cases := []struct {
+		file, original, edited, want string
+		changes                      []string
+	}{
+		{
+			file:     "create-common.toml",
+			original: "# Create settings\n[worldgen]\n\t# Ore veins\n\tenabled = true # on by default\n\tveins = [\"copper\",\n\t  \"zinc\"]\n\n[[kinetics.stress]]\n\t\"max speed\" = 256\n",
+			edited:   "[worldgen]\nenabled = false\nveins = [\"zinc\"]\n[[kinetics.stress]]\n\"max speed\" = 128\n",
+			want:     "# Create settings\n[worldgen]\n\t# Ore veins\n\tenabled = false # on by default\n\tveins = [\"zinc\"]\n\n[[kinetics.stress]]\n\t\"max speed\" = 128\n",
+			changes:  []string{"worldgen.enabled: true -> false", `worldgen.veins: ["copper",` + "\n\t  \"zinc\"] -> [\"zinc\"]", "kinetics.stress[0].max speed: 256 -> 128"},
+		},
+		{
+			file:     "server.properties",
+			original: "#Minecraft server properties\r\nmotd=A Minecraft \\\r\n    Server\r\nmax-players = 20\r\n",
+			edited:   "motd=Welcome\nmax-players = 40\n",
+			want:     "#Minecraft server properties\r\nmotd=Welcome\r\nmax-players = 40\r\n",
+			changes:  []string{"motd: A Minecraft \\\r\n    Server -> Welcome", "max-players: 20 -> 40"},
+		},
+		{
+			file:     "mod.cfg",
+			original: "# Configuration file\n\ngeneral {\n    # Spawn weight\n    I:weight=10\n\n    S:\"biome list\" <\n        plains\n     >\n}\n",
+			edited:   "general {\n    I:weight=25\n    S:\"biome list\" <\n        desert\n     >\n}\n",
+			want:     "# Configuration file\n\ngeneral {\n    # Spawn weight\n    I:weight=25\n\n    S:\"biome list\" <\n        desert\n     >\n}\n",
+			changes:  []string{"general.weight: 10 -> 25", "general.biome list: plains -> desert"},
+		},
+		{
+			file:     "config.yml",
+			original: "# Shop settings\nshop:\n  prices: # per item\n    diamond: 100\n  items:\n  - name: 'Sword'\n    count: 1\n  - stone\ngreeting: |\n  Hello\n  there\n",
+			edited:   "shop:\n  prices:\n    diamond: 250\n  items:\n  - name: \"Blade\"\n    count: 1\n  - stone\ngreeting: Hi\n",
+			want:     "# Shop settings\nshop:\n  prices: # per item\n    diamond: 250\n  items:\n  - name: \"Blade\"\n    count: 1\n  - stone\ngreeting: Hi\n",
+			changes:  []string{"shop.prices.diamond: 100 -> 250", "shop.items[0].name: 'Sword' -> \"Blade\"", "greeting: |\n  Hello\n  there -> Hi"},
+		},
+	}
+
+	for _, c := range cases {
+		format, err := mods.FormatFor(c.file, []byte(c.original))
+		if err != nil {
+			t.Fatalf("%s: %v", c.file, err)
+		}
+		output, err := format.Rewrite([]byte(c.original), []byte(c.edited))
+		if err != nil {
+			t.Fatalf("%s: %v", c.file, err)
+		}
+		if string(output) != c.want {
+			t.Errorf("%s output:\n%q\nwant\n%q", c.file, output, c.want)
+		}
+		changes, err := format.(mods.ChangeReporter).ReportChanges([]byte(c.original), []byte(c.edited))
+		if err != nil || strings.Join(changes, "\n") != strings.Join(c.changes, "\n") {
+			t.Errorf("%s changes = %q, %v", c.file, changes, err)
+		}
+	}
+
+	format, _ := mods.FormatFor("config.yml", []byte("a: 1\n"))
+	for edited, want := range map[string]string{
+		"a: 1\nb: 2\n": "edit added b",
+		"c: 1\n":       "edit removed a",
+		"a: [1]\n":     "a changed from a number to a list",
+	} {
+		if _, err := format.Rewrite([]byte("a: 1\n"), []byte(edited)); err == nil || err.Error() != want {
+			t.Errorf("edit %q: error %v, want %s", edited, err, want)
+		}
+	}
+}
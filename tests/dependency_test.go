@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+func TestVersionRanges(t *testing.T) {
+	cases := []struct {
+		syntax  string
+		rng     string
+		version string
+		want    bool
+	}{
+		{mods.RangeSemver, ">=0.15.0", "0.15.7", true},
+		{mods.RangeSemver, ">=0.15.0", "0.14.22", false},
+		{mods.RangeSemver, "~1.20", "1.20.4", true},
+		{mods.RangeSemver, "~1.20", "1.21", false},
+		{mods.RangeSemver, "^2.1.0", "2.9.0", true},
+		{mods.RangeSemver, "^2.1.0", "3.0.0", false},
+		{mods.RangeSemver, "1.20.x", "1.20.1", true},
+		{mods.RangeSemver, ">=1.19 <1.20 || >=1.20.2", "1.20.1", false},
+		{mods.RangeSemver, ">=1.19 <1.20 || >=1.20.2", "1.20.4", true},
+		{mods.RangeSemver, "*", "anything", true},
+		{mods.RangeMaven, "[1.20,1.21)", "1.20.1", true},
+		{mods.RangeMaven, "[1.20,1.21)", "1.21", false},
+		{mods.RangeMaven, "[47,)", "47.1.0", true},
+		{mods.RangeMaven, "(,1.0]", "1.0.1", false},
+		{mods.RangeMaven, "[1.0],[2.0,)", "1.5", false},
+		{mods.RangeMaven, "1.0", "0.1", true},
+	}
+
+	for _, tc := range cases {
+		rel := mods.Relation{VersionRange: tc.rng, RangeSyntax: tc.syntax}
+		r, err := rel.Range()
+		if err != nil {
+			t.Fatalf("%s range %q: %v", tc.syntax, tc.rng, err)
+		}
+		if got := r.Contains(mods.ParseVersion(tc.version)); got != tc.want {
+			t.Errorf("%s range %q contains %s = %v, want %v", tc.syntax, tc.rng, tc.version, got, tc.want)
+		}
+	}
+}
+
+func TestResolveDependencies(t *testing.T) {
+	descriptors := []mods.ModDescriptor{
+		{ID: "alpha", Version: "1.0.0", Loader: mods.LoaderFabric, Relations: []mods.Relation{
+			{Kind: mods.RelationDepends, ModID: "beta", VersionRange: ">=2.0", RangeSyntax: mods.RangeSemver},
+			{Kind: mods.RelationDepends, ModID: "gamma"},
+			{Kind: mods.RelationDepends, ModID: "minecraft", VersionRange: "~1.20", RangeSyntax: mods.RangeSemver},
+		}},
+		{ID: "beta", Version: "1.5.0", Loader: mods.LoaderFabric, Relations: []mods.Relation{
+			{Kind: mods.RelationDepends, ModID: "delta"},
+		}},
+		{ID: "delta", Version: "1.0.0", Loader: mods.LoaderFabric, Relations: []mods.Relation{
+			{Kind: mods.RelationDepends, ModID: "beta"},
+			{Kind: mods.RelationBreaks, ModID: "alpha", VersionRange: "<2", RangeSyntax: mods.RangeSemver},
+		}},
+	}
+
+	report := mods.ResolveDependencies(descriptors, map[string]string{"minecraft": "1.20.1"})
+	if report.Loadable() {
+		t.Fatal("expected mod set to be reported as not loadable")
+	}
+	if len(report.Missing) != 1 || report.Missing[0].Dependency != "gamma" {
+		t.Errorf("missing = %+v", report.Missing)
+	}
+	if len(report.Incompatible) != 2 {
+		t.Errorf("incompatible = %+v", report.Incompatible)
+	}
+	if len(report.Cycles) != 1 || len(report.Cycles[0]) != 2 {
+		t.Errorf("cycles = %+v", report.Cycles)
+	}
+}
+
+func TestResolveDependenciesDedupesBundledLibraries(t *testing.T) {
+	descriptors := []mods.ModDescriptor{
+		{ID: "alpha", Version: "1.0.0", Source: "alpha.jar", Relations: []mods.Relation{
+			{Kind: mods.RelationDepends, ModID: "cloth-config", VersionRange: ">=11.1", RangeSyntax: mods.RangeSemver},
+		}},
+		{ID: "cloth-config", Version: "11.0.0", Source: "alpha.jar!/META-INF/jars/cloth-config-11.0.0.jar", Relations: []mods.Relation{
+			{Kind: mods.RelationDepends, ModID: "missing-lib"},
+		}},
+		{ID: "beta", Version: "1.0.0", Source: "beta.jar"},
+		{ID: "cloth-config", Version: "11.1.0", Source: "beta.jar!/META-INF/jars/cloth-config-11.1.0.jar"},
+	}
+	report := mods.ResolveDependencies(descriptors, nil)
+	if !report.Loadable() {
+		t.Errorf("bundled copies of one library should resolve to the highest: %+v %+v", report.Missing, report.Incompatible)
+	}
+
+	descriptors = append(descriptors, mods.ModDescriptor{ID: "beta", Version: "2.0.0", Source: "beta-2.jar"})
+	report = mods.ResolveDependencies(descriptors, nil)
+	if len(report.Incompatible) != 1 || report.Incompatible[0].ModID != "beta" {
+		t.Errorf("two top-level jars with one ID: incompatible = %+v", report.Incompatible)
+	}
+}
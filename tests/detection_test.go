@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+func TestDetectRanksCandidates(t *testing.T) {
+	cases := []struct {
+		name      string
+		filename  string
+		content   string
+		want      mods.GameType
+		confident bool
+	}{
+		{
+			name:      "shaped recipe",
+			filename:  "sword.json",
+			content:   `{"type": "minecraft:crafting_shaped", "pattern": ["X", "X", "/"], "key": {"X": {"item": "minecraft:diamond"}}, "result": {"item": "minecraft:diamond_sword"}}`,
+			want:      mods.GameTypeMinecraft,
+			confident: true,
+		},
+		{
+			name:      "lua script",
+			filename:  "init.lua",
+			content:   "local config = require(\"config\")\n\nfunction on_load()\n  print(config.name)\nend\n",
+			want:      mods.GameTypeLua,
+			confident: true,
+		},
+		{
+			name:      "forge toml config",
+			filename:  "example-common.toml",
+			content:   "[general]\n\t#Ticks between checks\n\t#Range: 1 ~ 100\n\tinterval = 20\n",
+			want:      mods.GameTypeMinecraft,
+			confident: true,
+		},
+		{
+			name:      "toml config with a general table",
+			filename:  "example-client.toml",
+			content:   "[general]\nshowHud = true\n",
+			want:      mods.GameTypeMinecraft,
+			confident: true,
+		},
+		{
+			name:      "forge 1.12 config",
+			filename:  "example.cfg",
+			content:   "# Configuration file\n\ngeneral {\n    I:\"Max Items\"=64\n}\n",
+			want:      mods.GameTypeMinecraft,
+			confident: true,
+		},
+		{
+			name:      "server properties",
+			filename:  "server.properties",
+			content:   "motd=A Minecraft Server\nlevel-name=world\nonline-mode=true\n",
+			want:      mods.GameTypeMinecraft,
+			confident: true,
+		},
+		{
+			name:     "unrelated toml",
+			filename: "Cargo.toml",
+			content:  "[package]\nname = \"example\"\n",
+			want:     mods.GameTypeUnknown,
+		},
+		{
+			name:     "json mentioning item",
+			filename: "notes.json",
+			content:  `{"item": "a shopping list"}`,
+			want:     mods.GameTypeUnknown,
+		},
+		{
+			name:     "prose mentioning local",
+			filename: "readme.txt",
+			content:  "This is a local copy of the readme.",
+			want:     mods.GameTypeUnknown,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			detection := mods.Detect(tc.filename, []byte(tc.content))
+			if detection.IsConfident() != tc.confident {
+				t.Errorf("IsConfident = %v, want %v (candidates %+v)", detection.IsConfident(), tc.confident, detection.Candidates)
+			}
+			if tc.want != mods.GameTypeUnknown && detection.Best().GameType != tc.want {
+				t.Errorf("best = %s, want %s", detection.Best().GameType, tc.want)
+			}
+			if tc.confident && len(detection.Best().Evidence) == 0 {
+				t.Error("confident candidate has no evidence")
+			}
+		})
+	}
+}
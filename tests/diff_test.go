@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+func TestDiffFilesReportsJSONPaths(t *testing.T) {
+	original := "{\n  \"type\": \"minecraft:crafting_shaped\",\n  \"key\": {\"#\": \"minecraft:diamond\"},\n  \"result\": {\"count\": 1}\n}\n"
+	processed := "{\n  \"type\": \"minecraft:crafting_shaped\",\n  \"key\": {\"#\": \"minecraft:netherite_ingot\"},\n  \"result\": {\"count\": 1, \"id\": \"a/b\"}\n}\n"
+
+	diff, err := mods.DiffFiles("recipe.json", []byte(original), []byte(processed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Kind != mods.DiffJSON || diff.Status != mods.ChangeModified {
+		t.Fatalf("kind %s status %s", diff.Kind, diff.Status)
+	}
+	paths := make(map[string]mods.ChangeKind)
+	for _, c := range diff.Changes {
+		paths[c.Path] = c.Kind
+	}
+	if paths["/key/#"] != mods.ChangeModified || paths["/result/id"] != mods.ChangeAdded || len(paths) != 2 {
+		t.Errorf("changes = %+v", diff.Changes)
+	}
+
+	unified := diff.Unified()
+	for _, want := range []string{"--- a/recipe.json\n+++ b/recipe.json\n", "@@ -1,5 +1,5 @@\n", "-  \"key\": {\"#\": \"minecraft:diamond\"},\n", "+  \"key\": {\"#\": \"minecraft:netherite_ingot\"},\n"} {
+		if !strings.Contains(unified, want) {
+			t.Errorf("unified diff missing %q:\n%s", want, unified)
+		}
+	}
+}
+
+func TestDiffFilesComparesArchiveEntries(t *testing.T) {
+	original := buildZip(t,
+		[2]string{"fabric.mod.json", `{"id": "example"}`},
+		[2]string{"assets/example/lang/en_us.json", `{"item.example.gem": "Gem"}`},
+		[2]string{"README.txt", "old\n"},
+	)
+	processed := buildZip(t,
+		[2]string{"fabric.mod.json", `{"id": "example"}`},
+		[2]string{"assets/example/lang/en_us.json", `{"item.example.gem": "Shiny Gem"}`},
+		[2]string{"CHANGES.txt", "new\n"},
+	)
+
+	diff, err := mods.DiffFiles("example.jar", original, processed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Kind != mods.DiffArchive {
+		t.Fatalf("kind = %s", diff.Kind)
+	}
+	got := make(map[string]mods.ChangeKind)
+	for _, e := range diff.Entries {
+		got[e.Path] = e.Status
+	}
+	want := map[string]mods.ChangeKind{
+		"assets/example/lang/en_us.json": mods.ChangeModified,
+		"README.txt":                     mods.ChangeRemoved,
+		"CHANGES.txt":                    mods.ChangeAdded,
+	}
+	if len(got) != len(want) {
+		t.Errorf("entries = %v", got)
+	}
+	for name, status := range want {
+		if got[name] != status {
+			t.Errorf("%s: got %s, want %s", name, got[name], status)
+		}
+	}
+	if unified := diff.Unified(); !strings.Contains(unified, "--- /dev/null\n+++ b/example.jar!/CHANGES.txt\n@@ -0,0 +1,1 @@\n+new\n") {
+		t.Errorf("unified diff:\n%s", unified)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+func TestDetectEcosystem(t *testing.T) {
+	factorio := buildZip(t,
+		[2]string{"bigger-belts_1.2.0/info.json", `{"name": "bigger-belts", "version": "1.2.0", "title": "Bigger Belts", "factorio_version": "1.1", "dependencies": ["base >= 1.1", "? space-exploration", "! bad-belts"]}`},
+		[2]string{"bigger-belts_1.2.0/data.lua", `data:extend({{type = "item", name = "big-belt"}})`},
+		[2]string{"bigger-belts_1.2.0/control.lua", `script.on_init(function() end)`},
+	)
+	info := mods.DetectEcosystem("bigger-belts_1.2.0.zip", factorio)
+	if info == nil || info.Ecosystem != mods.EcosystemFactorio {
+		t.Fatalf("factorio archive detected as %+v", info)
+	}
+	if info.Root != "bigger-belts_1.2.0/" || info.Name != "bigger-belts" || info.GameVersion != "1.1" {
+		t.Errorf("factorio info = %+v", info)
+	}
+	if !reflect.DeepEqual(info.Dependencies, []string{"base"}) {
+		t.Errorf("factorio dependencies = %v, want [base]", info.Dependencies)
+	}
+
+	wow := buildZip(t,
+		[2]string{"LootLog/LootLog.toc", "## Interface: 110002\n## Title: Loot Log\n## Version: 2.1\n## Dependencies: Ace3\n## SavedVariables: LootLogDB\n\nLootLog.lua\n"},
+		[2]string{"LootLog/LootLog.lua", `local f = CreateFrame("Frame")`},
+	)
+	info = mods.DetectEcosystem("LootLog.zip", wow)
+	if info == nil || info.Ecosystem != mods.EcosystemWoW {
+		t.Fatalf("wow archive detected as %+v", info)
+	}
+	if info.GameVersion != "110002" || info.Title != "Loot Log" || !reflect.DeepEqual(info.Dependencies, []string{"Ace3"}) {
+		t.Errorf("wow info = %+v", info)
+	}
+
+	// A lone script is placed by the game APIs it calls
+	script := []byte(`
+hook.Add("PlayerSpawn", "give_crowbar", function(ply) ply:Give("weapon_crowbar") end)
+util.AddNetworkString("greet")
+net.Receive("greet", function(len, ply) print(ply:Nick()) end)
+`)
+	info = mods.DetectEcosystem("autorun.lua", script)
+	if info == nil || info.Ecosystem != mods.EcosystemGarrysMod {
+		t.Errorf("gmod script detected as %+v", info)
+	}
+	if info := mods.DetectEcosystem("util.lua", []byte("local function add(a, b) return a + b end\nreturn add\n")); info != nil {
+		t.Errorf("plain script detected as %+v", info)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+func TestFactorioMod(t *testing.T) {
+	content := buildZip(t,
+		[2]string{"info.json", `{"name": "belts", "version": "1.2.0", "title": "Belts", "factorio_version": "1.1", "dependencies": ["base >= 1.1", "? space-exploration", "(?) helmod", "! bad-belts", "~ lib = 0.3.1"]}`},
+		[2]string{"data.lua", `require("prototypes.belt")`},
+		[2]string{"prototypes/belt.lua", `data:extend({{type = "transport-belt", name = "big-belt", speed = 0.1}})`},
+		[2]string{"control.lua", `script.on_init(function() end)`},
+		[2]string{"locale/en/belts.cfg", "; names\n[item-name]\nbig-belt=Big belt\n\n[item-description]\nbig-belt=Moves __1__ items per second on [item=big-belt]\n"},
+		[2]string{"locale/de/belts.cfg", "[item-name]\nbig-belt=Großes Band\n"},
+	)
+
+	format, err := mods.FormatFor("belts.zip", content)
+	if err != nil || format.Info().Name != "factorio" {
+		t.Fatalf("FormatFor = %v, %v; want factorio", format, err)
+	}
+	mod, err := mods.ParseFactorioMod(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deps []string
+	for _, dep := range mod.Info.Dependencies {
+		deps = append(deps, dep.Kind+":"+dep.String())
+	}
+	want := "required:base >= 1.1 optional:? space-exploration hidden_optional:(?) helmod incompatible:! bad-belts no_load_order:~ lib = 0.3.1"
+	if got := strings.Join(deps, " "); got != want {
+		t.Errorf("dependencies = %s, want %s", got, want)
+	}
+	if !mod.IsPrototypeScript("prototypes/belt.lua") || !mod.IsPrototypeScript("data.lua") || mod.IsPrototypeScript("control.lua") {
+		t.Errorf("prototype scripts = %v", mod.Prototypes)
+	}
+
+	// Translations keep parameters and go into their section
+	translate := func(locale string, batch map[string]string) (map[string]string, error) {
+		out := make(map[string]string)
+		for key, value := range batch {
+			out[key] = "DE " + value
+		}
+		out["item-description.big-belt"] = "Bewegt Gegenstände pro Sekunde"
+		return out, nil
+	}
+	output, report, err := mods.TranslateFactorioMod(content, []string{"de"}, translate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Translated != 0 || len(report.Issues) != 1 {
+		t.Errorf("report = %+v, want the dropped parameter rejected", report)
+	}
+	output, report, err = mods.TranslateFactorioMod(content, []string{"pt-BR"}, func(locale string, batch map[string]string) (map[string]string, error) {
+		return batch, nil
+	})
+	if err != nil || report.Translated != 2 {
+		t.Fatalf("TranslateFactorioMod = %+v, %v", report, err)
+	}
+	if got := readZipEntry(t, output, "locale/pt-BR/belts.cfg"); got != "[item-name]\nbig-belt=Big belt\n\n[item-description]\nbig-belt=Moves __1__ items per second on [item=big-belt]\n" {
+		t.Errorf("pt-BR locale =\n%s", got)
+	}
+
+	// The result is moved into the name_version folder
+	packaged, dropped, err := mods.PackageFactorioMod(output)
+	if err != nil || len(dropped) != 0 {
+		t.Fatalf("PackageFactorioMod = %v, %v", dropped, err)
+	}
+	if got := readZipEntry(t, packaged, "belts_1.2.0/prototypes/belt.lua"); !strings.Contains(got, "big-belt") {
+		t.Errorf("prototype script not repackaged: %q", got)
+	}
+	if again, _, err := mods.PackageFactorioMod(packaged); err != nil || !bytes.Equal(again, packaged) {
+		t.Errorf("packaging a packaged mod changed it: %v", err)
+	}
+}
+
+func readZipEntry(t *testing.T, content []byte, name string) string {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range reader.File {
+		if f.Name == name {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			var buf bytes.Buffer
+			buf.ReadFrom(rc)
+			return buf.String()
+		}
+	}
+	t.Fatalf("%s not in archive", name)
+	return ""
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+func TestFingerprintIgnoresWhitespaceForCurseForge(t *testing.T) {
+	compact := mods.Fingerprint([]byte(`{"id":"example","version":"1.0.0"}`))
+	spaced := mods.Fingerprint([]byte("{\n\t\"id\": \"example\",\r\n\t\"version\": \"1.0.0\"\n}\n"))
+
+	if compact.CurseForge != spaced.CurseForge {
+		t.Errorf("CurseForge fingerprints differ: %d != %d", compact.CurseForge, spaced.CurseForge)
+	}
+	if compact.SHA1 == spaced.SHA1 || compact.SHA512 == spaced.SHA512 {
+		t.Error("SHA hashes should cover the exact bytes")
+	}
+	if len(compact.SHA1) != 40 || len(compact.SHA512) != 128 {
+		t.Errorf("unexpected hash lengths %d and %d", len(compact.SHA1), len(compact.SHA512))
+	}
+	if other := mods.CurseForgeFingerprint([]byte(`{"id":"other"}`)); other == compact.CurseForge {
+		t.Error("different content should not share a fingerprint")
+	}
+}
+
+func TestCurseForgeFingerprintVectors(t *testing.T) {
+	// SMHasher's verification: hash keys {}, {0}, {0, 1}, ... with seed 256-i,
+	// then hash the concatenated results with seed 0
+	key := make([]byte, 256)
+	hashes := make([]byte, 0, 256*4)
+	for i := range key {
+		key[i] = byte(i)
+		hashes = binary.LittleEndian.AppendUint32(hashes, mods.MurmurHash2(key[:i], uint32(256-i)))
+	}
+	if got := mods.MurmurHash2(hashes, 0); got != 0x27864c1e {
+		t.Errorf("MurmurHash2 verification = %#08x, want 0x27864c1e", got)
+	}
+
+	// Whitespace is dropped before hashing with seed 1, so every empty or
+	// whitespace-only file shares one fingerprint
+	cases := map[string]uint32{
+		"":              1540447798,
+		" \r\n\t":       1540447798,
+		"hello world\n": 2824650221,
+	}
+	for content, want := range cases {
+		if got := mods.CurseForgeFingerprint([]byte(content)); got != want {
+			t.Errorf("CurseForgeFingerprint(%q) = %d, want %d", content, got, want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+func TestFormatRegistry(t *testing.T) {
+	var names []string
+	for _, f := range mods.FormatsForExtension("Example.ZIP") {
+		names = append(names, f.Info().Name)
+	}
+	if want := []string{"factorio", "stardew", "bedrock", "archive"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("zip formats = %v, want %v in registration order", names, want)
+	}
+
+	cases := []struct {
+		filename, content, want string
+	}{
+		{"recipe.json", `{"type": "minecraft:crafting_shaped"}`, "json"},
+		{"content.json", `{"Format": "2.0.0", "Changes": []}`, "content_patcher"},
+		{"example.jar", string(buildZip(t, [2]string{"fabric.mod.json", `{"id": "example"}`})), "archive"},
+		{"init.lua", "local x = 1\n", "lua"},
+		{"server.properties", "motd=Hello\n", "properties"},
+	}
+	for _, tc := range cases {
+		format, err := mods.FormatFor(tc.filename, []byte(tc.content))
+		if err != nil || format.Info().Name != tc.want {
+			t.Errorf("FormatFor(%s) = %v, %v; want %s", tc.filename, format, err, tc.want)
+		}
+	}
+	if _, err := mods.FormatFor("readme.txt", []byte("notes")); err == nil || err.Error() != "unsupported file type: .txt" {
+		t.Errorf("txt error = %v", err)
+	}
+	if _, err := mods.FormatFor("recipe.json", []byte("not json")); err == nil || err.Error() != "file content does not match its .json extension" {
+		t.Errorf("mismatched content error = %v", err)
+	}
+
+	script := []byte("local x = 1\n")
+	if _, err := mods.ValidateUpload("init.lua", 11*1024*1024, "text/plain", script); err == nil || err.Error() != "file size exceeds maximum limit of 10MB for lua files" {
+		t.Errorf("oversized upload error = %v", err)
+	}
+	if _, err := mods.ValidateUpload("init.lua", int64(len(script)), "image/png", script); err == nil || !strings.Contains(err.Error(), "content type image/png is not valid for lua files") {
+		t.Errorf("wrong content type error = %v", err)
+	}
+	if format, err := mods.ValidateUpload("init.lua", int64(len(script)), "text/plain; charset=utf-8", script); err != nil || format.Info().Name != "lua" {
+		t.Errorf("ValidateUpload = %v, %v", format, err)
+	}
+	if mods.MaxUploadSize() != 100*1024*1024 {
+		t.Errorf("MaxUploadSize = %d", mods.MaxUploadSize())
+	}
+	for _, ext := range mods.AllowedExtensions() {
+		if ext == ".txt" {
+			t.Error(".txt has no format and should not be allowed")
+		}
+	}
+}
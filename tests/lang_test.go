@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+func TestCheckTranslationKeepsPlaceholders(t *testing.T) {
+	cases := []struct {
+		source, translated string
+		ok                 bool
+	}{
+		{"%s killed %s", "%s hat %s getötet", true},
+		{"%1$s gave %2$s", "%2$s erhielt von %1$s", true},
+		{"§aReady§r", "§aBereit§r", true},
+		{"Deals %d damage", "Verursacht Schaden", false},
+		{"§cWarning", "Warnung", false},
+		{"Hello", "", false},
+	}
+	for _, c := range cases {
+		if err := mods.CheckTranslation(c.source, c.translated); (err == nil) != c.ok {
+			t.Errorf("CheckTranslation(%q, %q) = %v", c.source, c.translated, err)
+		}
+	}
+}
+
+func TestTranslateArchiveWritesOnlyLangFiles(t *testing.T) {
+	jar := buildZip(t,
+		[2]string{"fabric.mod.json", `{"id": "example"}`},
+		[2]string{"assets/example/lang/en_us.json", "{\n  \"item.example.gem\": \"Gem\",\n  \"msg.example.hit\": \"%s hit %s\",\n  \"msg.example.ready\": \"§aReady\"\n}\n"},
+		[2]string{"assets/example/lang/de_de.json", "{\n  \"item.example.gem\": \"Edelstein\"\n}\n"},
+	)
+
+	archive, err := mods.OpenArchive(jar, mods.DefaultArchiveLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	langs, err := mods.ExtractLang(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coverage := mods.LangCoverage(langs)
+	if len(coverage) != 1 || coverage[0].Locale != "de_de" || coverage[0].Translated != 1 || len(coverage[0].Missing) != 2 {
+		t.Fatalf("coverage = %+v", coverage)
+	}
+
+	// The fake translator drops the formatting code of one string
+	translate := func(locale string, batch map[string]string) (map[string]string, error) {
+		out := make(map[string]string)
+		for key, value := range batch {
+			out[key] = "[" + locale + "] " + strings.ReplaceAll(value, "§a", "")
+		}
+		return out, nil
+	}
+	output, report, err := mods.TranslateArchive(jar, []string{"de_de", "fr_fr"}, translate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Translated != 3 || len(report.Issues) != 2 {
+		t.Errorf("report = %+v", report)
+	}
+
+	diff, err := mods.DiffFiles("example.jar", jar, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range diff.Entries {
+		if !mods.IsLangPath(entry.Path) {
+			t.Errorf("translation touched %s", entry.Path)
+		}
+	}
+
+	archive, err = mods.OpenArchive(output, mods.DefaultArchiveLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := archive.ReadFileNamed("assets/example/lang/de_de.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"item.example.gem\": \"Edelstein\",\n  \"msg.example.hit\": \"[de_de] %s hit %s\"\n}\n"
+	if string(data) != want {
+		t.Errorf("de_de.json:\n%s", data)
+	}
+	if _, err := archive.ReadFileNamed("assets/example/lang/fr_fr.json"); err != nil {
+		t.Errorf("fr_fr.json not created: %v", err)
+	}
+}
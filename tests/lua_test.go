@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+func TestAnalyzeLuaListsDefinitionsAndAPIs(t *testing.T) {
+	script := []byte(`local util = require("lib.util")
+local M = {}
+
+config = { speed = 2 }
+
+local function clamp(x) return math.max(0, math.min(x, 1)) end
+
+function M.tick(event)
+  if event.tick % 60 == 0 && !paused then
+    game.print(clamp(config.speed)) // GMod-style comment
+  end
+end
+
+function M:reset()
+  self.count = 0
+end
+
+script.on_event(defines.events.on_tick, M.tick)
+data:extend({})
+return M
+`)
+	analysis, err := mods.AnalyzeLua(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct {
+		name      string
+		got, want []string
+	}{
+		{"globals", analysis.Globals, []string{"config"}},
+		{"functions", analysis.Functions, []string{"M.tick", "M:reset", "clamp"}},
+		{"requires", analysis.Requires, []string{"lib.util"}},
+		{"apis", analysis.APIs, []string{"data:extend", "defines.events", "game.print", "paused", "script.on_event"}},
+	}
+	for _, c := range checks {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	edited, err := mods.AnalyzeLua([]byte("local M = {}\nfunction M.tick() end\nreturn M\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped := mods.DroppedLuaFunctions(analysis, edited); !reflect.DeepEqual(dropped, []string{"M:reset", "clamp"}) {
+		t.Errorf("DroppedLuaFunctions = %v", dropped)
+	}
+}
+
+func TestParseLuaReportsPosition(t *testing.T) {
+	_, err := mods.ParseLua([]byte("local x = 1\nif x then\n  print(x\nend\n"))
+	var syntaxErr *mods.LuaSyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("ParseLua error = %v, want a LuaSyntaxError", err)
+	}
+	if syntaxErr.Line != 4 {
+		t.Errorf("error at line %d, want 4: %v", syntaxErr.Line, err)
+	}
+}
+
+func TestParseLuaAcceptsLuau(t *testing.T) {
+	script := []byte(`local Players = game:GetService("Players")
+export type Config<T> = {
+	name: string,
+	callback: (player: Player, value: T) -> (),
+}
+local total: number, label: string = 0, "none"
+
+local function clamp<T>(value: number, low: number, high: number): number
+	return math.clamp(value, low, high)
+end
+
+Players.PlayerAdded:Connect(function(player: Player)
+	for i: number = 1, 10 do
+		if i % 2 == 0 then
+			continue
+		end
+		total += clamp(i, 0, 5) :: number
+		label ..= ` + "`{player.Name} {i}`" + `
+	end
+end)
+`)
+	analysis, err := mods.AnalyzeLua(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !analysis.Luau || !reflect.DeepEqual(analysis.Functions, []string{"clamp"}) {
+		t.Errorf("luau = %v, functions = %v; want true, [clamp]", analysis.Luau, analysis.Functions)
+	}
+	if info := mods.DetectEcosystem("PlayerStats.server.lua", script); info == nil || info.Ecosystem != mods.EcosystemRoblox {
+		t.Errorf("DetectEcosystem = %+v, want roblox", info)
+	}
+
+	// Luau syntax only parses for analysis; the sandbox still rejects it
+	if result := mods.LoadLua(script, mods.DefaultLuaLimits); result.Error == "" {
+		t.Error("LoadLua ran a Luau script")
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+// buildClass assembles a class file whose constant pool references the given
+// classes and string constants
+func buildClass(name string, classes, constants []string) []byte {
+	var pool bytes.Buffer
+	count := uint16(1)
+	entry := func(tag byte, payload ...interface{}) uint16 {
+		pool.WriteByte(tag)
+		for _, p := range payload {
+			binary.Write(&pool, binary.BigEndian, p)
+		}
+		count++
+		return count - 1
+	}
+	utf8 := func(s string) uint16 {
+		index := entry(1, uint16(len(s)))
+		pool.WriteString(s)
+		return index
+	}
+
+	this := entry(7, utf8(name))
+	super := entry(7, utf8("java/lang/Object"))
+	for _, c := range classes {
+		entry(7, utf8(c))
+	}
+	for _, s := range constants {
+		entry(8, utf8(s))
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint32(0xCAFEBABE))
+	for _, v := range []uint16{0, 52, count} { // minor, major (Java 8), pool count
+		binary.Write(&buf, binary.BigEndian, v)
+	}
+	buf.Write(pool.Bytes())
+	for _, v := range []uint16{0x21, this, super, 0, 0, 0, 0} { // no interfaces, fields, methods or attributes
+		binary.Write(&buf, binary.BigEndian, v)
+	}
+	return buf.Bytes()
+}
+
+func TestScanForMalwareFlagsStageLoader(t *testing.T) {
+	loader := buildClass("com/example/Loader", []string{"java/net/URLClassLoader"}, []string{"https://example.invalid/stage2.jar"})
+	jar := buildZip(t,
+		[2]string{"fabric.mod.json", `{"id": "example"}`},
+		[2]string{"com/example/Loader.class", string(loader)},
+		[2]string{"natives/hook.dll", "MZ"},
+	)
+
+	findings, err := mods.ScanForMalware("example.jar", jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := make(map[string]mods.Severity)
+	for _, f := range findings {
+		rules[f.Rule] = f.Severity
+	}
+	if rules["remote_class_loading"] != mods.SeverityHigh {
+		t.Errorf("remote class loading not flagged: %+v", findings)
+	}
+	if rules["native_library"] != mods.SeverityMedium {
+		t.Errorf("native library not flagged: %+v", findings)
+	}
+	if !mods.ShouldQuarantine(findings) {
+		t.Error("stage loader should be quarantined")
+	}
+
+	clean := buildZip(t, [2]string{"com/example/Mod.class", string(buildClass("com/example/Mod", []string{"net/minecraft/world/item/Item"}, []string{"example"}))})
+	findings, err = mods.ScanForMalware("clean.jar", clean)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mods.ShouldQuarantine(findings) {
+		t.Errorf("clean jar quarantined: %+v", findings)
+	}
+}
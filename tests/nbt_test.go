@@ -0,0 +1,191 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+func nbtList(tag byte, items ...interface{}) *mods.NBTList {
+	return &mods.NBTList{Type: tag, Items: items}
+}
+
+func nbtCompound(pairs ...interface{}) *mods.NBTCompound {
+	c := mods.NewNBTCompound()
+	for i := 0; i < len(pairs); i += 2 {
+		c.Set(pairs[i].(string), pairs[i+1])
+	}
+	return c
+}
+
+func testStructure(t *testing.T) []byte {
+	t.Helper()
+	root := nbtCompound(
+		"DataVersion", int32(3953),
+		"size", nbtList(mods.TagInt, int32(2), int32(1), int32(1)),
+		"palette", nbtList(mods.TagCompound,
+			nbtCompound("Name", "minecraft:oak_planks"),
+			nbtCompound("Name", "minecraft:oak_stairs", "Properties", nbtCompound("facing", "north")),
+		),
+		"blocks", nbtList(mods.TagCompound,
+			nbtCompound("pos", nbtList(mods.TagInt, int32(0), int32(0), int32(0)), "state", int32(0)),
+			nbtCompound("pos", nbtList(mods.TagInt, int32(1), int32(0), int32(0)), "state", int32(1)),
+		),
+		"entities", nbtList(mods.TagEnd),
+	)
+	content, err := (&mods.NBTFile{Root: root, Compression: mods.NBTGzip}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestNBTRoundTrip(t *testing.T) {
+	root := nbtCompound(
+		"byte", int8(-3), "short", int16(300), "int", int32(-70000), "long", int64(1)<<40,
+		"float", float32(0.5), "double", 1e100,
+		"bytes", []int8{1, -1}, "ints", []int32{7, 8}, "longs", []int64{-9},
+		"text", "café \U0001F600 nul\x00 \"quoted\"\nline",
+		"empty", nbtList(mods.TagString),
+		"nested", nbtList(mods.TagList, nbtList(mods.TagByte, int8(1)), nbtList(mods.TagDouble, 2.5)),
+		"weird key!", nbtCompound(),
+	)
+	for _, compression := range []string{mods.NBTGzip, mods.NBTZlib, mods.NBTUncompressed} {
+		content, err := (&mods.NBTFile{Name: "root", Root: root, Compression: compression}).Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := mods.ReadNBT(content)
+		if err != nil {
+			t.Fatalf("%s: %v", compression, err)
+		}
+		if file.Name != "root" || file.Compression != compression || !reflect.DeepEqual(file.Root, root) {
+			t.Errorf("%s round trip = %+v", compression, file)
+		}
+	}
+
+	snbt := mods.FormatSNBT(root)
+	parsed, err := mods.ParseSNBT(snbt)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, snbt)
+	}
+	// SNBT cannot record the item type of an empty list
+	parsed.(*mods.NBTCompound).Set("empty", nbtList(mods.TagString))
+	if !reflect.DeepEqual(parsed, root) {
+		t.Errorf("SNBT round trip:\n%s\n%s", snbt, mods.FormatSNBT(parsed))
+	}
+	if _, err := mods.ParseSNBT(`{a: [1, 2b]}`); err == nil || !strings.Contains(err.Error(), "list mixes int and byte") {
+		t.Errorf("mixed list error = %v", err)
+	}
+
+	json, err := mods.NBTToJSON(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON, err := mods.NBTFromJSON(json, root)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, json)
+	}
+	if !reflect.DeepEqual(fromJSON, root) {
+		t.Errorf("JSON round trip:\n%s\n%s", json, mods.FormatSNBT(fromJSON))
+	}
+}
+
+func TestNBTStructureEdit(t *testing.T) {
+	content := testStructure(t)
+	format, err := mods.FormatFor("house.nbt", content)
+	if err != nil || format.Info().Name != "nbt" {
+		t.Fatalf("FormatFor = %v, %v; want nbt", format, err)
+	}
+	if best := mods.Detect("house.nbt", content).Best(); best.GameType != mods.GameTypeMinecraft {
+		t.Errorf("detected %s, want minecraft", best.GameType)
+	}
+
+	snbt, err := mods.EditableContent(format, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unchanged, err := format.Rewrite(content, snbt); err != nil || string(unchanged) != string(content) {
+		t.Errorf("unedited SNBT should return the original: %v", err)
+	}
+	swapped := strings.ReplaceAll(string(snbt), "oak", "spruce")
+	swapped = strings.Replace(swapped, "DataVersion: 3953", "DataVersion: 3953s", 1) // conformed back to an int
+	output, err := format.Rewrite(content, []byte(swapped))
+	if err != nil {
+		t.Fatalf("%v\n%s", err, swapped)
+	}
+	metadata, err := format.ExtractMetadata(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	structure := metadata["structure"].(*mods.NBTStructure)
+	if structure.DataVersion != 3953 || structure.BlockCounts["minecraft:spruce_stairs"] != 1 || structure.Blocks != 2 {
+		t.Errorf("structure = %+v", structure)
+	}
+	if _, err := format.Rewrite(content, []byte(strings.Replace(string(snbt), "state: 1", "state: 5", 1))); err == nil {
+		t.Error("a block state outside the palette should be rejected")
+	}
+	oversized := strings.Replace(string(snbt), "size: [2, 1, 1]", "size: [1L, 5000000000L, 1L]", 1)
+	if _, err := format.Rewrite(content, []byte(oversized)); err == nil || !strings.Contains(err.Error(), "/size mixes int and long items") {
+		t.Errorf("a size that does not fit an int should be rejected, got %v", err)
+	}
+
+	diff, err := mods.DiffFiles("house.nbt", content, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Kind != mods.DiffNBT || len(diff.Changes) != 2 || diff.Changes[0].Path != "/palette/0/Name" || diff.Changes[0].New != `"minecraft:spruce_planks"` {
+		t.Errorf("diff = %+v", diff)
+	}
+
+	pack := buildZip(t, [2]string{"data/demo/structure/house.nbt", string(content)})
+	archive, err := mods.OpenArchive(pack, mods.DefaultArchiveLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if structures, err := mods.ExtractStructures(archive); err != nil || structures["data/demo/structure/house.nbt"].BlockCounts["minecraft:oak_planks"] != 1 {
+		t.Errorf("structures = %v, %v", structures, err)
+	}
+}
+
+func TestNBTReviewSelection(t *testing.T) {
+	content := testStructure(t)
+	format, _ := mods.FormatFor("house.nbt", content)
+	snbt, err := mods.EditableContent(format, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := format.Rewrite(content, []byte(strings.ReplaceAll(string(snbt), "oak", "spruce")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := mods.DiffFiles("house.nbt", content, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := diff.ChangeIDs()
+	if !reflect.DeepEqual(ids, []string{"#/palette/0/Name", "#/palette/1/Name"}) {
+		t.Fatalf("ids = %v", ids)
+	}
+
+	partial, err := diff.Apply(content, output, map[string]bool{ids[1]: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := format.ExtractMetadata(partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := metadata["structure"].(*mods.NBTStructure).BlockCounts
+	if counts["minecraft:oak_planks"] != 1 || counts["minecraft:spruce_stairs"] != 1 {
+		t.Errorf("block counts = %v", counts)
+	}
+
+	all, err := mods.BuildFromSelection("house.nbt", content, output, map[string]bool{ids[0]: true, ids[1]: true})
+	if err != nil || string(all) != string(output) {
+		t.Errorf("accepting every change should give the processed file: %v", err)
+	}
+}
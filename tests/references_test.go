@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+func TestCheckReferencesFindsInventedIDs(t *testing.T) {
+	jar := buildZip(t,
+		[2]string{"fabric.mod.json", `{"id": "example", "depends": {"fabricloader": "*", "create": "*"}}`},
+		[2]string{"assets/example/lang/en_us.json", `{"item.example.ruby": "Ruby", "block.example.ruby_block": "Block of Ruby"}`},
+		[2]string{"assets/example/models/item/ruby.json", `{"parent": "item/generated", "textures": {"layer0": "example:item/ruby"}}`},
+		[2]string{"assets/example/models/block/ruby_block.json", `{"parent": "minecraft:block/cube_all", "textures": {"all": "example:block/ruby_blok"}}`},
+		[2]string{"assets/example/textures/item/ruby.png", "png"},
+		[2]string{"data/example/recipes/ruby_block.json", `{"type": "minecraft:crafting_shaped", "pattern": ["###"], "key": {"#": {"item": "example:ruby"}}, "result": {"item": "example:ruby_block"}}`},
+		[2]string{"data/example/recipes/sword.json", `{"type": "minecraft:crafting_shapeless", "ingredients": [{"item": "minecraft:netherite_ingot"}, {"tag": "minecraft:planks"}, {"item": "minecraft:ruby"}, {"item": "create:brass_ingot"}], "result": {"item": "example:sapphire"}}`},
+		[2]string{"data/example/loot_tables/blocks/ruby_block.json", `{"pools": [{"entries": [{"type": "minecraft:item", "name": "example:ruby_block"}, {"type": "minecraft:tag", "name": "c:gems"}]}]}`},
+		[2]string{"data/minecraft/tags/items/beacon_payment_items.json", `{"values": ["example:ruby", "#example:gems", {"id": "other:gem", "required": false}]}`},
+	)
+
+	report, err := mods.CheckReferences("example.jar", jar, "1.20.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Registry != "1.20" {
+		t.Errorf("checked against snapshot %s, want 1.20", report.Registry)
+	}
+
+	got := make(map[string]bool)
+	for _, ref := range report.Unresolved {
+		got[ref.Kind+" "+ref.ID] = true
+	}
+	want := []string{"texture example:block/ruby_blok", "item minecraft:ruby", "item example:sapphire", "item_tag example:gems"}
+	for _, w := range want {
+		if !got[w] {
+			t.Errorf("missing unresolved %q in %+v", w, report.Unresolved)
+		}
+	}
+	if len(report.Unresolved) != len(want) {
+		t.Errorf("unresolved = %+v, want %v", report.Unresolved, want)
+	}
+
+	// An edit is only blamed for references it introduces
+	edited := buildZip(t,
+		[2]string{"data/example/recipes/sword.json", `{"type": "minecraft:crafting_shapeless", "ingredients": [{"item": "minecraft:diamond"}, {"item": "minecraft:mithril_ingot"}], "result": "minecraft:diamond_sword"}`},
+	)
+	before, err := mods.CheckReferences("pack.zip", buildZip(t,
+		[2]string{"data/example/recipes/sword.json", `{"type": "minecraft:crafting_shapeless", "ingredients": [{"item": "minecraft:mithril_ingot"}], "result": "minecraft:diamond_sword"}`},
+	), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := mods.CheckReferences("pack.zip", edited, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added := after.NewUnresolved(before); len(added) != 0 {
+		t.Errorf("NewUnresolved = %+v, want none", added)
+	}
+}
+
+func TestCheckReferencesPicksSnapshotByVersion(t *testing.T) {
+	pack := buildZip(t,
+		[2]string{"data/example/recipes/grass.json", `{"type": "minecraft:crafting_shapeless", "ingredients": [{"item": "minecraft:short_grass"}, {"item": "minecraft:scute"}, {"item": "minecraft:turtle_scute"}], "result": {"item": "minecraft:mace"}}`},
+	)
+	for version, want := range map[string]struct {
+		registry   string
+		unresolved []string
+	}{
+		"1.20.1": {"1.20", []string{"minecraft:short_grass", "minecraft:turtle_scute", "minecraft:mace"}},
+		"1.20.4": {"1.20.3", []string{"minecraft:turtle_scute", "minecraft:mace"}},
+		"1.20.6": {"1.20.5", []string{"minecraft:scute", "minecraft:mace"}},
+		"1.21.1": {"1.21", []string{"minecraft:scute"}},
+		"1.21.4": {"", nil},
+	} {
+		report, err := mods.CheckReferences("pack.zip", pack, version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var unresolved []string
+		for _, ref := range report.Unresolved {
+			unresolved = append(unresolved, ref.ID)
+		}
+		if report.Registry != want.registry || !reflect.DeepEqual(unresolved, want.unresolved) {
+			t.Errorf("%s: snapshot %s, unresolved %v; want %s, %v", version, report.Registry, unresolved, want.registry, want.unresolved)
+		}
+	}
+}
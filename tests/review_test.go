@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+func TestApplyKeepsOnlyAcceptedJSONChanges(t *testing.T) {
+	original := "{\n  \"type\": \"minecraft:crafting_shaped\",\n  \"key\": {\"#\": \"minecraft:diamond\"},\n  \"result\": {\"count\": 1}\n}\n"
+	processed := "{\n  \"type\": \"minecraft:crafting_shaped\",\n  \"key\": {\"#\": \"minecraft:netherite_ingot\"},\n  \"result\": {\"count\": 2}\n}\n"
+
+	diff, err := mods.DiffFiles("recipe.json", []byte(original), []byte(processed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := diff.ChangeIDs(); len(ids) != 2 || ids[0] != "#/key/#" || ids[1] != "#/result/count" {
+		t.Fatalf("change IDs = %v", ids)
+	}
+
+	output, err := diff.Apply([]byte(original), []byte(processed), map[string]bool{"#/result/count": true, "#/key/#": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"type\": \"minecraft:crafting_shaped\",\n  \"key\": {\n    \"#\": \"minecraft:diamond\"\n  },\n  \"result\": {\n    \"count\": 2\n  }\n}\n"
+	if string(output) != want {
+		t.Errorf("output:\n%s\nwant:\n%s", output, want)
+	}
+}
+
+func TestApplySelectsArchiveEntriesAndHunks(t *testing.T) {
+	script := "local a = 1\nlocal b = 2\nlocal c = 3\nlocal d = 4\nlocal e = 5\nlocal f = 6\nlocal g = 7\nlocal h = 8\nlocal i = 9\n"
+	edited := "local a = 10\nlocal b = 2\nlocal c = 3\nlocal d = 4\nlocal e = 5\nlocal f = 6\nlocal g = 7\nlocal h = 8\nlocal i = 90\n"
+	original := buildZip(t,
+		[2]string{"scripts/init.lua", script},
+		[2]string{"README.txt", "readme\n"},
+	)
+	processed := buildZip(t,
+		[2]string{"scripts/init.lua", edited},
+		[2]string{"CHANGES.txt", "changes\n"},
+	)
+
+	accepted := map[string]bool{
+		"scripts/init.lua#hunk-1": true,
+		"README.txt#file":         false,
+		"CHANGES.txt#file":        true,
+	}
+	output, err := mods.BuildFromSelection("example.zip", original, processed, accepted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := mods.OpenArchive(output, mods.DefaultArchiveLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func(name string) string {
+		data, err := archive.ReadFileNamed(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return string(data)
+	}
+	if got, want := read("scripts/init.lua"), "local a = 1\nlocal b = 2\nlocal c = 3\nlocal d = 4\nlocal e = 5\nlocal f = 6\nlocal g = 7\nlocal h = 8\nlocal i = 90\n"; got != want {
+		t.Errorf("init.lua = %q", got)
+	}
+	if read("README.txt") != "readme\n" || read("CHANGES.txt") != "changes\n" {
+		t.Error("rejected removal or accepted addition not applied")
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+func TestRulesEditJSONAndConfig(t *testing.T) {
+	rules, err := mods.ParseRules([]byte(`[
+		{"path": "**.durability", "op": "multiply", "value": 1.5},
+		{"path": "result.count", "op": "add", "value": 1},
+		{"path": "spawners.*[*].weight", "op": "min", "value": 20},
+		{"path": "name", "op": "set", "value": "Sturdy"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := "{\n  \"name\": \"Plain\",\n  \"tools\": [\n    {\n      \"durability\": 251\n    },\n    {\n      \"durability\": 0.3\n    }\n  ],\n  \"result\": {\n    \"count\": 3\n  },\n  \"spawners\": {\n    \"monster\": [\n      {\n        \"weight\": 100\n      }\n    ]\n  }\n}\n"
+	output, changes, err := mods.ApplyRules("item.json", []byte(original), rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.NewReplacer("\"Plain\"", "\"Sturdy\"", "251", "377", "0.3", "0.45", "3\n", "4\n", "100", "20").Replace(original)
+	if string(output) != want {
+		t.Errorf("JSON output:\n%s\nwant\n%s", output, want)
+	}
+	var notes []string
+	for _, c := range changes {
+		notes = append(notes, c.String())
+	}
+	if got := strings.Join(notes, "\n"); got != "/tools/0/durability: 251 -> 377\n/tools/1/durability: 0.3 -> 0.45\n/result/count: 3 -> 4\n/spawners/monster/0/weight: 100 -> 20\n/name: \"Plain\" -> \"Sturdy\"" {
+		t.Errorf("JSON changes:\n%s", got)
+	}
+
+	config := "# Tools\nname = 'Plain'\n[pickaxe]\ndurability = 2.0 # base\nenabled = true\n"
+	output, changes, err = mods.ApplyRules("tools.toml", []byte(config), rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# Tools\nname = 'Sturdy'\n[pickaxe]\ndurability = 3.0 # base\nenabled = true\n"; string(output) != want || len(changes) != 2 {
+		t.Errorf("TOML output:\n%s\nchanges %v", output, changes)
+	}
+	if unchanged, changes, err := mods.ApplyRules("other.yml", []byte("speed: 4\n"), rules); err != nil || string(unchanged) != "speed: 4\n" || changes != nil {
+		t.Errorf("unmatched config = %q, %v, %v", unchanged, changes, err)
+	}
+
+	for rule, want := range map[string]string{
+		`{"path": "a..b", "op": "set", "value": 1}`:               `rule 1: path "a..b" has an empty key`,
+		`{"path": "a[x]", "op": "set", "value": 1}`:               `rule 1: path "a[x]" has an invalid index [x]`,
+		`{"path": "a", "op": "divide", "value": 2}`:               `rule 1: unknown op "divide"; use set, multiply, add, min or max`,
+		`{"path": "a", "op": "multiply", "value": "twice"}`:       "rule 1: multiply needs a number value",
+		`{"path": "a", "op": "set", "value": 1, "default": 1}`:    "rule 1: default needs a number and a multiply, add, min or max op",
+		`{"path": "a[*]", "op": "add", "value": 1, "default": 1}`: "rule 1: default needs a path ending in a key",
+		`{"path": "**.a", "op": "add", "value": 1, "default": 1}`: "rule 1: default cannot be used with ** in the path",
+	} {
+		if _, err := mods.ParseRules([]byte("[" + rule + "]")); err == nil || err.Error() != want {
+			t.Errorf("%s: error %v, want %s", rule, err, want)
+		}
+	}
+}
+
+func TestRulesEditArchiveEntries(t *testing.T) {
+	pack := buildZip(t,
+		[2]string{"pack.mcmeta", `{"pack": {"pack_format": 48, "description": "Recipes"}}`},
+		[2]string{"data/demo/recipe/planks.json", `{"type": "minecraft:crafting_shapeless", "result": {"id": "minecraft:oak_planks", "count": 4}}`},
+		[2]string{"data/demo/loot_table/chest.json", `{"result": {"count": 4}}`},
+		[2]string{"config/demo.properties", "planks=4\n"},
+	)
+	rules := []mods.Rule{
+		{Files: "data/*/recipe/*.json", Path: "result.count", Op: mods.RuleMultiply, Value: 2.0},
+		{Files: "config/*", Path: "planks", Op: mods.RuleSet, Value: 6.0},
+	}
+	output, changes, err := mods.ApplyRules("recipes.zip", pack, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].String() != "data/demo/recipe/planks.json: /result/count: 4 -> 8" || changes[1].String() != "config/demo.properties: planks: 4 -> 6" {
+		t.Errorf("changes = %v", changes)
+	}
+	archive, err := mods.OpenArchive(output, mods.DefaultArchiveLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loot, _ := archive.ReadFileNamed("data/demo/loot_table/chest.json"); string(loot) != `{"result": {"count": 4}}` {
+		t.Errorf("entries outside files should be unchanged, got %s", loot)
+	}
+
+	again, _, err := mods.ApplyRules("recipes.zip", pack, rules)
+	if err != nil || string(again) != string(output) {
+		t.Error("applying the same rules twice should give the same archive")
+	}
+}
+
+func TestRulesInsertDefaults(t *testing.T) {
+	pack := buildZip(t,
+		[2]string{"pack.mcmeta", `{"pack": {"pack_format": 48, "description": "Recipes"}}`},
+		[2]string{"data/demo/recipe/planks.json", `{"result": {"id": "minecraft:oak_planks", "count": 4}}`},
+		[2]string{"data/demo/recipe/tools/axe.json", "{\n  \"result\": {\n    \"id\": \"minecraft:stone_axe\"\n  }\n}\n"},
+		[2]string{"data/demo/recipe/smelt.json", `{"result": "minecraft:glass"}`},
+	)
+	rules := []mods.Rule{{Files: "data/*/recipe/**/*.json", Path: "result.count", Op: mods.RuleMultiply, Value: 2.0, Default: 1.0}}
+	output, changes, err := mods.ApplyRules("recipes.zip", pack, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notes []string
+	for _, c := range changes {
+		notes = append(notes, c.String())
+	}
+	if got := strings.Join(notes, "\n"); got != "data/demo/recipe/planks.json: /result/count: 4 -> 8\ndata/demo/recipe/tools/axe.json: /result/count: 1 (default) -> 2" {
+		t.Errorf("changes:\n%s", got)
+	}
+	archive, err := mods.OpenArchive(output, mods.DefaultArchiveLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if axe, _ := archive.ReadFileNamed("data/demo/recipe/tools/axe.json"); string(axe) != "{\n  \"result\": {\n    \"id\": \"minecraft:stone_axe\",\n    \"count\": 2\n  }\n}\n" {
+		t.Errorf("axe.json:\n%s", axe)
+	}
+}
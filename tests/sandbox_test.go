@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"modforge.ai/mods"
+)
+
+func TestCompareLuaLoadReportsBrokenEdits(t *testing.T) {
+	original := []byte(`
+local M = {}
+MyAddon = MyAddon or {}
+hook.Add("Think", "myaddon_think", function() end)
+data:extend({ { type = "item", name = "gem" } })
+function M.init() end
+return M
+`)
+	edited := []byte(`
+local M = {}
+hook.Add("Think", "myaddon_think", function() end)
+local cfg = nil
+local speed = cfg.speed
+return M
+`)
+
+	report := mods.CompareLuaLoad(original, edited, mods.DefaultLuaLimits)
+	if report.Original.Error != "" {
+		t.Fatalf("original failed to load: %s", report.Original.Error)
+	}
+	if want := []string{"MyAddon"}; !reflect.DeepEqual(report.Original.Globals, want) {
+		t.Errorf("globals = %v, want %v", report.Original.Globals, want)
+	}
+	if want := []string{"init"}; !reflect.DeepEqual(report.Original.Exports, want) {
+		t.Errorf("exports = %v, want %v", report.Original.Exports, want)
+	}
+	if want := []string{`data.extend()`, `hook.Add("Think", "myaddon_think")`}; !reflect.DeepEqual(report.Original.Calls, want) {
+		t.Errorf("calls = %v, want %v", report.Original.Calls, want)
+	}
+
+	if report.Edited.Error == "" {
+		t.Fatal("edited script indexes nil but loaded without error")
+	}
+	if want := []string{`data.extend()`}; !reflect.DeepEqual(report.RemovedCalls, want) {
+		t.Errorf("removed calls = %v, want %v", report.RemovedCalls, want)
+	}
+	if len(report.Problems()) != 4 {
+		t.Errorf("problems = %v", report.Problems())
+	}
+}
+
+func TestLoadLuaEnforcesLimits(t *testing.T) {
+	cases := map[string]string{
+		"while true do end":                "instruction limit",
+		`local s = string.rep("x", 1e9)`:   "string.rep",
+		`local f = io.open("/etc/passwd")`: "",
+	}
+	for script, want := range cases {
+		result := mods.LoadLua([]byte(script), mods.DefaultLuaLimits)
+		if want == "" {
+			// io is not available, so the call only reaches a stub
+			if result.Error != "" || !reflect.DeepEqual(result.Calls, []string{`io.open("/etc/passwd")`}) {
+				t.Errorf("%s: error %q, calls %v", script, result.Error, result.Calls)
+			}
+			continue
+		}
+		if !strings.Contains(result.Error, want) {
+			t.Errorf("%s: error %q, want %q", script, result.Error, want)
+		}
+	}
+}
+
+func TestLoadLuaBoundsStringMemory(t *testing.T) {
+	limits := mods.DefaultLuaLimits
+	limits.Memory = 8 * 1024 * 1024
+	limits.StringLength = 64 * 1024 * 1024
+	cases := map[string]string{
+		"local s = 'xxxxxxxx' for i = 1, 24 do s = s .. s end":                                           "memory limit exceeded",
+		"local t = {} for i = 1, 100 do t[i] = string.rep('x', 1e5) end":                                 "memory limit exceeded",
+		"local s = string.rep('xx', 2^62)":                                                               "string.rep result longer than",
+		"local s = string.format('%99999999d', 1)":                                                       "string.format result longer than",
+		"local t = {} for i = 1, 1000 do t[i] = 'x' end local s = table.concat(t, string.rep('y', 1e5))": "table.concat result longer than",
+		`local s = ("x"):rep(20000):gsub(".", ("y"):rep(5000))`:                                          "string.gsub result longer than",
+		"local big = ('y'):rep(5000) local s = ('x'):rep(20000):gsub('.', function() return big end)":    "memory limit exceeded",
+		"local t = {} for i = 1, 2e6 do t[i] = {} end":                                                   "memory limit exceeded",
+	}
+	for script, want := range cases {
+		if result := mods.LoadLua([]byte(script), limits); !strings.Contains(result.Error, want) {
+			t.Errorf("%s: error %q, want %q", script, result.Error, want)
+		}
+	}
+
+	result := mods.LoadLua([]byte("Name = 'gem' .. 1 .. hook.name local v = {} .. 'x'"), limits)
+	if !strings.Contains(result.Error, "attempt to concatenate a table value") {
+		t.Errorf("concatenating a table: error %q", result.Error)
+	}
+	if result := mods.LoadLua([]byte("Name = 'gem' .. 1 .. hook.name"), limits); result.Error != "" || !reflect.DeepEqual(result.Globals, []string{"Name"}) {
+		t.Errorf("ordinary concatenation: %+v", result)
+	}
+	script := "Name, Count = ('a b c'):gsub(' ', '_%0') Upper = ('hello world'):gsub('(o)', {o = '0'}):upper() Tools = {1, 2, x = 3}"
+	if result := mods.LoadLua([]byte(script), limits); result.Error != "" || len(result.Globals) != 4 {
+		t.Errorf("ordinary gsub and tables: %+v", result)
+	}
+
+	// A library call that never yields to the VM is still cut off
+	limits.Timeout = 200 * time.Millisecond
+	start := time.Now()
+	result = mods.LoadLua([]byte(`local s = string.find(("a"):rep(30), ("a-"):rep(30) .. "b")`), limits)
+	if result.Error != "time limit exceeded" || time.Since(start) > time.Second {
+		t.Errorf("slow pattern: error %q after %v", result.Error, time.Since(start))
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"modforge.ai/mods"
+	"modforge.ai/scanner"
+)
+
+// stubScanner reports fixed findings or an error
+type stubScanner struct {
+	name     string
+	findings []mods.Finding
+	err      error
+}
+
+func (s stubScanner) Name() string { return s.name }
+
+func (s stubScanner) Scan(ctx context.Context, filename string, content []byte) ([]mods.Finding, error) {
+	return s.findings, s.err
+}
+
+func TestScanAsyncCombinesScanners(t *testing.T) {
+	service := scanner.NewService(
+		stubScanner{name: "flagging", findings: []mods.Finding{{Rule: "engine_detection", Severity: mods.SeverityHigh}}},
+		stubScanner{name: "broken", err: errors.New("unavailable")},
+	)
+
+	results := make(chan *scanner.Result)
+	service.ScanAsync("example.jar", []byte("PK"), func(r *scanner.Result) { results <- r })
+	result := <-results
+
+	if !result.Quarantined {
+		t.Error("high severity finding should quarantine")
+	}
+	if len(result.Findings) != 1 || result.Findings[0].Scanner != "flagging" {
+		t.Errorf("findings = %+v", result.Findings)
+	}
+	if len(result.Errors) != 1 || result.Incomplete {
+		t.Errorf("errors = %v, incomplete = %v", result.Errors, result.Incomplete)
+	}
+}
+
+func TestScanIncompleteWhenScannersFail(t *testing.T) {
+	broken := stubScanner{name: "broken", err: errors.New("unavailable")}
+	if result := scanner.NewService(broken, broken).Scan(context.Background(), "example.jar", []byte("PK")); !result.Incomplete || result.Quarantined {
+		t.Errorf("every scanner failing should leave the scan incomplete: %+v", result)
+	}
+
+	// A truncated jar the heuristics cannot open
+	service := scanner.NewService(scanner.Heuristic{}, stubScanner{name: "clean"})
+	if result := service.Scan(context.Background(), "example.jar", []byte("PK\x03\x04 truncated")); !result.Incomplete {
+		t.Errorf("a heuristic failure should leave the scan incomplete: %+v", result)
+	}
+}
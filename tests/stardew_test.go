@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"modforge.ai/mods"
+)
+
+func TestStardewContentPack(t *testing.T) {
+	content := buildZip(t,
+		[2]string{"[CP] Fruit/manifest.json", `{
+  // SMAPI allows comments and trailing commas
+  "Name": "Fruit", "Author": "Sam", "Version": "1.0.0", "UniqueID": "Sam.Fruit",
+  "MinimumApiVersion": "4.0.0",
+  "ContentPackFor": {"UniqueID": "Pathoschild.ContentPatcher"},
+  "Dependencies": [{"UniqueID": "spacechase0.JsonAssets", "IsRequired": false}, {"UniqueID": "Sam.Core"},],
+}`},
+		[2]string{"[CP] Fruit/content.json", `{
+  "Format": "2.0.0",
+  "ConfigSchema": {"Price": {"AllowValues": "100, 200", "Default": "100"}},
+  "Changes": [
+    {"Action": "EditData", "Target": "Data/Objects", "Entries": {"{{ModId}}_Mango": {"Name": "{{i18n:mango.name}}", "Price": "{{Price}}"}}, "When": {"Season": "summer"}},
+    {"Action": "Include", "FromFile": "assets/shops.json"}
+  ]
+}`},
+		[2]string{"[CP] Fruit/assets/shops.json", `{"Changes": [{"Action": "EditData", "Target": "Data/Shops", "Entries": {"x": "{{i18n:shop.missing}} {{Weathr}}"}}]}`},
+		[2]string{"[CP] Fruit/i18n/default.json", `{"mango.name": "Mango", "mango.gift": "Thanks, {{name}}!"}`},
+		[2]string{"[CP] Fruit/i18n/de.json", "{\n  // German\n  \"mango.name\": \"Mango\"\n}\n"},
+	)
+
+	format, err := mods.FormatFor("fruit.zip", content)
+	if err != nil || format.Info().Name != "stardew" {
+		t.Fatalf("FormatFor = %v, %v; want stardew", format, err)
+	}
+	if _, err := mods.ParseStardewMods(buildZip(t,
+		[2]string{"manifest.json", `{"Name": "X", "Author": "Y", "Version": "1.0.0", "UniqueID": "Y.X", "ContentPackFor": {"UniqueID": "Pathoschild.ContentPatcher"}}`},
+	)); err == nil || !strings.Contains(err.Error(), "no content.json") {
+		t.Errorf("pack without content.json: %v", err)
+	}
+	if best := mods.Detect("fruit.zip", content).Best(); best.GameType != mods.GameTypeStardew {
+		t.Errorf("detected %s, want stardew", best.GameType)
+	}
+	list, err := mods.ParseStardewMods(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mod := list[0]
+	if deps := mod.Manifest.Dependencies; len(deps) != 2 || deps[0].IsRequired || !deps[1].IsRequired {
+		t.Errorf("dependencies = %+v", deps)
+	}
+	if mod.ContentPatch.Changes != 3 || len(mod.Errors()) != 0 {
+		t.Errorf("content patch = %+v, issues %+v", mod.ContentPatch, mod.Issues)
+	}
+	var warnings []string
+	for _, issue := range mod.Issues {
+		warnings = append(warnings, issue.Message)
+	}
+	if got := strings.Join(warnings, "; "); got != `i18n key "shop.missing" is not in i18n/default.json; unknown token "weathr"` {
+		t.Errorf("warnings = %s", got)
+	}
+
+	// Translations keep tokens and are inserted into the existing file
+	output, report, err := mods.TranslateStardewMods(content, []string{"de"}, func(locale string, batch map[string]string) (map[string]string, error) {
+		return map[string]string{"mango.gift": "Danke, {{name}}!"}, nil
+	})
+	if err != nil || report.Translated != 1 {
+		t.Fatalf("TranslateStardewMods = %+v, %v", report, err)
+	}
+	if got := readZipEntry(t, output, "[CP] Fruit/i18n/de.json"); got != "{\n  // German\n  \"mango.name\": \"Mango\",\n  \"mango.gift\": \"Danke, {{name}}!\"\n}\n" {
+		t.Errorf("de.json =\n%s", got)
+	}
+
+	// An edit may not introduce tokens the original did not use
+	patch := []byte(`{"Format": "2.0.0", "Changes": [{"Action": "EditData", "Target": "Data/Crops", "Fields": {"1": {"0": "1 1 1 1"}}}]}`)
+	cp, err := mods.FormatFor("content.json", patch)
+	if err != nil || cp.Info().Name != "content_patcher" {
+		t.Fatalf("FormatFor = %v, %v; want content_patcher", cp, err)
+	}
+	if _, err := cp.Rewrite(patch, []byte(`{"Format": "2.0.0", "Changes": [{"Action": "EditData", "Target": "Data/Crops", "Fields": {"1": {"0": "{{Seasn}}"}}}]}`)); err == nil {
+		t.Error("Rewrite accepted an unknown token")
+	}
+	if _, err := cp.Rewrite(patch, []byte(`{"Format": "2.0.0", "Changes": [{"Action": "EditData", "Target": "Data/Crops"}]}`)); err == nil {
+		t.Error("Rewrite accepted an EditData patch without edits")
+	}
+	if _, err := cp.Rewrite(patch, []byte(`{"Format": "2.0.0", "Changes": [{"Action": "EditData", "Target": "Data/Crops", "Fields": {"1": {"0": "2 2 2 2"}}}]}`)); err != nil {
+		t.Errorf("Rewrite rejected a valid edit: %v", err)
+	}
+}